@@ -0,0 +1,242 @@
+// path: accounts/accounts.go
+// Package accounts implements optional reporter accounts: phone numbers
+// verified with a one-time SMS code, and long-lived device sessions.
+package accounts
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"meniba/database"
+	"meniba/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Collection names used by accounts.
+const (
+	ReportersCol = "reporters"
+	OTPCol       = "otp_challenges"
+	SessionsCol  = "reporter_sessions"
+)
+
+var (
+	ErrInvalidPhone = errors.New("invalid phone number")
+	ErrTooMany      = errors.New("too many codes requested; try again later")
+	ErrInvalidCode  = errors.New("invalid or expired code")
+	ErrUnauthorized = errors.New("invalid or expired session")
+)
+
+// Service issues codes and sessions.
+type Service struct {
+	Store       Store
+	SMS         Sender
+	CountryCode string        // assumed for numbers written with a leading 0
+	CodeTTL     time.Duration // how long a code can be used
+	MaxAttempts int           // wrong guesses allowed per code
+	Resend      time.Duration // minimum gap between codes to one phone
+	HourlyMax   int           // codes per phone per hour
+	SessionTTL  time.Duration
+}
+
+// New returns a service with production defaults. PHONE_COUNTRY_CODE sets
+// the default country (232, Sierra Leone).
+func New(db *database.DB, sms Sender) *Service {
+	cc := strings.TrimPrefix(strings.TrimSpace(os.Getenv("PHONE_COUNTRY_CODE")), "+")
+	if cc == "" {
+		cc = "232"
+	}
+	return &Service{
+		Store:       NewMongoStore(db),
+		SMS:         sms,
+		CountryCode: cc,
+		CodeTTL:     10 * time.Minute,
+		MaxAttempts: 5,
+		Resend:      time.Minute,
+		HourlyMax:   5,
+		SessionTTL:  90 * 24 * time.Hour,
+	}
+}
+
+// StartLogin sends a fresh code to phone and returns the normalised number.
+func (s *Service) StartLogin(ctx context.Context, phone string) (string, error) {
+	phone, err := NormalizePhone(phone, s.CountryCode)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now().UTC()
+
+	// challenges live for an hour (TTL index), so they double as the rate log
+	n, err := s.Store.CountChallenges(ctx, phone, now.Add(-time.Hour))
+	if err != nil {
+		return "", err
+	}
+	if n >= int64(s.HourlyMax) {
+		return "", ErrTooMany
+	}
+	recent, err := s.Store.CountChallenges(ctx, phone, now.Add(-s.Resend))
+	if err != nil {
+		return "", err
+	}
+	if recent > 0 {
+		return "", ErrTooMany
+	}
+
+	code, err := newCode()
+	if err != nil {
+		return "", err
+	}
+	ch := models.OTPChallenge{
+		ID:        primitive.NewObjectID(),
+		Phone:     phone,
+		ExpiresAt: now.Add(s.CodeTTL),
+		CreatedAt: now,
+	}
+	ch.CodeHash = codeHash(ch.ID, code)
+	if err := s.Store.InsertChallenge(ctx, ch); err != nil {
+		return "", err
+	}
+	text := fmt.Sprintf("Your Mineba code is %s. It expires in %d minutes.", code, int(s.CodeTTL.Minutes()))
+	if err := s.SMS.SendSMS(ctx, phone, text); err != nil {
+		return "", fmt.Errorf("accounts: send code: %w", err)
+	}
+	return phone, nil
+}
+
+// VerifyLogin checks code against the latest live code for phone. On
+// success it creates the account if needed and returns a session token.
+func (s *Service) VerifyLogin(ctx context.Context, phone, code string) (string, models.Reporter, error) {
+	var rep models.Reporter
+	phone, err := NormalizePhone(phone, s.CountryCode)
+	if err != nil {
+		return "", rep, err
+	}
+	now := time.Now().UTC()
+
+	// why: count the guess before comparing, atomically, so parallel
+	// guesses can't exceed MaxAttempts
+	ch, err := s.Store.ClaimChallenge(ctx, phone, now, s.MaxAttempts)
+	if errors.Is(err, errNotFound) {
+		return "", rep, ErrInvalidCode
+	}
+	if err != nil {
+		return "", rep, err
+	}
+	if subtle.ConstantTimeCompare([]byte(codeHash(ch.ID, strings.TrimSpace(code))), []byte(ch.CodeHash)) != 1 {
+		return "", rep, ErrInvalidCode
+	}
+	// used: expire every outstanding code for the phone, keep the rate log
+	if err := s.Store.ExpireChallenges(ctx, phone, now); err != nil {
+		return "", rep, err
+	}
+
+	rep, err = s.Store.VerifyReporter(ctx, phone, now)
+	if err != nil {
+		return "", rep, err
+	}
+
+	token := randomToken()
+	sess := models.ReporterSession{
+		ReporterID: rep.ID,
+		TokenHash:  hashToken(token),
+		ExpiresAt:  now.Add(s.SessionTTL),
+		CreatedAt:  now,
+	}
+	if err := s.Store.InsertSession(ctx, sess); err != nil {
+		return "", rep, err
+	}
+	return token, rep, nil
+}
+
+// Authenticate returns the reporter signed in with token.
+func (s *Service) Authenticate(ctx context.Context, token string) (models.Reporter, error) {
+	var rep models.Reporter
+	if token == "" {
+		return rep, ErrUnauthorized
+	}
+	sess, err := s.Store.FindSession(ctx, hashToken(token), time.Now().UTC())
+	if errors.Is(err, errNotFound) {
+		return rep, ErrUnauthorized
+	}
+	if err != nil {
+		return rep, err
+	}
+	rep, err = s.Store.GetReporter(ctx, sess.ReporterID)
+	if errors.Is(err, errNotFound) {
+		return rep, ErrUnauthorized
+	}
+	return rep, err
+}
+
+// Logout ends the session behind token.
+func (s *Service) Logout(ctx context.Context, token string) error {
+	return s.Store.DeleteSession(ctx, hashToken(token))
+}
+
+// NormalizePhone returns phone in E.164 form. Local numbers (leading 0,
+// or too short to include a country code) get countryCode.
+func NormalizePhone(phone, countryCode string) (string, error) {
+	var b strings.Builder
+	for i, r := range strings.TrimSpace(phone) {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+			b.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return "", ErrInvalidPhone
+		}
+	}
+	p := b.String()
+	switch {
+	case strings.HasPrefix(p, "+"):
+	case strings.HasPrefix(p, "00"):
+		p = "+" + p[2:]
+	case strings.HasPrefix(p, "0"):
+		p = "+" + countryCode + p[1:]
+	case len(p) <= 9:
+		p = "+" + countryCode + p // local number without the 0
+	default:
+		p = "+" + p
+	}
+	// E.164: at most 15 digits; shorter than 8 is never a mobile number
+	if n := len(p) - 1; n < 8 || n > 15 || p[1] == '0' {
+		return "", ErrInvalidPhone
+	}
+	return p, nil
+}
+
+func newCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// codeHash binds the code to its challenge, so equal codes hash differently.
+func codeHash(id primitive.ObjectID, code string) string {
+	sum := sha256.Sum256([]byte(id.Hex() + ":" + code))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomToken() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
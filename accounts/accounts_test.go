@@ -0,0 +1,179 @@
+package accounts
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func newTestService() (*Service, *FakeSender) {
+	sms := &FakeSender{}
+	return &Service{
+		Store:       NewMemoryStore(),
+		SMS:         sms,
+		CountryCode: "232",
+		CodeTTL:     10 * time.Minute,
+		MaxAttempts: 3,
+		Resend:      time.Minute,
+		HourlyMax:   5,
+		SessionTTL:  time.Hour,
+	}, sms
+}
+
+var codeRE = regexp.MustCompile(`\b(\d{6})\b`)
+
+func lastCode(t *testing.T, sms *FakeSender, phone string) string {
+	t.Helper()
+	msg, ok := sms.Last(phone)
+	if !ok {
+		t.Fatalf("no SMS to %s", phone)
+	}
+	m := codeRE.FindStringSubmatch(msg.Text)
+	if m == nil {
+		t.Fatalf("no code in %q", msg.Text)
+	}
+	return m[1]
+}
+
+func wrong(code string) string {
+	if code == "000000" {
+		return "111111"
+	}
+	return "000000"
+}
+
+func TestLoginRoundTrip(t *testing.T) {
+	s, sms := newTestService()
+	ctx := context.Background()
+
+	phone, err := s.StartLogin(ctx, "076 123 456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if phone != "+23276123456" {
+		t.Fatalf("phone = %q", phone)
+	}
+	code := lastCode(t, sms, phone)
+
+	token, rep, err := s.VerifyLogin(ctx, "+232 76 123456", code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Phone != phone || token == "" {
+		t.Fatalf("rep = %+v, token = %q", rep, token)
+	}
+	got, err := s.Authenticate(ctx, token)
+	if err != nil || got.ID != rep.ID {
+		t.Fatalf("Authenticate = %+v, %v", got, err)
+	}
+
+	// codes are single-use
+	if _, _, err := s.VerifyLogin(ctx, phone, code); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("reused code: %v", err)
+	}
+
+	if err := s.Logout(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Authenticate(ctx, token); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("after logout: %v", err)
+	}
+}
+
+func TestLoginRateLimit(t *testing.T) {
+	s, _ := newTestService()
+	ctx := context.Background()
+
+	if _, err := s.StartLogin(ctx, "076123456"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.StartLogin(ctx, "076123456"); !errors.Is(err, ErrTooMany) {
+		t.Fatalf("resend within %v: %v", s.Resend, err)
+	}
+	if _, err := s.StartLogin(ctx, "077999888"); err != nil {
+		t.Fatalf("other phone limited too: %v", err)
+	}
+
+	s.Resend = 0
+	for i := 1; i < s.HourlyMax; i++ {
+		if _, err := s.StartLogin(ctx, "076123456"); err != nil {
+			t.Fatalf("code %d: %v", i+1, err)
+		}
+	}
+	if _, err := s.StartLogin(ctx, "076123456"); !errors.Is(err, ErrTooMany) {
+		t.Fatalf("code %d in an hour: %v", s.HourlyMax+1, err)
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	s, sms := newTestService()
+	ctx := context.Background()
+
+	phone, err := s.StartLogin(ctx, "076123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := lastCode(t, sms, phone)
+	for i := 0; i < s.MaxAttempts; i++ {
+		if _, _, err := s.VerifyLogin(ctx, phone, wrong(code)); !errors.Is(err, ErrInvalidCode) {
+			t.Fatalf("guess %d: %v", i+1, err)
+		}
+	}
+	// the right code no longer works once the guesses are used up
+	if _, _, err := s.VerifyLogin(ctx, phone, code); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("after lockout: %v", err)
+	}
+}
+
+func TestLoginNewCodeReplacesOld(t *testing.T) {
+	s, sms := newTestService()
+	s.Resend = 0
+	ctx := context.Background()
+
+	phone, _ := s.StartLogin(ctx, "076123456")
+	first := lastCode(t, sms, phone)
+	time.Sleep(time.Millisecond) // why: codes are ordered by creation time
+	if _, err := s.StartLogin(ctx, phone); err != nil {
+		t.Fatal(err)
+	}
+	second := lastCode(t, sms, phone)
+	if first != second {
+		if _, _, err := s.VerifyLogin(ctx, phone, first); !errors.Is(err, ErrInvalidCode) {
+			t.Fatalf("older code accepted: %v", err)
+		}
+	}
+	if _, _, err := s.VerifyLogin(ctx, phone, second); err != nil {
+		t.Fatalf("latest code: %v", err)
+	}
+}
+
+func TestNormalizePhone(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+		err      bool
+	}{
+		{"076123456", "+23276123456", false},
+		{"076 123-456", "+23276123456", false},
+		{"(076) 123.456", "+23276123456", false},
+		{"76123456", "+23276123456", false},
+		{"+23276123456", "+23276123456", false},
+		{"0023276123456", "+23276123456", false},
+		{"447700900123", "+447700900123", false},
+		{"+44 7700 900123", "+447700900123", false},
+		{"", "", true},
+		{"1234", "", true},
+		{"0762abc456", "", true},
+		{"+2327612345612345", "", true},
+		{"76+123456", "", true},
+	} {
+		got, err := NormalizePhone(tc.in, "232")
+		if (err != nil) != tc.err || got != tc.want {
+			t.Errorf("NormalizePhone(%q) = %q, %v; want %q (error %v)", tc.in, got, err, tc.want, tc.err)
+		}
+		if tc.err && !errors.Is(err, ErrInvalidPhone) {
+			t.Errorf("NormalizePhone(%q) error = %v, want ErrInvalidPhone", tc.in, err)
+		}
+	}
+}
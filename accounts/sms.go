@@ -0,0 +1,110 @@
+// path: accounts/sms.go
+package accounts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sender delivers one-time codes by SMS.
+type Sender interface {
+	SendSMS(ctx context.Context, to, text string) error
+}
+
+// SenderFromEnv returns an HTTPSender for SMS_GATEWAY_URL. Without a
+// gateway it returns nil, and accounts stay disabled: a LogSender puts
+// every code in the server log, so it is only used when SMS_LOG_CODES=1
+// is set explicitly for local development.
+func SenderFromEnv() Sender {
+	u := strings.TrimSpace(os.Getenv("SMS_GATEWAY_URL"))
+	if u == "" {
+		if dev, _ := strconv.ParseBool(os.Getenv("SMS_LOG_CODES")); dev {
+			return LogSender{}
+		}
+		return nil
+	}
+	return &HTTPSender{
+		URL:   u,
+		Token: strings.TrimSpace(os.Getenv("SMS_GATEWAY_TOKEN")),
+		HTTP:  &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// HTTPSender posts {"to": "...", "text": "..."} to a generic SMS gateway,
+// with "Authorization: Bearer {Token}" when set.
+type HTTPSender struct {
+	URL   string
+	Token string
+	HTTP  *http.Client
+}
+
+func (s *HTTPSender) SendSMS(ctx context.Context, to, text string) error {
+	body, _ := json.Marshal(map[string]string{"to": to, "text": text})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+	resp, err := s.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("accounts: sms gateway: %s", resp.Status)
+	}
+	return nil
+}
+
+// LogSender logs messages instead of sending them. Development only:
+// anyone who can read the log can sign in as any phone number.
+type LogSender struct{}
+
+func (LogSender) SendSMS(_ context.Context, to, text string) error {
+	log.Printf("accounts: (log only) sms to=%s text=%q", to, text)
+	return nil
+}
+
+// FakeSender records messages in memory, for tests.
+type FakeSender struct {
+	mu   sync.Mutex
+	Sent []SMS
+}
+
+// SMS is one message captured by FakeSender.
+type SMS struct {
+	To, Text string
+}
+
+func (f *FakeSender) SendSMS(_ context.Context, to, text string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Sent = append(f.Sent, SMS{To: to, Text: text})
+	return nil
+}
+
+// Last returns the latest message sent to to.
+func (f *FakeSender) Last(to string) (SMS, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := len(f.Sent) - 1; i >= 0; i-- {
+		if f.Sent[i].To == to {
+			return f.Sent[i], true
+		}
+	}
+	return SMS{}, false
+}
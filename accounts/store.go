@@ -0,0 +1,241 @@
+// path: accounts/store.go
+package accounts
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"meniba/database"
+	"meniba/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// errNotFound is returned by Store lookups that match nothing.
+var errNotFound = errors.New("accounts: not found")
+
+// Store persists codes, reporters and sessions. Service runs against
+// MongoDB in production and against MemoryStore in tests.
+type Store interface {
+	// CountChallenges counts the codes sent to phone after since.
+	CountChallenges(ctx context.Context, phone string, since time.Time) (int64, error)
+	InsertChallenge(ctx context.Context, ch models.OTPChallenge) error
+	// ClaimChallenge counts a guess against the newest code for phone that
+	// is unexpired at now and has fewer than maxAttempts guesses, and
+	// returns it; errNotFound if there is none. The increment must be
+	// atomic so parallel guesses can't exceed maxAttempts.
+	ClaimChallenge(ctx context.Context, phone string, now time.Time, maxAttempts int) (models.OTPChallenge, error)
+	// ExpireChallenges ends every outstanding code for phone as of now.
+	ExpireChallenges(ctx context.Context, phone string, now time.Time) error
+	// VerifyReporter marks phone verified at now, creating the reporter if
+	// needed.
+	VerifyReporter(ctx context.Context, phone string, now time.Time) (models.Reporter, error)
+	GetReporter(ctx context.Context, id primitive.ObjectID) (models.Reporter, error)
+	InsertSession(ctx context.Context, sess models.ReporterSession) error
+	// FindSession returns the session with tokenHash that is live at now.
+	FindSession(ctx context.Context, tokenHash string, now time.Time) (models.ReporterSession, error)
+	DeleteSession(ctx context.Context, tokenHash string) error
+}
+
+// MongoStore keeps accounts in ReportersCol, OTPCol and SessionsCol.
+type MongoStore struct {
+	DB *database.DB
+}
+
+// NewMongoStore returns a store over db.
+func NewMongoStore(db *database.DB) *MongoStore {
+	return &MongoStore{DB: db}
+}
+
+func (m *MongoStore) CountChallenges(ctx context.Context, phone string, since time.Time) (int64, error) {
+	return m.DB.Col(OTPCol).CountDocuments(ctx, bson.M{"phone": phone, "created_at": bson.M{"$gt": since}})
+}
+
+func (m *MongoStore) InsertChallenge(ctx context.Context, ch models.OTPChallenge) error {
+	_, err := m.DB.Col(OTPCol).InsertOne(ctx, ch)
+	return err
+}
+
+func (m *MongoStore) ClaimChallenge(ctx context.Context, phone string, now time.Time, maxAttempts int) (models.OTPChallenge, error) {
+	var ch models.OTPChallenge
+	err := m.DB.Col(OTPCol).FindOneAndUpdate(ctx,
+		bson.M{"phone": phone, "expires_at": bson.M{"$gt": now}, "attempts": bson.M{"$lt": maxAttempts}},
+		bson.M{"$inc": bson.M{"attempts": 1}},
+		options.FindOneAndUpdate().SetSort(bson.D{{Key: "created_at", Value: -1}}),
+	).Decode(&ch)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ch, errNotFound
+	}
+	return ch, err
+}
+
+func (m *MongoStore) ExpireChallenges(ctx context.Context, phone string, now time.Time) error {
+	_, err := m.DB.Col(OTPCol).UpdateMany(ctx, bson.M{"phone": phone}, bson.M{"$set": bson.M{"expires_at": now}})
+	return err
+}
+
+func (m *MongoStore) VerifyReporter(ctx context.Context, phone string, now time.Time) (models.Reporter, error) {
+	var rep models.Reporter
+	err := m.DB.Col(ReportersCol).FindOneAndUpdate(ctx,
+		bson.M{"phone": phone},
+		bson.M{
+			"$set":         bson.M{"verified_at": now, "last_seen_at": now},
+			"$setOnInsert": bson.M{"created_at": now},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&rep)
+	return rep, err
+}
+
+func (m *MongoStore) GetReporter(ctx context.Context, id primitive.ObjectID) (models.Reporter, error) {
+	var rep models.Reporter
+	err := m.DB.Col(ReportersCol).FindOne(ctx, bson.M{"_id": id}).Decode(&rep)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return rep, errNotFound
+	}
+	return rep, err
+}
+
+func (m *MongoStore) InsertSession(ctx context.Context, sess models.ReporterSession) error {
+	_, err := m.DB.Col(SessionsCol).InsertOne(ctx, sess)
+	return err
+}
+
+func (m *MongoStore) FindSession(ctx context.Context, tokenHash string, now time.Time) (models.ReporterSession, error) {
+	var sess models.ReporterSession
+	err := m.DB.Col(SessionsCol).FindOne(ctx, bson.M{
+		"token_hash": tokenHash, "expires_at": bson.M{"$gt": now},
+	}).Decode(&sess)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return sess, errNotFound
+	}
+	return sess, err
+}
+
+func (m *MongoStore) DeleteSession(ctx context.Context, tokenHash string) error {
+	_, err := m.DB.Col(SessionsCol).DeleteOne(ctx, bson.M{"token_hash": tokenHash})
+	return err
+}
+
+// MemoryStore is an in-process Store for tests and local demos. Nothing
+// expires on its own.
+type MemoryStore struct {
+	mu         sync.Mutex
+	challenges []models.OTPChallenge
+	reporters  []models.Reporter
+	sessions   []models.ReporterSession
+}
+
+// NewMemoryStore returns an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (m *MemoryStore) CountChallenges(_ context.Context, phone string, since time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for _, ch := range m.challenges {
+		if ch.Phone == phone && ch.CreatedAt.After(since) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *MemoryStore) InsertChallenge(_ context.Context, ch models.OTPChallenge) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.challenges = append(m.challenges, ch)
+	// newest first, like ClaimChallenge's sort in Mongo
+	sort.SliceStable(m.challenges, func(i, j int) bool {
+		return m.challenges[i].CreatedAt.After(m.challenges[j].CreatedAt)
+	})
+	return nil
+}
+
+func (m *MemoryStore) ClaimChallenge(_ context.Context, phone string, now time.Time, maxAttempts int) (models.OTPChallenge, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.challenges {
+		ch := &m.challenges[i]
+		if ch.Phone == phone && ch.ExpiresAt.After(now) && ch.Attempts < maxAttempts {
+			out := *ch // pre-increment, as FindOneAndUpdate returns by default
+			ch.Attempts++
+			return out, nil
+		}
+	}
+	return models.OTPChallenge{}, errNotFound
+}
+
+func (m *MemoryStore) ExpireChallenges(_ context.Context, phone string, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.challenges {
+		if m.challenges[i].Phone == phone {
+			m.challenges[i].ExpiresAt = now
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) VerifyReporter(_ context.Context, phone string, now time.Time) (models.Reporter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.reporters {
+		if m.reporters[i].Phone == phone {
+			m.reporters[i].VerifiedAt, m.reporters[i].LastSeenAt = now, now
+			return m.reporters[i], nil
+		}
+	}
+	rep := models.Reporter{ID: primitive.NewObjectID(), Phone: phone, VerifiedAt: now, LastSeenAt: now, CreatedAt: now}
+	m.reporters = append(m.reporters, rep)
+	return rep, nil
+}
+
+func (m *MemoryStore) GetReporter(_ context.Context, id primitive.ObjectID) (models.Reporter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, rep := range m.reporters {
+		if rep.ID == id {
+			return rep, nil
+		}
+	}
+	return models.Reporter{}, errNotFound
+}
+
+func (m *MemoryStore) InsertSession(_ context.Context, sess models.ReporterSession) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions = append(m.sessions, sess)
+	return nil
+}
+
+func (m *MemoryStore) FindSession(_ context.Context, tokenHash string, now time.Time) (models.ReporterSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, sess := range m.sessions {
+		if sess.TokenHash == tokenHash && sess.ExpiresAt.After(now) {
+			return sess, nil
+		}
+	}
+	return models.ReporterSession{}, errNotFound
+}
+
+func (m *MemoryStore) DeleteSession(_ context.Context, tokenHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, sess := range m.sessions {
+		if sess.TokenHash == tokenHash {
+			m.sessions = append(m.sessions[:i], m.sessions[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
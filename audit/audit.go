@@ -0,0 +1,353 @@
+// path: audit/audit.go
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"meniba/database"
+	"meniba/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Col is the audit log collection. Nothing in the API updates or deletes
+// from it; give the application's database user insert/find rights only.
+const Col = "audit_events"
+
+// Actor roles (AuditEvent.Role).
+const (
+	RolePublic   = "public"   // anonymous web/app or Open311 client
+	RoleReporter = "reporter" // signed-in reporter or tracking-token holder
+	RoleGateway  = "gateway"  // SMS/USSD/chat provider callback
+	RoleAdmin    = "admin"
+	RoleAgency   = "agency"
+	RoleSystem   = "system" // background jobs
+)
+
+// Actions recorded in the log (AuditEvent.Action).
+const (
+	ReportCreate         = "report.create"
+	ReportEdit           = "report.edit"
+	ReportWithdraw       = "report.withdraw"
+	ReportStatus         = "report.status"
+	ReportMerge          = "report.merge"
+	ReportComment        = "report.comment"
+	ReportAssign         = "report.assign"
+	ReportSLABreach      = "report.sla_breach"
+	ReportEscalate       = "report.escalate"
+	ReportTrackingRevoke = "report.tracking_revoke"
+	AgencyCreate         = "agency.create"
+	AgencyRules          = "agency.rules"
+	AgencyDelete         = "agency.delete"
+	SLAPolicyCreate      = "sla_policy.create"
+	SLAPolicyDelete      = "sla_policy.delete"
+	WebhookCreate        = "webhook.create"
+	WebhookDelete        = "webhook.delete"
+	RetentionRuleCreate  = "retention_rule.create"
+	RetentionRuleDelete  = "retention_rule.delete"
+	Retention            = "retention." // + the rule's action, e.g. "retention.delete_voice"
+)
+
+// Actor is who performed an action.
+type Actor struct {
+	ID     string // admin name, "agency:<id>", reporter id, gateway channel...
+	Role   string
+	IPHash string
+}
+
+// System returns the actor for a background job.
+func System(job string) Actor {
+	return Actor{ID: job, Role: RoleSystem}
+}
+
+// Entry is an action to record. Before and After are the target as it was
+// and as it is now (nil for creations and deletions); the log stores only
+// the fields that differ, as they appear in the API's JSON. For reports,
+// content fields are stored without their values (see reportContent).
+type Entry struct {
+	Action   string
+	Target   string
+	TargetID primitive.ObjectID
+	Before   any
+	After    any
+}
+
+// reportContent are the report (and comment) fields holding what someone
+// wrote, recorded or where they were. Entries can never be purged, so for
+// these the log keeps only the fact that they changed; otherwise retention
+// rules could not erase them. Add new reporter-supplied fields here.
+var reportContent = map[string]bool{
+	"note": true, "area_label": true, "lat": true, "lng": true,
+	"accuracy_m": true, "privacy_radius_m": true, "adrehs": true,
+	"district": true, "chiefdom": true, "region": true, "section": true,
+	"voice_url": true, "photo_urls": true, "revisions": true,
+	"status_history": true, "captured_at": true, "body": true,
+}
+
+// ErrChainBusy is returned when another writer kept appending while Record
+// tried to claim the next sequence number.
+var ErrChainBusy = errors.New("audit: could not append; log busy")
+
+// Log appends hash-chained entries to Col.
+type Log struct {
+	DB *database.DB
+
+	salt []byte
+	mu   sync.Mutex
+	tip  *models.AuditEvent // last entry this process saw; nil = reload
+}
+
+// New returns a log over db. IP addresses are stored as HMACs keyed with
+// AUDIT_IP_SALT, so the same client can be correlated without keeping
+// the address itself. The salt is required: with an empty key anyone
+// could hash candidate addresses and match them against the log, and a
+// random one would break correlation across restarts and replicas.
+func New(db *database.DB) (*Log, error) {
+	salt := []byte(os.Getenv("AUDIT_IP_SALT"))
+	if len(salt) == 0 {
+		return nil, errors.New("AUDIT_IP_SALT must be set to hash client addresses")
+	}
+	return &Log{DB: db, salt: salt}, nil
+}
+
+// HashIP returns the stored form of a client IP address, or "" for a log
+// built without New, which has no salt to key it with.
+func (l *Log) HashIP(ip string) string {
+	if ip == "" || len(l.salt) == 0 {
+		return ""
+	}
+	m := hmac.New(sha256.New, l.salt)
+	m.Write([]byte(ip))
+	return hex.EncodeToString(m.Sum(nil))[:32]
+}
+
+// Record appends e to the log on behalf of who.
+func (l *Log) Record(ctx context.Context, who Actor, e Entry) error {
+	changes, err := e.changes()
+	if err != nil {
+		return err
+	}
+	ev := models.AuditEvent{
+		Actor:   who.ID,
+		Role:    who.Role,
+		IPHash:  who.IPHash,
+		Action:  e.Action,
+		Target:  e.Target,
+		Changes: changes,
+		// why: Mongo keeps milliseconds; hash what will be read back
+		At: time.Now().UTC().Truncate(time.Millisecond),
+	}
+	if !e.TargetID.IsZero() {
+		ev.TargetID = e.TargetID.Hex()
+		if e.Target == "report" {
+			id := e.TargetID
+			ev.ReportID = &id
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// several API processes may append at once; the unique seq index makes
+	// all but one lose, and the losers reload the tip and try again
+	for attempt := 0; attempt < 5; attempt++ {
+		if l.tip == nil {
+			tip, err := l.last(ctx)
+			if err != nil {
+				return err
+			}
+			l.tip = &tip
+		}
+		ev.ID = primitive.NewObjectID()
+		ev.Seq = l.tip.Seq + 1
+		ev.PrevHash = l.tip.Hash
+		ev.Hash = Hash(ev)
+
+		_, err := l.DB.Col(Col).InsertOne(ctx, ev)
+		if mongo.IsDuplicateKeyError(err) {
+			l.tip = nil
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		l.tip = &ev
+		return nil
+	}
+	return ErrChainBusy
+}
+
+// changes is the Diff of e as stored, report content redacted.
+func (e Entry) changes() ([]models.FieldChange, error) {
+	changes, err := Diff(e.Before, e.After)
+	if err != nil || e.Target != "report" {
+		return changes, err
+	}
+	for i, ch := range changes {
+		if reportContent[ch.Field] {
+			changes[i] = models.FieldChange{Field: ch.Field, Redacted: true}
+		}
+	}
+	return changes, nil
+}
+
+// last returns the newest entry, or a zero event for an empty log.
+func (l *Log) last(ctx context.Context) (models.AuditEvent, error) {
+	var ev models.AuditEvent
+	err := l.DB.Col(Col).FindOne(ctx, bson.M{},
+		options.FindOne().SetSort(bson.D{{Key: "seq", Value: -1}})).Decode(&ev)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.AuditEvent{}, nil
+	}
+	return ev, err
+}
+
+// Hash returns the chain hash of ev: SHA-256 over its recorded fields and
+// PrevHash. ID and Hash itself are not covered.
+func Hash(ev models.AuditEvent) string {
+	reportID := ""
+	if ev.ReportID != nil {
+		reportID = ev.ReportID.Hex()
+	}
+	b, _ := json.Marshal(struct {
+		Seq      int64                `json:"seq"`
+		Actor    string               `json:"actor"`
+		Role     string               `json:"role"`
+		IPHash   string               `json:"ip_hash"`
+		Action   string               `json:"action"`
+		Target   string               `json:"target"`
+		TargetID string               `json:"target_id"`
+		ReportID string               `json:"report_id"`
+		Changes  []models.FieldChange `json:"changes"`
+		At       int64                `json:"at"`
+		PrevHash string               `json:"prev_hash"`
+	}{ev.Seq, ev.Actor, ev.Role, ev.IPHash, ev.Action, ev.Target, ev.TargetID, reportID,
+		ev.Changes, ev.At.UnixMilli(), ev.PrevHash})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// Diff lists the top-level JSON fields that differ between before and
+// after, in field order. Either side may be nil.
+func Diff(before, after any) ([]models.FieldChange, error) {
+	b, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range b {
+		keys = append(keys, k)
+	}
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var out []models.FieldChange
+	for _, k := range keys {
+		if !bytes.Equal(b[k], a[k]) {
+			out = append(out, models.FieldChange{Field: k, Before: string(b[k]), After: string(a[k])})
+		}
+	}
+	return out, nil
+}
+
+// Snapshot freezes v as JSON, for a Before taken inside an update that
+// goes on to change v's slices in place.
+func Snapshot(v any) json.RawMessage {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+func fields(v any) (map[string]json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("audit: encode: %w", err)
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, fmt.Errorf("audit: %T is not an object: %w", v, err)
+	}
+	for k, v := range m {
+		if string(v) == "null" {
+			delete(m, k)
+		}
+	}
+	return m, nil
+}
+
+// VerifyResult is the outcome of Verify.
+type VerifyResult struct {
+	Checked  int64  `json:"checked"`
+	OK       bool   `json:"ok"`
+	LastHash string `json:"last_hash,omitempty"` // hash of the last good entry
+	BrokenAt int64  `json:"broken_at,omitempty"` // seq of the first bad entry
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Verify walks the whole log in sequence order and checks that the
+// numbering has no gaps and every entry's hash and back-link are intact.
+// It stops at the first broken entry. Removing the newest entries leaves
+// a valid chain, so keep (Checked, LastHash) from earlier runs to compare.
+func (l *Log) Verify(ctx context.Context) (VerifyResult, error) {
+	cur, err := l.DB.Col(Col).Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "seq", Value: 1}}))
+	if err != nil {
+		return VerifyResult{}, err
+	}
+	defer cur.Close(ctx)
+
+	var res VerifyResult
+	prev := models.AuditEvent{}
+	for cur.Next(ctx) {
+		var ev models.AuditEvent
+		if err := cur.Decode(&ev); err != nil {
+			return res, err
+		}
+		switch {
+		case ev.Seq != prev.Seq+1:
+			res.Reason = fmt.Sprintf("expected seq %d, found %d (entries missing)", prev.Seq+1, ev.Seq)
+		case ev.PrevHash != prev.Hash:
+			res.Reason = "prev_hash does not match the previous entry"
+		case Hash(ev) != ev.Hash:
+			res.Reason = "hash does not match the entry's contents"
+		}
+		if res.Reason != "" {
+			res.BrokenAt = ev.Seq
+			return res, nil
+		}
+		res.Checked++
+		res.LastHash = ev.Hash
+		prev = ev
+	}
+	if err := cur.Err(); err != nil {
+		return res, err
+	}
+	res.OK = true
+	return res, nil
+}
@@ -0,0 +1,82 @@
+package audit
+
+import (
+	"strings"
+	"testing"
+
+	"meniba/models"
+)
+
+func TestReportContentIsRedacted(t *testing.T) {
+	before := models.Report{Note: "Pipe burst by Fatmata's house", Lat: 8.4844, Lng: -13.2344, Status: models.StatusOpen}
+	after := before
+	after.Note = "Pipe burst"
+	after.Lat = 8.49
+	after.Status = models.StatusInProgress
+
+	changes, err := Entry{Action: ReportEdit, Target: "report", Before: before, After: after}.changes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]models.FieldChange{}
+	for _, ch := range changes {
+		got[ch.Field] = ch
+	}
+	for _, f := range []string{"note", "lat"} {
+		if ch, ok := got[f]; !ok || !ch.Redacted || ch.Before != "" || ch.After != "" {
+			t.Errorf("%s = %+v, want redacted", f, ch)
+		}
+	}
+	if ch := got["status"]; ch.Redacted || !strings.Contains(ch.After, models.StatusInProgress) {
+		t.Errorf("status = %+v, want its values kept", ch)
+	}
+
+	// other targets keep their values
+	changes, _ = Entry{Target: "agency", Before: map[string]string{"note": "a"}, After: map[string]string{"note": "b"}}.changes()
+	if len(changes) != 1 || changes[0].Redacted || changes[0].After != `"b"` {
+		t.Errorf("agency changes = %+v", changes)
+	}
+}
+
+func TestHashUnchangedForUnredactedChanges(t *testing.T) {
+	ev := models.AuditEvent{Seq: 1, Action: ReportStatus, Changes: []models.FieldChange{{Field: "status", Before: `"open"`, After: `"resolved"`}}}
+	h := Hash(ev)
+	ev.Changes[0].Redacted = true
+	if Hash(ev) == h {
+		t.Fatal("Redacted not covered by the hash")
+	}
+	ev.Changes[0].Redacted = false
+	if Hash(ev) != h {
+		t.Fatal("hash not stable")
+	}
+}
+
+func TestNewRequiresIPSalt(t *testing.T) {
+	t.Setenv("AUDIT_IP_SALT", "")
+	if l, err := New(nil); err == nil || l != nil {
+		t.Fatal("audit log started without AUDIT_IP_SALT")
+	}
+
+	t.Setenv("AUDIT_IP_SALT", "pepper")
+	l, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := l.HashIP("197.215.8.10")
+	if len(h) != 32 || strings.Contains(h, "197.215") || h != l.HashIP("197.215.8.10") || h == l.HashIP("197.215.8.11") {
+		t.Fatalf("HashIP = %q", h)
+	}
+	if l.HashIP("") != "" {
+		t.Fatal("hashed an empty address")
+	}
+
+	t.Setenv("AUDIT_IP_SALT", "salt")
+	other, _ := New(nil)
+	if other.HashIP("197.215.8.10") == h {
+		t.Fatal("hash does not depend on the salt")
+	}
+	// a log built by hand (e.g. only to Verify) never stores unkeyed hashes
+	if h := (&Log{}).HashIP("197.215.8.10"); h != "" {
+		t.Fatalf("unsalted HashIP = %q", h)
+	}
+}
@@ -0,0 +1,140 @@
+// path: controllers/accounts.go
+package controllers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"meniba/accounts"
+	"meniba/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type OTPVerifyResp struct {
+	OK       bool            `json:"ok"`
+	Token    string          `json:"token"` // send as "Authorization: Bearer <token>"
+	Reporter models.Reporter `json:"reporter"`
+}
+
+// HandleRequestOTP texts a sign-in code to a phone number.
+// POST /api/auth/otp {"phone": "076 123456"}
+func (a *API) HandleRequestOTP(c *fiber.Ctx) error {
+	if a.Accounts == nil {
+		return accountsDisabled(c)
+	}
+	var p models.OTPRequestPayload
+	if err := c.BodyParser(&p); err != nil {
+		return badReq(c, "invalid JSON")
+	}
+	ctx, cancel := context.WithTimeout(c.Context(), 15*time.Second)
+	defer cancel()
+
+	phone, err := a.Accounts.StartLogin(ctx, p.Phone)
+	if err != nil {
+		return accountsErr(c, err)
+	}
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"ok": true, "phone": phone})
+}
+
+// HandleVerifyOTP exchanges a code for a session token, creating the
+// account on first sign-in.
+// POST /api/auth/verify {"phone": "...", "code": "123456"}
+func (a *API) HandleVerifyOTP(c *fiber.Ctx) error {
+	if a.Accounts == nil {
+		return accountsDisabled(c)
+	}
+	var p models.OTPVerifyPayload
+	if err := c.BodyParser(&p); err != nil {
+		return badReq(c, "invalid JSON")
+	}
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+
+	token, rep, err := a.Accounts.VerifyLogin(ctx, p.Phone, p.Code)
+	if err != nil {
+		return accountsErr(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(OTPVerifyResp{OK: true, Token: token, Reporter: rep})
+}
+
+// HandleLogout ends the current session.
+// POST /api/auth/logout
+func (a *API) HandleLogout(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+	if err := a.Accounts.Logout(ctx, bearerToken(c)); err != nil {
+		return serverErr(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"ok": true})
+}
+
+// HandleMe returns the signed-in reporter.
+// GET /api/me
+func (a *API) HandleMe(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(c.Locals("reporter").(models.Reporter))
+}
+
+// HandleMyReports lists the signed-in reporter's submissions, merged and
+// withdrawn ones included, with the same filters and paging as GET /api/reports.
+// GET /api/me/reports
+func (a *API) HandleMyReports(c *fiber.Ctx) error {
+	f, err := reportQuery(c)
+	if err != nil {
+		return badReq(c, err.Error())
+	}
+	rep := c.Locals("reporter").(models.Reporter)
+	f.ReporterID = &rep.ID
+	f.IncludeMerged, f.IncludeWithdrawn = true, true
+	return a.listReports(c, f)
+}
+
+// RequireReporter authenticates a reporter session (Bearer token) and
+// stores the account in c.Locals("reporter").
+func (a *API) RequireReporter(c *fiber.Ctx) error {
+	if a.Accounts == nil {
+		return accountsDisabled(c)
+	}
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	rep, err := a.Accounts.Authenticate(ctx, bearerToken(c))
+	if err != nil {
+		return accountsErr(c, err)
+	}
+	c.Locals("reporter", rep)
+	return c.Next()
+}
+
+// linkReporter attaches the signed-in reporter, if any, to a
+// non-anonymous report. A bad token is an error rather than silently
+// submitting an unlinked report.
+func (a *API) linkReporter(c *fiber.Ctx, ctx context.Context, doc *models.Report) error {
+	token := bearerToken(c)
+	if a.Accounts == nil || token == "" || doc.Anonymous {
+		return nil
+	}
+	rep, err := a.Accounts.Authenticate(ctx, token)
+	if err != nil {
+		return err
+	}
+	doc.ReporterID = &rep.ID
+	return nil
+}
+
+func accountsErr(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, accounts.ErrInvalidPhone), errors.Is(err, accounts.ErrInvalidCode):
+		return badReq(c, err.Error())
+	case errors.Is(err, accounts.ErrTooMany):
+		return c.Status(fiber.StatusTooManyRequests).JSON(ErrorResp{OK: false, Error: err.Error()})
+	case errors.Is(err, accounts.ErrUnauthorized):
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResp{OK: false, Error: err.Error()})
+	}
+	return serverErr(c, err)
+}
+
+func accountsDisabled(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusServiceUnavailable).JSON(ErrorResp{OK: false, Error: "reporter accounts disabled"})
+}
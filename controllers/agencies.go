@@ -0,0 +1,286 @@
+// path: controllers/agencies.go
+package controllers
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"meniba/audit"
+	"meniba/events"
+	"meniba/models"
+	"meniba/repository"
+	"meniba/routing"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type AgencyCreateResp struct {
+	models.Agency
+	Key string `json:"key"` // shown once
+}
+
+type AgencyListResp struct {
+	OK    bool            `json:"ok"`
+	Items []models.Agency `json:"items"`
+}
+
+var agencyCode = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{1,31}$`)
+
+// HandleCreateAgency registers an agency with its routing rules. The API
+// key is returned only in this response.
+// POST /api/admin/agencies
+func (a *API) HandleCreateAgency(c *fiber.Ctx) error {
+	var p models.AgencyCreatePayload
+	if err := c.BodyParser(&p); err != nil {
+		return badReq(c, "invalid JSON")
+	}
+	p.Name = strings.TrimSpace(p.Name)
+	p.Code = strings.ToLower(strings.TrimSpace(p.Code))
+	if p.Name == "" {
+		return badReq(c, "missing name")
+	}
+	if !agencyCode.MatchString(p.Code) {
+		return badReq(c, "invalid code (2-32 chars: a-z, 0-9, _ or -)")
+	}
+	if err := routing.ValidateRules(p.Rules); err != nil {
+		return badReq(c, err.Error())
+	}
+
+	key := randString(40)
+	now := time.Now().UTC()
+	ag := models.Agency{
+		Name:      p.Name,
+		Code:      p.Code,
+		Email:     strings.TrimSpace(p.Email),
+		KeyHash:   routing.HashKey(key),
+		Rules:     p.Rules,
+		Active:    true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+	res, err := a.DB.Col(routing.AgenciesCol).InsertOne(ctx, ag)
+	if mongo.IsDuplicateKeyError(err) {
+		return c.Status(fiber.StatusConflict).JSON(ErrorResp{OK: false, Error: "code already in use"})
+	}
+	if err != nil {
+		return serverErr(c, err)
+	}
+	ag.ID = res.InsertedID.(primitive.ObjectID)
+	a.recordAudit(ctx, a.actor(c), audit.Entry{Action: audit.AgencyCreate, Target: "agency", TargetID: ag.ID, After: ag})
+	return c.Status(fiber.StatusOK).JSON(AgencyCreateResp{Agency: ag, Key: key})
+}
+
+// HandleListAgencies lists agencies with their rules, by name.
+// GET /api/admin/agencies
+func (a *API) HandleListAgencies(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+
+	cur, err := a.DB.Col(routing.AgenciesCol).Find(ctx, bson.M{},
+		options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return serverErr(c, err)
+	}
+	items := make([]models.Agency, 0)
+	if err := cur.All(ctx, &items); err != nil {
+		return serverErr(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(AgencyListResp{OK: true, Items: items})
+}
+
+// HandleSetAgencyRules replaces an agency's routing rules. Only reports
+// created afterwards are affected.
+// POST /api/admin/agencies/:id/rules {"rules": [...]}
+func (a *API) HandleSetAgencyRules(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return badReq(c, "invalid id")
+	}
+	var p models.AgencyRulesPayload
+	if err := c.BodyParser(&p); err != nil {
+		return badReq(c, "invalid JSON")
+	}
+	if err := routing.ValidateRules(p.Rules); err != nil {
+		return badReq(c, err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	var before models.Agency
+	err = a.DB.Col(routing.AgenciesCol).FindOneAndUpdate(ctx, bson.M{"_id": id},
+		bson.M{"$set": bson.M{"rules": p.Rules, "updated_at": now}}).Decode(&before)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound(c, "agency not found")
+	}
+	if err != nil {
+		return serverErr(c, err)
+	}
+	ag := before
+	ag.Rules, ag.UpdatedAt = p.Rules, now
+	a.recordAudit(ctx, a.actor(c), audit.Entry{Action: audit.AgencyRules, Target: "agency", TargetID: id, Before: before, After: ag})
+	return c.Status(fiber.StatusOK).JSON(ag)
+}
+
+// HandleDeleteAgency deactivates an agency: it stops receiving new reports
+// and its key stops working. Past assignments are kept.
+// DELETE /api/admin/agencies/:id
+func (a *API) HandleDeleteAgency(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return badReq(c, "invalid id")
+	}
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	var before models.Agency
+	err = a.DB.Col(routing.AgenciesCol).FindOneAndUpdate(ctx, bson.M{"_id": id},
+		bson.M{"$set": bson.M{"active": false, "updated_at": now}}).Decode(&before)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound(c, "agency not found")
+	}
+	if err != nil {
+		return serverErr(c, err)
+	}
+	after := before
+	after.Active, after.UpdatedAt = false, now
+	a.recordAudit(ctx, a.actor(c), audit.Entry{Action: audit.AgencyDelete, Target: "agency", TargetID: id, Before: before, After: after})
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"ok": true})
+}
+
+// HandleAssignReport replaces the agencies responsible for a report and
+// records the change in its assignment history. An empty list unassigns it.
+// POST /api/admin/reports/:id/assign {"agency_ids": ["..."], "reason": "..."}
+func (a *API) HandleAssignReport(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return badReq(c, "invalid id")
+	}
+	var p models.AssignPayload
+	if err := c.BodyParser(&p); err != nil {
+		return badReq(c, "invalid JSON")
+	}
+	var to []primitive.ObjectID
+	for _, s := range p.AgencyIDs {
+		oid, err := primitive.ObjectIDFromHex(strings.TrimSpace(s))
+		if err != nil {
+			return badReq(c, "invalid agency id: "+s)
+		}
+		if !containsObjectID(to, oid) {
+			to = append(to, oid)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+
+	if len(to) > 0 {
+		n, err := a.DB.Col(routing.AgenciesCol).CountDocuments(ctx, bson.M{"_id": bson.M{"$in": to}, "active": true})
+		if err != nil {
+			return serverErr(c, err)
+		}
+		if n != int64(len(to)) {
+			return badReq(c, "unknown or inactive agency")
+		}
+	}
+
+	var change models.Assignment
+	after, err := a.updateReport(ctx, a.actor(c), audit.ReportAssign, id, func(r *models.Report) error {
+		if sameIDs(r.Agencies, to) {
+			return repository.ErrNoChange
+		}
+		change = models.Assignment{
+			From:   r.Agencies,
+			To:     to,
+			By:     models.AssignedByModerator,
+			Reason: strings.TrimSpace(p.Reason),
+			At:     time.Now().UTC(),
+		}
+		r.Agencies = to
+		r.AssignmentHistory = append(r.AssignmentHistory, change)
+		return nil
+	})
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return notFound(c, "report not found")
+	case errors.Is(err, repository.ErrConflict):
+		return c.Status(fiber.StatusConflict).JSON(ErrorResp{OK: false, Error: "report changed concurrently; retry"})
+	case err != nil:
+		return serverErr(c, err)
+	}
+	if change.At.IsZero() {
+		return c.Status(fiber.StatusOK).JSON(after)
+	}
+
+	a.Bus.Publish(events.Event{
+		Type:     events.ReportAssigned,
+		ReportID: id.Hex(),
+		Report:   &after,
+		Data:     change,
+	})
+	return c.Status(fiber.StatusOK).JSON(after)
+}
+
+// HandleAgencyReports lists the reports assigned to the calling agency,
+// with the same filters and paging as GET /api/reports.
+// GET /api/agency/reports
+func (a *API) HandleAgencyReports(c *fiber.Ctx) error {
+	f, err := reportQuery(c)
+	if err != nil {
+		return badReq(c, err.Error())
+	}
+	f.Agencies = []primitive.ObjectID{c.Locals("agency").(models.Agency).ID}
+	return a.listReports(c, f)
+}
+
+// RequireAgency authenticates an agency by its key (X-Agency-Key or
+// Bearer) and stores it in c.Locals("agency").
+func (a *API) RequireAgency(c *fiber.Ctx) error {
+	key := c.Get("X-Agency-Key")
+	if key == "" {
+		key = bearerToken(c)
+	}
+	if key == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResp{OK: false, Error: "unauthorized"})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Second)
+	defer cancel()
+
+	var ag models.Agency
+	err := a.DB.Col(routing.AgenciesCol).FindOne(ctx,
+		bson.M{"key_hash": routing.HashKey(key), "active": true}).Decode(&ag)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResp{OK: false, Error: "unauthorized"})
+	}
+	if err != nil {
+		return serverErr(c, err)
+	}
+	c.Locals("agency", ag)
+	return c.Next()
+}
+
+// sameIDs reports whether a and b hold the same ids, in any order.
+func sameIDs(a, b []primitive.ObjectID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, x := range a {
+		if !containsObjectID(b, x) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+// path: controllers/alerts.go
+package controllers
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"meniba/jobs"
+	"meniba/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type AlertListResp struct {
+	OK    bool           `json:"ok"`
+	Items []models.Alert `json:"items"`
+}
+
+// HandleListAlerts returns anomaly alerts, newest first.
+// GET /api/alerts?active=&category=&district=&since=&limit=
+func (a *API) HandleListAlerts(c *fiber.Ctx) error {
+	filter := bson.M{}
+	switch c.Query("active") {
+	case "":
+	case "true", "1":
+		filter["active"] = true
+	case "false", "0":
+		filter["active"] = false
+	default:
+		return badReq(c, "invalid active")
+	}
+	if v := strings.TrimSpace(c.Query("category")); v != "" {
+		filter["category"] = v
+	}
+	if v := strings.TrimSpace(c.Query("district")); v != "" {
+		filter["district"] = v
+	}
+	if v := c.Query("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return badReq(c, "invalid since (RFC3339)")
+		}
+		filter["updated_at"] = bson.M{"$gte": t.UTC()}
+	}
+	limit := 50
+	if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 && v <= 500 {
+		limit = v
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+
+	cur, err := a.DB.Col(jobs.AlertsCol).Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(int64(limit)))
+	if err != nil {
+		return serverErr(c, err)
+	}
+	items := make([]models.Alert, 0, limit)
+	if err := cur.All(ctx, &items); err != nil {
+		return serverErr(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(AlertListResp{OK: true, Items: items})
+}
@@ -0,0 +1,37 @@
+// path: controllers/api.go
+package controllers
+
+import (
+	"meniba/accounts"
+	"meniba/audit"
+	"meniba/database"
+	"meniba/events"
+	"meniba/jobs"
+	"meniba/media"
+	"meniba/messaging"
+	"meniba/notify"
+	"meniba/repository"
+	"meniba/routing"
+	"meniba/webhooks"
+)
+
+// API holds everything the HTTP handlers depend on. main builds one and
+// routes.Register mounts its handlers; tests can build one around
+// repository.NewMemory instead of a live MongoDB.
+type API struct {
+	Reports      repository.ReportRepository
+	DB           *database.DB // collections other than reports (comments, webhooks, ...)
+	Bus          *events.Bus
+	Media        *media.Store
+	Chat         messaging.Provider
+	ChatSessions repository.ChatSessionRepository
+	Comments     repository.CommentRepository
+	Tracking     repository.TrackingTokenRepository
+	Webhooks     *webhooks.Dispatcher
+	Router       *routing.Router // assigns new reports to agencies; nil = no routing
+	Notifier     *notify.Notifier
+	Accounts     *accounts.Service // reporter sign-in; nil = accounts disabled
+	Audit        *audit.Log        // nil = no audit trail (tests, demos)
+	Retention    *jobs.Retention   // runs retention rules on demand; nil = disabled
+	MediaGC      *jobs.MediaGC     // collects orphaned uploads on demand; nil = disabled
+}
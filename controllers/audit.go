@@ -0,0 +1,136 @@
+// path: controllers/audit.go
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"meniba/audit"
+	"meniba/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type AuditListResp struct {
+	OK    bool                `json:"ok"`
+	Items []models.AuditEvent `json:"items"`
+}
+
+// actor identifies the caller for the audit log from what the auth
+// middleware stored: admin, agency or reporter, else an anonymous client.
+func (a *API) actor(c *fiber.Ctx) audit.Actor {
+	who := audit.Actor{ID: "anonymous", Role: audit.RolePublic}
+	if name, ok := c.Locals("admin").(string); ok {
+		who = audit.Actor{ID: name, Role: audit.RoleAdmin}
+	} else if ag, ok := c.Locals("agency").(models.Agency); ok {
+		who = audit.Actor{ID: "agency:" + ag.ID.Hex(), Role: audit.RoleAgency}
+	} else if rep, ok := c.Locals("reporter").(models.Reporter); ok {
+		who = audit.Actor{ID: rep.ID.Hex(), Role: audit.RoleReporter}
+	}
+	if a.Audit != nil {
+		who.IPHash = a.Audit.HashIP(c.IP())
+	}
+	return who
+}
+
+// reporterActor is the actor for a reporter identified per request
+// (tracking token or session) rather than by RequireReporter.
+func (a *API) reporterActor(c *fiber.Ctx, id string) audit.Actor {
+	who := a.actor(c)
+	who.ID, who.Role = id, audit.RoleReporter
+	return who
+}
+
+// recordAudit appends to the audit log. The change it describes has
+// already happened, so a failure is logged rather than returned.
+func (a *API) recordAudit(ctx context.Context, who audit.Actor, e audit.Entry) {
+	if a.Audit == nil {
+		return
+	}
+	if err := a.Audit.Record(ctx, who, e); err != nil {
+		log.Printf("audit: %s %s %s: %v", e.Action, e.Target, e.TargetID.Hex(), err)
+	}
+}
+
+// updateReport is Reports.Update plus an audit entry, recorded only when
+// mutate actually changed the report.
+func (a *API) updateReport(ctx context.Context, who audit.Actor, action string, id primitive.ObjectID, mutate func(r *models.Report) error) (models.Report, error) {
+	var before json.RawMessage
+	changed := false
+	after, err := a.Reports.Update(ctx, id, func(r *models.Report) error {
+		before = audit.Snapshot(*r)
+		err := mutate(r)
+		changed = err == nil
+		return err
+	})
+	if err == nil && changed {
+		a.recordAudit(ctx, who, audit.Entry{Action: action, Target: "report", TargetID: id, Before: before, After: after})
+	}
+	return after, err
+}
+
+// HandleListAudit queries the audit log, newest first.
+// GET /api/admin/audit?report_id=&target=&target_id=&action=&actor=&role=&since=&until=&before_seq=&limit=
+func (a *API) HandleListAudit(c *fiber.Ctx) error {
+	filter := bson.M{}
+	if v := c.Query("report_id"); v != "" {
+		id, err := primitive.ObjectIDFromHex(v)
+		if err != nil {
+			return badReq(c, "invalid report_id")
+		}
+		filter["report_id"] = id
+	}
+	for _, k := range []string{"target", "target_id", "actor", "role"} {
+		if v := strings.TrimSpace(c.Query(k)); v != "" {
+			filter[k] = v
+		}
+	}
+	if v := c.Query("action"); v != "" {
+		filter["action"] = bson.M{"$in": trimAll(strings.Split(v, ","))}
+	}
+	at := bson.M{}
+	for param, op := range map[string]string{"since": "$gte", "until": "$lt"} {
+		if v := c.Query(param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return badReq(c, "invalid "+param+" (RFC3339)")
+			}
+			at[op] = t
+		}
+	}
+	if len(at) > 0 {
+		filter["at"] = at
+	}
+	if v := c.Query("before_seq"); v != "" {
+		seq, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return badReq(c, "invalid before_seq")
+		}
+		filter["seq"] = bson.M{"$lt": seq}
+	}
+	limit := 50
+	if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 && v <= 500 {
+		limit = v
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+
+	cur, err := a.DB.Col(audit.Col).Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "seq", Value: -1}}).SetLimit(int64(limit)))
+	if err != nil {
+		return serverErr(c, err)
+	}
+	items := make([]models.AuditEvent, 0, limit)
+	if err := cur.All(ctx, &items); err != nil {
+		return serverErr(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(AuditListResp{OK: true, Items: items})
+}
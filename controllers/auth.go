@@ -0,0 +1,44 @@
+// path: controllers/auth.go
+package controllers
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequireAdmin guards moderator/admin endpoints with a shared token taken
+// from ADMIN_TOKEN. The token may be sent as "Authorization: Bearer <t>" or
+// "X-Admin-Token: <t>". If ADMIN_TOKEN is unset the admin API is disabled.
+// Moderators sharing the token can name themselves in X-Admin-User; the
+// name (default "admin") is kept in c.Locals("admin") for the audit log.
+func RequireAdmin(c *fiber.Ctx) error {
+	want := getenv("ADMIN_TOKEN", "")
+	if want == "" {
+		return c.Status(fiber.StatusServiceUnavailable).
+			JSON(ErrorResp{OK: false, Error: "admin API disabled"})
+	}
+	got := c.Get("X-Admin-Token")
+	if got == "" {
+		got = bearerToken(c)
+	}
+	if got == "" || subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+		return c.Status(fiber.StatusUnauthorized).
+			JSON(ErrorResp{OK: false, Error: "unauthorized"})
+	}
+	name := strings.TrimSpace(c.Get("X-Admin-User"))
+	if name == "" || len(name) > 64 {
+		name = "admin"
+	}
+	c.Locals("admin", name)
+	return c.Next()
+}
+
+func bearerToken(c *fiber.Ctx) string {
+	h := strings.TrimSpace(c.Get("Authorization"))
+	if len(h) > 7 && strings.EqualFold(h[:7], "bearer ") {
+		return strings.TrimSpace(h[7:])
+	}
+	return ""
+}
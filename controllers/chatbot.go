@@ -0,0 +1,365 @@
+// path: controllers/chatbot.go
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+
+	"meniba/audit"
+	"meniba/media"
+	"meniba/models"
+	"meniba/repository"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	chatSessionTTL = time.Hour
+	chatMaxPhotos  = 5
+)
+
+// chatInbound is one message from the generic messaging webhook.
+type chatInbound struct {
+	MessageID string `json:"message_id"`
+	From      string `json:"from"`
+	Type      string `json:"type"` // text | location | image | audio | voice
+	Text      string `json:"text"`
+	Location  *struct {
+		Lat     float64 `json:"lat"`
+		Lng     float64 `json:"lng"`
+		Name    string  `json:"name"`
+		Address string  `json:"address"`
+	} `json:"location,omitempty"`
+	Media *struct {
+		ID       string `json:"id"`
+		URL      string `json:"url"`
+		MimeType string `json:"mime_type"`
+	} `json:"media,omitempty"`
+}
+
+type ChatReplyResp struct {
+	OK      bool     `json:"ok"`
+	Replies []string `json:"replies"`
+}
+
+// HandleInboundMessage runs one step of the chat intake conversation for
+// the sender, persisting progress in ChatSessions.
+// POST /api/inbound/messages
+func (a *API) HandleInboundMessage(c *fiber.Ctx) error {
+	if st, msg := gatewayAuth(c, "CHAT_WEBHOOK_TOKEN"); st != 0 {
+		return c.Status(st).JSON(ErrorResp{OK: false, Error: msg})
+	}
+	var in chatInbound
+	if err := c.BodyParser(&in); err != nil {
+		return badReq(c, "invalid JSON")
+	}
+	if strings.TrimSpace(in.From) == "" {
+		return badReq(c, "missing from")
+	}
+
+	// why: downloads and report creation can take a while on slow links
+	ctx, cancel := context.WithTimeout(c.Context(), 45*time.Second)
+	defer cancel()
+
+	sess, err := a.loadChatSession(ctx, in.From)
+	if err != nil {
+		return serverErr(c, err)
+	}
+	if in.MessageID != "" && in.MessageID == sess.LastMessageID {
+		// provider retried a webhook we already handled
+		return c.Status(fiber.StatusOK).JSON(ChatReplyResp{OK: true, Replies: []string{}})
+	}
+	sess.LastMessageID = in.MessageID
+
+	replies, done := a.chatStep(ctx, &sess, in)
+	if done {
+		if err := a.ChatSessions.Delete(ctx, sess.ID); err != nil {
+			log.Printf("chat: clear session: %v", err)
+		}
+	} else if err := a.saveChatSession(ctx, sess); err != nil {
+		return serverErr(c, err)
+	}
+
+	for _, r := range replies {
+		if err := a.Chat.Send(ctx, in.From, r); err != nil {
+			log.Printf("chat: send reply: %v", err)
+		}
+	}
+	return c.Status(fiber.StatusOK).JSON(ChatReplyResp{OK: true, Replies: replies})
+}
+
+// chatStep advances the session with one message and returns the replies.
+// done means the session is finished (submitted or cancelled).
+func (a *API) chatStep(ctx context.Context, sess *models.ChatSession, in chatInbound) ([]string, bool) {
+	text := strings.TrimSpace(in.Text)
+	lower := strings.ToLower(text)
+
+	if lower == "cancel" || lower == "stop" {
+		a.discardChatMedia(*sess)
+		return []string{"Your report was cancelled. Send any message to start again."}, true
+	}
+
+	// attachments are accepted at any step and kept for the report
+	if in.Type == "image" || in.Type == "audio" || in.Type == "voice" {
+		reply := a.attachChatMedia(ctx, sess, in)
+		if sess.Step == "" {
+			sess.Step = models.ChatStepCategory
+			return []string{reply, chatCategoryPrompt()}, false
+		}
+		return []string{reply}, false
+	}
+
+	switch sess.Step {
+	case "":
+		sess.Step = models.ChatStepCategory
+		return []string{"Hello! Let's report an issue. Reply CANCEL at any time to stop.", chatCategoryPrompt()}, false
+
+	case models.ChatStepCategory:
+		cat, ok := chatPickCategory(text)
+		if !ok {
+			return []string{"Sorry, I didn't understand that. " + chatCategoryPrompt()}, false
+		}
+		sess.Category = cat.Code
+		sess.Step = models.ChatStepLocation
+		return []string{cat.Name + ". Where is it? Share your location pin, or type the district and chiefdom (e.g. \"Bo Kakua\")."}, false
+
+	case models.ChatStepLocation:
+		if in.Type == "location" && in.Location != nil && (in.Location.Lat != 0 || in.Location.Lng != 0) {
+			sess.HasPin = true
+			sess.Lat, sess.Lng = in.Location.Lat, in.Location.Lng
+			sess.AreaLabel = strings.TrimSpace(in.Location.Name)
+			if sess.AreaLabel == "" {
+				sess.AreaLabel = strings.TrimSpace(in.Location.Address)
+			}
+			if sess.AreaLabel == "" {
+				sess.AreaLabel = areaLabel(sess.Lat, sess.Lng, nil)
+			}
+		} else {
+			d, cf, _, ok := matchPlace(strings.Fields(text))
+			if !ok {
+				return []string{"I couldn't find that place. Share your location pin, or type a district name such as \"Kenema\" or \"Port Loko Maforki\"."}, false
+			}
+			sess.HasPin = false
+			sess.District = d.Name
+			sess.Chiefdom = ""
+			if cf != nil {
+				sess.Chiefdom = cf.Name
+			}
+		}
+		sess.Step = models.ChatStepDescription
+		return []string{"Thanks. Please describe the problem."}, false
+
+	case models.ChatStepDescription:
+		if text == "" {
+			return []string{"Please describe the problem in a few words."}, false
+		}
+		sess.Note = text
+		sess.Step = models.ChatStepConfirm
+		return []string{"Got it. You can send photos or a voice note now. Reply 1 to submit or 2 to cancel."}, false
+
+	case models.ChatStepConfirm:
+		switch lower {
+		case "1", "submit", "yes", "done", "send":
+			doc, dups, err := a.submitChatReport(ctx, *sess)
+			if err != nil {
+				log.Printf("chat: create report: %v", err)
+				return []string{"Sorry, we could not save your report. Reply 1 to try again or 2 to cancel."}, false
+			}
+			return []string{intakeReceipt(doc, dups)}, true
+		case "2", "no":
+			a.discardChatMedia(*sess)
+			return []string{"Your report was cancelled. Send any message to start again."}, true
+		}
+		return []string{"Reply 1 to submit or 2 to cancel."}, false
+	}
+
+	// unknown step (e.g. from an older version): start over
+	sess.Step = models.ChatStepCategory
+	return []string{chatCategoryPrompt()}, false
+}
+
+func chatCategoryPrompt() string {
+	var b strings.Builder
+	b.WriteString("What kind of problem is it? Reply with a number:")
+	for i, cat := range models.Categories {
+		fmt.Fprintf(&b, "\n%d. %s", i+1, cat.Name)
+	}
+	return b.String()
+}
+
+func chatPickCategory(text string) (models.Category, bool) {
+	if n, err := strconv.Atoi(text); err == nil && n >= 1 && n <= len(models.Categories) {
+		return models.Categories[n-1], true
+	}
+	if f := strings.Fields(text); len(f) > 0 {
+		return models.CategoryByKeyword(f[0])
+	}
+	return models.Category{}, false
+}
+
+// attachChatMedia downloads an attachment into the media store's staging
+// area. Staged files aren't served; they are committed with the report, or
+// discarded with the session.
+func (a *API) attachChatMedia(ctx context.Context, sess *models.ChatSession, in chatInbound) string {
+	if in.Media == nil || (in.Media.URL == "" && in.Media.ID == "") {
+		return "Sorry, I couldn't read that attachment."
+	}
+	isVoice := in.Type != "image"
+	if !isVoice && len(sess.PhotoURLs) >= chatMaxPhotos {
+		return fmt.Sprintf("You can attach up to %d photos.", chatMaxPhotos)
+	}
+	if isVoice && sess.VoiceURL != "" {
+		return "You already sent a voice note."
+	}
+
+	ref := in.Media.ID
+	if ref == "" {
+		ref = in.Media.URL
+	}
+	body, ctype, err := a.Chat.Download(ctx, ref)
+	if err != nil {
+		log.Printf("chat: download media: %v", err)
+		return "Sorry, I couldn't download that attachment. Please try again."
+	}
+	defer body.Close()
+
+	if in.Media.MimeType != "" {
+		ctype = in.Media.MimeType
+	}
+	ext, ok := mimeExt(ctype, isVoice)
+	if !ok {
+		if isVoice {
+			return "Sorry, that audio format isn't supported."
+		}
+		return "Sorry, please send photos as JPEG, PNG or WebP."
+	}
+	prefix := "photo"
+	if isVoice {
+		prefix = "voice"
+	}
+	url, err := a.Media.Stage().Add(prefix, ext, body)
+	if err != nil {
+		log.Printf("chat: store media: %v", err)
+		return "Sorry, I couldn't save that attachment."
+	}
+	if isVoice {
+		sess.VoiceURL = url
+		return "Voice note received."
+	}
+	sess.PhotoURLs = append(sess.PhotoURLs, url)
+	return "Photo received."
+}
+
+// mimeExt returns the file extension for an accepted photo (or, with
+// voice, audio) type. Anything else is refused so a gateway can't get
+// e.g. HTML stored under /uploads.
+func mimeExt(ctype string, voice bool) (string, bool) {
+	ctype, _, _ = mime.ParseMediaType(ctype)
+	exts := map[string]string{
+		"image/jpeg": ".jpg",
+		"image/png":  ".png",
+		"image/webp": ".webp",
+	}
+	if voice {
+		exts = map[string]string{
+			"audio/ogg":   ".ogg",
+			"audio/opus":  ".ogg",
+			"audio/mpeg":  ".mp3",
+			"audio/mp4":   ".m4a",
+			"audio/x-m4a": ".m4a",
+			"audio/aac":   ".aac",
+			"audio/amr":   ".amr",
+		}
+	}
+	ext, ok := exts[strings.ToLower(ctype)]
+	return ext, ok
+}
+
+func (a *API) submitChatReport(ctx context.Context, sess models.ChatSession) (models.Report, []models.DuplicateCandidate, error) {
+	cat, ok := models.CategoryByCode(sess.Category)
+	if !ok {
+		return models.Report{}, nil, errors.New("session has no category")
+	}
+	var p ReportJSON
+	if sess.HasPin {
+		p = ReportJSON{
+			Category:  cat.Code,
+			Note:      sess.Note,
+			AreaLabel: sess.AreaLabel,
+			Lat:       sess.Lat,
+			Lng:       sess.Lng,
+			Anonymous: true,
+			GeoMethod: "shared_location",
+		}
+	} else {
+		d, ok := models.DistrictByName(sess.District)
+		if !ok {
+			return models.Report{}, nil, errors.New("session has no location")
+		}
+		var cf *models.Chiefdom
+		if ch, ok := d.ChiefdomByName(sess.Chiefdom); ok {
+			cf = &ch
+		}
+		p = gazetteerReport(cat, d, cf, sess.Note)
+	}
+
+	doc, err := reportFromJSON(p)
+	if err != nil {
+		return models.Report{}, nil, err
+	}
+	doc.Source = models.SourceChat
+	doc.VoiceURL = sess.VoiceURL
+	doc.PhotoURLs = sess.PhotoURLs
+	// why: on failure the staged files stay put so the sender can retry
+	return a.insertReport(ctx, doc, audit.Actor{ID: models.SourceChat, Role: audit.RoleGateway}, a.chatMedia(sess))
+}
+
+// chatMedia returns the session's staged attachments as a batch.
+func (a *API) chatMedia(sess models.ChatSession) *media.Batch {
+	urls := append([]string{}, sess.PhotoURLs...)
+	if sess.VoiceURL != "" {
+		urls = append(urls, sess.VoiceURL)
+	}
+	return a.Media.Resume(urls)
+}
+
+// discardChatMedia removes the staged files of a session that won't become
+// a report.
+func (a *API) discardChatMedia(sess models.ChatSession) {
+	a.chatMedia(sess).Rollback()
+}
+
+func chatSessionID(from string) string {
+	sum := sha256.Sum256([]byte("chat:" + strings.TrimSpace(from)))
+	return hex.EncodeToString(sum[:])
+}
+
+func (a *API) loadChatSession(ctx context.Context, from string) (models.ChatSession, error) {
+	id := chatSessionID(from)
+	sess, err := a.ChatSessions.Get(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.ChatSession{ID: id}, nil
+	}
+	if err != nil {
+		return sess, err
+	}
+	if time.Since(sess.UpdatedAt) > chatSessionTTL {
+		// stale conversation: start fresh rather than resume mid-flow
+		a.discardChatMedia(sess)
+		return models.ChatSession{ID: id}, nil
+	}
+	return sess, nil
+}
+
+func (a *API) saveChatSession(ctx context.Context, sess models.ChatSession) error {
+	sess.UpdatedAt = time.Now().UTC()
+	return a.ChatSessions.Save(ctx, sess)
+}
@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"meniba/events"
+	"meniba/media"
+	"meniba/models"
+	"meniba/repository"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// fakeChat is a messaging.Provider that records replies and serves media
+// from a map keyed by media ID.
+type fakeChat struct {
+	sent  []string
+	media map[string]string // id -> body; served as image/jpeg
+}
+
+func (f *fakeChat) Send(_ context.Context, _, text string) error {
+	f.sent = append(f.sent, text)
+	return nil
+}
+
+func (f *fakeChat) Download(_ context.Context, ref string) (io.ReadCloser, string, error) {
+	body, ok := f.media[ref]
+	if !ok {
+		return nil, "", errors.New("no such media")
+	}
+	return io.NopCloser(strings.NewReader(body)), "image/jpeg", nil
+}
+
+func newChatTestAPI(t *testing.T) (*API, *fakeChat, *fiber.App) {
+	t.Helper()
+	t.Setenv("CHAT_WEBHOOK_TOKEN", "secret")
+	chat := &fakeChat{media: map[string]string{"m1": "jpeg-bytes"}}
+	a := &API{
+		Reports:      repository.NewMemory(),
+		Bus:          events.NewBus(10),
+		Media:        media.NewStore(filepath.Join(t.TempDir(), "uploads"), "/uploads"),
+		Chat:         chat,
+		ChatSessions: repository.NewMemoryChatSessions(),
+	}
+	app := fiber.New()
+	app.Post("/api/inbound/messages", a.HandleInboundMessage)
+	return a, chat, app
+}
+
+func sendChat(t *testing.T, app *fiber.App, msg map[string]any) ChatReplyResp {
+	t.Helper()
+	body, _ := json.Marshal(msg)
+	req := httptest.NewRequest("POST", "/api/inbound/messages", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gateway-Token", "secret")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status %d for %v", resp.StatusCode, msg)
+	}
+	var out ChatReplyResp
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestChatIntakeFlow(t *testing.T) {
+	a, chat, app := newChatTestAPI(t)
+	from := "+23276000001"
+	ctx := context.Background()
+
+	sendChat(t, app, map[string]any{"message_id": "1", "from": from, "type": "text", "text": "hi"})
+	sendChat(t, app, map[string]any{"message_id": "2", "from": from, "type": "text", "text": "1"})
+	sendChat(t, app, map[string]any{"message_id": "3", "from": from, "type": "location",
+		"location": map[string]any{"lat": 8.4844, "lng": -13.2344, "name": "Kissy Road"}})
+	sendChat(t, app, map[string]any{"message_id": "4", "from": from, "type": "text", "text": "Burst pipe flooding the road"})
+
+	out := sendChat(t, app, map[string]any{"message_id": "5", "from": from, "type": "image",
+		"media": map[string]any{"id": "m1", "mime_type": "image/jpeg"}})
+	if len(out.Replies) != 1 || out.Replies[0] != "Photo received." {
+		t.Fatalf("photo replies = %q", out.Replies)
+	}
+	sess, err := a.ChatSessions.Get(ctx, chatSessionID(from))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.Step != models.ChatStepConfirm || len(sess.PhotoURLs) != 1 {
+		t.Fatalf("session = %+v", sess)
+	}
+	if _, ok := a.Media.Path(sess.PhotoURLs[0]); !ok {
+		t.Fatalf("photo URL %q not owned by the store", sess.PhotoURLs[0])
+	}
+	if files, _ := a.Media.List(); len(files) != 0 {
+		t.Fatalf("photo served before submit: %v", files)
+	}
+
+	// a retried webhook is ignored
+	if out := sendChat(t, app, map[string]any{"message_id": "5", "from": from, "type": "image",
+		"media": map[string]any{"id": "m1"}}); len(out.Replies) != 0 {
+		t.Fatalf("retry replies = %q", out.Replies)
+	}
+
+	out = sendChat(t, app, map[string]any{"message_id": "6", "from": from, "type": "text", "text": "1"})
+	if len(out.Replies) != 1 || !strings.HasPrefix(out.Replies[0], "Thank you. Report ") {
+		t.Fatalf("submit replies = %q", out.Replies)
+	}
+	if len(chat.sent) == 0 || chat.sent[len(chat.sent)-1] != out.Replies[0] {
+		t.Fatalf("receipt not sent through the provider: %q", chat.sent)
+	}
+	if _, err := a.ChatSessions.Get(ctx, chatSessionID(from)); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("session not cleared: %v", err)
+	}
+
+	reports, err := a.Reports.List(ctx, repository.ReportQuery{})
+	if err != nil || len(reports) != 1 {
+		t.Fatalf("reports = %d, %v", len(reports), err)
+	}
+	r := reports[0]
+	if r.Source != models.SourceChat || r.Category != models.Categories[0].Code ||
+		r.Note != "Burst pipe flooding the road" || r.AreaLabel != "Kissy Road" || r.Lat != 8.4844 {
+		t.Fatalf("report = %+v", r)
+	}
+	if len(r.PhotoURLs) != 1 || r.PhotoURLs[0] != sess.PhotoURLs[0] {
+		t.Fatalf("photos = %q", r.PhotoURLs)
+	}
+	p, _ := a.Media.Path(r.PhotoURLs[0])
+	if b, err := os.ReadFile(p); err != nil || string(b) != "jpeg-bytes" {
+		t.Fatalf("committed photo = %q, %v", b, err)
+	}
+}
+
+func TestChatIntakeGazetteerAndCancel(t *testing.T) {
+	a, _, app := newChatTestAPI(t)
+	from := "+23276000002"
+	ctx := context.Background()
+
+	sendChat(t, app, map[string]any{"message_id": "1", "from": from, "type": "text", "text": "hello"})
+	if out := sendChat(t, app, map[string]any{"message_id": "2", "from": from, "type": "text", "text": "nonsense"}); !strings.HasPrefix(out.Replies[0], "Sorry") {
+		t.Fatalf("bad category replies = %q", out.Replies)
+	}
+	sendChat(t, app, map[string]any{"message_id": "3", "from": from, "type": "text", "text": "2"})
+	sendChat(t, app, map[string]any{"message_id": "4", "from": from, "type": "text", "text": "Bo Kakua"})
+	sess, _ := a.ChatSessions.Get(ctx, chatSessionID(from))
+	if sess.HasPin || sess.District != "Bo" || sess.Chiefdom != "Kakua" || sess.Step != models.ChatStepDescription {
+		t.Fatalf("session = %+v", sess)
+	}
+
+	sendChat(t, app, map[string]any{"message_id": "5", "from": from, "type": "image",
+		"media": map[string]any{"id": "m1", "mime_type": "text/html"}})
+	sess, _ = a.ChatSessions.Get(ctx, chatSessionID(from))
+	if len(sess.PhotoURLs) != 0 {
+		t.Fatalf("html attachment accepted: %q", sess.PhotoURLs)
+	}
+	sendChat(t, app, map[string]any{"message_id": "6", "from": from, "type": "image",
+		"media": map[string]any{"id": "m1"}})
+	if staged, _ := a.Media.Staged(); len(staged) != 1 {
+		t.Fatalf("staged = %v", staged)
+	}
+
+	sendChat(t, app, map[string]any{"message_id": "7", "from": from, "type": "text", "text": "cancel"})
+	if _, err := a.ChatSessions.Get(ctx, chatSessionID(from)); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("session not cleared: %v", err)
+	}
+	if staged, _ := a.Media.Staged(); len(staged) != 0 {
+		t.Fatalf("staged files left after cancel: %v", staged)
+	}
+	if n, _ := a.Reports.Count(ctx, repository.ReportQuery{}); n != 0 {
+		t.Fatalf("cancelled chat created %d report(s)", n)
+	}
+}
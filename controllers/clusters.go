@@ -0,0 +1,76 @@
+// path: controllers/clusters.go
+package controllers
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"meniba/repository"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// cellsPerTile sets cluster density: a 256px map tile is split into this
+// many cells per side (~64px apart).
+const cellsPerTile = 4
+
+type ClusterListResp struct {
+	OK       bool                 `json:"ok"`
+	Zoom     int                  `json:"zoom"`
+	CellDeg  float64              `json:"cell_deg"`
+	Total    int64                `json:"total"`
+	Clusters []repository.Cluster `json:"clusters"`
+}
+
+// HandleReportClusters groups the reports in a viewport into grid cells
+// for zoomed-out maps. It takes the list filters; bbox and zoom are
+// required. Points are privacy-snapped (see repository.PublicPosition).
+// GET /api/reports/clusters?bbox=&zoom=
+func (a *API) HandleReportClusters(c *fiber.Ctx) error {
+	q, err := reportQuery(c)
+	if err != nil {
+		return badReq(c, err.Error())
+	}
+	if q.Bbox == nil {
+		return badReq(c, "missing bbox")
+	}
+	zoom, err := strconv.Atoi(c.Query("zoom"))
+	if err != nil || zoom < 0 || zoom > 22 {
+		return badReq(c, "invalid zoom (0-22)")
+	}
+	cellDeg := clusterCellDeg(zoom)
+
+	ctx, cancel := context.WithTimeout(c.Context(), 10*time.Second)
+	defer cancel()
+
+	clusters, err := a.Reports.Clusters(ctx, q, cellDeg)
+	if err != nil {
+		return serverErr(c, err)
+	}
+	var total int64
+	for _, cl := range clusters {
+		total += cl.Count
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		if clusters[i].Count != clusters[j].Count {
+			return clusters[i].Count > clusters[j].Count
+		}
+		return clusters[i].Lat < clusters[j].Lat
+	})
+
+	return c.Status(fiber.StatusOK).JSON(ClusterListResp{
+		OK:       true,
+		Zoom:     zoom,
+		CellDeg:  cellDeg,
+		Total:    total,
+		Clusters: clusters,
+	})
+}
+
+// clusterCellDeg is the cluster grid size at a map zoom level.
+func clusterCellDeg(zoom int) float64 {
+	return 360 / math.Pow(2, float64(zoom)) / cellsPerTile
+}
@@ -0,0 +1,85 @@
+// path: controllers/comments.go
+package controllers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"meniba/audit"
+	"meniba/events"
+	"meniba/models"
+	"meniba/repository"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type CommentListResp struct {
+	OK    bool             `json:"ok"`
+	Items []models.Comment `json:"items"`
+}
+
+// HandleAddComment attaches a moderator comment to a report.
+// POST /api/admin/reports/:id/comments {"body": "...", "public": true}
+func (a *API) HandleAddComment(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return badReq(c, "invalid id")
+	}
+	var p models.CommentCreatePayload
+	if err := c.BodyParser(&p); err != nil {
+		return badReq(c, "invalid JSON")
+	}
+	if strings.TrimSpace(p.Body) == "" {
+		return badReq(c, "missing body")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+
+	report, err := a.Reports.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return notFound(c, "report not found")
+		}
+		return serverErr(c, err)
+	}
+
+	cm := models.Comment{
+		ReportID:  id,
+		Body:      strings.TrimSpace(p.Body),
+		Author:    strings.TrimSpace(p.Author),
+		Public:    p.Public,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := a.Comments.Insert(ctx, &cm); err != nil {
+		return serverErr(c, err)
+	}
+	a.recordAudit(ctx, a.actor(c), audit.Entry{Action: audit.ReportComment, Target: "report", TargetID: id, After: cm})
+
+	a.Bus.Publish(events.Event{Type: events.CommentAdded, ReportID: id.Hex(), Report: &report, Data: cm})
+	return c.Status(fiber.StatusOK).JSON(cm)
+}
+
+// HandleListComments returns the public comments on a report.
+// GET /api/reports/:id/comments
+func (a *API) HandleListComments(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return badReq(c, "invalid id")
+	}
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+
+	items, err := a.publicComments(ctx, id)
+	if err != nil {
+		return serverErr(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(CommentListResp{OK: true, Items: items})
+}
+
+func (a *API) publicComments(ctx context.Context, reportID primitive.ObjectID) ([]models.Comment, error) {
+	return a.Comments.Public(ctx, reportID, 200)
+}
@@ -0,0 +1,102 @@
+// path: controllers/density.go
+package controllers
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	densityMaxPrecision = 7     // ~150 m cells
+	densityMaxCells     = 50000 // bbox / cell-size guard
+)
+
+type DensityCell struct {
+	Geohash string     `json:"geohash"`
+	Count   int        `json:"count"`
+	Lat     float64    `json:"lat"` // cell centre
+	Lng     float64    `json:"lng"`
+	Bbox    [4]float64 `json:"bbox"` // minLng,minLat,maxLng,maxLat
+}
+
+type DensityResp struct {
+	OK              bool          `json:"ok"`
+	Precision       int           `json:"precision"`
+	MinCount        int           `json:"min_count"`
+	Total           int           `json:"total"` // reports in published cells
+	SuppressedCells int           `json:"suppressed_cells"`
+	Cells           []DensityCell `json:"cells"`
+}
+
+// HandleReportDensity counts reports per geohash cell over a bbox. Cells
+// with fewer than min_count reports are left out (k-anonymity); the floor
+// is DENSITY_MIN_COUNT (default 5) and callers may only raise it. Takes
+// the list filters, e.g. start_date/end_date and category.
+// GET /api/reports/density?bbox=&precision=5&min_count=
+func (a *API) HandleReportDensity(c *fiber.Ctx) error {
+	q, err := reportQuery(c)
+	if err != nil {
+		return badReq(c, err.Error())
+	}
+	if q.Bbox == nil {
+		return badReq(c, "missing bbox")
+	}
+	precision := 5
+	if v := c.Query("precision"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > densityMaxPrecision {
+			return badReq(c, "invalid precision (1-"+strconv.Itoa(densityMaxPrecision)+")")
+		}
+		precision = n
+	}
+	cellLng, cellLat := geohashCellSize(precision)
+	if (q.Bbox.MaxLng-q.Bbox.MinLng)/cellLng*(q.Bbox.MaxLat-q.Bbox.MinLat)/cellLat > densityMaxCells {
+		return badReq(c, "bbox too large for this precision")
+	}
+	floor := max(1, int(envFloat("DENSITY_MIN_COUNT", 5)))
+	minCount := floor
+	if v := c.Query("min_count"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < floor {
+			return badReq(c, "min_count must be a number >= "+strconv.Itoa(floor))
+		}
+		minCount = n
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 15*time.Second)
+	defer cancel()
+
+	lngBits, latBits := geohashBits(precision)
+	grid, err := a.Reports.Grid(ctx, q, lngBits, latBits)
+	if err != nil {
+		return serverErr(c, err)
+	}
+	if len(grid) > densityMaxCells {
+		return badReq(c, "bbox too large for this precision")
+	}
+
+	resp := DensityResp{OK: true, Precision: precision, MinCount: minCount, Cells: make([]DensityCell, 0, len(grid))}
+	for _, g := range grid {
+		n := int(g.N)
+		if n < minCount {
+			resp.SuppressedCells++
+			continue
+		}
+		h := geohashFromCell(g.X, g.Y, precision)
+		minLat, minLng, maxLat, maxLng := geohashBounds(h)
+		resp.Cells = append(resp.Cells, DensityCell{
+			Geohash: h,
+			Count:   n,
+			Lat:     (minLat + maxLat) / 2,
+			Lng:     (minLng + maxLng) / 2,
+			Bbox:    [4]float64{minLng, minLat, maxLng, maxLat},
+		})
+		resp.Total += n
+	}
+	sort.Slice(resp.Cells, func(i, j int) bool { return resp.Cells[i].Geohash < resp.Cells[j].Geohash })
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
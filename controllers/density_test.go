@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"meniba/models"
+	"meniba/repository"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestReportDensitySuppressesSmallCells(t *testing.T) {
+	t.Setenv("DENSITY_MIN_COUNT", "3")
+	a := &API{Reports: repository.NewMemory()}
+	app := fiber.New()
+	app.Get("/api/reports/density", a.HandleReportDensity)
+	ctx := context.Background()
+
+	add := func(n int, lat, lng float64) {
+		for i := 0; i < n; i++ {
+			if err := a.Reports.Create(ctx, &models.Report{Category: "water", Lat: lat, Lng: lng, CreatedAt: time.Now().UTC()}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	add(4, 6.5244, 3.3792) // Lagos: above k
+	add(2, 6.4550, 3.5000) // Lekki: below k
+	add(3, 6.6000, 3.3500) // Ikeja: exactly k
+
+	get := func(target string) (int, DensityResp) {
+		t.Helper()
+		resp, err := app.Test(httptest.NewRequest("GET", target, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out DensityResp
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	st, out := get("/api/reports/density?bbox=3.2,6.3,3.6,6.7&precision=5")
+	if st != fiber.StatusOK {
+		t.Fatalf("status %d", st)
+	}
+	if out.MinCount != 3 || out.SuppressedCells != 1 || out.Total != 7 || len(out.Cells) != 2 {
+		t.Fatalf("density = %+v", out)
+	}
+	for _, cell := range out.Cells {
+		if cell.Count < 3 {
+			t.Errorf("cell %s published with %d reports", cell.Geohash, cell.Count)
+		}
+		minLat, minLng, maxLat, maxLng := geohashBounds(cell.Geohash)
+		if cell.Bbox != [4]float64{minLng, minLat, maxLng, maxLat} {
+			t.Errorf("cell %s bbox %v", cell.Geohash, cell.Bbox)
+		}
+	}
+
+	// raising k hides the three-report cell too; lowering it is refused
+	if _, out := get("/api/reports/density?bbox=3.2,6.3,3.6,6.7&precision=5&min_count=4"); out.SuppressedCells != 2 || len(out.Cells) != 1 || out.Cells[0].Count != 4 {
+		t.Fatalf("min_count=4: %+v", out)
+	}
+	if st, _ := get("/api/reports/density?bbox=3.2,6.3,3.6,6.7&precision=5&min_count=2"); st != fiber.StatusBadRequest {
+		t.Fatalf("min_count below the floor = %d", st)
+	}
+}
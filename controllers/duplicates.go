@@ -0,0 +1,282 @@
+// path: controllers/duplicates.go
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"meniba/audit"
+	"meniba/events"
+	"meniba/models"
+	"meniba/repository"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// dupConfig controls near-duplicate detection at submission time.
+type dupConfig struct {
+	RadiusM  float64       // max distance between two reports of the same issue
+	Window   time.Duration // how far back we look for candidates
+	MinScore float64       // combined proximity/text score needed to flag
+	Max      int           // max candidates returned to the app
+}
+
+func loadDupConfig() dupConfig {
+	return dupConfig{
+		RadiusM:  envFloat("DUP_RADIUS_M", 200),
+		Window:   time.Duration(envFloat("DUP_WINDOW_HOURS", 72) * float64(time.Hour)),
+		MinScore: envFloat("DUP_MIN_SCORE", 0.45),
+		Max:      int(envFloat("DUP_MAX", 5)),
+	}
+}
+
+// findDuplicates returns recent reports of the same category near doc whose
+// combined proximity and note similarity is high enough to be the same issue.
+func (a *API) findDuplicates(ctx context.Context, doc models.Report) ([]models.DuplicateCandidate, []primitive.ObjectID, error) {
+	cfg := loadDupConfig()
+	if cfg.RadiusM <= 0 || cfg.Window <= 0 || cfg.Max <= 0 {
+		return nil, nil, nil
+	}
+
+	minLat, minLng, maxLat, maxLng := boxAround(doc.Lat, doc.Lng, cfg.RadiusM)
+	since := doc.CreatedAt.Add(-cfg.Window)
+	recent, err := a.Reports.List(ctx, repository.ReportQuery{
+		Category:    repository.Terms{In: []string{doc.Category}},
+		CreatedFrom: &since,
+		Bbox:        &repository.Bbox{MinLng: minLng, MinLat: minLat, MaxLng: maxLng, MaxLat: maxLat},
+		Limit:       200,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tokens := noteTokens(doc.Note)
+	var out []models.DuplicateCandidate
+	var ids []primitive.ObjectID
+	for _, r := range recent {
+		d := haversineM(doc.Lat, doc.Lng, r.Lat, r.Lng)
+		if d > cfg.RadiusM {
+			continue
+		}
+		sim := jaccard(tokens, noteTokens(r.Note))
+		// why: proximity alone is weak in dense markets; text alone is weak
+		// for generic notes ("no water"). Weight text a little higher.
+		score := 0.4*(1-d/cfg.RadiusM) + 0.6*sim
+		if score < cfg.MinScore {
+			continue
+		}
+		out = append(out, models.DuplicateCandidate{
+			ID:         r.ID.Hex(),
+			Category:   r.Category,
+			Note:       r.Note,
+			AreaLabel:  r.AreaLabel,
+			DistanceM:  int(math.Round(d)),
+			Similarity: math.Round(sim*100) / 100,
+			Score:      math.Round(score*100) / 100,
+			CreatedAt:  r.CreatedAt.UTC().Format(time.RFC3339),
+		})
+	}
+
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	if len(out) > cfg.Max {
+		out = out[:cfg.Max]
+	}
+	for _, cand := range out {
+		if oid, err := primitive.ObjectIDFromHex(cand.ID); err == nil {
+			ids = append(ids, oid)
+		}
+	}
+	return out, ids, nil
+}
+
+// HandleMergeReports folds duplicate reports into a canonical one.
+// POST /api/admin/reports/:id/merge {"duplicate_ids": ["..."]}
+func (a *API) HandleMergeReports(c *fiber.Ctx) error {
+	canonical, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return badReq(c, "invalid id")
+	}
+	var req models.MergeReportsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return badReq(c, "invalid JSON")
+	}
+	if len(req.DuplicateIDs) == 0 {
+		return badReq(c, "missing duplicate_ids")
+	}
+	dupIDs := make([]primitive.ObjectID, 0, len(req.DuplicateIDs))
+	for _, s := range req.DuplicateIDs {
+		oid, err := primitive.ObjectIDFromHex(strings.TrimSpace(s))
+		if err != nil {
+			return badReq(c, "invalid duplicate id: "+s)
+		}
+		if oid == canonical {
+			return badReq(c, "cannot merge a report into itself")
+		}
+		if !containsObjectID(dupIDs, oid) {
+			dupIDs = append(dupIDs, oid)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+
+	target, err := a.Reports.Get(ctx, canonical)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return notFound(c, "report not found")
+		}
+		return serverErr(c, err)
+	}
+	if target.DuplicateOf != nil {
+		return badReq(c, "canonical report is itself merged into "+target.DuplicateOf.Hex())
+	}
+
+	now := time.Now().UTC()
+	who := a.actor(c)
+	var merged []primitive.ObjectID
+	// what each merged report had collected itself: its own merged
+	// reports and their confirmations move to the canonical with it
+	carried := map[primitive.ObjectID]models.Report{}
+	for _, id := range dupIDs {
+		_, err := a.updateReport(ctx, who, audit.ReportMerge, id, func(r *models.Report) error {
+			delete(carried, id) // a retry may find it merged meanwhile
+			if r.DuplicateOf != nil {
+				return repository.ErrNoChange
+			}
+			carried[id] = models.Report{MergedIDs: r.MergedIDs, Confirmations: r.Confirmations}
+			r.DuplicateOf = &canonical
+			r.MergedAt = &now
+			r.MergedIDs = nil
+			r.Confirmations = 0
+			return nil
+		})
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return serverErr(c, err)
+		}
+		if _, ok := carried[id]; ok && err == nil {
+			merged = append(merged, id)
+		}
+	}
+	// why: anything previously merged into one of these now points at the
+	// new canonical so we never build chains.
+	chained, err := a.Reports.List(ctx, repository.ReportQuery{DuplicateOf: dupIDs, IncludeWithdrawn: true})
+	if err != nil {
+		return serverErr(c, err)
+	}
+	touched := append([]primitive.ObjectID{canonical}, merged...)
+	var rehomed []primitive.ObjectID
+	for _, r := range chained {
+		if _, err := a.updateReport(ctx, who, audit.ReportMerge, r.ID, func(r *models.Report) error {
+			r.DuplicateOf = &canonical
+			r.MergedAt = &now
+			return nil
+		}); err != nil {
+			return serverErr(c, err)
+		}
+		touched = append(touched, r.ID)
+		rehomed = append(rehomed, r.ID)
+	}
+
+	if _, err := a.updateReport(ctx, who, audit.ReportMerge, canonical, func(r *models.Report) error {
+		add := func(id primitive.ObjectID) {
+			if id != canonical && !containsObjectID(r.MergedIDs, id) {
+				r.MergedIDs = append(r.MergedIDs, id)
+			}
+		}
+		for _, id := range merged {
+			add(id)
+			for _, sub := range carried[id].MergedIDs {
+				add(sub)
+			}
+			r.Confirmations += 1 + carried[id].Confirmations
+		}
+		for _, id := range rehomed {
+			add(id)
+		}
+		kept := r.PossibleDuplicates[:0]
+		for _, id := range r.PossibleDuplicates {
+			if !containsObjectID(dupIDs, id) {
+				kept = append(kept, id)
+			}
+		}
+		r.PossibleDuplicates = kept
+		return nil
+	}); err != nil {
+		return serverErr(c, err)
+	}
+
+	a.publishUpdated(ctx, touched)
+
+	return c.Status(fiber.StatusOK).JSON(models.MergeReportsResp{
+		OK:     true,
+		ID:     canonical.Hex(),
+		Merged: int64(len(merged)),
+	})
+}
+
+// publishUpdated re-reads the given reports and emits report.updated for
+// each, so in-process subscribers see moderation changes.
+func (a *API) publishUpdated(ctx context.Context, ids []primitive.ObjectID) {
+	docs, err := a.Reports.List(ctx, repository.ReportQuery{IDs: ids, IncludeMerged: true, IncludeWithdrawn: true})
+	if err != nil {
+		log.Printf("events: reload for publish failed: %v", err)
+		return
+	}
+	for i := range docs {
+		doc := docs[i]
+		a.Bus.Publish(events.Event{Type: events.ReportUpdated, ReportID: doc.ID.Hex(), Report: &doc})
+	}
+}
+
+func containsObjectID(list []primitive.ObjectID, id primitive.ObjectID) bool {
+	for _, x := range list {
+		if x == id {
+			return true
+		}
+	}
+	return false
+}
+
+// --- text similarity ---
+
+var dupStopwords = map[string]bool{
+	"the": true, "a": true, "an": true, "and": true, "or": true, "of": true,
+	"in": true, "on": true, "at": true, "to": true, "is": true, "are": true,
+	"for": true, "near": true, "by": true, "with": true, "it": true, "this": true,
+	"there": true, "has": true, "have": true, "no": true, "not": true,
+}
+
+// noteTokens lower-cases and splits a note into a set of meaningful words.
+func noteTokens(s string) map[string]bool {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		if len(w) < 3 || dupStopwords[w] {
+			continue
+		}
+		set[w] = true
+	}
+	return set
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	inter := 0
+	for w := range a {
+		if b[w] {
+			inter++
+		}
+	}
+	return float64(inter) / float64(len(a)+len(b)-inter)
+}
@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"meniba/events"
+	"meniba/models"
+	"meniba/repository"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestNoteTokens(t *testing.T) {
+	got := noteTokens("The WATER pump near Kissy-Road is broken; no water at 3pm!")
+	want := []string{"water", "pump", "kissy", "road", "broken", "3pm"}
+	if len(got) != len(want) {
+		t.Fatalf("tokens = %v", got)
+	}
+	for _, w := range want {
+		if !got[w] {
+			t.Errorf("missing %q in %v", w, got)
+		}
+	}
+	if len(noteTokens("a an of to is it")) != 0 {
+		t.Error("stopwords and short words kept")
+	}
+}
+
+func TestJaccard(t *testing.T) {
+	set := func(ws ...string) map[string]bool {
+		m := map[string]bool{}
+		for _, w := range ws {
+			m[w] = true
+		}
+		return m
+	}
+	for _, tc := range []struct {
+		a, b map[string]bool
+		want float64
+	}{
+		{set("water", "pump"), set("water", "pump"), 1},
+		{set("water", "pump"), set("water", "road"), 1.0 / 3},
+		{set("water"), set("road"), 0},
+		{set(), set("road"), 0},
+		{set(), set(), 0},
+	} {
+		if got := jaccard(tc.a, tc.b); math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("jaccard(%v, %v) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestFindDuplicatesScoring(t *testing.T) {
+	t.Setenv("DUP_RADIUS_M", "200")
+	t.Setenv("DUP_WINDOW_HOURS", "72")
+	t.Setenv("DUP_MIN_SCORE", "0.45")
+	t.Setenv("DUP_MAX", "5")
+	a := &API{Reports: repository.NewMemory()}
+	ctx := context.Background()
+	now := time.Now().UTC()
+	const lat, lng = 8.4844, -13.2344
+	mPerDegLat := 111195.08
+	add := func(cat, note string, northM float64, age time.Duration) primitive.ObjectID {
+		r := &models.Report{Category: cat, Note: note, Lat: lat + northM/mPerDegLat, Lng: lng, CreatedAt: now.Add(-age)}
+		if err := a.Reports.Create(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+		return r.ID
+	}
+	same := add("water", "burst pipe flooding road", 10, time.Hour) // close, same words
+	add("water", "burst pipe flooding road", 150, 100*time.Hour)    // outside the window
+	far := add("water", "burst pipe flooding road", 180, time.Hour) // text carries it
+	add("water", "street light broken", 20, time.Hour)              // close but unrelated text
+	add("roads", "burst pipe flooding road", 5, time.Hour)          // other category
+	add("water", "burst pipe flooding road", 400, time.Hour)        // out of radius
+
+	cands, ids, err := a.findDuplicates(ctx, models.Report{Category: "water", Note: "Pipe burst, road flooding", Lat: lat, Lng: lng, CreatedAt: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cands) != 2 || ids[0] != same || ids[1] != far {
+		t.Fatalf("candidates = %+v", cands)
+	}
+	// proximity 0.4*(1-10/200) + text 0.6*1
+	if c := cands[0]; c.Similarity != 1 || c.DistanceM != 10 || c.Score != 0.98 {
+		t.Fatalf("best = %+v", c)
+	}
+	if cands[1].Score >= cands[0].Score {
+		t.Fatal("candidates not sorted by score")
+	}
+}
+
+func mergeReports(t *testing.T, app *fiber.App, canonical primitive.ObjectID, dups ...primitive.ObjectID) int {
+	t.Helper()
+	var ids []string
+	for _, id := range dups {
+		ids = append(ids, id.Hex())
+	}
+	body, _ := json.Marshal(models.MergeReportsRequest{DuplicateIDs: ids})
+	req := httptest.NewRequest("POST", "/api/admin/reports/"+canonical.Hex()+"/merge", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode
+}
+
+func TestMergeReportsTransitive(t *testing.T) {
+	a := &API{Reports: repository.NewMemory(), Bus: events.NewBus(50)}
+	app := fiber.New()
+	app.Post("/api/admin/reports/:id/merge", a.HandleMergeReports)
+	ctx := context.Background()
+	mk := func(confirmations int) primitive.ObjectID {
+		r := &models.Report{Category: "water", Confirmations: confirmations, CreatedAt: time.Now().UTC()}
+		if err := a.Reports.Create(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+		return r.ID
+	}
+	get := func(id primitive.ObjectID) models.Report {
+		r, err := a.Reports.Get(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	repA, repB, repC, repD := mk(2), mk(0), mk(1), mk(0)
+
+	// A → B
+	if st := mergeReports(t, app, repB, repA); st != fiber.StatusOK {
+		t.Fatalf("merge A into B = %d", st)
+	}
+	b := get(repB)
+	if b.Confirmations != 3 || len(b.MergedIDs) != 1 || b.MergedIDs[0] != repA {
+		t.Fatalf("B after A = %+v", b)
+	}
+
+	// B, D → C: A moves with B
+	if st := mergeReports(t, app, repC, repB, repD, repB); st != fiber.StatusOK {
+		t.Fatalf("merge B, D into C = %d", st)
+	}
+	c := get(repC)
+	if c.Confirmations != 1+(1+3)+(1+0) {
+		t.Fatalf("C confirmations = %d", c.Confirmations)
+	}
+	if len(c.MergedIDs) != 3 || !containsObjectID(c.MergedIDs, repA) || !containsObjectID(c.MergedIDs, repB) || !containsObjectID(c.MergedIDs, repD) {
+		t.Fatalf("C merged ids = %v", c.MergedIDs)
+	}
+	b = get(repB)
+	if b.DuplicateOf == nil || *b.DuplicateOf != repC || b.MergedIDs != nil || b.Confirmations != 0 || b.MergedAt == nil {
+		t.Fatalf("B after merge = %+v", b)
+	}
+	ra := get(repA)
+	if ra.DuplicateOf == nil || *ra.DuplicateOf != repC || ra.MergedAt == nil {
+		t.Fatalf("A after merge = %+v", ra)
+	}
+
+	// merging again changes nothing; merging into a merged report fails
+	if st := mergeReports(t, app, repC, repB); st != fiber.StatusOK || get(repC).Confirmations != c.Confirmations {
+		t.Fatalf("re-merge = %d, confirmations %d", st, get(repC).Confirmations)
+	}
+	if st := mergeReports(t, app, repB, repD); st != fiber.StatusBadRequest {
+		t.Fatalf("merge into merged report = %d", st)
+	}
+	if st := mergeReports(t, app, repC, repC); st != fiber.StatusBadRequest {
+		t.Fatalf("self merge = %d", st)
+	}
+}
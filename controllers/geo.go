@@ -0,0 +1,29 @@
+// path: controllers/geo.go
+package controllers
+
+import "math"
+
+const earthRadiusM = 6371000.0
+
+// haversineM returns the great-circle distance between two points in meters.
+func haversineM(lat1, lng1, lat2, lng2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLng := (lng2 - lng1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusM * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// boxAround returns a lat/lng box that contains the circle of radiusM around
+// the point. why: lets Mongo prefilter on the plain lat/lng index before we
+// do exact distance checks in Go.
+func boxAround(lat, lng, radiusM float64) (minLat, minLng, maxLat, maxLng float64) {
+	dLat := radiusM / 111320.0
+	cos := math.Cos(lat * math.Pi / 180)
+	if cos < 0.01 {
+		cos = 0.01
+	}
+	dLng := radiusM / (111320.0 * cos)
+	return lat - dLat, lng - dLng, lat + dLat, lng + dLng
+}
@@ -0,0 +1,117 @@
+// path: controllers/geohash.go
+package controllers
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// geohashEncode returns the geohash of a point at the given precision
+// (number of characters).
+func geohashEncode(lat, lng float64, precision int) string {
+	minLat, maxLat := -90.0, 90.0
+	minLng, maxLng := -180.0, 180.0
+	out := make([]byte, 0, precision)
+	bit, ch := 0, 0
+	even := true // geohash interleaves bits starting with longitude
+	for len(out) < precision {
+		if even {
+			mid := (minLng + maxLng) / 2
+			if lng >= mid {
+				ch = ch<<1 | 1
+				minLng = mid
+			} else {
+				ch <<= 1
+				maxLng = mid
+			}
+		} else {
+			mid := (minLat + maxLat) / 2
+			if lat >= mid {
+				ch = ch<<1 | 1
+				minLat = mid
+			} else {
+				ch <<= 1
+				maxLat = mid
+			}
+		}
+		even = !even
+		if bit++; bit == 5 {
+			out = append(out, geohashAlphabet[ch])
+			bit, ch = 0, 0
+		}
+	}
+	return string(out)
+}
+
+// geohashBounds returns the box a geohash covers.
+func geohashBounds(hash string) (minLat, minLng, maxLat, maxLng float64) {
+	minLat, maxLat = -90, 90
+	minLng, maxLng = -180, 180
+	even := true
+	for i := 0; i < len(hash); i++ {
+		v := indexByte(geohashAlphabet, hash[i])
+		for b := 4; b >= 0; b-- {
+			on := v>>b&1 == 1
+			if even {
+				mid := (minLng + maxLng) / 2
+				if on {
+					minLng = mid
+				} else {
+					maxLng = mid
+				}
+			} else {
+				mid := (minLat + maxLat) / 2
+				if on {
+					minLat = mid
+				} else {
+					maxLat = mid
+				}
+			}
+			even = !even
+		}
+	}
+	return
+}
+
+// geohashBits returns how many of the 5p bits of a precision-p geohash
+// split longitude and latitude.
+func geohashBits(p int) (lngBits, latBits int) {
+	return (5*p + 1) / 2, 5 * p / 2
+}
+
+// geohashCellSize returns the width and height in degrees of a cell at
+// precision p.
+func geohashCellSize(p int) (lngDeg, latDeg float64) {
+	lngBits, latBits := geohashBits(p)
+	return 360 / float64(uint64(1)<<lngBits), 180 / float64(uint64(1)<<latBits)
+}
+
+// geohashFromCell returns the geohash of grid cell x (column from -180°),
+// y (row from -90°) in the grid geohashBits(precision) describes.
+func geohashFromCell(x, y int64, precision int) string {
+	lngBits, latBits := geohashBits(precision)
+	out := make([]byte, 0, precision)
+	bit, ch := 0, 0
+	even := true
+	for len(out) < precision {
+		if even {
+			lngBits--
+			ch = ch<<1 | int(x>>lngBits&1)
+		} else {
+			latBits--
+			ch = ch<<1 | int(y>>latBits&1)
+		}
+		even = !even
+		if bit++; bit == 5 {
+			out = append(out, geohashAlphabet[ch])
+			bit, ch = 0, 0
+		}
+	}
+	return string(out)
+}
+
+func indexByte(s string, c byte) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] == c {
+			return i
+		}
+	}
+	return -1
+}
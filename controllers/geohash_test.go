@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGeohashEncode(t *testing.T) {
+	cases := []struct {
+		lat, lng float64
+		p        int
+		want     string
+	}{
+		{57.64911, 10.40744, 11, "u4pruydqqvj"},
+		{57.64911, 10.40744, 5, "u4pru"},
+		{42.6, -5.6, 5, "ezs42"},
+		{-25.382708, -49.265506, 8, "6gkzwgjz"},
+		{0, 0, 1, "s"},
+		{-90, -180, 2, "00"},
+	}
+	for _, tc := range cases {
+		if got := geohashEncode(tc.lat, tc.lng, tc.p); got != tc.want {
+			t.Errorf("geohashEncode(%v, %v, %d) = %q, want %q", tc.lat, tc.lng, tc.p, got, tc.want)
+		}
+	}
+}
+
+func TestGeohashBounds(t *testing.T) {
+	minLat, minLng, maxLat, maxLng := geohashBounds("ezs42")
+	want := [4]float64{42.583007812, -5.625, 42.626953125, -5.581054688}
+	for i, got := range [4]float64{minLat, minLng, maxLat, maxLng} {
+		if math.Abs(got-want[i]) > 1e-6 {
+			t.Fatalf("geohashBounds(ezs42) = %v %v %v %v, want %v", minLat, minLng, maxLat, maxLng, want)
+		}
+	}
+
+	for _, pt := range [][2]float64{{57.64911, 10.40744}, {-33.8688, 151.2093}, {6.5244, 3.3792}} {
+		for p := 1; p <= densityMaxPrecision; p++ {
+			minLat, minLng, maxLat, maxLng := geohashBounds(geohashEncode(pt[0], pt[1], p))
+			if pt[0] < minLat || pt[0] > maxLat || pt[1] < minLng || pt[1] > maxLng {
+				t.Errorf("%v not inside its precision-%d cell", pt, p)
+			}
+			dLng, dLat := geohashCellSize(p)
+			if math.Abs(maxLng-minLng-dLng) > 1e-9 || math.Abs(maxLat-minLat-dLat) > 1e-9 {
+				t.Errorf("precision %d cell is %vx%v, geohashCellSize says %vx%v", p, maxLng-minLng, maxLat-minLat, dLng, dLat)
+			}
+		}
+	}
+}
+
+func TestGeohashFromCellAgreesWithEncode(t *testing.T) {
+	for _, pt := range [][2]float64{{57.64911, 10.40744}, {-33.8688, 151.2093}, {6.5244, 3.3792}, {-89.9, -179.9}} {
+		for p := 1; p <= densityMaxPrecision; p++ {
+			lngBits, latBits := geohashBits(p)
+			x := int64(math.Floor((pt[1] + 180) / 360 * float64(int64(1)<<lngBits)))
+			y := int64(math.Floor((pt[0] + 90) / 180 * float64(int64(1)<<latBits)))
+			if got, want := geohashFromCell(x, y, p), geohashEncode(pt[0], pt[1], p); got != want {
+				t.Errorf("%v at precision %d: from cell %q, encoded %q", pt, p, got, want)
+			}
+		}
+	}
+}
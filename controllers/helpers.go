@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
@@ -32,6 +33,9 @@ type ErrorResp struct {
 func badReq(c *fiber.Ctx, msg string) error {
 	return c.Status(fiber.StatusBadRequest).JSON(ErrorResp{OK: false, Error: msg})
 }
+func notFound(c *fiber.Ctx, msg string) error {
+	return c.Status(fiber.StatusNotFound).JSON(ErrorResp{OK: false, Error: msg})
+}
 func serverErr(c *fiber.Ctx, err error) error {
 	return c.Status(fiber.StatusInternalServerError).JSON(ErrorResp{OK: false, Error: err.Error()})
 }
@@ -54,6 +58,14 @@ func getenv(k, def string) string {
 	return def
 }
 
+// envFloat reads a numeric env var, falling back to def when unset/invalid.
+func envFloat(k string, def float64) float64 {
+	if v, err := strconv.ParseFloat(getenv(k, ""), 64); err == nil {
+		return v
+	}
+	return def
+}
+
 // randString returns a short, safe random string (hex) of length n.
 func randString(n int) string {
 	if n <= 0 {
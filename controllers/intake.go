@@ -0,0 +1,134 @@
+// path: controllers/intake.go
+package controllers
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"strings"
+
+	"meniba/audit"
+	"meniba/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Shared helpers for intake channels that don't speak our JSON API
+// (SMS, USSD, chat). They all end in reportFromJSON + insertReport.
+
+// gazetteerReport builds a report placed at the chiefdom centroid, or the
+// district centroid when the chiefdom is unknown. The privacy radius and
+// accuracy are widened to match how coarse that location is.
+func gazetteerReport(cat models.Category, d models.District, cf *models.Chiefdom, note string) ReportJSON {
+	lat, lng := d.Lat, d.Lng
+	area := d.Name
+	method := "district_centroid"
+	acc := 15000
+	if cf != nil {
+		lat, lng = cf.Lat, cf.Lng
+		area = cf.Name + ", " + d.Name
+		method = "chiefdom_centroid"
+		acc = 5000
+	}
+	radius := acc
+	p := ReportJSON{
+		Category:       cat.Code,
+		Note:           strings.TrimSpace(note),
+		AreaLabel:      area,
+		Lat:            lat,
+		Lng:            lng,
+		AccuracyM:      &acc,
+		PrivacyRadiusM: &radius,
+		Anonymous:      true,
+		District:       d.Name,
+		Region:         d.Region,
+		GeoMethod:      method,
+	}
+	if cf != nil {
+		p.Chiefdom = cf.Name
+	}
+	return p
+}
+
+// submitIntake validates p like the JSON API does and stores it.
+func (a *API) submitIntake(ctx context.Context, p ReportJSON, source string) (models.Report, []models.DuplicateCandidate, error) {
+	doc, err := reportFromJSON(p)
+	if err != nil {
+		return models.Report{}, nil, err
+	}
+	doc.Source = source
+	return a.insertReport(ctx, doc, audit.Actor{ID: source, Role: audit.RoleGateway}, nil)
+}
+
+// intakeReceipt is the short confirmation sent back over SMS/USSD/chat.
+func intakeReceipt(doc models.Report, dups []models.DuplicateCandidate) string {
+	msg := fmt.Sprintf("Thank you. Report %s received (%s, %s).", doc.ID.Hex(), models.CategoryName(doc.Category), doc.AreaLabel)
+	if len(dups) > 0 {
+		msg += " A similar issue was already reported nearby."
+	}
+	return msg
+}
+
+// gatewayAuth checks the shared secret a gateway is configured with,
+// sent as ?token= or X-Gateway-Token. It returns 0 if the callback may
+// proceed, or the status and message to reject it with: 503 while envKey
+// is unset, 401 on a wrong token. GATEWAY_AUTH=off skips the check for
+// local development against a fake gateway.
+func gatewayAuth(c *fiber.Ctx, envKey string) (int, string) {
+	if strings.EqualFold(getenv("GATEWAY_AUTH", ""), "off") {
+		return 0, ""
+	}
+	want := getenv(envKey, "")
+	if want == "" {
+		return fiber.StatusServiceUnavailable, "gateway not configured"
+	}
+	got := c.Get("X-Gateway-Token")
+	if got == "" {
+		got = c.Query("token")
+	}
+	if got == "" || subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+		return fiber.StatusUnauthorized, "unauthorized"
+	}
+	return 0, ""
+}
+
+// matchName finds the longest run of leading words that equals one of
+// names (case-insensitive), so "Port Loko ..." matches "Port Loko".
+func matchName(words, names []string) (string, int) {
+	best, bestN := "", 0
+	for _, name := range names {
+		n := len(strings.Fields(name))
+		if n == 0 || n > len(words) || n <= bestN {
+			continue
+		}
+		if strings.EqualFold(strings.Join(words[:n], " "), name) {
+			best, bestN = name, n
+		}
+	}
+	return best, bestN
+}
+
+// matchPlace reads a district name and an optional chiefdom of that
+// district from the start of words, returning the words left over.
+func matchPlace(words []string) (models.District, *models.Chiefdom, []string, bool) {
+	names := make([]string, 0, len(models.Districts))
+	for _, d := range models.Districts {
+		names = append(names, d.Name)
+	}
+	dname, n := matchName(words, names)
+	if n == 0 {
+		return models.District{}, nil, words, false
+	}
+	d, _ := models.DistrictByName(dname)
+	words = words[n:]
+
+	cnames := make([]string, 0, len(d.Chiefdoms))
+	for _, ch := range d.Chiefdoms {
+		cnames = append(cnames, ch.Name)
+	}
+	if cname, m := matchName(words, cnames); m > 0 {
+		ch, _ := d.ChiefdomByName(cname)
+		return d, &ch, words[m:], true
+	}
+	return d, nil, words, true
+}
@@ -0,0 +1,86 @@
+// path: controllers/media_gc.go
+package controllers
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"meniba/jobs"
+	"meniba/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// HandleMediaGCStats reports what is in quarantine, the space reclaimed
+// so far and the most recent collector runs.
+// GET /api/admin/media/gc?limit=
+func (a *API) HandleMediaGCStats(c *fiber.Ctx) error {
+	limit := 20
+	if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 && v <= 500 {
+		limit = v
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+
+	resp := models.MediaGCStats{OK: true, Runs: make([]models.MediaGCRun, 0, limit)}
+	held, err := a.Media.Quarantined()
+	if err != nil {
+		return serverErr(c, err)
+	}
+	for _, f := range held {
+		resp.PendingFiles++
+		resp.PendingBytes += f.Size
+	}
+
+	runs := a.DB.Col(jobs.MediaGCRunsCol)
+	cur, err := runs.Aggregate(ctx, bson.A{
+		bson.M{"$group": bson.M{
+			"_id":       nil,
+			"deleted":   bson.M{"$sum": "$deleted"},
+			"reclaimed": bson.M{"$sum": "$reclaimed_bytes"},
+		}},
+	})
+	if err != nil {
+		return serverErr(c, err)
+	}
+	var totals []struct {
+		Deleted   int64 `bson:"deleted"`
+		Reclaimed int64 `bson:"reclaimed"`
+	}
+	if err := cur.All(ctx, &totals); err != nil {
+		return serverErr(c, err)
+	}
+	if len(totals) > 0 {
+		resp.TotalDeleted, resp.TotalReclaimedBytes = totals[0].Deleted, totals[0].Reclaimed
+	}
+
+	cur, err = runs.Find(ctx, bson.M{},
+		options.Find().SetSort(bson.D{{Key: "started_at", Value: -1}}).SetLimit(int64(limit)))
+	if err != nil {
+		return serverErr(c, err)
+	}
+	if err := cur.All(ctx, &resp.Runs); err != nil {
+		return serverErr(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// HandleRunMediaGC runs the media garbage collector now.
+// POST /api/admin/media/gc/run
+func (a *API) HandleRunMediaGC(c *fiber.Ctx) error {
+	if a.MediaGC == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(ErrorResp{OK: false, Error: "media gc disabled"})
+	}
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Minute)
+	defer cancel()
+
+	run, err := a.MediaGC.Collect(ctx, time.Now())
+	if err != nil {
+		return serverErr(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(run)
+}
@@ -0,0 +1,103 @@
+// path: controllers/notifications.go
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"meniba/models"
+	"meniba/notify"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type NotificationListResp struct {
+	OK    bool                  `json:"ok"`
+	Items []models.Notification `json:"items"`
+}
+
+// unsubscribePage asks for confirmation. Mail scanners and link
+// previewers fetch links in emails, so GET must not opt anyone out.
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!doctype html>
+<html><body style="font-family: sans-serif; color: #222;">
+<p>Stop all Mineba emails to <b>{{.Email}}</b>?</p>
+<form method="post" action="{{.Action}}"><button type="submit">Unsubscribe</button></form>
+</body></html>
+`))
+
+// HandleUnsubscribe opts an address out of all notification emails. The
+// signed link in every email opens a confirmation page (GET); the opt-out
+// itself happens on POST, from that page or from mail clients that
+// unsubscribe in one click.
+// GET|POST /api/notifications/unsubscribe?email=&token=
+func (a *API) HandleUnsubscribe(c *fiber.Ctx) error {
+	if a.Notifier == nil {
+		return c.Status(fiber.StatusServiceUnavailable).
+			JSON(ErrorResp{OK: false, Error: "notifications disabled"})
+	}
+	email, err := notify.NormalizeEmail(c.Query("email"))
+	if err != nil {
+		return badReq(c, "invalid email")
+	}
+	token := c.Query("token")
+	if !a.Notifier.ValidUnsubscribeToken(email, token) {
+		return c.Status(fiber.StatusForbidden).JSON(ErrorResp{OK: false, Error: "invalid token"})
+	}
+
+	if c.Method() == fiber.MethodGet {
+		var b bytes.Buffer
+		v := url.Values{"email": {email}, "token": {token}}
+		if err := unsubscribePage.Execute(&b, map[string]string{"Email": email, "Action": c.Path() + "?" + v.Encode()}); err != nil {
+			return serverErr(c, err)
+		}
+		c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+		return c.Status(fiber.StatusOK).Send(b.Bytes())
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+	if err := a.Notifier.OptOut(ctx, email); err != nil {
+		return serverErr(c, err)
+	}
+	if strings.Contains(c.Get(fiber.HeaderAccept), "text/html") {
+		return c.Status(fiber.StatusOK).SendString("You will no longer receive emails from Mineba.")
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"ok": true})
+}
+
+// HandleListNotifications returns the email outbox, newest first.
+// GET /api/admin/notifications?status=&report_id=&limit=
+func (a *API) HandleListNotifications(c *fiber.Ctx) error {
+	filter := bson.M{}
+	if st := c.Query("status"); st != "" {
+		filter["status"] = st
+	}
+	if id := c.Query("report_id"); id != "" {
+		filter["report_id"] = id
+	}
+	limit := 50
+	if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 && v <= 500 {
+		limit = v
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+
+	cur, err := a.DB.Col(notify.OutboxCol).Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(int64(limit)))
+	if err != nil {
+		return serverErr(c, err)
+	}
+	items := make([]models.Notification, 0, limit)
+	if err := cur.All(ctx, &items); err != nil {
+		return serverErr(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(NotificationListResp{OK: true, Items: items})
+}
@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"meniba/notify"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestUnsubscribeConfirmsBeforeOptingOut(t *testing.T) {
+	store := notify.NewMemoryStore()
+	n := &notify.Notifier{Store: store, Secret: []byte("test-secret"), BaseURL: "https://api.mineba.test"}
+	a := &API{Notifier: n}
+	app := fiber.New()
+	app.Get("/api/notifications/unsubscribe", a.HandleUnsubscribe)
+	app.Post("/api/notifications/unsubscribe", a.HandleUnsubscribe)
+	ctx := context.Background()
+
+	link, _ := url.Parse(n.UnsubscribeURL("amie@example.org"))
+	target := link.Path + "?" + link.RawQuery
+
+	// a link scanner following the email link changes nothing
+	resp, err := app.Test(httptest.NewRequest("GET", target, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != fiber.StatusOK || !strings.Contains(string(page), `method="post"`) {
+		t.Fatalf("GET = %d %s", resp.StatusCode, page)
+	}
+	if out, _ := n.OptedOut(ctx, "amie@example.org"); out {
+		t.Fatal("GET opted the address out")
+	}
+
+	bad := strings.Replace(target, "token=", "token=00", 1)
+	if resp, _ := app.Test(httptest.NewRequest("POST", bad, nil)); resp.StatusCode != fiber.StatusForbidden {
+		t.Fatalf("forged POST = %d", resp.StatusCode)
+	}
+
+	resp, err = app.Test(httptest.NewRequest("POST", target, nil))
+	if err != nil || resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("POST = %v, %v", resp.StatusCode, err)
+	}
+	if out, _ := n.OptedOut(ctx, "amie@example.org"); !out {
+		t.Fatal("POST did not opt the address out")
+	}
+}
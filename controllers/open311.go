@@ -0,0 +1,356 @@
+// path: controllers/open311.go
+package controllers
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/xml"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"meniba/models"
+	"meniba/repository"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Open311 GeoReport v2 layer. Services are the category taxonomy and
+// service requests are models.Report. Every endpoint takes a .json or .xml
+// format suffix, per the spec.
+
+type open311Service struct {
+	XMLName     xml.Name `json:"-" xml:"service"`
+	ServiceCode string   `json:"service_code" xml:"service_code"`
+	ServiceName string   `json:"service_name" xml:"service_name"`
+	Description string   `json:"description" xml:"description"`
+	Metadata    bool     `json:"metadata" xml:"metadata"`
+	Type        string   `json:"type" xml:"type"`
+	Keywords    string   `json:"keywords" xml:"keywords"`
+	Group       string   `json:"group" xml:"group"`
+}
+
+type open311Services struct {
+	XMLName xml.Name         `xml:"services"`
+	Items   []open311Service `xml:"service"`
+}
+
+type open311Request struct {
+	XMLName           xml.Name `json:"-" xml:"request"`
+	ServiceRequestID  string   `json:"service_request_id" xml:"service_request_id"`
+	Status            string   `json:"status" xml:"status"`
+	StatusNotes       string   `json:"status_notes,omitempty" xml:"status_notes,omitempty"`
+	ServiceName       string   `json:"service_name" xml:"service_name"`
+	ServiceCode       string   `json:"service_code" xml:"service_code"`
+	Description       string   `json:"description" xml:"description"`
+	AgencyResponsible string   `json:"agency_responsible,omitempty" xml:"agency_responsible,omitempty"`
+	ServiceNotice     string   `json:"service_notice,omitempty" xml:"service_notice,omitempty"`
+	RequestedDatetime string   `json:"requested_datetime" xml:"requested_datetime"`
+	UpdatedDatetime   string   `json:"updated_datetime" xml:"updated_datetime"`
+	Address           string   `json:"address" xml:"address"`
+	Lat               float64  `json:"lat" xml:"lat"`
+	Long              float64  `json:"long" xml:"long"`
+	MediaURL          string   `json:"media_url,omitempty" xml:"media_url,omitempty"`
+}
+
+type open311Requests struct {
+	XMLName xml.Name         `xml:"service_requests"`
+	Items   []open311Request `xml:"request"`
+}
+
+type open311Created struct {
+	XMLName          xml.Name `json:"-" xml:"request"`
+	ServiceRequestID string   `json:"service_request_id" xml:"service_request_id"`
+	ServiceNotice    string   `json:"service_notice,omitempty" xml:"service_notice,omitempty"`
+	AccountID        string   `json:"account_id,omitempty" xml:"account_id,omitempty"`
+}
+
+type open311CreatedList struct {
+	XMLName xml.Name         `xml:"service_requests"`
+	Items   []open311Created `xml:"request"`
+}
+
+type open311Error struct {
+	XMLName     xml.Name `json:"-" xml:"error"`
+	Code        int      `json:"code" xml:"code"`
+	Description string   `json:"description" xml:"description"`
+}
+
+type open311Errors struct {
+	XMLName xml.Name       `xml:"errors"`
+	Items   []open311Error `xml:"error"`
+}
+
+// open311Post accepts the spec's form-encoded fields (and JSON, for
+// convenience).
+type open311Post struct {
+	APIKey        string  `form:"api_key" json:"api_key"`
+	ServiceCode   string  `form:"service_code" json:"service_code"`
+	Lat           float64 `form:"lat" json:"lat"`
+	Long          float64 `form:"long" json:"long"`
+	AddressString string  `form:"address_string" json:"address_string"`
+	Description   string  `form:"description" json:"description"`
+	MediaURL      string  `form:"media_url" json:"media_url"`
+	Email         string  `form:"email" json:"email"`
+}
+
+// HandleOpen311Services lists the category taxonomy as Open311 services.
+// GET /open311/v2/services.:format
+func (a *API) HandleOpen311Services(c *fiber.Ctx) error {
+	format, ok := open311Format(c)
+	if !ok {
+		return open311Fail(c, "json", fiber.StatusNotFound, "format must be json or xml")
+	}
+	items := make([]open311Service, 0, len(models.Categories))
+	for _, cat := range models.Categories {
+		items = append(items, open311Service{
+			ServiceCode: cat.Code,
+			ServiceName: cat.Name,
+			Description: cat.Description,
+			Metadata:    false,
+			Type:        "realtime",
+			Keywords:    strings.Join(cat.Keywords, ","),
+			Group:       cat.Group,
+		})
+	}
+	return open311Reply(c, format, fiber.StatusOK, items, open311Services{Items: items})
+}
+
+// HandleOpen311ListRequests lists reports as service requests.
+// GET /open311/v2/requests.:format?service_request_id=&service_code=&status=&start_date=&end_date=
+func (a *API) HandleOpen311ListRequests(c *fiber.Ctx) error {
+	format, ok := open311Format(c)
+	if !ok {
+		return open311Fail(c, "json", fiber.StatusNotFound, "format must be json or xml")
+	}
+
+	var f repository.ReportQuery
+	if ids := c.Query("service_request_id"); ids != "" {
+		for _, s := range strings.Split(ids, ",") {
+			oid, err := primitive.ObjectIDFromHex(strings.TrimSpace(s))
+			if err != nil {
+				return open311Fail(c, format, fiber.StatusBadRequest, "invalid service_request_id")
+			}
+			f.IDs = append(f.IDs, oid)
+		}
+		// spec: other parameters are ignored when ids are given
+		f.IncludeMerged, f.IncludeWithdrawn = true, true
+	} else {
+		if codes := c.Query("service_code"); codes != "" {
+			f.Category.In = trimAll(strings.Split(codes, ","))
+		}
+		closed := []string{models.StatusResolved, models.StatusRejected}
+		switch st := c.Query("status"); st {
+		case "":
+		case "open":
+			f.Status.NotIn = closed
+		case "closed":
+			f.Status.In = closed
+		default:
+			return open311Fail(c, format, fiber.StatusBadRequest, "status must be open or closed")
+		}
+
+		end := time.Now().UTC()
+		if v := c.Query("end_date"); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return open311Fail(c, format, fiber.StatusBadRequest, "invalid end_date (ISO 8601)")
+			}
+			end = t
+		}
+		// spec: default window is the last 90 days
+		start := end.AddDate(0, 0, -90)
+		if v := c.Query("start_date"); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return open311Fail(c, format, fiber.StatusBadRequest, "invalid start_date (ISO 8601)")
+			}
+			start = t
+		}
+		f.CreatedFrom, f.CreatedTo = &start, &end
+	}
+
+	pageSize := 100
+	if v, err := strconv.Atoi(c.Query("page_size")); err == nil && v > 0 {
+		pageSize = min(v, 1000)
+	}
+	page := 1
+	if v, err := strconv.Atoi(c.Query("page")); err == nil && v > 1 {
+		page = v
+	}
+	f.Skip = (page - 1) * pageSize
+	f.Limit = pageSize
+
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+
+	docs, err := a.Reports.List(ctx, f)
+	if err != nil {
+		return open311Fail(c, format, fiber.StatusInternalServerError, err.Error())
+	}
+
+	items := make([]open311Request, 0, len(docs))
+	for _, d := range docs {
+		items = append(items, toOpen311Request(c, d))
+	}
+	return open311Reply(c, format, fiber.StatusOK, items, open311Requests{Items: items})
+}
+
+// HandleOpen311GetRequest returns one service request (as a one-item list,
+// per the spec).
+// GET /open311/v2/requests/:id.:format
+func (a *API) HandleOpen311GetRequest(c *fiber.Ctx) error {
+	format, ok := open311Format(c)
+	if !ok {
+		return open311Fail(c, "json", fiber.StatusNotFound, "format must be json or xml")
+	}
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return open311Fail(c, format, fiber.StatusNotFound, "service request not found")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+
+	doc, err := a.Reports.Get(ctx, id)
+	if err != nil {
+		return open311Fail(c, format, fiber.StatusNotFound, "service request not found")
+	}
+	items := []open311Request{toOpen311Request(c, doc)}
+	return open311Reply(c, format, fiber.StatusOK, items, open311Requests{Items: items})
+}
+
+// HandleOpen311CreateRequest creates a report from an Open311 client.
+// POST /open311/v2/requests.:format
+func (a *API) HandleOpen311CreateRequest(c *fiber.Ctx) error {
+	format, ok := open311Format(c)
+	if !ok {
+		return open311Fail(c, "json", fiber.StatusNotFound, "format must be json or xml")
+	}
+	var p open311Post
+	if err := c.BodyParser(&p); err != nil {
+		return open311Fail(c, format, fiber.StatusBadRequest, "invalid request body")
+	}
+	if key := getenv("OPEN311_API_KEY", ""); key != "" && subtle.ConstantTimeCompare([]byte(p.APIKey), []byte(key)) != 1 {
+		return open311Fail(c, format, fiber.StatusForbidden, "invalid api_key")
+	}
+	cat, ok := models.CategoryByCode(p.ServiceCode)
+	if !ok {
+		return open311Fail(c, format, fiber.StatusBadRequest, "unknown service_code")
+	}
+	if p.Lat == 0 && p.Long == 0 {
+		// why: we don't geocode address_string, so coordinates are required
+		return open311Fail(c, format, fiber.StatusBadRequest, "lat and long are required")
+	}
+	area := strings.TrimSpace(p.AddressString)
+	if area == "" {
+		area = areaLabel(p.Lat, p.Long, nil)
+	}
+	if err := validateReport(cat.Code, p.Description, area, p.Lat, p.Long); err != nil {
+		return open311Fail(c, format, fiber.StatusBadRequest, err.Error())
+	}
+
+	email, _, err := parseContact(p.Email, "")
+	if err != nil {
+		return open311Fail(c, format, fiber.StatusBadRequest, "invalid email")
+	}
+
+	radius := 300
+	doc := models.Report{
+		Category:       cat.Code,
+		Note:           strings.TrimSpace(p.Description),
+		AreaLabel:      area,
+		Lat:            p.Lat,
+		Lng:            p.Long,
+		PrivacyRadiusM: &radius,
+		Anonymous:      true,
+		Source:         models.SourceOpen311,
+		GeoMethod:      "open311",
+		ContactEmail:   email,
+		CreatedAt:      time.Now().UTC(),
+	}
+	if u, err := url.Parse(strings.TrimSpace(p.MediaURL)); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		doc.PhotoURLs = []string{u.String()}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+	doc, dups, err := a.insertReport(ctx, doc, a.actor(c), nil)
+	if err != nil {
+		return open311Fail(c, format, fiber.StatusInternalServerError, err.Error())
+	}
+
+	created := open311Created{ServiceRequestID: doc.ID.Hex()}
+	if len(dups) > 0 {
+		created.ServiceNotice = "A similar issue was reported nearby recently (" + dups[0].ID + ")."
+	}
+	items := []open311Created{created}
+	return open311Reply(c, format, fiber.StatusCreated, items, open311CreatedList{Items: items})
+}
+
+func toOpen311Request(c *fiber.Ctx, d models.Report) open311Request {
+	status := "open"
+	if st := d.CurrentStatus(); st == models.StatusResolved || st == models.StatusRejected || d.WithdrawnAt != nil {
+		status = "closed"
+	}
+	updated := d.UpdatedAt
+	if updated.IsZero() {
+		updated = d.CreatedAt
+	}
+	r := open311Request{
+		ServiceRequestID:  d.ID.Hex(),
+		Status:            status,
+		ServiceName:       models.CategoryName(d.Category),
+		ServiceCode:       d.Category,
+		Description:       d.Note,
+		RequestedDatetime: d.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedDatetime:   updated.UTC().Format(time.RFC3339),
+		Address:           d.AreaLabel,
+		Lat:               d.Lat,
+		Long:              d.Lng,
+	}
+	if n := len(d.StatusHistory); n > 0 {
+		r.StatusNotes = d.StatusHistory[n-1].Note
+	}
+	if d.WithdrawnAt != nil {
+		r.StatusNotes = "Withdrawn by the reporter."
+	}
+	if len(d.PhotoURLs) > 0 {
+		r.MediaURL = absURL(c, d.PhotoURLs[0])
+	}
+	return r
+}
+
+// absURL turns a stored "/uploads/..." path into a full URL for clients
+// that live on other hosts.
+func absURL(c *fiber.Ctx, p string) string {
+	if strings.HasPrefix(p, "/") {
+		return c.BaseURL() + p
+	}
+	return p
+}
+
+func open311Format(c *fiber.Ctx) (string, bool) {
+	f := strings.ToLower(c.Params("format"))
+	return f, f == "json" || f == "xml"
+}
+
+// open311Reply writes jsonBody or xmlBody depending on the requested format.
+func open311Reply(c *fiber.Ctx, format string, status int, jsonBody, xmlBody any) error {
+	if format == "xml" {
+		out, err := xml.Marshal(xmlBody)
+		if err != nil {
+			return serverErr(c, err)
+		}
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationXMLCharsetUTF8)
+		return c.Status(status).Send(append([]byte(xml.Header), out...))
+	}
+	return c.Status(status).JSON(jsonBody)
+}
+
+func open311Fail(c *fiber.Ctx, format string, status int, msg string) error {
+	items := []open311Error{{Code: status, Description: msg}}
+	return open311Reply(c, format, status, items, open311Errors{Items: items})
+}
@@ -5,19 +5,20 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io"
+	"log"
 	"mime/multipart"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
-	"meniba/database"
+	"meniba/audit"
+	"meniba/events"
+	"meniba/media"
 	"meniba/models"
+	"meniba/notify"
 
 	"github.com/gofiber/fiber/v2"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // JSON payload for POST /api/reports when Content-Type: application/json
@@ -30,41 +31,61 @@ type ReportJSON struct {
 	AccuracyM      *int    `json:"accuracy_m,omitempty"`
 	PrivacyRadiusM *int    `json:"privacy_radius_m,omitempty"`
 	Anonymous      bool    `json:"anonymous"`
-        Adrehs    string `json:"adrehs,omitempty"`
-	District  string `json:"district,omitempty"`
-	Chiefdom  string `json:"chiefdom,omitempty"`
-	Region    string `json:"region,omitempty"`
-	Section   string `json:"section,omitempty"`
-	GeoMethod string `json:"geo_method,omitempty"`
-
+	Adrehs         string  `json:"adrehs,omitempty"`
+	District       string  `json:"district,omitempty"`
+	Chiefdom       string  `json:"chiefdom,omitempty"`
+	Region         string  `json:"region,omitempty"`
+	Section        string  `json:"section,omitempty"`
+	GeoMethod      string  `json:"geo_method,omitempty"`
+	CapturedAt     string  `json:"captured_at,omitempty"`   // RFC3339
+	ContactEmail   string  `json:"contact_email,omitempty"` // for status updates
+	Language       string  `json:"language,omitempty"`
 }
 
-func HandlePostReport(c *fiber.Ctx) error {
+func (a *API) HandlePostReport(c *fiber.Ctx) error {
 	ct := c.Get("Content-Type")
 	if strings.HasPrefix(ct, "application/json") {
-		return handleReportJSON(c)
+		return a.handleReportJSON(c)
 	}
 	if strings.HasPrefix(ct, "multipart/form-data") {
-		return handleReportMultipart(c)
+		return a.handleReportMultipart(c)
 	}
 	return c.Status(fiber.StatusUnsupportedMediaType).
 		JSON(ErrorResp{OK: false, Error: "unsupported content type"})
 }
 
-func handleReportJSON(c *fiber.Ctx) error {
+func (a *API) handleReportJSON(c *fiber.Ctx) error {
 	var p ReportJSON
 	if err := c.BodyParser(&p); err != nil {
 		return badReq(c, "invalid JSON")
 	}
-	if err := validateReport(p.Category, p.Note, p.AreaLabel, p.Lat, p.Lng); err != nil {
+	doc, err := reportFromJSON(p)
+	if err != nil {
 		return badReq(c, err.Error())
 	}
+	return a.createReport(c, doc, nil)
+}
+
+// reportFromJSON validates p and builds the document to store. Channels
+// without their own form (SMS, USSD) build a ReportJSON and go through here.
+func reportFromJSON(p ReportJSON) (models.Report, error) {
+	if err := validateReport(p.Category, p.Note, p.AreaLabel, p.Lat, p.Lng); err != nil {
+		return models.Report{}, err
+	}
+	capturedAt, err := parseCapturedAt(p.CapturedAt)
+	if err != nil {
+		return models.Report{}, err
+	}
+	email, lang, err := parseContact(p.ContactEmail, p.Language)
+	if err != nil {
+		return models.Report{}, err
+	}
 	if p.PrivacyRadiusM == nil {
 		def := 300
 		p.PrivacyRadiusM = &def
 	}
 
-	doc := models.Report{
+	return models.Report{
 		Category:       p.Category,
 		Note:           p.Note,
 		AreaLabel:      p.AreaLabel,
@@ -73,26 +94,20 @@ func handleReportJSON(c *fiber.Ctx) error {
 		AccuracyM:      p.AccuracyM,
 		PrivacyRadiusM: p.PrivacyRadiusM,
 		Anonymous:      p.Anonymous,
-                Adrehs:         strings.TrimSpace(p.Adrehs),
+		Adrehs:         strings.TrimSpace(p.Adrehs),
 		District:       strings.TrimSpace(p.District),
 		Chiefdom:       strings.TrimSpace(p.Chiefdom),
 		Region:         strings.TrimSpace(p.Region),
 		Section:        strings.TrimSpace(p.Section),
 		GeoMethod:      strings.TrimSpace(p.GeoMethod),
+		CapturedAt:     capturedAt,
+		ContactEmail:   email,
+		Language:       lang,
 		CreatedAt:      time.Now().UTC(),
-	}
-
-	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
-	defer cancel()
-	res, err := database.Col("reports").InsertOne(ctx, doc)
-	if err != nil {
-		return serverErr(c, err)
-	}
-	id := res.InsertedID.(primitive.ObjectID).Hex()
-	return c.Status(fiber.StatusOK).JSON(models.CreateReportResp{OK: true, ID: id})
+	}, nil
 }
 
-func handleReportMultipart(c *fiber.Ctx) error {
+func (a *API) handleReportMultipart(c *fiber.Ctx) error {
 	// fields
 	category := strings.TrimSpace(c.FormValue("category"))
 	note := strings.TrimSpace(c.FormValue("note"))
@@ -128,13 +143,23 @@ func handleReportMultipart(c *fiber.Ctx) error {
 		}
 	}
 	anonymous := parseBool(anonStr)
+	capturedAt, err := parseCapturedAt(c.FormValue("captured_at"))
+	if err != nil {
+		return badReq(c, err.Error())
+	}
+	email, lang, err := parseContact(c.FormValue("contact_email"), c.FormValue("language"))
+	if err != nil {
+		return badReq(c, err.Error())
+	}
 
 	if err := validateReport(category, note, areaLabel, lat, lng); err != nil {
 		return badReq(c, err.Error())
 	}
 
-	// files
-	uploadDir := getenv("UPLOAD_DIR", "uploads")
+	// files: staged until the report is stored, then committed together;
+	// any earlier return discards them
+	batch := a.Media.Stage()
+	defer batch.Rollback()
 	var voiceSaved string
 	var photoPaths []string
 
@@ -147,7 +172,7 @@ func handleReportMultipart(c *fiber.Ctx) error {
 			switch {
 			case key == "voice":
 				if voiceSaved == "" {
-					if p, e := saveFormFile(uploadDir, "voice", files[0]); e == nil {
+					if p, e := stageFormFile(batch, "voice", files[0]); e == nil {
 						voiceSaved = p
 					} else {
 						return serverErr(c, e)
@@ -155,7 +180,7 @@ func handleReportMultipart(c *fiber.Ctx) error {
 				}
 			case strings.HasPrefix(key, "photo"):
 				for _, fh := range files {
-					if p, e := saveFormFile(uploadDir, "photo", fh); e == nil {
+					if p, e := stageFormFile(batch, "photo", fh); e == nil {
 						photoPaths = append(photoPaths, p)
 					} else {
 						return serverErr(c, e)
@@ -166,14 +191,14 @@ func handleReportMultipart(c *fiber.Ctx) error {
 	} else {
 		// fallback single
 		if f, err := c.FormFile("voice"); err == nil && f != nil {
-			if p, e := saveFormFile(uploadDir, "voice", f); e == nil {
+			if p, e := stageFormFile(batch, "voice", f); e == nil {
 				voiceSaved = p
 			} else {
 				return serverErr(c, e)
 			}
 		}
 		if f, err := c.FormFile("photo1"); err == nil && f != nil {
-			if p, e := saveFormFile(uploadDir, "photo", f); e == nil {
+			if p, e := stageFormFile(batch, "photo", f); e == nil {
 				photoPaths = append(photoPaths, p)
 			} else {
 				return serverErr(c, e)
@@ -192,23 +217,103 @@ func handleReportMultipart(c *fiber.Ctx) error {
 		Anonymous:      anonymous,
 		VoiceURL:       voiceSaved,
 		PhotoURLs:      photoPaths,
-                Adrehs:    strings.TrimSpace(c.FormValue("adrehs")),
-		District:  strings.TrimSpace(c.FormValue("district")),
-		Chiefdom:  strings.TrimSpace(c.FormValue("chiefdom")),
-		Region:    strings.TrimSpace(c.FormValue("region")),
-		Section:   strings.TrimSpace(c.FormValue("section")),
-		GeoMethod: strings.TrimSpace(c.FormValue("geo_method")),
-		CreatedAt: time.Now().UTC(),		
+		Adrehs:         strings.TrimSpace(c.FormValue("adrehs")),
+		District:       strings.TrimSpace(c.FormValue("district")),
+		Chiefdom:       strings.TrimSpace(c.FormValue("chiefdom")),
+		Region:         strings.TrimSpace(c.FormValue("region")),
+		Section:        strings.TrimSpace(c.FormValue("section")),
+		GeoMethod:      strings.TrimSpace(c.FormValue("geo_method")),
+		CapturedAt:     capturedAt,
+		ContactEmail:   email,
+		Language:       lang,
+		CreatedAt:      time.Now().UTC(),
 	}
 
+	return a.createReport(c, doc, batch)
+}
+
+// createReport stores the report with its staged media (files may be nil)
+// and replies with its id, any likely duplicates and a tracking token.
+func (a *API) createReport(c *fiber.Ctx, doc models.Report, files *media.Batch) error {
 	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
 	defer cancel()
-	res, err := database.Col("reports").InsertOne(ctx, doc)
+
+	if err := a.linkReporter(c, ctx, &doc); err != nil {
+		return accountsErr(c, err)
+	}
+	who := a.actor(c)
+	if doc.ReporterID != nil {
+		who = a.reporterActor(c, doc.ReporterID.Hex())
+	}
+	doc, dups, err := a.insertReport(ctx, doc, who, files)
 	if err != nil {
 		return serverErr(c, err)
 	}
-	id := res.InsertedID.(primitive.ObjectID).Hex()
-	return c.Status(fiber.StatusOK).JSON(models.CreateReportResp{OK: true, ID: id})
+	resp := models.CreateReportResp{
+		OK:                 true,
+		ID:                 doc.ID.Hex(),
+		PossibleDuplicates: dups,
+	}
+	if token, exp, err := a.issueTrackingToken(ctx, doc.ID); err != nil {
+		// why: the report is stored; don't make the client resubmit it
+		log.Printf("reports: tracking token for %s: %v", doc.ID.Hex(), err)
+	} else {
+		resp.TrackingToken, resp.TrackingExpiresAt = token, &exp
+	}
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// insertReport is the single write path for new reports from every intake
+// channel: it fills defaults, flags likely duplicates, stores the document
+// and announces it on behalf of who. Media the report points to may be
+// staged in files (or nil); they are committed once the document is stored,
+// and the document is removed again if that fails, so a report is only
+// announced with its media in place. Callers validate first and roll the
+// batch back on error.
+func (a *API) insertReport(ctx context.Context, doc models.Report, who audit.Actor, files *media.Batch) (models.Report, []models.DuplicateCandidate, error) {
+	if doc.Status == "" {
+		doc.Status = models.StatusOpen
+	}
+	if doc.Source == "" {
+		doc.Source = models.SourceApp
+	}
+	if doc.CreatedAt.IsZero() {
+		doc.CreatedAt = time.Now().UTC()
+	}
+	doc.UpdatedAt = doc.CreatedAt
+
+	dups, dupIDs, err := a.findDuplicates(ctx, doc)
+	if err != nil {
+		// why: duplicate hints are best-effort; never lose a report over them
+		log.Printf("reports: duplicate check failed: %v", err)
+	}
+	doc.PossibleDuplicates = dupIDs
+
+	if a.Router != nil {
+		ids, err := a.Router.Route(ctx, doc)
+		if err != nil {
+			// why: an unrouted report can still be assigned by a moderator
+			log.Printf("reports: routing failed: %v", err)
+		} else if len(ids) > 0 {
+			doc.Agencies = ids
+			doc.AssignmentHistory = append(doc.AssignmentHistory, models.Assignment{
+				To: ids, By: models.AssignedByRouting, At: doc.CreatedAt,
+			})
+		}
+	}
+
+	if err := a.Reports.Create(ctx, &doc); err != nil {
+		return doc, nil, err
+	}
+	if err := files.Commit(); err != nil {
+		if derr := a.Reports.Delete(ctx, doc.ID); derr != nil {
+			log.Printf("reports: remove %s after media commit failed: %v", doc.ID.Hex(), derr)
+		}
+		return doc, nil, fmt.Errorf("store media: %w", err)
+	}
+	a.recordAudit(ctx, who, audit.Entry{Action: audit.ReportCreate, Target: "report", TargetID: doc.ID, After: doc})
+	a.Bus.Publish(events.Event{Type: events.ReportCreated, ReportID: doc.ID.Hex(), Report: &doc})
+	return doc, dups, nil
 }
 
 func validateReport(category, note, area string, lat, lng float64) error {
@@ -227,35 +332,46 @@ func validateReport(category, note, area string, lat, lng float64) error {
 	return nil
 }
 
-func saveFormFile(uploadDir, prefix string, f *multipart.FileHeader) (string, error) {
-	ext := strings.ToLower(filepath.Ext(f.Filename))
-	if len(ext) > 8 {
-		ext = ext[:8]
-	}
-	name := fmt.Sprintf("%s_%d_%s%s", prefix, time.Now().UnixNano(), randString(6), ext)
-	dst := filepath.Join(uploadDir, name)
-	if err := cpyFile(f, dst); err != nil {
-		return "", err
+// parseCapturedAt reads the optional capture time. Phones with a wrong
+// clock are common, so only times clearly in the future are rejected.
+func parseCapturedAt(s string) (*time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
 	}
-	return "/uploads/" + name, nil
-}
-
-func cpyFile(fh *multipart.FileHeader, dst string) error {
-	src, err := fh.Open()
+	t, err := time.Parse(time.RFC3339, s)
 	if err != nil {
-		return err
+		return nil, errors.New("invalid captured_at (RFC3339)")
 	}
-	defer src.Close()
+	if t.After(time.Now().Add(time.Hour)) {
+		return nil, errors.New("captured_at is in the future")
+	}
+	t = t.UTC()
+	return &t, nil
+}
 
-	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
-		return err
+// parseContact validates the optional reporter email and language code.
+func parseContact(email, lang string) (string, string, error) {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if len(lang) > 8 || strings.Trim(lang, "abcdefghijklmnopqrstuvwxyz-") != "" {
+		return "", "", errors.New("invalid language")
+	}
+	if strings.TrimSpace(email) == "" {
+		return "", lang, nil
 	}
-	out, err := os.Create(dst)
+	e, err := notify.NormalizeEmail(email)
 	if err != nil {
-		return err
+		return "", "", errors.New("invalid contact_email")
 	}
-	defer out.Close()
+	return e, lang, nil
+}
 
-	_, err = io.Copy(out, src)
-	return err
+// stageFormFile adds an uploaded file to b.
+func stageFormFile(b *media.Batch, prefix string, f *multipart.FileHeader) (string, error) {
+	src, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	return b.Add(prefix, filepath.Ext(f.Filename), src)
 }
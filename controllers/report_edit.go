@@ -0,0 +1,331 @@
+// path: controllers/report_edit.go
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"meniba/audit"
+	"meniba/events"
+	"meniba/models"
+	"meniba/repository"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ReportPatch is the body for PATCH /api/reports/:id (JSON, or the same
+// fields as multipart form values plus new voice/photo files). Omitted
+// fields are left alone.
+type ReportPatch struct {
+	Category     *string  `json:"category"`
+	Note         *string  `json:"note"`
+	AreaLabel    *string  `json:"area_label"`
+	RemovePhotos []string `json:"remove_photos"`
+	RemoveVoice  bool     `json:"remove_voice"`
+}
+
+var (
+	errEditWindow = errors.New("the edit window for this report has closed")
+	errEditLocked = errors.New("the report is already being handled and can no longer be changed")
+	errWithdrawn  = errors.New("the report was withdrawn")
+)
+
+// editWindow is how long after submission a reporter may change or
+// withdraw a report (REPORT_EDIT_WINDOW_MINUTES, default 30).
+func editWindow() time.Duration {
+	if v, err := strconv.Atoi(getenv("REPORT_EDIT_WINDOW_MINUTES", "")); err == nil && v >= 0 {
+		return time.Duration(v) * time.Minute
+	}
+	return 30 * time.Minute
+}
+
+// HandlePatchReport lets the reporter correct their report within the
+// edit window, authorised by its tracking token (X-Tracking-Token) or the
+// reporter account it is linked to (Bearer).
+// PATCH /api/reports/:id
+func (a *API) HandlePatchReport(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return badReq(c, "invalid id")
+	}
+	var p ReportPatch
+	var files []*multipart.FileHeader
+	var voice *multipart.FileHeader
+	if strings.HasPrefix(c.Get("Content-Type"), "multipart/form-data") {
+		form, err := c.MultipartForm()
+		if err != nil {
+			return badReq(c, "invalid form")
+		}
+		for _, f := range []struct {
+			key string
+			dst **string
+		}{{"category", &p.Category}, {"note", &p.Note}, {"area_label", &p.AreaLabel}} {
+			if v, ok := form.Value[f.key]; ok && len(v) > 0 {
+				*f.dst = &v[0]
+			}
+		}
+		p.RemovePhotos = trimAll(strings.Split(c.FormValue("remove_photos"), ","))
+		p.RemoveVoice = parseBool(c.FormValue("remove_voice"))
+		for key, fhs := range form.File {
+			switch {
+			case key == "voice" && len(fhs) > 0:
+				voice = fhs[0]
+			case strings.HasPrefix(key, "photo"):
+				files = append(files, fhs...)
+			}
+		}
+	} else if err := c.BodyParser(&p); err != nil {
+		return badReq(c, "invalid JSON")
+	}
+	for _, f := range []struct {
+		name string
+		v    *string
+	}{{"category", p.Category}, {"note", p.Note}, {"area_label", p.AreaLabel}} {
+		if f.v != nil && strings.TrimSpace(*f.v) == "" {
+			return badReq(c, "missing "+f.name)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 15*time.Second)
+	defer cancel()
+
+	current, err := a.Reports.Get(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return notFound(c, "report not found")
+	}
+	if err != nil {
+		return serverErr(c, err)
+	}
+	by, ok, err := a.reportOwner(c, ctx, current)
+	if !ok {
+		return err
+	}
+	who := a.ownerActor(c, by, current)
+	if err := editable(current); err != nil {
+		return c.Status(fiber.StatusConflict).JSON(ErrorResp{OK: false, Error: err.Error()})
+	}
+
+	// new media is staged until the edit is stored; any earlier return
+	// discards it
+	batch := a.Media.Stage()
+	defer batch.Rollback()
+	var added []string
+	var voiceURL string
+	if voice != nil {
+		if voiceURL, err = stageFormFile(batch, "voice", voice); err != nil {
+			return serverErr(c, err)
+		}
+		added = append(added, voiceURL)
+	}
+	for _, fh := range files {
+		u, err := stageFormFile(batch, "photo", fh)
+		if err != nil {
+			return serverErr(c, err)
+		}
+		added = append(added, u)
+	}
+
+	var rev models.Revision
+	var removed []string
+	after, err := a.updateReport(ctx, who, audit.ReportEdit, id, func(r *models.Report) error {
+		if err := editable(*r); err != nil {
+			return err
+		}
+		rev = models.Revision{Kind: models.RevisionEdit, Before: r.Snapshot(), By: by, At: time.Now().UTC()}
+		removed = nil
+		set := func(field string, dst *string, v *string) {
+			if v != nil && strings.TrimSpace(*v) != *dst {
+				*dst = strings.TrimSpace(*v)
+				rev.Fields = append(rev.Fields, field)
+			}
+		}
+		set("category", &r.Category, p.Category)
+		set("note", &r.Note, p.Note)
+		set("area_label", &r.AreaLabel, p.AreaLabel)
+
+		if len(p.RemovePhotos) > 0 || len(files) > 0 {
+			var keep []string
+			for _, u := range r.PhotoURLs {
+				if slices.Contains(p.RemovePhotos, u) {
+					removed = append(removed, u)
+				} else {
+					keep = append(keep, u)
+				}
+			}
+			for _, u := range added {
+				if u != voiceURL {
+					keep = append(keep, u)
+				}
+			}
+			if len(removed) > 0 || len(keep) != len(r.PhotoURLs) {
+				r.PhotoURLs = keep
+				rev.Fields = append(rev.Fields, "photo_urls")
+			}
+		}
+		if voiceURL != "" || (p.RemoveVoice && r.VoiceURL != "") {
+			if r.VoiceURL != "" {
+				removed = append(removed, r.VoiceURL)
+			}
+			r.VoiceURL = voiceURL
+			rev.Fields = append(rev.Fields, "voice_url")
+		}
+
+		if len(rev.Fields) == 0 {
+			return repository.ErrNoChange
+		}
+		r.Revisions = append(r.Revisions, rev)
+		return nil
+	})
+	if err != nil {
+		return reportEditErr(c, err)
+	}
+	if len(rev.Fields) == 0 {
+		return c.Status(fiber.StatusOK).JSON(toReportItem(after))
+	}
+	if err := batch.Commit(); err != nil {
+		// why: the report now points at files that never arrived; put it
+		// back as it was. Files it dropped haven't been deleted yet.
+		if _, rerr := a.updateReport(ctx, who, audit.ReportEdit, id, func(r *models.Report) error {
+			return undoRevision(r, rev)
+		}); rerr != nil {
+			log.Printf("reports: undo edit of %s after media commit failed: %v", id.Hex(), rerr)
+		}
+		return serverErr(c, fmt.Errorf("store media: %w", err))
+	}
+	for _, u := range removed {
+		if err := a.Media.Delete(u); err != nil {
+			log.Printf("reports: delete media %s: %v", u, err)
+		}
+	}
+
+	a.Bus.Publish(events.Event{Type: events.ReportUpdated, ReportID: id.Hex(), Report: &after, Data: rev})
+	return c.Status(fiber.StatusOK).JSON(toReportItem(after))
+}
+
+// HandleWithdrawReport lets the reporter take back a report within the
+// edit window. The report is kept, with its history, but hidden from
+// public lists and maps.
+// DELETE /api/reports/:id
+func (a *API) HandleWithdrawReport(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return badReq(c, "invalid id")
+	}
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+
+	current, err := a.Reports.Get(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return notFound(c, "report not found")
+	}
+	if err != nil {
+		return serverErr(c, err)
+	}
+	by, ok, err := a.reportOwner(c, ctx, current)
+	if !ok {
+		return err
+	}
+	who := a.ownerActor(c, by, current)
+
+	after, err := a.updateReport(ctx, who, audit.ReportWithdraw, id, func(r *models.Report) error {
+		if err := editable(*r); err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		r.WithdrawnAt = &now
+		r.Revisions = append(r.Revisions, models.Revision{
+			Kind: models.RevisionWithdraw, Before: r.Snapshot(), By: by, At: now,
+		})
+		return nil
+	})
+	if err != nil {
+		return reportEditErr(c, err)
+	}
+
+	a.Bus.Publish(events.Event{Type: events.ReportWithdrawn, ReportID: id.Hex(), Report: &after})
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"ok": true})
+}
+
+// undoRevision restores r to before rev if rev is still its latest
+// revision. Stored times keep only milliseconds, so At is compared at that
+// precision.
+func undoRevision(r *models.Report, rev models.Revision) error {
+	n := len(r.Revisions)
+	if n == 0 || !r.Revisions[n-1].At.Truncate(time.Millisecond).Equal(rev.At.Truncate(time.Millisecond)) {
+		return repository.ErrNoChange
+	}
+	b := rev.Before
+	r.Category, r.Note, r.AreaLabel = b.Category, b.Note, b.AreaLabel
+	r.VoiceURL, r.PhotoURLs = b.VoiceURL, b.PhotoURLs
+	r.Revisions = r.Revisions[:n-1]
+	return nil
+}
+
+// editable reports why r can no longer be changed by its reporter, if so.
+func editable(r models.Report) error {
+	switch {
+	case r.WithdrawnAt != nil:
+		return errWithdrawn
+	case time.Since(r.CreatedAt) > editWindow():
+		return errEditWindow
+	case r.DuplicateOf != nil || r.CurrentStatus() != models.StatusOpen:
+		return errEditLocked
+	}
+	return nil
+}
+
+// reportOwner checks that the caller submitted r: either a live tracking
+// token for it (X-Tracking-Token) or the reporter account it is linked to.
+// It replies 401/403 itself and returns how the caller was identified.
+func (a *API) reportOwner(c *fiber.Ctx, ctx context.Context, r models.Report) (string, bool, error) {
+	if token := strings.TrimSpace(c.Get("X-Tracking-Token")); token != "" {
+		tok, err := a.Tracking.FindByHash(ctx, hashToken(token))
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return "", false, serverErr(c, err)
+		}
+		if err == nil && tok.ReportID == r.ID && tok.Live(time.Now()) {
+			return "token", true, nil
+		}
+		return "", false, c.Status(fiber.StatusForbidden).JSON(ErrorResp{OK: false, Error: "not your report"})
+	}
+	if bearerToken(c) != "" && a.Accounts != nil {
+		rep, err := a.Accounts.Authenticate(ctx, bearerToken(c))
+		if err != nil {
+			return "", false, accountsErr(c, err)
+		}
+		if r.ReporterID == nil || *r.ReporterID != rep.ID {
+			return "", false, c.Status(fiber.StatusForbidden).JSON(ErrorResp{OK: false, Error: "not your report"})
+		}
+		return "account", true, nil
+	}
+	return "", false, c.Status(fiber.StatusUnauthorized).
+		JSON(ErrorResp{OK: false, Error: "tracking token or reporter session required"})
+}
+
+// ownerActor is the audit actor for a reporter identified by reportOwner.
+func (a *API) ownerActor(c *fiber.Ctx, by string, r models.Report) audit.Actor {
+	if by == "account" && r.ReporterID != nil {
+		return a.reporterActor(c, r.ReporterID.Hex())
+	}
+	return a.reporterActor(c, "tracking-token")
+}
+
+func reportEditErr(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return notFound(c, "report not found")
+	case errors.Is(err, errEditWindow), errors.Is(err, errEditLocked), errors.Is(err, errWithdrawn):
+		return c.Status(fiber.StatusConflict).JSON(ErrorResp{OK: false, Error: err.Error()})
+	case errors.Is(err, repository.ErrConflict):
+		return c.Status(fiber.StatusConflict).JSON(ErrorResp{OK: false, Error: "report changed concurrently; retry"})
+	}
+	return serverErr(c, err)
+}
@@ -0,0 +1,309 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"meniba/accounts"
+	"meniba/events"
+	"meniba/media"
+	"meniba/models"
+	"meniba/repository"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newEditTestAPI(t *testing.T) (*API, *fiber.App) {
+	t.Helper()
+	a := &API{
+		Reports:  repository.NewMemory(),
+		Bus:      events.NewBus(10),
+		Media:    media.NewStore(filepath.Join(t.TempDir(), "uploads"), "/uploads"),
+		Tracking: repository.NewMemoryTrackingTokens(),
+		Accounts: &accounts.Service{
+			Store:       accounts.NewMemoryStore(),
+			SMS:         &accounts.FakeSender{},
+			CountryCode: "232",
+			CodeTTL:     10 * time.Minute,
+			MaxAttempts: 3,
+			HourlyMax:   5,
+			SessionTTL:  time.Hour,
+		},
+	}
+	app := fiber.New()
+	app.Patch("/api/reports/:id", a.HandlePatchReport)
+	app.Delete("/api/reports/:id", a.HandleWithdrawReport)
+	return a, app
+}
+
+var otpRE = regexp.MustCompile(`\b(\d{6})\b`)
+
+// signIn logs phone in and returns the session token and reporter id.
+func signIn(t *testing.T, a *API, phone string) (string, primitive.ObjectID) {
+	t.Helper()
+	ctx := context.Background()
+	e164, err := a.Accounts.StartLogin(ctx, phone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, _ := a.Accounts.SMS.(*accounts.FakeSender).Last(e164)
+	m := otpRE.FindStringSubmatch(msg.Text)
+	if m == nil {
+		t.Fatalf("no code in %q", msg.Text)
+	}
+	token, rep, err := a.Accounts.VerifyLogin(ctx, e164, m[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token, rep.ID
+}
+
+// newEditable stores an open report filed age ago and returns it with a
+// tracking token for it.
+func newEditable(t *testing.T, a *API, age time.Duration, mutate func(*models.Report)) (models.Report, string) {
+	t.Helper()
+	ctx := context.Background()
+	r := models.Report{Category: "water", Note: "pump broken", AreaLabel: "Kissy", Status: models.StatusOpen,
+		CreatedAt: time.Now().UTC().Add(-age)}
+	if mutate != nil {
+		mutate(&r)
+	}
+	if err := a.Reports.Create(ctx, &r); err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := a.issueTrackingToken(ctx, r.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r, token
+}
+
+type editAuth struct{ token, bearer string }
+
+func editRequest(t *testing.T, app *fiber.App, method string, id primitive.ObjectID, auth editAuth, body string) int {
+	t.Helper()
+	req := httptest.NewRequest(method, "/api/reports/"+id.Hex(), strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if auth.token != "" {
+		req.Header.Set("X-Tracking-Token", auth.token)
+	}
+	if auth.bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+auth.bearer)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode
+}
+
+func TestReportOwner(t *testing.T) {
+	a, app := newEditTestAPI(t)
+	ctx := context.Background()
+	alice, aliceID := signIn(t, a, "076 111 111")
+	bob, _ := signIn(t, a, "076 222 222")
+	mine, mineToken := newEditable(t, a, time.Minute, func(r *models.Report) { r.ReporterID = &aliceID })
+	anon, anonToken := newEditable(t, a, time.Minute, func(r *models.Report) { r.Anonymous = true })
+	_, otherToken := newEditable(t, a, time.Minute, nil)
+	revoked, _, _ := a.issueTrackingToken(ctx, mine.ID)
+	tok, _ := a.Tracking.FindByHash(ctx, hashToken(revoked))
+	_ = a.Tracking.Revoke(ctx, tok.ID, time.Now())
+
+	for _, tc := range []struct {
+		name   string
+		report models.Report
+		auth   editAuth
+		want   int
+	}{
+		{"no credentials", mine, editAuth{}, fiber.StatusUnauthorized},
+		{"its tracking token", mine, editAuth{token: mineToken}, fiber.StatusOK},
+		{"another report's token", mine, editAuth{token: otherToken}, fiber.StatusForbidden},
+		{"revoked token", mine, editAuth{token: revoked}, fiber.StatusForbidden},
+		{"unknown token", mine, editAuth{token: "guess"}, fiber.StatusForbidden},
+		// a token decides on its own; a matching account can't rescue a bad one
+		{"bad token with the right account", mine, editAuth{token: "guess", bearer: alice}, fiber.StatusForbidden},
+		{"reporter account", mine, editAuth{bearer: alice}, fiber.StatusOK},
+		{"another account", mine, editAuth{bearer: bob}, fiber.StatusForbidden},
+		{"expired session", mine, editAuth{bearer: "stale"}, fiber.StatusUnauthorized},
+		{"account on an anonymous report", anon, editAuth{bearer: alice}, fiber.StatusForbidden},
+		{"anonymous report's token", anon, editAuth{token: anonToken}, fiber.StatusOK},
+	} {
+		if st := editRequest(t, app, "PATCH", tc.report.ID, tc.auth, `{"note":"`+tc.name+`"}`); st != tc.want {
+			t.Errorf("%s: %d, want %d", tc.name, st, tc.want)
+		}
+	}
+
+	got, _ := a.Reports.Get(ctx, mine.ID)
+	if got.Note != "reporter account" || len(got.Revisions) != 2 || got.Revisions[0].By != "token" || got.Revisions[1].By != "account" {
+		t.Fatalf("after edits: note %q, revisions %+v", got.Note, got.Revisions)
+	}
+}
+
+func TestReportEditWindowAndLocks(t *testing.T) {
+	t.Setenv("REPORT_EDIT_WINDOW_MINUTES", "30")
+	a, app := newEditTestAPI(t)
+	other := primitive.NewObjectID()
+	for _, tc := range []struct {
+		name   string
+		age    time.Duration
+		mutate func(*models.Report)
+		want   int
+	}{
+		{"inside the window", 29 * time.Minute, nil, fiber.StatusOK},
+		{"window closed", 31 * time.Minute, nil, fiber.StatusConflict},
+		{"acknowledged", time.Minute, func(r *models.Report) { r.Status = models.StatusAcknowledged }, fiber.StatusConflict},
+		{"resolved", time.Minute, func(r *models.Report) { r.Status = models.StatusResolved }, fiber.StatusConflict},
+		{"merged", time.Minute, func(r *models.Report) { r.DuplicateOf = &other }, fiber.StatusConflict},
+		{"withdrawn", time.Minute, func(r *models.Report) { now := time.Now(); r.WithdrawnAt = &now }, fiber.StatusConflict},
+	} {
+		for _, method := range []string{"PATCH", "DELETE"} {
+			r, token := newEditable(t, a, tc.age, tc.mutate)
+			if st := editRequest(t, app, method, r.ID, editAuth{token: token}, `{"note":"changed"}`); st != tc.want {
+				t.Errorf("%s: %s = %d, want %d", tc.name, method, st, tc.want)
+			}
+			if tc.want != fiber.StatusOK {
+				if got, _ := a.Reports.Get(context.Background(), r.ID); got.Note != r.Note || len(got.Revisions) != 0 ||
+					(tc.mutate == nil && got.WithdrawnAt != nil) {
+					t.Errorf("%s: %s changed the report: %+v", tc.name, method, got)
+				}
+			}
+		}
+	}
+
+	t.Setenv("REPORT_EDIT_WINDOW_MINUTES", "0")
+	r, token := newEditable(t, a, time.Second, nil)
+	if st := editRequest(t, app, "PATCH", r.ID, editAuth{token: token}, `{"note":"changed"}`); st != fiber.StatusConflict {
+		t.Fatalf("edits switched off: %d", st)
+	}
+}
+
+func TestWithdrawReport(t *testing.T) {
+	a, app := newEditTestAPI(t)
+	ctx := context.Background()
+	_, sub, cancel := a.Bus.Subscribe("")
+	defer cancel()
+	r, token := newEditable(t, a, time.Minute, nil)
+
+	if st := editRequest(t, app, "DELETE", r.ID, editAuth{token: token}, ""); st != fiber.StatusOK {
+		t.Fatalf("withdraw = %d", st)
+	}
+	got, _ := a.Reports.Get(ctx, r.ID)
+	if got.WithdrawnAt == nil || len(got.Revisions) != 1 || got.Revisions[0].Kind != models.RevisionWithdraw ||
+		got.Revisions[0].Before.Note != "pump broken" {
+		t.Fatalf("withdrawn report = %+v", got)
+	}
+	select {
+	case ev := <-sub:
+		if ev.Type != events.ReportWithdrawn || ev.ReportID != r.ID.Hex() {
+			t.Fatalf("event = %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no report.withdrawn event")
+	}
+	// hidden from public lists, and final
+	if list, _ := a.Reports.List(ctx, repository.ReportQuery{}); len(list) != 0 {
+		t.Fatalf("withdrawn report listed: %+v", list)
+	}
+	if st := editRequest(t, app, "DELETE", r.ID, editAuth{token: token}, ""); st != fiber.StatusConflict {
+		t.Fatalf("second withdrawal = %d", st)
+	}
+	if st := editRequest(t, app, "PATCH", r.ID, editAuth{token: token}, `{"note":"back"}`); st != fiber.StatusConflict {
+		t.Fatalf("edit after withdrawal = %d", st)
+	}
+}
+
+// patchPhoto sends a multipart edit adding one photo and dropping remove.
+func patchPhoto(t *testing.T, app *fiber.App, id primitive.ObjectID, token, note, remove string) int {
+	t.Helper()
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	_ = w.WriteField("note", note)
+	if remove != "" {
+		_ = w.WriteField("remove_photos", remove)
+	}
+	fw, _ := w.CreateFormFile("photo1", "fixed.jpg")
+	_, _ = fw.Write([]byte("jpeg bytes"))
+	_ = w.Close()
+	req := httptest.NewRequest("PATCH", "/api/reports/"+id.Hex(), &buf)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	req.Header.Set("X-Tracking-Token", token)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode
+}
+
+func TestPatchReportStagesMedia(t *testing.T) {
+	a, app := newEditTestAPI(t)
+	ctx := context.Background()
+	old, err := a.Media.Save("photo", ".jpg", strings.NewReader("old"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, token := newEditable(t, a, time.Minute, func(r *models.Report) { r.PhotoURLs = []string{old} })
+
+	if st := patchPhoto(t, app, r.ID, token, "fixed photo", old); st != fiber.StatusOK {
+		t.Fatalf("patch = %d", st)
+	}
+	got, _ := a.Reports.Get(ctx, r.ID)
+	if len(got.PhotoURLs) != 1 || got.PhotoURLs[0] == old || got.Note != "fixed photo" {
+		t.Fatalf("after patch = %+v", got)
+	}
+	if p, _ := a.Media.Path(got.PhotoURLs[0]); !fileExists(p) {
+		t.Fatal("new photo not committed to the store")
+	}
+	if p, _ := a.Media.Path(old); fileExists(p) {
+		t.Fatal("removed photo still on disk")
+	}
+	if staged, _ := a.Media.Staged(); len(staged) != 0 {
+		t.Fatalf("left staged: %+v", staged)
+	}
+
+	// refused edits leave nothing behind
+	r2, token2 := newEditable(t, a, time.Minute, func(r *models.Report) { r.Status = models.StatusInProgress })
+	if st := patchPhoto(t, app, r2.ID, token2, "late", ""); st != fiber.StatusConflict {
+		t.Fatalf("locked patch = %d", st)
+	}
+	if files, _ := a.Media.List(); len(files) != 1 {
+		t.Fatalf("store after a refused edit: %+v", files)
+	}
+	if staged, _ := a.Media.Staged(); len(staged) != 0 {
+		t.Fatalf("left staged after a refused edit: %+v", staged)
+	}
+}
+
+func TestPatchReportUndoesEditWhenMediaCommitFails(t *testing.T) {
+	a, app := newEditTestAPI(t)
+	ctx := context.Background()
+	r, token := newEditable(t, a, time.Minute, nil)
+	// Dir can't be created, so committing the batch fails after the update
+	if err := os.WriteFile(a.Media.Dir, []byte("not a directory"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if st := patchPhoto(t, app, r.ID, token, "with photo", ""); st != fiber.StatusInternalServerError {
+		t.Fatalf("patch = %d", st)
+	}
+	got, _ := a.Reports.Get(ctx, r.ID)
+	if got.Note != "pump broken" || len(got.PhotoURLs) != 0 || len(got.Revisions) != 0 {
+		t.Fatalf("edit not undone: %+v", got)
+	}
+	if staged, _ := a.Media.Staged(); len(staged) != 0 {
+		t.Fatalf("left staged: %+v", staged)
+	}
+}
+
+func fileExists(p string) bool {
+	_, err := os.Stat(p)
+	return err == nil
+}
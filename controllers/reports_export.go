@@ -0,0 +1,195 @@
+// path: controllers/reports_export.go
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"meniba/models"
+	"meniba/repository"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	exportBatch = 500
+	exportMax   = 50000
+	geoJSONMax  = 5000
+)
+
+var exportHeader = []string{
+	"id", "created_at", "captured_at", "category", "status", "source", "note", "area_label",
+	"lat", "lng", "privacy_radius_m", "district", "chiefdom", "region",
+	"confirmations", "duplicate_of", "has_voice", "photos",
+}
+
+// HandleExportReports downloads every report matching the list filters as
+// CSV. If more than exportMax match it fails with 413 rather than cut the
+// file short.
+// GET /api/reports/export
+func (a *API) HandleExportReports(c *fiber.Ctx) error {
+	q, err := reportQuery(c)
+	if err != nil {
+		return badReq(c, err.Error())
+	}
+	ctx, cancel := context.WithTimeout(c.Context(), 60*time.Second)
+	defer cancel()
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(exportHeader)
+	err = a.eachReport(ctx, q, exportMax, func(r models.Report) error {
+		return w.Write(csvRow(r))
+	})
+	if errors.Is(err, errTooManyRows) {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(ErrorResp{OK: false,
+			Error: "more than " + strconv.Itoa(exportMax) + " reports match; narrow the filters (e.g. start_date, end_date)"})
+	}
+	if err != nil {
+		return serverErr(c, err)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return serverErr(c, err)
+	}
+
+	name := "reports-" + time.Now().UTC().Format("20060102") + ".csv"
+	c.Set("Content-Type", "text/csv; charset=utf-8")
+	c.Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	return c.Status(fiber.StatusOK).Send(buf.Bytes())
+}
+
+func csvRow(r models.Report) []string {
+	radius, dupOf, captured := "", "", ""
+	if r.CapturedAt != nil {
+		captured = r.CapturedAt.UTC().Format(time.RFC3339)
+	}
+	if r.PrivacyRadiusM != nil {
+		radius = strconv.Itoa(*r.PrivacyRadiusM)
+	}
+	if r.DuplicateOf != nil {
+		dupOf = r.DuplicateOf.Hex()
+	}
+	return []string{
+		r.ID.Hex(),
+		r.CreatedAt.UTC().Format(time.RFC3339),
+		captured,
+		r.Category,
+		r.CurrentStatus(),
+		r.Source,
+		csvText(r.Note),
+		csvText(r.AreaLabel),
+		strconv.FormatFloat(r.Lat, 'f', 6, 64),
+		strconv.FormatFloat(r.Lng, 'f', 6, 64),
+		radius,
+		r.District,
+		r.Chiefdom,
+		r.Region,
+		strconv.Itoa(r.Confirmations),
+		dupOf,
+		strconv.FormatBool(r.VoiceURL != ""),
+		strconv.Itoa(len(r.PhotoURLs)),
+	}
+}
+
+// csvText defuses free text that a spreadsheet would run as a formula.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+type geoJSONFeature struct {
+	Type       string       `json:"type"`
+	ID         string       `json:"id"`
+	Geometry   geoJSONPoint `json:"geometry"`
+	Properties ReportItem   `json:"properties"`
+}
+
+type geoJSONPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+type geoJSONCollection struct {
+	Type       string           `json:"type"`
+	Features   []geoJSONFeature `json:"features"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+// HandleReportsGeoJSON returns matching reports as a GeoJSON
+// FeatureCollection, paged like the list endpoint.
+// GET /api/reports/geojson?limit=&cursor=
+func (a *API) HandleReportsGeoJSON(c *fiber.Ctx) error {
+	q, err := reportQuery(c)
+	if err != nil {
+		return badReq(c, err.Error())
+	}
+	limit := queryLimit(c, 1000, geoJSONMax)
+	if err := applyCursor(&q, c.Query("cursor")); err != nil {
+		return badReq(c, err.Error())
+	}
+	q.Limit = limit + 1
+
+	ctx, cancel := context.WithTimeout(c.Context(), 15*time.Second)
+	defer cancel()
+
+	docs, err := a.Reports.List(ctx, q)
+	if err != nil {
+		return serverErr(c, err)
+	}
+	out := geoJSONCollection{Type: "FeatureCollection", Features: make([]geoJSONFeature, 0, len(docs))}
+	if len(docs) > limit {
+		docs = docs[:limit]
+		out.NextCursor = nextCursor(q, docs)
+	}
+	for _, d := range docs {
+		out.Features = append(out.Features, geoJSONFeature{
+			Type:       "Feature",
+			ID:         d.ID.Hex(),
+			Geometry:   geoJSONPoint{Type: "Point", Coordinates: [2]float64{d.Lng, d.Lat}},
+			Properties: toReportItem(d),
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(out, "application/geo+json")
+}
+
+// errTooManyRows is returned by eachReport when more than max reports
+// match.
+var errTooManyRows = errors.New("too many matching reports")
+
+// eachReport pages through every report matching q, newest first. It
+// calls fn for at most max documents and returns errTooManyRows if more
+// match, so callers never serve a silently truncated export.
+func (a *API) eachReport(ctx context.Context, q repository.ReportQuery, max int, fn func(models.Report) error) error {
+	seen := 0
+	for {
+		// one past the cap, to tell "exactly max" from "more than max"
+		q.Limit = min(exportBatch, max-seen+1)
+		docs, err := a.Reports.List(ctx, q)
+		if err != nil {
+			return err
+		}
+		if seen+len(docs) > max {
+			return errTooManyRows
+		}
+		for _, d := range docs {
+			if err := fn(d); err != nil {
+				return err
+			}
+		}
+		seen += len(docs)
+		if len(docs) < q.Limit {
+			return nil
+		}
+		if err := applyCursor(&q, nextCursor(q, docs)); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"meniba/models"
+	"meniba/repository"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestEachReportRefusesToTruncate(t *testing.T) {
+	a := &API{Reports: repository.NewMemory()}
+	ctx := context.Background()
+	add := func(n int) {
+		for i := 0; i < n; i++ {
+			if err := a.Reports.Create(ctx, &models.Report{Category: "water", CreatedAt: time.Now().UTC()}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	count := func(max int) (int, error) {
+		n := 0
+		err := a.eachReport(ctx, repository.ReportQuery{}, max, func(models.Report) error { n++; return nil })
+		return n, err
+	}
+
+	add(exportBatch + 2)
+	if n, err := count(exportBatch + 2); err != nil || n != exportBatch+2 {
+		t.Fatalf("exactly at the cap: %d, %v", n, err)
+	}
+	if n, err := count(exportBatch + 5); err != nil || n != exportBatch+2 {
+		t.Fatalf("under the cap: %d, %v", n, err)
+	}
+	if n, err := count(exportBatch + 1); !errors.Is(err, errTooManyRows) || n > exportBatch+1 {
+		t.Fatalf("over the cap: %d, %v", n, err)
+	}
+	if _, err := count(3); !errors.Is(err, errTooManyRows) {
+		t.Fatalf("over the cap in the first batch: %v", err)
+	}
+}
+
+func TestExportReportsCSV(t *testing.T) {
+	a := &API{Reports: repository.NewMemory()}
+	app := fiber.New()
+	app.Get("/api/reports/export", a.HandleExportReports)
+	ctx := context.Background()
+	for _, note := range []string{"burst pipe", "no water since Monday"} {
+		if err := a.Reports.Create(ctx, &models.Report{Category: "water", Note: note, CreatedAt: time.Now().UTC()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/reports/export?category=water", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	if resp.StatusCode != fiber.StatusOK || len(lines) != 3 || !strings.HasPrefix(lines[0], "id,created_at") {
+		t.Fatalf("export = %d %q", resp.StatusCode, body)
+	}
+}
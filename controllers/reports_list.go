@@ -3,18 +3,20 @@ package controllers
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"net/url"
 	"strconv"
-	"strings"
 	"time"
 
-	"meniba/database"
 	"meniba/models"
+	"meniba/repository"
 
 	"github.com/gofiber/fiber/v2"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type ReportItem struct {
@@ -27,176 +29,272 @@ type ReportItem struct {
 	AccuracyM      *int     `json:"accuracy_m,omitempty"`
 	PrivacyRadiusM *int     `json:"privacy_radius_m,omitempty"`
 	Anonymous      bool     `json:"anonymous"`
+	District       string   `json:"district,omitempty"`
+	Chiefdom       string   `json:"chiefdom,omitempty"`
+	Region         string   `json:"region,omitempty"`
+	Status         string   `json:"status"`
 	CreatedAt      string   `json:"created_at"`
 	VoiceURL       string   `json:"voice_url,omitempty"`
 	PhotoURLs      []string `json:"photo_urls,omitempty"`
+	Confirmations  int      `json:"confirmations,omitempty"`
+	DuplicateOf    string   `json:"duplicate_of,omitempty"`
+	CapturedAt     string   `json:"captured_at,omitempty"`
+	DistanceM      *int     `json:"distance_m,omitempty"` // from near=, when given
+	Snippet        string   `json:"snippet,omitempty"`    // search hit in context, HTML with <mark>
+	Agencies       []string `json:"agencies,omitempty"`
+	AckDueAt       string   `json:"ack_due_at,omitempty"`
+	ResolveDueAt   string   `json:"resolve_due_at,omitempty"`
+	Overdue        bool     `json:"overdue,omitempty"`
+	WithdrawnAt    string   `json:"withdrawn_at,omitempty"`
 }
 
 type ReportListResp struct {
 	OK         bool         `json:"ok"`
 	Items      []ReportItem `json:"items"`
 	NextCursor string       `json:"next_cursor,omitempty"`
+	Total      *int64       `json:"total,omitempty"` // only with include_total=true
 }
 
-func HandleListReports(c *fiber.Ctx) error {
-	limit := 20
-	if v := c.Query("limit"); v != "" {
-		if n, err := strconv.Atoi(v); err == nil {
-			if n < 1 {
-				n = 1
-			}
-			if n > 100 {
-				n = 100
-			}
-			limit = n
-		}
-	}
-
-	filter := bson.M{}
-
-	if cat := c.Query("category"); cat != "" {
-		filter["category"] = cat
-	}
-	if sd := c.Query("start_date"); sd != "" {
-		if t, err := time.Parse(time.RFC3339, sd); err == nil {
-			setRange(filter, "created_at", "$gte", t)
-		} else {
-			return badReq(c, "invalid start_date (RFC3339)")
-		}
-	}
-	if ed := c.Query("end_date"); ed != "" {
-		if t, err := time.Parse(time.RFC3339, ed); err == nil {
-			setRange(filter, "created_at", "$lte", t)
-		} else {
-			return badReq(c, "invalid end_date (RFC3339)")
-		}
-	}
-
-	if hm := c.Query("has_media"); hm != "" {
-		has := parseBool(hm)
-		if has {
-			filter["$or"] = []bson.M{
-				{"voice_url": bson.M{"$exists": true, "$ne": ""}},
-				{"photo_urls.0": bson.M{"$exists": true}},
-			}
-		} else {
-			filter["$and"] = append(asArr(filter["$and"]),
-				bson.M{"$or": []bson.M{
-					{"voice_url": bson.M{"$exists": false}},
-					{"voice_url": ""},
-				}},
-				bson.M{"photo_urls.0": bson.M{"$exists": false}},
-			)
-		}
-	}
-
-	if bb := c.Query("bbox"); bb != "" {
-		minLng, minLat, maxLng, maxLat, err := parseBbox(bb)
-		if err != nil {
-			return badReq(c, "invalid bbox (minLng,minLat,maxLng,maxLat)")
-		}
-		filter["lat"] = bson.M{"$gte": minLat, "$lte": maxLat}
-		filter["lng"] = bson.M{"$gte": minLng, "$lte": maxLng}
+func (a *API) HandleListReports(c *fiber.Ctx) error {
+	f, err := reportQuery(c)
+	if err != nil {
+		return badReq(c, err.Error())
 	}
+	return a.listReports(c, f)
+}
 
-	if cursorHex := c.Query("cursor"); cursorHex != "" {
-		if oid, err := primitive.ObjectIDFromHex(cursorHex); err == nil {
-			filter["_id"] = bson.M{"$lt": oid}
-		} else {
-			return badReq(c, "invalid cursor")
-		}
+// listReports serves one page of reports matching f, reading paging and
+// output options from the request.
+func (a *API) listReports(c *fiber.Ctx, f repository.ReportQuery) error {
+	limit := queryLimit(c, 20, 100)
+	if err := applyCursor(&f, c.Query("cursor")); err != nil {
+		return badReq(c, err.Error())
 	}
-
-	findOpts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(int64(limit + 1))
+	f.Limit = limit + 1
 
 	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
 	defer cancel()
 
-	cur, err := database.Col("reports").Find(ctx, filter, findOpts)
+	docs, err := a.Reports.List(ctx, f)
 	if err != nil {
 		return serverErr(c, err)
 	}
-	defer cur.Close(ctx)
-
-	items := make([]ReportItem, 0, limit)
-	var nextCursor string
-	count := 0
-
-	for cur.Next(ctx) {
-		var doc models.Report
-		if err := cur.Decode(&doc); err != nil {
+	var total *int64
+	if parseBool(c.Query("include_total")) {
+		n, err := a.Reports.Count(ctx, f)
+		if err != nil {
 			return serverErr(c, err)
 		}
-		count++
-		if count > limit {
-			nextCursor = doc.ID.Hex()
-			break
+		total = &n
+	}
+
+	var next string
+	if len(docs) > limit {
+		docs = docs[:limit]
+		next = nextCursor(f, docs)
+	}
+	items := make([]ReportItem, 0, len(docs))
+	for _, doc := range docs {
+		item := toReportItem(doc)
+		if f.Text != "" {
+			item.Snippet = searchSnippet(doc, f.Text)
+		}
+		if f.Near != nil {
+			d := int(math.Round(haversineM(f.Near.Lat, f.Near.Lng, doc.Lat, doc.Lng)))
+			item.DistanceM = &d
 		}
-		items = append(items, ReportItem{
-			ID:             doc.ID.Hex(),
-			Category:       doc.Category,
-			Note:           doc.Note,
-			AreaLabel:      doc.AreaLabel,
-			Lat:            doc.Lat,
-			Lng:            doc.Lng,
-			AccuracyM:      doc.AccuracyM,
-			PrivacyRadiusM: doc.PrivacyRadiusM,
-			Anonymous:      doc.Anonymous,
-			CreatedAt:      doc.CreatedAt.UTC().Format(time.RFC3339),
-			VoiceURL:       doc.VoiceURL,
-			PhotoURLs:      doc.PhotoURLs,
-		})
-	}
-	if err := cur.Err(); err != nil {
-		return serverErr(c, err)
+		items = append(items, item)
 	}
 
 	return c.Status(fiber.StatusOK).JSON(ReportListResp{
 		OK:         true,
 		Items:      items,
-		NextCursor: nextCursor,
+		NextCursor: next,
+		Total:      total,
 	})
 }
 
 // helpers reused here
 
-func setRange(m bson.M, key, op string, t time.Time) {
-	if m[key] == nil {
-		m[key] = bson.M{}
+func toReportItem(doc models.Report) ReportItem {
+	item := ReportItem{
+		ID:             doc.ID.Hex(),
+		Category:       doc.Category,
+		Note:           doc.Note,
+		AreaLabel:      doc.AreaLabel,
+		Lat:            doc.Lat,
+		Lng:            doc.Lng,
+		AccuracyM:      doc.AccuracyM,
+		PrivacyRadiusM: doc.PrivacyRadiusM,
+		Anonymous:      doc.Anonymous,
+		District:       doc.District,
+		Chiefdom:       doc.Chiefdom,
+		Region:         doc.Region,
+		Status:         doc.CurrentStatus(),
+		CreatedAt:      doc.CreatedAt.UTC().Format(time.RFC3339),
+		VoiceURL:       doc.VoiceURL,
+		PhotoURLs:      doc.PhotoURLs,
+		Confirmations:  doc.Confirmations,
+	}
+	if doc.CapturedAt != nil {
+		item.CapturedAt = doc.CapturedAt.UTC().Format(time.RFC3339)
 	}
-	m[key].(bson.M)[op] = t
+	if doc.DuplicateOf != nil {
+		item.DuplicateOf = doc.DuplicateOf.Hex()
+	}
+	if doc.WithdrawnAt != nil {
+		item.WithdrawnAt = doc.WithdrawnAt.UTC().Format(time.RFC3339)
+	}
+	for _, id := range doc.Agencies {
+		item.Agencies = append(item.Agencies, id.Hex())
+	}
+	if s := doc.SLA; s != nil {
+		if s.AckDueAt != nil {
+			item.AckDueAt = s.AckDueAt.UTC().Format(time.RFC3339)
+		}
+		if s.ResolveDueAt != nil {
+			item.ResolveDueAt = s.ResolveDueAt.UTC().Format(time.RFC3339)
+		}
+		item.Overdue = doc.Overdue(time.Now())
+	}
+	return item
+}
+
+// reportQuery parses the shared filter params of the request.
+func reportQuery(c *fiber.Ctx) (repository.ReportQuery, error) {
+	v, err := url.ParseQuery(string(c.Request().URI().QueryString()))
+	if err != nil {
+		return repository.ReportQuery{}, &repository.QueryError{Param: "query", Reason: "malformed query string"}
+	}
+	return repository.ParseReportQuery(v)
 }
 
-func parseBbox(s string) (minLng, minLat, maxLng, maxLat float64, err error) {
-	parts := strings.Split(s, ",")
-	if len(parts) != 4 {
-		return 0, 0, 0, 0, fmt.Errorf("need 4 numbers")
+// queryLimit reads ?limit=, clamped to [1, max].
+func queryLimit(c *fiber.Ctx, def, max int) int {
+	n, err := strconv.Atoi(c.Query("limit"))
+	if err != nil {
+		return def
 	}
-	parse := func(i int) (float64, error) { return strconv.ParseFloat(strings.TrimSpace(parts[i]), 64) }
-	if minLng, err = parse(0); err != nil {
-		return
+	if n < 1 {
+		n = 1
 	}
-	if minLat, err = parse(1); err != nil {
-		return
+	if n > max {
+		n = max
 	}
-	if maxLng, err = parse(2); err != nil {
-		return
+	return n
+}
+
+// listCursor is what an opaque cursor carries: the sort it was issued
+// for and where the page ended, either as an offset (relevance order has
+// no stable key) or as the last report's sort value and id.
+type listCursor struct {
+	Sort   string     `json:"s,omitempty"`
+	Offset int        `json:"o,omitempty"`
+	ID     string     `json:"i,omitempty"`
+	Time   *time.Time `json:"t,omitempty"`
+	Str    *string    `json:"k,omitempty"`
+	Num    *float64   `json:"n,omitempty"`
+}
+
+// cursorSort identifies q's order, so a cursor can't be replayed under a
+// different one.
+func cursorSort(q repository.ReportQuery) string {
+	s := q.Sort.Field
+	if q.Sort.Desc {
+		s = "-" + s
 	}
-	if maxLat, err = parse(3); err != nil {
-		return
+	if s == "" && q.Text != "" {
+		s = "relevance"
 	}
-	if maxLng < minLng || maxLat < minLat {
-		return 0, 0, 0, 0, fmt.Errorf("max must be >= min")
+	if q.Sort.Field == repository.SortDistance && q.Near != nil {
+		s += fmt.Sprintf("@%g,%g", q.Near.Lat, q.Near.Lng)
 	}
-	return
+	return s
+}
+
+func usesOffset(q repository.ReportQuery) bool {
+	return q.Text != "" && q.Sort.Field == ""
 }
 
-func asArr(v any) []bson.M {
-	if v == nil {
-		return []bson.M{}
+// applyCursor positions q after the page a cursor was issued for.
+func applyCursor(q *repository.ReportQuery, cursor string) error {
+	if cursor == "" {
+		return nil
+	}
+	bad := errors.New("invalid cursor")
+	// why: cursors issued before they became opaque were the last
+	// report's bare ObjectID, keyset on _id under the default order
+	if oid, err := primitive.ObjectIDFromHex(cursor); err == nil {
+		if cursorSort(*q) != "" {
+			return errors.New("cursor was issued for a different sort")
+		}
+		q.After = &repository.Position{ID: oid}
+		return nil
 	}
-	if a, ok := v.([]bson.M); ok {
-		return a
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return bad
+	}
+	var lc listCursor
+	if err := json.Unmarshal(raw, &lc); err != nil {
+		return bad
+	}
+	if lc.Sort != cursorSort(*q) {
+		return errors.New("cursor was issued for a different sort")
+	}
+	if usesOffset(*q) {
+		if lc.Offset < 0 {
+			return bad
+		}
+		q.Skip = lc.Offset
+		return nil
+	}
+	oid, err := primitive.ObjectIDFromHex(lc.ID)
+	if err != nil {
+		return bad
+	}
+	pos := &repository.Position{ID: oid}
+	switch {
+	case lc.Time != nil:
+		pos.Value = lc.Time.UTC()
+	case lc.Str != nil:
+		pos.Value = *lc.Str
+	case lc.Num != nil:
+		pos.Value = *lc.Num
+	}
+	// the value must have the type SortValue yields for this sort
+	if want := q.SortValue(models.Report{}); fmt.Sprintf("%T", want) != fmt.Sprintf("%T", pos.Value) {
+		return bad
+	}
+	q.After = pos
+	return nil
+}
+
+// nextCursor is the cursor for the page after docs, which q returned.
+func nextCursor(q repository.ReportQuery, docs []models.Report) string {
+	lc := listCursor{Sort: cursorSort(q)}
+	if usesOffset(q) {
+		lc.Offset = q.Skip + len(docs)
+	} else {
+		last := docs[len(docs)-1]
+		lc.ID = last.ID.Hex()
+		switch v := q.SortValue(last).(type) {
+		case time.Time:
+			lc.Time = &v
+		case string:
+			lc.Str = &v
+		case float64:
+			lc.Num = &v
+		}
+	}
+	raw, _ := json.Marshal(lc)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// searchSnippet shows where a report matched a text search.
+func searchSnippet(doc models.Report, text string) string {
+	if s := repository.Snippet(doc.Note, text, 160); s != "" {
+		return s
 	}
-	return []bson.M{}
+	return repository.Snippet(doc.AreaLabel, text, 160)
 }
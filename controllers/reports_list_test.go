@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"meniba/models"
+	"meniba/repository"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func listPage(t *testing.T, app *fiber.App, target string) (int, ReportListResp) {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest("GET", target, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out ReportListResp
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+	return resp.StatusCode, out
+}
+
+func TestListReportsCursors(t *testing.T) {
+	a := &API{Reports: repository.NewMemory()}
+	app := fiber.New()
+	app.Get("/api/reports", a.HandleListReports)
+	ctx := context.Background()
+
+	var ids []string // oldest first
+	for i := 0; i < 5; i++ {
+		r := &models.Report{Category: "water", CreatedAt: time.Now().UTC()}
+		if err := a.Reports.Create(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, r.ID.Hex())
+	}
+
+	st, page := listPage(t, app, "/api/reports?limit=2")
+	if st != fiber.StatusOK || len(page.Items) != 2 || page.Items[0].ID != ids[4] || page.NextCursor == "" {
+		t.Fatalf("first page = %d %+v", st, page)
+	}
+	st, page = listPage(t, app, "/api/reports?limit=2&cursor="+page.NextCursor)
+	if st != fiber.StatusOK || len(page.Items) != 2 || page.Items[0].ID != ids[2] {
+		t.Fatalf("second page = %d %+v", st, page)
+	}
+
+	// clients still holding a bare ObjectID from before opaque cursors
+	st, page = listPage(t, app, "/api/reports?limit=2&cursor="+ids[3])
+	if st != fiber.StatusOK || len(page.Items) != 2 || page.Items[0].ID != ids[2] || page.Items[1].ID != ids[1] {
+		t.Fatalf("legacy cursor page = %d %+v", st, page)
+	}
+	if st, _ := listPage(t, app, "/api/reports?sort=category&cursor="+ids[3]); st != fiber.StatusBadRequest {
+		t.Fatalf("legacy cursor under another sort = %d", st)
+	}
+	if st, _ := listPage(t, app, "/api/reports?cursor=not-a-cursor"); st != fiber.StatusBadRequest {
+		t.Fatalf("garbage cursor = %d", st)
+	}
+}
@@ -0,0 +1,170 @@
+// path: controllers/retention.go
+package controllers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"meniba/audit"
+	"meniba/jobs"
+	"meniba/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type RetentionRuleListResp struct {
+	OK    bool                   `json:"ok"`
+	Items []models.RetentionRule `json:"items"`
+}
+
+type RetentionRunListResp struct {
+	OK    bool                  `json:"ok"`
+	Items []models.RetentionRun `json:"items"`
+}
+
+// HandleCreateRetentionRule adds a retention rule. It takes effect on the
+// next scheduled run; try it first with a dry run.
+// POST /api/admin/retention/rules {"name": "", "action": "delete_voice", "after_days": 90, "statuses": [], "categories": [], "radius_m": 0}
+func (a *API) HandleCreateRetentionRule(c *fiber.Ctx) error {
+	var p models.RetentionRulePayload
+	if err := c.BodyParser(&p); err != nil {
+		return badReq(c, "invalid JSON")
+	}
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return badReq(c, "missing name")
+	}
+	if !models.ValidRetentionAction(p.Action) {
+		return badReq(c, "invalid action")
+	}
+	if p.AfterDays < 1 {
+		return badReq(c, "after_days must be at least 1")
+	}
+	statuses := trimAll(p.Statuses)
+	for _, s := range statuses {
+		if !models.ValidStatus(s) {
+			return badReq(c, "invalid status: "+s)
+		}
+	}
+	if p.RadiusM < 0 || (p.RadiusM > 0 && p.Action != models.RetentionCoarsenLocation) {
+		return badReq(c, "radius_m only applies to coarsen_location")
+	}
+
+	rule := models.RetentionRule{
+		Name:       p.Name,
+		Action:     p.Action,
+		AfterDays:  p.AfterDays,
+		Statuses:   statuses,
+		Categories: trimAll(p.Categories),
+		RadiusM:    p.RadiusM,
+		Active:     true,
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+	res, err := a.DB.Col(jobs.RetentionRulesCol).InsertOne(ctx, rule)
+	if err != nil {
+		return serverErr(c, err)
+	}
+	rule.ID = res.InsertedID.(primitive.ObjectID)
+	a.recordAudit(ctx, a.actor(c), audit.Entry{Action: audit.RetentionRuleCreate, Target: "retention_rule", TargetID: rule.ID, After: rule})
+	return c.Status(fiber.StatusOK).JSON(rule)
+}
+
+// HandleListRetentionRules lists retention rules, oldest first.
+// GET /api/admin/retention/rules
+func (a *API) HandleListRetentionRules(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+
+	cur, err := a.DB.Col(jobs.RetentionRulesCol).Find(ctx, bson.M{},
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return serverErr(c, err)
+	}
+	items := make([]models.RetentionRule, 0)
+	if err := cur.All(ctx, &items); err != nil {
+		return serverErr(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(RetentionRuleListResp{OK: true, Items: items})
+}
+
+// HandleDeleteRetentionRule removes a retention rule. Data it already
+// removed stays removed.
+// DELETE /api/admin/retention/rules/:id
+func (a *API) HandleDeleteRetentionRule(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return badReq(c, "invalid id")
+	}
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+
+	var rule models.RetentionRule
+	err = a.DB.Col(jobs.RetentionRulesCol).FindOneAndDelete(ctx, bson.M{"_id": id}).Decode(&rule)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound(c, "retention rule not found")
+	}
+	if err != nil {
+		return serverErr(c, err)
+	}
+	a.recordAudit(ctx, a.actor(c), audit.Entry{Action: audit.RetentionRuleDelete, Target: "retention_rule", TargetID: id, Before: rule})
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"ok": true})
+}
+
+// HandleRunRetention runs the active rules now and returns the run. It is
+// a dry run unless dry_run=false is passed.
+// POST /api/admin/retention/run?dry_run=false
+func (a *API) HandleRunRetention(c *fiber.Ctx) error {
+	if a.Retention == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(ErrorResp{OK: false, Error: "retention disabled"})
+	}
+	dryRun := true
+	if v := c.Query("dry_run"); v != "" {
+		dryRun = parseBool(v)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Minute)
+	defer cancel()
+
+	run, err := a.Retention.Apply(ctx, time.Now().UTC(), dryRun)
+	if err != nil {
+		return serverErr(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(run)
+}
+
+// HandleListRetentionRuns returns past runs, newest first.
+// GET /api/admin/retention/runs?dry_run=&limit=
+func (a *API) HandleListRetentionRuns(c *fiber.Ctx) error {
+	filter := bson.M{}
+	if v := c.Query("dry_run"); v != "" {
+		filter["dry_run"] = parseBool(v)
+	}
+	limit := 50
+	if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 && v <= 500 {
+		limit = v
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+
+	cur, err := a.DB.Col(jobs.RetentionRunsCol).Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "started_at", Value: -1}}).SetLimit(int64(limit)))
+	if err != nil {
+		return serverErr(c, err)
+	}
+	items := make([]models.RetentionRun, 0, limit)
+	if err := cur.All(ctx, &items); err != nil {
+		return serverErr(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(RetentionRunListResp{OK: true, Items: items})
+}
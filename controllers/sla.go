@@ -0,0 +1,125 @@
+// path: controllers/sla.go
+package controllers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"meniba/audit"
+	"meniba/jobs"
+	"meniba/models"
+	"meniba/routing"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type SLAPolicyListResp struct {
+	OK    bool               `json:"ok"`
+	Items []models.SLAPolicy `json:"items"`
+}
+
+// HandleCreateSLAPolicy adds an SLA definition. Category and agency_id are
+// optional and narrow which reports it covers.
+// POST /api/admin/sla {"category": "", "agency_id": "", "ack_minutes": 240, "resolve_minutes": 4320, "supervisor_id": ""}
+func (a *API) HandleCreateSLAPolicy(c *fiber.Ctx) error {
+	var p models.SLAPolicyPayload
+	if err := c.BodyParser(&p); err != nil {
+		return badReq(c, "invalid JSON")
+	}
+	if p.AckMinutes < 0 || p.ResolveMinutes < 0 || (p.AckMinutes == 0 && p.ResolveMinutes == 0) {
+		return badReq(c, "set ack_minutes and/or resolve_minutes")
+	}
+	if p.AckMinutes > 0 && p.ResolveMinutes > 0 && p.ResolveMinutes < p.AckMinutes {
+		return badReq(c, "resolve_minutes must not be less than ack_minutes")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+
+	pol := models.SLAPolicy{
+		Category:       strings.TrimSpace(p.Category),
+		AckMinutes:     p.AckMinutes,
+		ResolveMinutes: p.ResolveMinutes,
+		Active:         true,
+		CreatedAt:      time.Now().UTC(),
+	}
+	for _, f := range []struct {
+		param string
+		value string
+		dst   **primitive.ObjectID
+	}{
+		{"agency_id", p.AgencyID, &pol.AgencyID},
+		{"supervisor_id", p.SupervisorID, &pol.SupervisorID},
+	} {
+		if strings.TrimSpace(f.value) == "" {
+			continue
+		}
+		oid, err := primitive.ObjectIDFromHex(strings.TrimSpace(f.value))
+		if err != nil {
+			return badReq(c, "invalid "+f.param)
+		}
+		n, err := a.DB.Col(routing.AgenciesCol).CountDocuments(ctx, bson.M{"_id": oid, "active": true})
+		if err != nil {
+			return serverErr(c, err)
+		}
+		if n == 0 {
+			return badReq(c, f.param+": unknown or inactive agency")
+		}
+		*f.dst = &oid
+	}
+
+	res, err := a.DB.Col(jobs.SLAPoliciesCol).InsertOne(ctx, pol)
+	if err != nil {
+		return serverErr(c, err)
+	}
+	pol.ID = res.InsertedID.(primitive.ObjectID)
+	a.recordAudit(ctx, a.actor(c), audit.Entry{Action: audit.SLAPolicyCreate, Target: "sla_policy", TargetID: pol.ID, After: pol})
+	return c.Status(fiber.StatusOK).JSON(pol)
+}
+
+// HandleListSLAPolicies lists SLA definitions, oldest first.
+// GET /api/admin/sla
+func (a *API) HandleListSLAPolicies(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+
+	cur, err := a.DB.Col(jobs.SLAPoliciesCol).Find(ctx, bson.M{},
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return serverErr(c, err)
+	}
+	items := make([]models.SLAPolicy, 0)
+	if err := cur.All(ctx, &items); err != nil {
+		return serverErr(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(SLAPolicyListResp{OK: true, Items: items})
+}
+
+// HandleDeleteSLAPolicy removes an SLA definition; reports it covered move
+// to the next matching policy, or stop being timed, on the next check.
+// DELETE /api/admin/sla/:id
+func (a *API) HandleDeleteSLAPolicy(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return badReq(c, "invalid id")
+	}
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+
+	var pol models.SLAPolicy
+	err = a.DB.Col(jobs.SLAPoliciesCol).FindOneAndDelete(ctx, bson.M{"_id": id}).Decode(&pol)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound(c, "sla policy not found")
+	}
+	if err != nil {
+		return serverErr(c, err)
+	}
+	a.recordAudit(ctx, a.actor(c), audit.Entry{Action: audit.SLAPolicyDelete, Target: "sla_policy", TargetID: id, Before: pol})
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"ok": true})
+}
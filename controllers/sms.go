@@ -0,0 +1,80 @@
+// path: controllers/sms.go
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"meniba/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const smsHelp = "Send: CATEGORY DISTRICT CHIEFDOM description. Example: ROAD Bo Kakua pothole near market"
+
+// smsInbound is an Africa's Talking-style inbound SMS callback
+// (application/x-www-form-urlencoded).
+type smsInbound struct {
+	From   string `form:"from"`
+	To     string `form:"to"`
+	Text   string `form:"text"`
+	Date   string `form:"date"`
+	ID     string `form:"id"`
+	LinkID string `form:"linkId"`
+}
+
+// HandleInboundSMS turns a structured SMS into a report and replies with a
+// plain-text receipt (or usage help) that the gateway can relay.
+// POST /api/inbound/sms
+func (a *API) HandleInboundSMS(c *fiber.Ctx) error {
+	if st, msg := gatewayAuth(c, "SMS_WEBHOOK_TOKEN"); st != 0 {
+		return c.Status(st).SendString(msg)
+	}
+	var in smsInbound
+	if err := c.BodyParser(&in); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("invalid form")
+	}
+
+	p, err := parseSMS(in.Text)
+	if err != nil {
+		return c.Status(fiber.StatusOK).SendString(err.Error() + ". " + smsHelp)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+	doc, dups, err := a.submitIntake(ctx, p, models.SourceSMS)
+	if err != nil {
+		log.Printf("sms: create report from gateway msg %s failed: %v", in.ID, err)
+		return c.Status(fiber.StatusOK).SendString("Sorry, we could not save your report. Please try again.")
+	}
+	return c.Status(fiber.StatusOK).SendString(intakeReceipt(doc, dups))
+}
+
+// parseSMS reads "CATEGORY DISTRICT [CHIEFDOM] note...". The chiefdom is
+// optional; without it the report sits at the district centroid.
+func parseSMS(text string) (ReportJSON, error) {
+	words := strings.Fields(text)
+	if len(words) < 3 {
+		return ReportJSON{}, errors.New("Message too short")
+	}
+
+	cat, ok := models.CategoryByKeyword(words[0])
+	if !ok {
+		return ReportJSON{}, errors.New("Unknown category " + words[0])
+	}
+	words = words[1:]
+
+	district, cf, words, ok := matchPlace(words)
+	if !ok {
+		return ReportJSON{}, errors.New("Unknown district " + words[0])
+	}
+
+	note := strings.Join(words, " ")
+	if note == "" {
+		return ReportJSON{}, errors.New("Please describe the problem")
+	}
+	return gazetteerReport(cat, district, cf, note), nil
+}
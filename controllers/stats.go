@@ -0,0 +1,34 @@
+// path: controllers/stats.go
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"meniba/repository"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ReportStatsResp struct {
+	OK bool `json:"ok"`
+	repository.ReportStats
+}
+
+// HandleReportStats returns counts by category, status and district for
+// the reports matching the list filters.
+// GET /api/reports/stats
+func (a *API) HandleReportStats(c *fiber.Ctx) error {
+	f, err := reportQuery(c)
+	if err != nil {
+		return badReq(c, err.Error())
+	}
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+
+	st, err := a.Reports.Stats(ctx, f)
+	if err != nil {
+		return serverErr(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(ReportStatsResp{OK: true, ReportStats: st})
+}
@@ -0,0 +1,105 @@
+// path: controllers/status.go
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"meniba/audit"
+	"meniba/events"
+	"meniba/models"
+	"meniba/repository"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// HandleSetStatus moves a report to a new status and records the change.
+// POST /api/admin/reports/:id/status {"status": "resolved", "note": "..."}
+func (a *API) HandleSetStatus(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return badReq(c, "invalid id")
+	}
+	var p models.StatusUpdatePayload
+	if err := c.BodyParser(&p); err != nil {
+		return badReq(c, "invalid JSON")
+	}
+	p.Status = strings.TrimSpace(p.Status)
+	if !models.ValidStatus(p.Status) {
+		return badReq(c, "invalid status")
+	}
+	photos, err := a.photoURLs(p.PhotoURLs)
+	if err != nil {
+		return badReq(c, err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+
+	// why: Update retries on concurrent writes, and the mutator re-reads the
+	// current status each time, so two moderators can't both "win" and emit
+	// two conflicting status_changed events.
+	var change models.StatusChange
+	after, err := a.updateReport(ctx, a.actor(c), audit.ReportStatus, id, func(r *models.Report) error {
+		from := r.CurrentStatus()
+		if from == p.Status {
+			return repository.ErrNoChange
+		}
+		change = models.StatusChange{
+			From:      from,
+			To:        p.Status,
+			Note:      strings.TrimSpace(p.Note),
+			PhotoURLs: photos,
+			At:        time.Now().UTC(),
+		}
+		r.Status = p.Status
+		r.StatusHistory = append(r.StatusHistory, change)
+		return nil
+	})
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return notFound(c, "report not found")
+	case errors.Is(err, repository.ErrConflict):
+		return c.Status(fiber.StatusConflict).JSON(ErrorResp{OK: false, Error: "status changed concurrently; retry"})
+	case err != nil:
+		return serverErr(c, err)
+	}
+	if change.To == "" {
+		return c.Status(fiber.StatusOK).JSON(toReportItem(after))
+	}
+
+	a.Bus.Publish(events.Event{
+		Type:     events.ReportStatusChanged,
+		ReportID: id.Hex(),
+		Report:   &after,
+		Data:     change,
+	})
+	return c.Status(fiber.StatusOK).JSON(toReportItem(after))
+}
+
+// photoURLs accepts files already uploaded to the media store and absolute
+// http(s) URLs.
+func (a *API) photoURLs(in []string) ([]string, error) {
+	if len(in) > 10 {
+		return nil, errors.New("at most 10 photo_urls")
+	}
+	var out []string
+	for _, s := range in {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if _, ok := a.Media.Path(s); !ok {
+			u, err := url.Parse(s)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return nil, errors.New("invalid photo url: " + s)
+			}
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
@@ -0,0 +1,236 @@
+// path: controllers/stream.go
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"meniba/events"
+	"meniba/models"
+	"meniba/repository"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// streamEvent is one SSE message: an opaque resumable id, the event name
+// and the report it concerns.
+type streamEvent struct {
+	id  string
+	typ string
+	doc *models.Report
+}
+
+// HandleReportStream pushes report created/updated events over SSE, and
+// report.removed when a report leaves the filtered view: withdrawn, merged
+// into another, or changed so it no longer matches.
+// GET /api/reports/stream (accepts the list filters)
+// Resumes from the Last-Event-ID header (or ?last_event_id= for clients
+// that can't set headers on the first connect).
+func (a *API) HandleReportStream(c *fiber.Ctx) error {
+	q, err := reportQuery(c)
+	if err != nil {
+		return badReq(c, err.Error())
+	}
+	lastID := c.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = c.Query("last_event_id")
+	}
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no") // why: nginx would otherwise buffer the stream
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var src <-chan streamEvent
+		if wr, ok := a.Reports.(repository.Watcher); ok {
+			s, err := watchReports(ctx, wr, lastID)
+			switch {
+			case err == nil:
+				src = s
+			case !errors.Is(err, repository.ErrWatchUnsupported):
+				log.Printf("stream: change stream unavailable, using in-process events: %v", err)
+			}
+		}
+		if src == nil {
+			src = busEvents(ctx, a.Bus, lastID)
+		}
+
+		view := newStreamView(q)
+		fmt.Fprint(w, "retry: 3000\n\n")
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		ping := time.NewTicker(15 * time.Second)
+		defer ping.Stop()
+		for {
+			select {
+			case ev, ok := <-src:
+				if !ok {
+					return
+				}
+				typ, data, ok := view.render(ev)
+				if !ok {
+					continue
+				}
+				fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", ev.id, typ, data)
+				if err := w.Flush(); err != nil {
+					return
+				}
+			case <-ping.C:
+				fmt.Fprint(w, ": ping\n\n")
+				if err := w.Flush(); err != nil {
+					return
+				}
+			}
+		}
+	})
+	return nil
+}
+
+// streamRemoved is sent when a report leaves a stream's view.
+const streamRemoved = "report.removed"
+
+// maxStreamSeen bounds how many sent ids a connection remembers.
+const maxStreamSeen = 10000
+
+// removedItem is the payload of report.removed; it carries no content,
+// since the report may have been withdrawn by its reporter.
+type removedItem struct {
+	ID          string `json:"id"`
+	Reason      string `json:"reason"` // withdrawn, merged or filtered
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+}
+
+// streamView decides what one connection sees. Withdrawn and merged
+// reports fail q.Match, so a plain filter would silently drop the change
+// that hides them; instead, a report that matched before (it was sent on
+// this connection, or matches but for being hidden) is reported removed.
+type streamView struct {
+	q     repository.ReportQuery
+	loose repository.ReportQuery // q, ignoring withdrawal and merging
+	seen  map[primitive.ObjectID]bool
+}
+
+func newStreamView(q repository.ReportQuery) *streamView {
+	loose := q
+	loose.IncludeWithdrawn, loose.IncludeMerged = true, true
+	return &streamView{q: q, loose: loose, seen: map[primitive.ObjectID]bool{}}
+}
+
+// render returns the event name and payload for ev, or ok=false if this
+// connection shouldn't see it.
+func (v *streamView) render(ev streamEvent) (typ string, data []byte, ok bool) {
+	if ev.doc == nil {
+		return "", nil, false
+	}
+	doc := *ev.doc
+	var payload any
+	switch {
+	case v.q.Match(doc):
+		if len(v.seen) >= maxStreamSeen {
+			v.seen = map[primitive.ObjectID]bool{}
+		}
+		v.seen[doc.ID] = true
+		typ, payload = ev.typ, toReportItem(doc)
+	case ev.typ != events.ReportCreated && (v.seen[doc.ID] || v.loose.Match(doc)):
+		delete(v.seen, doc.ID)
+		rm := removedItem{ID: doc.ID.Hex(), Reason: "filtered"}
+		switch {
+		case doc.WithdrawnAt != nil && !v.q.IncludeWithdrawn:
+			rm.Reason = "withdrawn"
+		case doc.DuplicateOf != nil && !v.q.IncludeMerged && len(v.q.DuplicateOf) == 0:
+			rm.Reason, rm.DuplicateOf = "merged", doc.DuplicateOf.Hex()
+		}
+		typ, payload = streamRemoved, rm
+	default:
+		return "", nil, false
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", nil, false
+	}
+	return typ, data, true
+}
+
+// watchReports adapts a repository change stream. Event ids are the
+// stream's resume tokens, so Last-Event-ID survives API restarts.
+func watchReports(ctx context.Context, wr repository.Watcher, lastID string) (<-chan streamEvent, error) {
+	changes, err := wr.Watch(ctx, lastID)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan streamEvent, 16)
+	go func() {
+		defer close(out)
+		for ch := range changes {
+			typ := events.ReportUpdated
+			if ch.Created {
+				typ = events.ReportCreated
+			}
+			doc := ch.Report
+			select {
+			case out <- streamEvent{id: ch.Token, typ: typ, doc: &doc}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// busEvents adapts the in-process event bus for standalone servers.
+func busEvents(ctx context.Context, bus *events.Bus, lastID string) <-chan streamEvent {
+	replay, live, cancel := bus.Subscribe(lastID)
+	out := make(chan streamEvent, 16)
+	go func() {
+		defer close(out)
+		defer cancel()
+		send := func(ev events.Event) bool {
+			if ev.Report == nil {
+				return true
+			}
+			var typ string
+			switch ev.Type {
+			case events.ReportCreated:
+				typ = events.ReportCreated
+			case events.ReportUpdated, events.ReportStatusChanged, events.ReportWithdrawn:
+				typ = events.ReportUpdated
+			default:
+				return true
+			}
+			select {
+			case out <- streamEvent{id: ev.ID, typ: typ, doc: ev.Report}:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		for _, ev := range replay {
+			if !send(ev) {
+				return
+			}
+		}
+		for {
+			select {
+			case ev, ok := <-live:
+				if !ok || !send(ev) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"meniba/events"
+	"meniba/models"
+	"meniba/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestStreamViewReportsRemovals(t *testing.T) {
+	v := newStreamView(repository.ReportQuery{Category: repository.Terms{In: []string{"water"}}})
+	send := func(typ string, r models.Report) (string, map[string]any) {
+		t.Helper()
+		got, data, ok := v.render(streamEvent{id: "1", typ: typ, doc: &r})
+		if !ok {
+			return "", nil
+		}
+		var m map[string]any
+		if err := json.Unmarshal(data, &m); err != nil {
+			t.Fatal(err)
+		}
+		return got, m
+	}
+	now := time.Now().UTC()
+
+	// a report from before this connection, withdrawn now
+	old := models.Report{ID: primitive.NewObjectID(), Category: "water", Note: "secret"}
+	old.WithdrawnAt = &now
+	typ, m := send(events.ReportUpdated, old)
+	if typ != streamRemoved || m["id"] != old.ID.Hex() || m["reason"] != "withdrawn" || m["note"] != nil {
+		t.Fatalf("withdrawal = %s %v", typ, m)
+	}
+
+	// merged into another report
+	canon := primitive.NewObjectID()
+	merged := models.Report{ID: primitive.NewObjectID(), Category: "water", DuplicateOf: &canon}
+	if typ, m := send(events.ReportUpdated, merged); typ != streamRemoved || m["reason"] != "merged" || m["duplicate_of"] != canon.Hex() {
+		t.Fatalf("merge = %s %v", typ, m)
+	}
+
+	// sent on this connection, then recategorised out of the filter
+	r := models.Report{ID: primitive.NewObjectID(), Category: "water"}
+	if typ, _ := send(events.ReportCreated, r); typ != events.ReportCreated {
+		t.Fatalf("create = %q", typ)
+	}
+	r.Category = "roads"
+	if typ, m := send(events.ReportUpdated, r); typ != streamRemoved || m["reason"] != "filtered" {
+		t.Fatalf("recategorised = %s %v", typ, m)
+	}
+	// already removed: later changes outside the filter stay silent
+	if typ, _ := send(events.ReportUpdated, r); typ != "" {
+		t.Fatalf("second update outside filter = %q", typ)
+	}
+
+	// never matched
+	other := models.Report{ID: primitive.NewObjectID(), Category: "roads", WithdrawnAt: &now}
+	if typ, _ := send(events.ReportUpdated, other); typ != "" {
+		t.Fatalf("unrelated withdrawal = %q", typ)
+	}
+
+	// a stream that asked for withdrawn reports keeps them as updates
+	all := newStreamView(repository.ReportQuery{IncludeWithdrawn: true})
+	if typ, _, _ := all.render(streamEvent{typ: events.ReportUpdated, doc: &old}); typ != events.ReportUpdated {
+		t.Fatalf("include_withdrawn stream = %q", typ)
+	}
+}
+
+func TestBusEventsForwardsWithdrawals(t *testing.T) {
+	bus := events.NewBus(10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	src := busEvents(ctx, bus, "")
+
+	now := time.Now().UTC()
+	r := &models.Report{ID: primitive.NewObjectID(), WithdrawnAt: &now}
+	bus.Publish(events.Event{Type: events.ReportWithdrawn, ReportID: r.ID.Hex(), Report: r})
+	select {
+	case ev := <-src:
+		if ev.doc == nil || ev.doc.ID != r.ID || ev.typ != events.ReportUpdated {
+			t.Fatalf("event = %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("withdrawal not forwarded")
+	}
+}
@@ -0,0 +1,125 @@
+// path: controllers/tiles.go
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"meniba/models"
+	"meniba/mvt"
+	"meniba/repository"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// below this zoom tiles carry clusters instead of single reports
+	tileClusterMaxZoom = 12
+	// a denser tile is clustered even at high zoom
+	tileMaxFeatures = 5000
+	// features this far outside the tile (in extent units) are kept so
+	// symbols on the edge aren't clipped
+	tileBuffer = 64
+)
+
+// HandleReportTile serves reports as a Mapbox Vector Tile: a "clusters"
+// layer at low zoom (or when the tile is too dense) and a "reports" layer
+// otherwise. It takes the list filters; bbox is implied by the tile.
+// Positions are privacy-snapped like the clusters endpoint.
+// GET /api/tiles/reports/:z/:x/:y.mvt
+func (a *API) HandleReportTile(c *fiber.Ctx) error {
+	z, errZ := strconv.Atoi(c.Params("z"))
+	x, errX := strconv.Atoi(c.Params("x"))
+	y, errY := strconv.Atoi(c.Params("y"))
+	if errZ != nil || errX != nil || errY != nil || !mvt.ValidTile(z, x, y) {
+		return notFound(c, "no such tile")
+	}
+	q, err := reportQuery(c)
+	if err != nil {
+		return badReq(c, err.Error())
+	}
+	minLng, minLat, maxLng, maxLat := mvt.TileBounds(z, x, y)
+	padLng := (maxLng - minLng) * tileBuffer / mvt.DefaultExtent
+	padLat := (maxLat - minLat) * tileBuffer / mvt.DefaultExtent
+	q.Bbox = &repository.Bbox{MinLng: minLng - padLng, MinLat: minLat - padLat, MaxLng: maxLng + padLng, MaxLat: maxLat + padLat}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 10*time.Second)
+	defer cancel()
+
+	var layer *mvt.Layer
+	if z >= tileClusterMaxZoom {
+		q.Limit = tileMaxFeatures + 1
+		docs, err := a.Reports.List(ctx, q)
+		if err != nil {
+			return serverErr(c, err)
+		}
+		if len(docs) <= tileMaxFeatures {
+			layer = reportsLayer(z, x, y, docs)
+		}
+		q.Limit = 0
+	}
+	if layer == nil {
+		clusters, err := a.Reports.Clusters(ctx, q, clusterCellDeg(z))
+		if err != nil {
+			return serverErr(c, err)
+		}
+		layer = clustersLayer(z, x, y, clusters)
+	}
+	body := mvt.Encode(layer)
+
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Set("ETag", etag)
+	c.Set("Cache-Control", "public, max-age=60")
+	c.Set("Vary", "Accept-Encoding")
+	if c.Get("If-None-Match") == etag {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+	c.Set("Content-Type", "application/vnd.mapbox-vector-tile")
+	return c.Status(fiber.StatusOK).Send(body)
+}
+
+func reportsLayer(z, x, y int, docs []models.Report) *mvt.Layer {
+	l := mvt.NewLayer("reports", mvt.DefaultExtent)
+	for _, d := range docs {
+		lat, lng := repository.PublicPosition(d)
+		px, py := mvt.Project(z, x, y, mvt.DefaultExtent, lat, lng)
+		l.AddPoint(binary.BigEndian.Uint64(d.ID[4:]), px, py, map[string]any{
+			"id":            d.ID.Hex(),
+			"category":      d.Category,
+			"status":        d.CurrentStatus(),
+			"created_at":    d.CreatedAt.UTC().Format(time.RFC3339),
+			"created_ts":    d.CreatedAt.Unix(),
+			"confirmations": d.Confirmations,
+			"radius_m":      repository.PrivacyRadius(d),
+		})
+	}
+	return l
+}
+
+func clustersLayer(z, x, y int, clusters []repository.Cluster) *mvt.Layer {
+	l := mvt.NewLayer("clusters", mvt.DefaultExtent)
+	for _, cl := range clusters {
+		px, py := mvt.Project(z, x, y, mvt.DefaultExtent, cl.Lat, cl.Lng)
+		top, topN := "", int64(0)
+		for cat, n := range cl.ByCategory {
+			if n > topN || (n == topN && cat < top) {
+				top, topN = cat, n
+			}
+		}
+		props := map[string]any{
+			"count":    cl.Count,
+			"category": top,
+			"radius_m": cl.RadiusM,
+		}
+		if cl.ReportID != "" {
+			props["id"] = cl.ReportID
+		}
+		l.AddPoint(0, px, py, props)
+	}
+	return l
+}
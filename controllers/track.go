@@ -0,0 +1,167 @@
+// path: controllers/track.go
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"meniba/audit"
+	"meniba/models"
+	"meniba/repository"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TrackResp is what a tracking token reveals: the report's progress, not
+// its contents, location or reporter.
+type TrackResp struct {
+	OK               bool                  `json:"ok"`
+	ReportID         string                `json:"report_id"`
+	Category         string                `json:"category"`
+	Status           string                `json:"status"`
+	StatusHistory    []models.StatusChange `json:"status_history"`
+	Comments         []models.Comment      `json:"comments"`
+	ResolutionPhotos []string              `json:"resolution_photos,omitempty"`
+	Merged           bool                  `json:"merged,omitempty"` // progress shown is the canonical report's
+	CreatedAt        time.Time             `json:"created_at"`
+	UpdatedAt        time.Time             `json:"updated_at,omitempty"`
+	ExpiresAt        time.Time             `json:"expires_at"`
+}
+
+// issueTrackingToken stores a new token for reportID and returns the
+// secret, valid for TRACKING_TTL_DAYS (default 180).
+func (a *API) issueTrackingToken(ctx context.Context, reportID primitive.ObjectID) (string, time.Time, error) {
+	days := 180
+	if v, err := strconv.Atoi(getenv("TRACKING_TTL_DAYS", "")); err == nil && v > 0 {
+		days = v
+	}
+	now := time.Now().UTC()
+	secret := randString(40)
+	tok := models.TrackingToken{
+		ReportID:  reportID,
+		Hash:      hashToken(secret),
+		ExpiresAt: now.AddDate(0, 0, days),
+		CreatedAt: now,
+	}
+	if err := a.Tracking.Insert(ctx, &tok); err != nil {
+		return "", time.Time{}, err
+	}
+	return secret, tok.ExpiresAt, nil
+}
+
+// findTrackingToken resolves a secret to its live token. Unknown, revoked
+// and expired tokens all get the same 404, so a token that was switched
+// off can't be told from one that never existed.
+func (a *API) findTrackingToken(c *fiber.Ctx, ctx context.Context) (models.TrackingToken, bool, error) {
+	tok, err := a.Tracking.FindByHash(ctx, hashToken(c.Params("token")))
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return tok, false, serverErr(c, err)
+	}
+	if err != nil || !tok.Live(time.Now()) {
+		return tok, false, notFound(c, "unknown tracking token")
+	}
+	return tok, true, nil
+}
+
+// HandleTrackReport shows the progress of the report a token belongs to.
+// Once merged into another report, that report's progress is shown.
+// GET /api/track/:token
+func (a *API) HandleTrackReport(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+
+	tok, ok, err := a.findTrackingToken(c, ctx)
+	if !ok {
+		return err
+	}
+	r, err := a.Reports.Get(ctx, tok.ReportID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return notFound(c, "report not found")
+	}
+	if err != nil {
+		return serverErr(c, err)
+	}
+	resp := TrackResp{OK: true, ReportID: r.ID.Hex(), Category: r.Category, CreatedAt: r.CreatedAt, ExpiresAt: tok.ExpiresAt}
+	if r.DuplicateOf != nil {
+		canon, err := a.Reports.Get(ctx, *r.DuplicateOf)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return serverErr(c, err)
+		}
+		if err == nil {
+			r, resp.Merged = canon, true
+		}
+	}
+
+	resp.Status = r.CurrentStatus()
+	resp.UpdatedAt = r.UpdatedAt
+	resp.StatusHistory = make([]models.StatusChange, 0, len(r.StatusHistory))
+	for _, ch := range r.StatusHistory {
+		resp.StatusHistory = append(resp.StatusHistory, ch)
+		if ch.To == models.StatusResolved {
+			resp.ResolutionPhotos = append(resp.ResolutionPhotos, ch.PhotoURLs...)
+		}
+	}
+	if resp.Comments, err = a.publicComments(ctx, r.ID); err != nil {
+		return serverErr(c, err)
+	}
+	for i := range resp.Comments {
+		resp.Comments[i].Author = "" // staff names stay internal
+	}
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// HandleRevokeTrackingToken lets the holder switch a token off, e.g. after
+// sharing it by mistake.
+// DELETE /api/track/:token
+func (a *API) HandleRevokeTrackingToken(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+
+	tok, ok, err := a.findTrackingToken(c, ctx)
+	if !ok {
+		return err
+	}
+	now := time.Now().UTC()
+	if err := a.Tracking.Revoke(ctx, tok.ID, now); err != nil {
+		return serverErr(c, err)
+	}
+	a.recordAudit(ctx, a.reporterActor(c, "tracking-token"), audit.Entry{
+		Action: audit.ReportTrackingRevoke, Target: "report", TargetID: tok.ReportID,
+		After: fiber.Map{"revoked_tokens": 1, "revoked_at": now},
+	})
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"ok": true})
+}
+
+// HandleRevokeReportTracking revokes every live token of a report.
+// POST /api/admin/reports/:id/tracking/revoke
+func (a *API) HandleRevokeReportTracking(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return badReq(c, "invalid id")
+	}
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	n, err := a.Tracking.RevokeReport(ctx, id, now)
+	if err != nil {
+		return serverErr(c, err)
+	}
+	if n > 0 {
+		a.recordAudit(ctx, a.actor(c), audit.Entry{
+			Action: audit.ReportTrackingRevoke, Target: "report", TargetID: id,
+			After: fiber.Map{"revoked_tokens": n, "revoked_at": now},
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"ok": true, "revoked": n})
+}
+
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
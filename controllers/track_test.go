@@ -0,0 +1,194 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"meniba/events"
+	"meniba/models"
+	"meniba/repository"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newTrackTestAPI() (*API, *repository.MemoryTrackingTokens, *fiber.App) {
+	tokens := repository.NewMemoryTrackingTokens()
+	a := &API{
+		Reports:  repository.NewMemory(),
+		Bus:      events.NewBus(10),
+		Comments: repository.NewMemoryComments(),
+		Tracking: tokens,
+	}
+	app := fiber.New()
+	app.Post("/api/reports", a.HandlePostReport)
+	app.Get("/api/track/:token", a.HandleTrackReport)
+	app.Delete("/api/track/:token", a.HandleRevokeTrackingToken)
+	app.Post("/api/admin/reports/:id/tracking/revoke", a.HandleRevokeReportTracking)
+	return a, tokens, app
+}
+
+// postTrackedReport files a report through the API and returns its id and
+// tracking token.
+func postTrackedReport(t *testing.T, app *fiber.App) (primitive.ObjectID, string) {
+	t.Helper()
+	body := `{"category":"water","note":"pump broken at 14 Kissy Road","area_label":"Kissy",
+		"lat":8.484312,"lng":-13.234567,"contact_email":"amie@example.org"}`
+	req := httptest.NewRequest("POST", "/api/reports", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out models.CreateReportResp
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil || resp.StatusCode != fiber.StatusOK || out.TrackingToken == "" {
+		t.Fatalf("create: %d %+v %v", resp.StatusCode, out, err)
+	}
+	id, _ := primitive.ObjectIDFromHex(out.ID)
+	return id, out.TrackingToken
+}
+
+func trackRequest(t *testing.T, app *fiber.App, method, token string) (int, string) {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest(method, "/api/track/"+token, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(b)
+}
+
+func TestTrackingTokenShowsProgressOnly(t *testing.T) {
+	a, tokens, app := newTrackTestAPI()
+	ctx := context.Background()
+	id, secret := postTrackedReport(t, app)
+
+	stored := tokens.All()
+	if len(stored) != 1 || stored[0].ReportID != id || stored[0].Hash != hashToken(secret) {
+		t.Fatalf("stored tokens = %+v", stored)
+	}
+	if raw, _ := json.Marshal(stored[0]); strings.Contains(string(raw), secret) || strings.Contains(stored[0].Hash, secret) {
+		t.Fatal("the secret itself was stored")
+	}
+
+	resolved := time.Now().UTC()
+	if _, err := a.Reports.Update(ctx, id, func(r *models.Report) error {
+		r.Status = models.StatusResolved
+		r.StatusHistory = append(r.StatusHistory, models.StatusChange{
+			From: models.StatusOpen, To: models.StatusResolved, PhotoURLs: []string{"/uploads/fixed.jpg"}, At: resolved,
+		})
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, cm := range []models.Comment{
+		{ReportID: id, Body: "crew sent", Author: "F. Kamara", Public: true, CreatedAt: resolved},
+		{ReportID: id, Body: "internal: contractor late", Author: "F. Kamara", CreatedAt: resolved},
+	} {
+		if err := a.Comments.Insert(ctx, &cm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	st, body := trackRequest(t, app, "GET", secret)
+	if st != fiber.StatusOK {
+		t.Fatalf("track = %d %s", st, body)
+	}
+	var out TrackResp
+	if err := json.Unmarshal([]byte(body), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.ReportID != id.Hex() || out.Status != models.StatusResolved || len(out.StatusHistory) != 1 ||
+		len(out.ResolutionPhotos) != 1 || len(out.Comments) != 1 || out.Comments[0].Body != "crew sent" {
+		t.Fatalf("track = %+v", out)
+	}
+	for _, leak := range []string{"amie@example.org", "Kissy Road", "8.484", "13.234", "F. Kamara", "internal", "contact", `"lat"`, `"lng"`} {
+		if strings.Contains(body, leak) {
+			t.Errorf("track response leaks %q: %s", leak, body)
+		}
+	}
+}
+
+func TestTrackingTokenRevocation(t *testing.T) {
+	a, tokens, app := newTrackTestAPI()
+	ctx := context.Background()
+	id, secret := postTrackedReport(t, app)
+
+	if st, _ := trackRequest(t, app, "GET", "not-a-token"); st != fiber.StatusNotFound {
+		t.Fatalf("unknown token = %d", st)
+	}
+	if st, _ := trackRequest(t, app, "DELETE", "not-a-token"); st != fiber.StatusNotFound {
+		t.Fatalf("revoking an unknown token = %d", st)
+	}
+
+	if st, _ := trackRequest(t, app, "DELETE", secret); st != fiber.StatusOK {
+		t.Fatalf("revoke = %d", st)
+	}
+	if stored := tokens.All(); stored[0].RevokedAt == nil {
+		t.Fatalf("not revoked: %+v", stored)
+	}
+	// a revoked token looks exactly like one that never existed
+	st, revoked := trackRequest(t, app, "GET", secret)
+	_, unknown := trackRequest(t, app, "GET", "not-a-token")
+	if st != fiber.StatusNotFound || revoked != unknown {
+		t.Fatalf("revoked token = %d %s, unknown = %s", st, revoked, unknown)
+	}
+	if st, _ := trackRequest(t, app, "DELETE", secret); st != fiber.StatusNotFound {
+		t.Fatalf("revoking twice = %d", st)
+	}
+
+	// expired tokens are gone too
+	expired := models.TrackingToken{ReportID: id, Hash: hashToken("expired"), ExpiresAt: time.Now().Add(-time.Minute)}
+	if err := a.Tracking.Insert(ctx, &expired); err != nil {
+		t.Fatal(err)
+	}
+	if st, _ := trackRequest(t, app, "GET", "expired"); st != fiber.StatusNotFound {
+		t.Fatalf("expired token = %d", st)
+	}
+
+	// moderators revoke every live token of a report at once
+	live1, _, _ := a.issueTrackingToken(ctx, id)
+	live2, _, _ := a.issueTrackingToken(ctx, id)
+	resp, err := app.Test(httptest.NewRequest("POST", "/api/admin/reports/"+id.Hex()+"/tracking/revoke", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct{ Revoked int64 }
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+	if out.Revoked != 3 { // live1, live2 and the expired one
+		t.Fatalf("revoked %d", out.Revoked)
+	}
+	for _, s := range []string{live1, live2} {
+		if st, _ := trackRequest(t, app, "GET", s); st != fiber.StatusNotFound {
+			t.Fatalf("token after report-wide revoke = %d", st)
+		}
+	}
+}
+
+func TestTrackingFollowsMerges(t *testing.T) {
+	a, _, app := newTrackTestAPI()
+	ctx := context.Background()
+	id, secret := postTrackedReport(t, app)
+	canon := &models.Report{Category: "water", Status: models.StatusInProgress, CreatedAt: time.Now().UTC()}
+	if err := a.Reports.Create(ctx, canon); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Reports.Update(ctx, id, func(r *models.Report) error {
+		r.DuplicateOf = &canon.ID
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	st, body := trackRequest(t, app, "GET", secret)
+	var out TrackResp
+	_ = json.Unmarshal([]byte(body), &out)
+	if st != fiber.StatusOK || !out.Merged || out.Status != models.StatusInProgress || out.ReportID != id.Hex() {
+		t.Fatalf("merged track = %d %+v", st, out)
+	}
+}
@@ -0,0 +1,169 @@
+// path: controllers/ussd.go
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"meniba/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ussdInbound is an Africa's Talking-style USSD callback. Text holds every
+// answer of the session so far joined by "*" ("" on the first screen).
+type ussdInbound struct {
+	SessionID   string `form:"sessionId"`
+	ServiceCode string `form:"serviceCode"`
+	PhoneNumber string `form:"phoneNumber"`
+	NetworkCode string `form:"networkCode"`
+	Text        string `form:"text"`
+}
+
+const ussdPageSize = 6
+
+// HandleInboundUSSD drives the USSD menu. Replies start with "CON " to keep
+// the session open or "END " to close it.
+// POST /api/inbound/ussd
+func (a *API) HandleInboundUSSD(c *fiber.Ctx) error {
+	if st, msg := gatewayAuth(c, "USSD_WEBHOOK_TOKEN"); st != 0 {
+		return c.Status(st).SendString("END " + msg)
+	}
+	var in ussdInbound
+	if err := c.BodyParser(&in); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("END invalid request")
+	}
+
+	var inputs []string
+	if strings.TrimSpace(in.Text) != "" {
+		inputs = strings.Split(in.Text, "*")
+	}
+	st := ussdStep(inputs)
+	c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+	if st.report == nil {
+		return c.Status(fiber.StatusOK).SendString(st.screen)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+	doc, dups, err := a.submitIntake(ctx, *st.report, models.SourceUSSD)
+	if err != nil {
+		log.Printf("ussd: create report for session %s failed: %v", in.SessionID, err)
+		return c.Status(fiber.StatusOK).SendString("END Sorry, we could not save your report. Please try again.")
+	}
+	return c.Status(fiber.StatusOK).SendString("END " + intakeReceipt(doc, dups))
+}
+
+// ussdState is the outcome of replaying a session's inputs: either a screen
+// to show, or a finished report to submit.
+type ussdState struct {
+	screen string
+	report *ReportJSON
+}
+
+// ussdStep is the menu state machine. Because the gateway resends the whole
+// input path each time, the current state is rebuilt from scratch:
+// category -> district -> chiefdom -> note -> confirm.
+func ussdStep(inputs []string) ussdState {
+	// category
+	cats := make([]string, 0, len(models.Categories))
+	for _, ct := range models.Categories {
+		cats = append(cats, ct.Name)
+	}
+	ci, inputs, screen := ussdPick(inputs, "Report an issue. Choose category:", cats)
+	if screen != "" {
+		return ussdState{screen: screen}
+	}
+	cat := models.Categories[ci]
+
+	// district
+	dists := make([]string, 0, len(models.Districts))
+	for _, d := range models.Districts {
+		dists = append(dists, d.Name)
+	}
+	di, inputs, screen := ussdPick(inputs, "Choose district:", dists)
+	if screen != "" {
+		return ussdState{screen: screen}
+	}
+	district := models.Districts[di]
+
+	// chiefdom (last option: not sure)
+	chs := make([]string, 0, len(district.Chiefdoms)+1)
+	for _, ch := range district.Chiefdoms {
+		chs = append(chs, ch.Name)
+	}
+	chs = append(chs, "Not sure")
+	hi, inputs, screen := ussdPick(inputs, "Choose chiefdom:", chs)
+	if screen != "" {
+		return ussdState{screen: screen}
+	}
+	var cf *models.Chiefdom
+	if hi < len(district.Chiefdoms) {
+		cf = &district.Chiefdoms[hi]
+	}
+
+	// note: the rest of the path, since a note may itself contain "*". A
+	// final single digit after it is the answer to the confirm screen.
+	if len(inputs) == 0 {
+		return ussdState{screen: "CON Describe the problem briefly:"}
+	}
+	answer := ""
+	if n := len(inputs); n > 1 && ussdDigit(inputs[n-1]) {
+		answer, inputs = strings.TrimSpace(inputs[n-1]), inputs[:n-1]
+	}
+	note := strings.TrimSpace(strings.Join(inputs, "*"))
+	if note == "" {
+		return ussdState{screen: "END No description given. Please dial again."}
+	}
+	p := gazetteerReport(cat, district, cf, note)
+
+	// confirm
+	switch answer {
+	case "":
+		return ussdState{screen: fmt.Sprintf("CON Submit report?\n%s, %s\n1. Submit\n2. Cancel", cat.Name, p.AreaLabel)}
+	case "1":
+		return ussdState{report: &p}
+	case "2":
+		return ussdState{screen: "END Report cancelled."}
+	default:
+		return ussdState{screen: "END Invalid choice. Please dial again."}
+	}
+}
+
+func ussdDigit(s string) bool {
+	s = strings.TrimSpace(s)
+	return len(s) == 1 && s[0] >= '0' && s[0] <= '9'
+}
+
+// ussdPick consumes one paged menu from inputs. "0" moves to the next page;
+// options keep their global numbers across pages. It returns the chosen
+// index and remaining inputs, or a screen to show if no choice was made.
+func ussdPick(inputs []string, title string, options []string) (int, []string, string) {
+	pages := (len(options) + ussdPageSize - 1) / ussdPageSize
+	page := 0
+	for len(inputs) > 0 && strings.TrimSpace(inputs[0]) == "0" && page < pages-1 {
+		page++
+		inputs = inputs[1:]
+	}
+	if len(inputs) == 0 {
+		var b strings.Builder
+		b.WriteString("CON " + title)
+		end := min((page+1)*ussdPageSize, len(options))
+		for i := page * ussdPageSize; i < end; i++ {
+			fmt.Fprintf(&b, "\n%d. %s", i+1, options[i])
+		}
+		if page < pages-1 {
+			b.WriteString("\n0. More")
+		}
+		return 0, nil, b.String()
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(inputs[0]))
+	if err != nil || n < 1 || n > len(options) {
+		return 0, nil, "END Invalid choice. Please dial again."
+	}
+	return n - 1, inputs[1:], ""
+}
@@ -0,0 +1,25 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUSSDNoteMayContainStars(t *testing.T) {
+	st := ussdStep(strings.Split("1*1*1*Pipe burst * road flooded", "*"))
+	if st.report != nil || !strings.HasPrefix(st.screen, "CON Submit report?") {
+		t.Fatalf("after note: %+v", st)
+	}
+
+	st = ussdStep(strings.Split("1*1*1*Pipe burst * road flooded*1", "*"))
+	if st.report == nil {
+		t.Fatalf("after confirm: %+v", st)
+	}
+	if st.report.Note != "Pipe burst * road flooded" {
+		t.Fatalf("note = %q", st.report.Note)
+	}
+
+	if st := ussdStep(strings.Split("1*1*1*Pipe*2", "*")); st.screen != "END Report cancelled." {
+		t.Fatalf("cancel: %+v", st)
+	}
+}
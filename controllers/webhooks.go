@@ -0,0 +1,201 @@
+// path: controllers/webhooks.go
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"meniba/audit"
+	"meniba/models"
+	"meniba/webhooks"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type WebhookListResp struct {
+	OK    bool                         `json:"ok"`
+	Items []models.WebhookSubscription `json:"items"`
+}
+
+type DeliveryListResp struct {
+	OK    bool                     `json:"ok"`
+	Items []models.WebhookDelivery `json:"items"`
+}
+
+type ReplayResp struct {
+	OK       bool  `json:"ok"`
+	Replayed int64 `json:"replayed"`
+}
+
+// HandleCreateWebhook registers a partner endpoint. The secret is returned
+// only in this response; generate one if the partner didn't supply it.
+// POST /api/admin/webhooks
+func (a *API) HandleCreateWebhook(c *fiber.Ctx) error {
+	var p models.WebhookCreatePayload
+	if err := c.BodyParser(&p); err != nil {
+		return badReq(c, "invalid JSON")
+	}
+	u, err := url.Parse(strings.TrimSpace(p.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return badReq(c, "invalid url")
+	}
+	for _, e := range p.Events {
+		if !webhooks.SupportedEvent(e) {
+			return badReq(c, "unsupported event: "+e)
+		}
+	}
+	secret := strings.TrimSpace(p.Secret)
+	if secret == "" {
+		secret = randString(40)
+	}
+
+	sub := models.WebhookSubscription{
+		URL:        u.String(),
+		Secret:     secret,
+		Events:     p.Events,
+		Categories: trimAll(p.Categories),
+		Districts:  trimAll(p.Districts),
+		Active:     true,
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+	res, err := a.DB.Col(webhooks.SubscriptionsCol).InsertOne(ctx, sub)
+	if err != nil {
+		return serverErr(c, err)
+	}
+	sub.ID = res.InsertedID.(primitive.ObjectID)
+	logged := sub
+	logged.Secret = "" // why: the audit log must not hold partner secrets
+	a.recordAudit(ctx, a.actor(c), audit.Entry{Action: audit.WebhookCreate, Target: "webhook", TargetID: sub.ID, After: logged})
+	return c.Status(fiber.StatusOK).JSON(sub)
+}
+
+// HandleListWebhooks lists subscriptions without their secrets.
+// GET /api/admin/webhooks
+func (a *API) HandleListWebhooks(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+
+	cur, err := a.DB.Col(webhooks.SubscriptionsCol).Find(ctx, bson.M{},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetProjection(bson.M{"secret": 0}))
+	if err != nil {
+		return serverErr(c, err)
+	}
+	items := make([]models.WebhookSubscription, 0)
+	if err := cur.All(ctx, &items); err != nil {
+		return serverErr(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(WebhookListResp{OK: true, Items: items})
+}
+
+// HandleDeleteWebhook removes a subscription; its pending deliveries fail.
+// DELETE /api/admin/webhooks/:id
+func (a *API) HandleDeleteWebhook(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return badReq(c, "invalid id")
+	}
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+
+	var sub models.WebhookSubscription
+	err = a.DB.Col(webhooks.SubscriptionsCol).FindOneAndDelete(ctx, bson.M{"_id": id},
+		options.FindOneAndDelete().SetProjection(bson.M{"secret": 0})).Decode(&sub)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound(c, "webhook not found")
+	}
+	if err != nil {
+		return serverErr(c, err)
+	}
+	a.recordAudit(ctx, a.actor(c), audit.Entry{Action: audit.WebhookDelete, Target: "webhook", TargetID: id, Before: sub})
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"ok": true})
+}
+
+// HandleListDeliveries returns the delivery log, newest first.
+// GET /api/admin/webhooks/deliveries?subscription_id=&status=&limit=
+func (a *API) HandleListDeliveries(c *fiber.Ctx) error {
+	filter := bson.M{}
+	if s := c.Query("subscription_id"); s != "" {
+		oid, err := primitive.ObjectIDFromHex(s)
+		if err != nil {
+			return badReq(c, "invalid subscription_id")
+		}
+		filter["subscription_id"] = oid
+	}
+	if st := c.Query("status"); st != "" {
+		filter["status"] = st
+	}
+	limit := 50
+	if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 && v <= 500 {
+		limit = v
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+
+	cur, err := a.DB.Col(webhooks.DeliveriesCol).Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(int64(limit)))
+	if err != nil {
+		return serverErr(c, err)
+	}
+	items := make([]models.WebhookDelivery, 0, limit)
+	if err := cur.All(ctx, &items); err != nil {
+		return serverErr(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(DeliveryListResp{OK: true, Items: items})
+}
+
+// HandleReplayDeliveries re-queues failed deliveries.
+// POST /api/admin/webhooks/deliveries/replay {"subscription_id": "", "delivery_ids": []}
+func (a *API) HandleReplayDeliveries(c *fiber.Ctx) error {
+	var p models.WebhookReplayPayload
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&p); err != nil {
+			return badReq(c, "invalid JSON")
+		}
+	}
+	var subID *primitive.ObjectID
+	if p.SubscriptionID != "" {
+		oid, err := primitive.ObjectIDFromHex(p.SubscriptionID)
+		if err != nil {
+			return badReq(c, "invalid subscription_id")
+		}
+		subID = &oid
+	}
+	var ids []primitive.ObjectID
+	for _, s := range p.DeliveryIDs {
+		oid, err := primitive.ObjectIDFromHex(s)
+		if err != nil {
+			return badReq(c, "invalid delivery id: "+s)
+		}
+		ids = append(ids, oid)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 8*time.Second)
+	defer cancel()
+	n, err := a.Webhooks.Replay(ctx, subID, ids)
+	if err != nil {
+		return serverErr(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(ReplayResp{OK: true, Replayed: n})
+}
+
+func trimAll(in []string) []string {
+	var out []string
+	for _, s := range in {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -16,15 +16,16 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var client *mongo.Client
-var db *mongo.Database
-
-// Connect establishes a singleton MongoDB connection.
-func Connect(ctx context.Context) error {
-	if client != nil && db != nil {
-		return nil
-	}
+// DB is a connected MongoDB database. Create one with Connect and pass it
+// to whatever needs storage; there is no package-level connection.
+type DB struct {
+	client        *mongo.Client
+	db            *mongo.Database
+	changeStreams bool
+}
 
+// Connect establishes a MongoDB connection and ensures indexes.
+func Connect(ctx context.Context) (*DB, error) {
 	cfg, reason := resolveConfig()
 	if getenv("MONGO_DEBUG", "") != "" {
 		log.Printf("mongo: env snapshot %s", envSnapshot())
@@ -38,39 +39,39 @@ func Connect(ctx context.Context) error {
 
 	c, err := mongo.Connect(dctx, options.Client().ApplyURI(cfg.URI))
 	if err != nil {
-		return fmt.Errorf("mongo connect: %w", err)
+		return nil, fmt.Errorf("mongo connect: %w", err)
 	}
 	if err = c.Ping(dctx, nil); err != nil {
 		_ = c.Disconnect(context.Background())
-		return fmt.Errorf("mongo ping: %w", err)
+		return nil, fmt.Errorf("mongo ping: %w", err)
 	}
 
-	client = c
-	db = c.Database(cfg.DBName)
+	d := &DB{
+		client:        c,
+		db:            c.Database(cfg.DBName),
+		changeStreams: detectChangeStreams(dctx, c),
+	}
 
-	if err := createIndexes(); err != nil {
+	if err := d.createIndexes(); err != nil {
 		log.Printf("mongo: index creation warnings: %v", err)
 	}
 
-	log.Printf("mongo: connected ok in %s", time.Since(start).Round(time.Millisecond))
-	return nil
+	log.Printf("mongo: connected ok in %s (change streams: %v)", time.Since(start).Round(time.Millisecond), d.changeStreams)
+	return d, nil
 }
 
-func Disconnect(ctx context.Context) error {
-	if client == nil {
-		return nil
-	}
-	defer func() { client, db = nil, nil }()
-	return client.Disconnect(ctx)
+func (d *DB) Disconnect(ctx context.Context) error {
+	return d.client.Disconnect(ctx)
 }
 
-func Client() *mongo.Client { return client }
+func (d *DB) Client() *mongo.Client { return d.client }
 
-func Col(name string) *mongo.Collection {
-	if db == nil {
-		panic("database not connected: call database.Connect first")
-	}
-	return db.Collection(name)
+// ChangeStreamsSupported reports whether the server is a replica set or
+// sharded cluster; standalone servers cannot open change streams.
+func (d *DB) ChangeStreamsSupported() bool { return d.changeStreams }
+
+func (d *DB) Col(name string) *mongo.Collection {
+	return d.db.Collection(name)
 }
 
 // --- internal ---
@@ -116,15 +117,23 @@ func resolveConfig() (config, string) {
 	}
 }
 
-func createIndexes() error {
-	if db == nil {
-		return errors.New("db is nil")
+func detectChangeStreams(ctx context.Context, c *mongo.Client) bool {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
 	}
+	if err := c.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		return false
+	}
+	return hello.SetName != "" || hello.Msg == "isdbgrid"
+}
+
+func (d *DB) createIndexes() error {
 	ctxIdx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	var errs []string
-	col := Col("reports")
+	col := d.Col("reports")
 
 	if _, err := col.Indexes().CreateOne(ctxIdx, mongo.IndexModel{
 		Keys: bson.D{{Key: "created_at", Value: -1}},
@@ -141,6 +150,187 @@ func createIndexes() error {
 	}); err != nil {
 		errs = append(errs, "lat,lng: "+err.Error())
 	}
+	// duplicate detection: same category, recent first
+	if _, err := col.Indexes().CreateOne(ctxIdx, mongo.IndexModel{
+		Keys: bson.D{{Key: "category", Value: 1}, {Key: "created_at", Value: -1}},
+	}); err != nil {
+		errs = append(errs, "category,created_at: "+err.Error())
+	}
+	// full-text search (?q=); notes matter most, then place names
+	if _, err := col.Indexes().CreateOne(ctxIdx, mongo.IndexModel{
+		Keys: bson.D{{Key: "note", Value: "text"}, {Key: "area_label", Value: "text"}, {Key: "adrehs", Value: "text"}},
+		Options: options.Index().
+			SetName("reports_text").
+			SetDefaultLanguage("english").
+			SetWeights(bson.D{{Key: "note", Value: 5}, {Key: "area_label", Value: 3}, {Key: "adrehs", Value: 2}}),
+	}); err != nil {
+		errs = append(errs, "text: "+err.Error())
+	}
+
+	if _, err := d.Col("comments").Indexes().CreateOne(ctxIdx, mongo.IndexModel{
+		Keys: bson.D{{Key: "report_id", Value: 1}, {Key: "created_at", Value: 1}},
+	}); err != nil {
+		errs = append(errs, "comments: "+err.Error())
+	}
+
+	// abandoned chat intake sessions expire after a day
+	if _, err := d.Col("chat_sessions").Indexes().CreateOne(ctxIdx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "updated_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(86400),
+	}); err != nil {
+		errs = append(errs, "chat_sessions ttl: "+err.Error())
+	}
+
+	// webhook queue: due-delivery scan and per-subscription log
+	deliveries := d.Col("webhook_deliveries")
+	if _, err := deliveries.Indexes().CreateOne(ctxIdx, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}},
+	}); err != nil {
+		errs = append(errs, "webhook_deliveries status: "+err.Error())
+	}
+	if _, err := deliveries.Indexes().CreateOne(ctxIdx, mongo.IndexModel{
+		Keys: bson.D{{Key: "subscription_id", Value: 1}, {Key: "created_at", Value: -1}},
+	}); err != nil {
+		errs = append(errs, "webhook_deliveries subscription: "+err.Error())
+	}
+
+	// agency routing: unique handles, key lookup, and per-agency report lists
+	agencies := d.Col("agencies")
+	if _, err := agencies.Indexes().CreateOne(ctxIdx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "code", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		errs = append(errs, "agencies code: "+err.Error())
+	}
+	if _, err := agencies.Indexes().CreateOne(ctxIdx, mongo.IndexModel{
+		Keys: bson.D{{Key: "key_hash", Value: 1}},
+	}); err != nil {
+		errs = append(errs, "agencies key_hash: "+err.Error())
+	}
+	if _, err := col.Indexes().CreateOne(ctxIdx, mongo.IndexModel{
+		Keys: bson.D{{Key: "agencies", Value: 1}, {Key: "_id", Value: -1}},
+	}); err != nil {
+		errs = append(errs, "agencies,_id: "+err.Error())
+	}
+
+	// email outbox: due scan, and one opt-out per address
+	if _, err := d.Col("notifications").Indexes().CreateOne(ctxIdx, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}},
+	}); err != nil {
+		errs = append(errs, "notifications status: "+err.Error())
+	}
+	if _, err := d.Col("email_optouts").Indexes().CreateOne(ctxIdx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "email", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		errs = append(errs, "email_optouts: "+err.Error())
+	}
+
+	// tracking tokens: lookup by hash; expired ones are dropped by TTL
+	tracking := d.Col("tracking_tokens")
+	if _, err := tracking.Indexes().CreateOne(ctxIdx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "hash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		errs = append(errs, "tracking_tokens hash: "+err.Error())
+	}
+	if _, err := tracking.Indexes().CreateOne(ctxIdx, mongo.IndexModel{
+		Keys: bson.D{{Key: "report_id", Value: 1}},
+	}); err != nil {
+		errs = append(errs, "tracking_tokens report_id: "+err.Error())
+	}
+	if _, err := tracking.Indexes().CreateOne(ctxIdx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}); err != nil {
+		errs = append(errs, "tracking_tokens ttl: "+err.Error())
+	}
+
+	// reporter accounts: one per phone; codes double as a 1h rate log
+	if _, err := d.Col("reporters").Indexes().CreateOne(ctxIdx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "phone", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		errs = append(errs, "reporters phone: "+err.Error())
+	}
+	otp := d.Col("otp_challenges")
+	if _, err := otp.Indexes().CreateOne(ctxIdx, mongo.IndexModel{
+		Keys: bson.D{{Key: "phone", Value: 1}, {Key: "created_at", Value: -1}},
+	}); err != nil {
+		errs = append(errs, "otp_challenges phone: "+err.Error())
+	}
+	if _, err := otp.Indexes().CreateOne(ctxIdx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "created_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(3600),
+	}); err != nil {
+		errs = append(errs, "otp_challenges ttl: "+err.Error())
+	}
+	sessions := d.Col("reporter_sessions")
+	if _, err := sessions.Indexes().CreateOne(ctxIdx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "token_hash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		errs = append(errs, "reporter_sessions token: "+err.Error())
+	}
+	if _, err := sessions.Indexes().CreateOne(ctxIdx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}); err != nil {
+		errs = append(errs, "reporter_sessions ttl: "+err.Error())
+	}
+	if _, err := col.Indexes().CreateOne(ctxIdx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "reporter_id", Value: 1}, {Key: "_id", Value: -1}},
+		Options: options.Index().SetSparse(true),
+	}); err != nil {
+		errs = append(errs, "reporter_id: "+err.Error())
+	}
+
+	// anomaly alerts: at most one active alert per category and district
+	alerts := d.Col("alerts")
+	if _, err := alerts.Indexes().CreateOne(ctxIdx, mongo.IndexModel{
+		Keys: bson.D{{Key: "kind", Value: 1}, {Key: "category", Value: 1}, {Key: "district", Value: 1}},
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"active": true}),
+	}); err != nil {
+		errs = append(errs, "alerts active: "+err.Error())
+	}
+	if _, err := alerts.Indexes().CreateOne(ctxIdx, mongo.IndexModel{
+		Keys: bson.D{{Key: "created_at", Value: -1}},
+	}); err != nil {
+		errs = append(errs, "alerts created_at: "+err.Error())
+	}
+
+	// audit log: seq is the chain position, so it must be unique
+	audit := d.Col("audit_events")
+	if _, err := audit.Indexes().CreateOne(ctxIdx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "seq", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		errs = append(errs, "audit_events seq: "+err.Error())
+	}
+	if _, err := audit.Indexes().CreateOne(ctxIdx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "report_id", Value: 1}, {Key: "seq", Value: -1}},
+		Options: options.Index().SetSparse(true),
+	}); err != nil {
+		errs = append(errs, "audit_events report_id: "+err.Error())
+	}
+	if _, err := audit.Indexes().CreateOne(ctxIdx, mongo.IndexModel{
+		Keys: bson.D{{Key: "actor", Value: 1}, {Key: "seq", Value: -1}},
+	}); err != nil {
+		errs = append(errs, "audit_events actor: "+err.Error())
+	}
+
+	if _, err := d.Col("retention_runs").Indexes().CreateOne(ctxIdx, mongo.IndexModel{
+		Keys: bson.D{{Key: "started_at", Value: -1}},
+	}); err != nil {
+		errs = append(errs, "retention_runs started_at: "+err.Error())
+	}
+	if _, err := d.Col("media_gc_runs").Indexes().CreateOne(ctxIdx, mongo.IndexModel{
+		Keys: bson.D{{Key: "started_at", Value: -1}},
+	}); err != nil {
+		errs = append(errs, "media_gc_runs started_at: "+err.Error())
+	}
 
 	if len(errs) > 0 {
 		return errors.New(strings.Join(errs, "; "))
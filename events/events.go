@@ -0,0 +1,110 @@
+// path: events/events.go
+package events
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"meniba/models"
+)
+
+// Event types published by the API.
+const (
+	ReportCreated       = "report.created"
+	ReportUpdated       = "report.updated"
+	ReportStatusChanged = "report.status_changed"
+	ReportAssigned      = "report.assigned"
+	ReportOverdue       = "report.overdue"
+	ReportWithdrawn     = "report.withdrawn"
+	CommentAdded        = "comment.added"
+)
+
+// Event is something that happened to a report.
+type Event struct {
+	ID       string         `json:"id"`
+	Type     string         `json:"type"`
+	ReportID string         `json:"report_id"`
+	Report   *models.Report `json:"report,omitempty"`
+	Data     any            `json:"data,omitempty"`
+	At       time.Time      `json:"at"`
+}
+
+// Bus is an in-process pub/sub hub that keeps a short ring of recent events
+// so reconnecting subscribers can resume from the last ID they saw.
+type Bus struct {
+	mu     sync.Mutex
+	boot   string
+	seq    uint64
+	ring   []Event
+	size   int
+	subs   map[chan Event]struct{}
+	bufLen int
+}
+
+// NewBus returns a bus that remembers the last size events.
+func NewBus(size int) *Bus {
+	if size <= 0 {
+		size = 100
+	}
+	return &Bus{
+		// why: IDs from a previous process must not match this one's ring
+		boot:   strconv.FormatInt(time.Now().UnixNano(), 36),
+		size:   size,
+		subs:   map[chan Event]struct{}{},
+		bufLen: 64,
+	}
+}
+
+// Publish assigns an ID to ev, stores it in the ring and fans it out.
+// Subscribers that can't keep up are dropped (their channel is closed) so
+// they reconnect and resume instead of blocking publishers.
+func (b *Bus) Publish(ev Event) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	ev.ID = b.boot + "-" + strconv.FormatUint(b.seq, 10)
+	if ev.At.IsZero() {
+		ev.At = time.Now().UTC()
+	}
+	b.ring = append(b.ring, ev)
+	if len(b.ring) > b.size {
+		b.ring = b.ring[len(b.ring)-b.size:]
+	}
+	for ch := range b.subs {
+		select {
+		case ch <- ev:
+		default:
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+	return ev
+}
+
+// Subscribe returns the events after lastID still held in the ring (empty
+// if lastID is unknown) and a channel of live events. Call cancel when done.
+func (b *Bus) Subscribe(lastID string) (replay []Event, ch <-chan Event, cancel func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if lastID != "" {
+		for i, ev := range b.ring {
+			if ev.ID == lastID {
+				replay = append(replay, b.ring[i+1:]...)
+				break
+			}
+		}
+	}
+	c := make(chan Event, b.bufLen)
+	b.subs[c] = struct{}{}
+	return replay, c, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[c]; ok {
+			delete(b.subs, c)
+			close(c)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// path: jobs/alertstore.go
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"meniba/database"
+	"meniba/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AlertStore keeps the alerts AnomalyDetector raises. It runs against
+// AlertsCol in production and MemoryAlerts in tests.
+type AlertStore interface {
+	// Raise updates the active alert of a's kind, category and district
+	// with a's window, counts and score, or inserts one. PeakCount only
+	// grows and CreatedAt is kept from the first pass.
+	Raise(ctx context.Context, a models.Alert) error
+	// Active returns the open alerts of kind.
+	Active(ctx context.Context, kind string) ([]models.Alert, error)
+	// Resolve closes alert id as of now.
+	Resolve(ctx context.Context, id primitive.ObjectID, now time.Time) error
+}
+
+// MongoAlerts keeps alerts in AlertsCol.
+type MongoAlerts struct {
+	DB *database.DB
+}
+
+// NewMongoAlerts returns a store over db.
+func NewMongoAlerts(db *database.DB) *MongoAlerts {
+	return &MongoAlerts{DB: db}
+}
+
+func (m *MongoAlerts) Raise(ctx context.Context, a models.Alert) error {
+	_, err := m.DB.Col(AlertsCol).UpdateOne(ctx,
+		bson.M{"kind": a.Kind, "category": a.Category, "district": a.District, "active": true},
+		bson.M{
+			"$set": bson.M{
+				"window_start": a.WindowStart,
+				"window_end":   a.WindowEnd,
+				"count":        a.Count,
+				"baseline":     a.Baseline,
+				"std_dev":      a.StdDev,
+				"score":        a.Score,
+				"updated_at":   a.UpdatedAt,
+			},
+			"$max":         bson.M{"peak_count": a.Count},
+			"$setOnInsert": bson.M{"created_at": a.UpdatedAt},
+		},
+		options.Update().SetUpsert(true))
+	return err
+}
+
+func (m *MongoAlerts) Active(ctx context.Context, kind string) ([]models.Alert, error) {
+	cur, err := m.DB.Col(AlertsCol).Find(ctx, bson.M{"kind": kind, "active": true})
+	if err != nil {
+		return nil, err
+	}
+	var open []models.Alert
+	err = cur.All(ctx, &open)
+	return open, err
+}
+
+func (m *MongoAlerts) Resolve(ctx context.Context, id primitive.ObjectID, now time.Time) error {
+	_, err := m.DB.Col(AlertsCol).UpdateByID(ctx, id, bson.M{"$set": bson.M{
+		"active": false, "resolved_at": now, "updated_at": now,
+	}})
+	return err
+}
+
+// MemoryAlerts is an in-process AlertStore for tests and local demos.
+type MemoryAlerts struct {
+	mu     sync.Mutex
+	alerts []models.Alert
+}
+
+// NewMemoryAlerts returns an empty in-memory store.
+func NewMemoryAlerts() *MemoryAlerts {
+	return &MemoryAlerts{}
+}
+
+// All returns a copy of every alert, open or resolved, oldest first.
+func (m *MemoryAlerts) All() []models.Alert {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]models.Alert(nil), m.alerts...)
+}
+
+func (m *MemoryAlerts) Raise(_ context.Context, a models.Alert) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.alerts {
+		x := &m.alerts[i]
+		if !x.Active || x.Kind != a.Kind || x.Category != a.Category || x.District != a.District {
+			continue
+		}
+		x.WindowStart, x.WindowEnd = a.WindowStart, a.WindowEnd
+		x.Count, x.Baseline, x.StdDev, x.Score = a.Count, a.Baseline, a.StdDev, a.Score
+		x.PeakCount = max(x.PeakCount, a.Count)
+		x.UpdatedAt = a.UpdatedAt
+		return nil
+	}
+	a.ID = primitive.NewObjectID()
+	a.Active = true
+	a.PeakCount = a.Count
+	a.CreatedAt = a.UpdatedAt
+	m.alerts = append(m.alerts, a)
+	return nil
+}
+
+func (m *MemoryAlerts) Active(_ context.Context, kind string) ([]models.Alert, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var open []models.Alert
+	for _, a := range m.alerts {
+		if a.Active && a.Kind == kind {
+			open = append(open, a)
+		}
+	}
+	return open, nil
+}
+
+func (m *MemoryAlerts) Resolve(_ context.Context, id primitive.ObjectID, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.alerts {
+		if m.alerts[i].ID == id {
+			m.alerts[i].Active = false
+			m.alerts[i].ResolvedAt = &now
+			m.alerts[i].UpdatedAt = now
+		}
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+// path: jobs/anomaly.go
+// Package jobs holds background analytics and maintenance jobs.
+package jobs
+
+import (
+	"context"
+	"log"
+	"math"
+	"time"
+
+	"meniba/database"
+	"meniba/models"
+	"meniba/repository"
+)
+
+// AlertsCol holds the alerts raised by AnomalyDetector.
+const AlertsCol = "alerts"
+
+// AnomalyDetector compares the latest Window of reports per category and
+// district with the same-length windows over the preceding Baseline, and
+// keeps an active alert for every pair that is Threshold standard
+// deviations above normal.
+type AnomalyDetector struct {
+	Alerts    AlertStore
+	Reports   repository.ReportRepository
+	Interval  time.Duration // how often to run
+	Window    time.Duration // the "recent" period
+	Baseline  time.Duration // history the recent period is compared against
+	MinCount  int           // ignore windows with fewer reports than this
+	Threshold float64       // score needed to raise an alert
+}
+
+// NewAnomalyDetector returns a detector with production defaults: hourly
+// runs comparing the last 24h with the 28 days before.
+func NewAnomalyDetector(db *database.DB, reports repository.ReportRepository) *AnomalyDetector {
+	return &AnomalyDetector{
+		Alerts:    NewMongoAlerts(db),
+		Reports:   reports,
+		Interval:  time.Hour,
+		Window:    24 * time.Hour,
+		Baseline:  28 * 24 * time.Hour,
+		MinCount:  5,
+		Threshold: 3,
+	}
+}
+
+// Run detects once at start and then every Interval until ctx is cancelled.
+func (d *AnomalyDetector) Run(ctx context.Context) {
+	t := time.NewTicker(d.Interval)
+	defer t.Stop()
+	for {
+		if n, err := d.Detect(ctx, time.Now().UTC()); err != nil {
+			log.Printf("anomaly: %v", err)
+		} else if n > 0 {
+			log.Printf("anomaly: %d active alert(s)", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+type pairKey struct{ category, district string }
+
+// Detect runs one pass for the window ending at now and returns how many
+// alerts are active afterwards.
+func (d *AnomalyDetector) Detect(ctx context.Context, now time.Time) (int, error) {
+	windows := int(d.Baseline / d.Window)
+	if windows < 2 {
+		windows = 2
+	}
+	start := now.Add(-time.Duration(windows+1) * d.Window)
+
+	// counts[pair][0] is the recent window, [1..windows] the baseline
+	counts := map[pairKey][]int{}
+	err := d.Reports.Scan(ctx, repository.ReportQuery{CreatedFrom: &start, CreatedTo: &now}, func(r models.Report) error {
+		if r.District == "" {
+			return nil
+		}
+		i := int(now.Sub(r.CreatedAt) / d.Window)
+		if i < 0 || i > windows {
+			return nil
+		}
+		k := pairKey{r.Category, r.District}
+		if counts[k] == nil {
+			counts[k] = make([]int, windows+1)
+		}
+		counts[k][i]++
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	active := map[pairKey]bool{}
+	for k, c := range counts {
+		mean, sd := meanStdDev(c[1:])
+		// why: with sparse history the sample deviation is ~0, so fall
+		// back to the Poisson deviation sqrt(mean), and never below 1
+		spread := math.Max(1, math.Max(sd, math.Sqrt(mean)))
+		score := (float64(c[0]) - mean) / spread
+		if c[0] < d.MinCount || score < d.Threshold {
+			continue
+		}
+		active[k] = true
+
+		if err := d.Alerts.Raise(ctx, models.Alert{
+			Kind:        models.AlertSurge,
+			Category:    k.category,
+			District:    k.district,
+			WindowStart: now.Add(-d.Window),
+			WindowEnd:   now,
+			Count:       c[0],
+			Baseline:    mean,
+			StdDev:      sd,
+			Score:       score,
+			UpdatedAt:   now,
+		}); err != nil {
+			return 0, err
+		}
+	}
+
+	// close alerts whose surge has passed
+	open, err := d.Alerts.Active(ctx, models.AlertSurge)
+	if err != nil {
+		return 0, err
+	}
+	for _, a := range open {
+		if active[pairKey{a.Category, a.District}] {
+			continue
+		}
+		if err := d.Alerts.Resolve(ctx, a.ID, now); err != nil {
+			return 0, err
+		}
+	}
+	return len(active), nil
+}
+
+func meanStdDev(xs []int) (float64, float64) {
+	if len(xs) == 0 {
+		return 0, 0
+	}
+	sum := 0.0
+	for _, x := range xs {
+		sum += float64(x)
+	}
+	mean := sum / float64(len(xs))
+	ss := 0.0
+	for _, x := range xs {
+		ss += (float64(x) - mean) * (float64(x) - mean)
+	}
+	return mean, math.Sqrt(ss / float64(len(xs)))
+}
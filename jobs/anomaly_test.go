@@ -0,0 +1,169 @@
+package jobs
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"meniba/models"
+	"meniba/repository"
+)
+
+var anomalyNow = time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+func newTestDetector() (*AnomalyDetector, *MemoryAlerts) {
+	alerts := NewMemoryAlerts()
+	return &AnomalyDetector{
+		Alerts:    alerts,
+		Reports:   repository.NewMemory(),
+		Window:    24 * time.Hour,
+		Baseline:  4 * 24 * time.Hour, // four baseline windows
+		MinCount:  5,
+		Threshold: 3,
+	}, alerts
+}
+
+// addReports files n reports in district created ago before anomalyNow.
+func addReports(t *testing.T, repo repository.ReportRepository, district string, n int, ago time.Duration) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		r := &models.Report{Category: "water", District: district, CreatedAt: anomalyNow.Add(-ago)}
+		if err := repo.Create(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	day := 24 * time.Hour
+	// window i of the baseline is i days before the recent one
+	baseline := func(counts ...int) func(*testing.T, repository.ReportRepository) {
+		return func(t *testing.T, repo repository.ReportRepository) {
+			for i, n := range counts {
+				addReports(t, repo, "Central", n, time.Duration(i+1)*day+12*time.Hour)
+			}
+		}
+	}
+	for _, tc := range []struct {
+		name   string
+		recent int
+		setup  func(*testing.T, repository.ReportRepository)
+		alert  bool
+		score  float64
+	}{
+		{"no history", 6, nil, true, 6},
+		{"surge over a quiet baseline", 8, baseline(1, 2, 1, 0), true, 7},
+		{"surge over a varied baseline", 12, baseline(0, 4, 0, 4), true, 5},
+		{"noisy baseline absorbs it", 8, baseline(0, 6, 0, 6), false, 0},
+		// sd is 0: sqrt(mean) stands in for it
+		{"zero variance, under", 6, baseline(2, 2, 2, 2), false, 0},
+		{"zero variance, over", 7, baseline(2, 2, 2, 2), true, 5 / math.Sqrt2},
+		// sd and sqrt(mean) both under 1: the spread is 1
+		{"zero variance, sparse", 5, baseline(0, 0, 0, 0), true, 5},
+		{"under MinCount", 4, nil, false, 0},
+		{"last baseline window counts", 5, baseline(0, 0, 0, 20), false, 0},
+		{"older than the baseline is ignored", 5, func(t *testing.T, repo repository.ReportRepository) {
+			addReports(t, repo, "Central", 20, 5*day+12*time.Hour)
+		}, true, 5},
+		{"reports without a district are ignored", 5, func(t *testing.T, repo repository.ReportRepository) {
+			addReports(t, repo, "", 50, time.Hour)
+		}, true, 5},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d, alerts := newTestDetector()
+			addReports(t, d.Reports, "Central", tc.recent, time.Hour)
+			if tc.setup != nil {
+				tc.setup(t, d.Reports)
+			}
+			n, err := d.Detect(context.Background(), anomalyNow)
+			if err != nil {
+				t.Fatal(err)
+			}
+			all := alerts.All()
+			if !tc.alert {
+				if n != 0 || len(all) != 0 {
+					t.Fatalf("raised %d: %+v", n, all)
+				}
+				return
+			}
+			if n != 1 || len(all) != 1 {
+				t.Fatalf("raised %d: %+v", n, all)
+			}
+			a := all[0]
+			if a.Kind != models.AlertSurge || a.Category != "water" || a.District != "Central" || a.Count != tc.recent || !a.Active {
+				t.Fatalf("alert = %+v", a)
+			}
+			if math.Abs(a.Score-tc.score) > 1e-9 {
+				t.Fatalf("score = %v, want %v", a.Score, tc.score)
+			}
+			if !a.WindowStart.Equal(anomalyNow.Add(-day)) || !a.WindowEnd.Equal(anomalyNow) {
+				t.Fatalf("window = %v..%v", a.WindowStart, a.WindowEnd)
+			}
+		})
+	}
+}
+
+func TestDetectKeepsOneAlertPerSurge(t *testing.T) {
+	ctx := context.Background()
+	d, alerts := newTestDetector()
+	addReports(t, d.Reports, "Central", 6, time.Hour)
+
+	if n, err := d.Detect(ctx, anomalyNow); err != nil || n != 1 {
+		t.Fatalf("first pass: %d, %v", n, err)
+	}
+	// the surge grows an hour later: the open alert is updated, not repeated
+	addReports(t, d.Reports, "Central", 3, 0)
+	later := anomalyNow.Add(time.Hour)
+	if n, err := d.Detect(ctx, later); err != nil || n != 1 {
+		t.Fatalf("second pass: %d, %v", n, err)
+	}
+	all := alerts.All()
+	if len(all) != 1 {
+		t.Fatalf("re-alerted: %+v", all)
+	}
+	if a := all[0]; a.Count != 9 || a.PeakCount != 9 || !a.CreatedAt.Equal(anomalyNow) || !a.UpdatedAt.Equal(later) {
+		t.Fatalf("updated alert = %+v", a)
+	}
+
+	// two days on the surge is baseline history and the alert closes
+	passed := anomalyNow.Add(48 * time.Hour)
+	if n, err := d.Detect(ctx, passed); err != nil || n != 0 {
+		t.Fatalf("after the surge: %d, %v", n, err)
+	}
+	all = alerts.All()
+	if len(all) != 1 || all[0].Active || all[0].ResolvedAt == nil || !all[0].ResolvedAt.Equal(passed) || all[0].PeakCount != 9 {
+		t.Fatalf("resolved alert = %+v", all)
+	}
+
+	// a new surge opens a new alert rather than reviving the closed one
+	for i := 0; i < 20; i++ {
+		if err := d.Reports.Create(ctx, &models.Report{Category: "water", District: "Central", CreatedAt: passed.Add(-time.Minute)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, err := d.Detect(ctx, passed); err != nil || n != 1 {
+		t.Fatalf("new surge: %d, %v", n, err)
+	}
+	if all = alerts.All(); len(all) != 2 || all[0].Active || !all[1].Active || all[1].Count != 20 {
+		t.Fatalf("alerts after a new surge = %+v", all)
+	}
+}
+
+func TestMeanStdDev(t *testing.T) {
+	for _, tc := range []struct {
+		xs       []int
+		mean, sd float64
+	}{
+		{nil, 0, 0},
+		{[]int{3}, 3, 0},
+		{[]int{2, 2, 2, 2}, 2, 0},
+		{[]int{0, 6, 0, 6}, 3, 3},
+		{[]int{2, 4, 4, 4, 5, 5, 7, 9}, 5, 2},
+	} {
+		mean, sd := meanStdDev(tc.xs)
+		if math.Abs(mean-tc.mean) > 1e-9 || math.Abs(sd-tc.sd) > 1e-9 {
+			t.Errorf("meanStdDev(%v) = %v, %v, want %v, %v", tc.xs, mean, sd, tc.mean, tc.sd)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// path: jobs/mediagc.go
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"meniba/database"
+	"meniba/media"
+	"meniba/models"
+	"meniba/repository"
+)
+
+// MediaGCRunsCol holds a record of every MediaGC pass.
+const MediaGCRunsCol = "media_gc_runs"
+
+// MediaGC reconciles the media store with the documents that reference
+// it. Files no report points to are moved to quarantine once older than
+// MinAge, restored if something references them again, and deleted after
+// Grace in quarantine. Abandoned staged uploads, including attachments of
+// chat conversations that never became a report, are deleted once older
+// than MinAge. Each pass is recorded in the Store.
+type MediaGC struct {
+	Store    MediaGCStore
+	Reports  repository.ReportRepository
+	Media    *media.Store
+	Interval time.Duration
+	MinAge   time.Duration // younger files may belong to an upload still in flight
+	Grace    time.Duration // time in quarantine before deletion
+}
+
+// NewMediaGC returns a collector that runs every 6 hours, leaves files
+// alone for their first day and keeps them in quarantine for a week.
+func NewMediaGC(db *database.DB, reports repository.ReportRepository, store *media.Store) *MediaGC {
+	return &MediaGC{
+		Store:    NewMongoMediaGC(db),
+		Reports:  reports,
+		Media:    store,
+		Interval: 6 * time.Hour,
+		MinAge:   24 * time.Hour,
+		Grace:    7 * 24 * time.Hour,
+	}
+}
+
+// Run collects once at start and then every Interval until ctx is cancelled.
+func (g *MediaGC) Run(ctx context.Context) {
+	t := time.NewTicker(g.Interval)
+	defer t.Stop()
+	for {
+		if run, err := g.Collect(ctx, time.Now()); err != nil {
+			log.Printf("media gc: %v", err)
+		} else if run.Quarantined+run.Restored+run.Deleted+run.Failures > 0 {
+			log.Printf("media gc: %d quarantined, %d restored, %d deleted (%d bytes reclaimed), %d failure(s)",
+				run.Quarantined, run.Restored, run.Deleted, run.ReclaimedBytes, run.Failures)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Collect runs one pass as of now and stores its record.
+func (g *MediaGC) Collect(ctx context.Context, now time.Time) (models.MediaGCRun, error) {
+	run := models.MediaGCRun{StartedAt: now.UTC()}
+
+	// why: an incomplete reference set would quarantine live media, so any
+	// error here aborts the pass before a file is touched
+	refs, err := g.references(ctx)
+	if err != nil {
+		return run, err
+	}
+	run.Referenced = len(refs)
+
+	files, err := g.Media.List()
+	if err != nil {
+		return run, err
+	}
+	for _, f := range files {
+		run.Scanned++
+		run.ScannedBytes += f.Size
+		if refs[f.URL] || now.Sub(f.ModTime) < g.MinAge {
+			continue
+		}
+		if err := g.Media.Quarantine(f.URL); err != nil {
+			log.Printf("media gc: quarantine %s: %v", f.Name, err)
+			run.Failures++
+			continue
+		}
+		run.Quarantined++
+		run.QuarantinedBytes += f.Size
+	}
+
+	held, err := g.Media.Quarantined()
+	if err != nil {
+		return run, err
+	}
+	for _, f := range held {
+		switch {
+		case refs[f.URL]:
+			if err := g.Media.Restore(f.Name); err != nil {
+				log.Printf("media gc: restore %s: %v", f.Name, err)
+				run.Failures++
+				continue
+			}
+			run.Restored++
+		case now.Sub(f.ModTime) >= g.Grace:
+			if err := g.Media.Purge(f.Name); err != nil {
+				log.Printf("media gc: delete %s: %v", f.Name, err)
+				run.Failures++
+				continue
+			}
+			run.Deleted++
+			run.ReclaimedBytes += f.Size
+		}
+	}
+
+	// staged uploads older than MinAge belong to a batch whose process died
+	// before committing or rolling back
+	staged, err := g.Media.Staged()
+	if err != nil {
+		return run, err
+	}
+	for _, f := range staged {
+		if now.Sub(f.ModTime) < g.MinAge {
+			continue
+		}
+		if err := g.Media.DiscardStaged(f.Name); err != nil {
+			log.Printf("media gc: discard staged %s: %v", f.Name, err)
+			run.Failures++
+			continue
+		}
+		run.Deleted++
+		run.ReclaimedBytes += f.Size
+	}
+
+	run.FinishedAt = time.Now().UTC()
+	err = g.Store.SaveRun(ctx, &run)
+	return run, err
+}
+
+// references returns every media URL a report (merged and withdrawn ones
+// included) points to.
+func (g *MediaGC) references(ctx context.Context) (map[string]bool, error) {
+	refs := map[string]bool{}
+	q := repository.ReportQuery{IncludeMerged: true, IncludeWithdrawn: true}
+	err := g.Reports.Scan(ctx, q, func(r models.Report) error {
+		for _, u := range mediaURLs(r) {
+			refs[u] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
@@ -0,0 +1,221 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"meniba/media"
+	"meniba/models"
+	"meniba/repository"
+)
+
+func newTestGC(t *testing.T) (*MediaGC, *MemoryMediaGC) {
+	t.Helper()
+	store := NewMemoryMediaGC()
+	return &MediaGC{
+		Store:   store,
+		Reports: repository.NewMemory(),
+		Media:   media.NewStore(filepath.Join(t.TempDir(), "uploads"), "/uploads"),
+		MinAge:  24 * time.Hour,
+		Grace:   7 * 24 * time.Hour,
+	}, store
+}
+
+// age sets the modification time of the file at p to ago before now.
+func age(t *testing.T, p string, ago time.Duration) {
+	t.Helper()
+	then := time.Now().Add(-ago)
+	if err := os.Chtimes(p, then, then); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// oldMedia stores a file last modified two days ago.
+func oldMedia(t *testing.T, s *media.Store, prefix string) string {
+	t.Helper()
+	u := saveMedia(t, s, prefix)
+	p, _ := s.Path(u)
+	age(t, p, 48*time.Hour)
+	return u
+}
+
+func fileNames(t *testing.T, list func() ([]media.File, error)) []string {
+	t.Helper()
+	files, err := list()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+// listed reports whether the file behind url is among names.
+func listed(names []string, url string) bool {
+	for _, x := range names {
+		if strings.HasSuffix(url, "/"+x) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestMediaGCCollect(t *testing.T) {
+	ctx := context.Background()
+	g, store := newTestGC(t)
+
+	voice := oldMedia(t, g.Media, "voice")
+	revised := oldMedia(t, g.Media, "photo")
+	crew := oldMedia(t, g.Media, "status")
+	orphan := oldMedia(t, g.Media, "photo")
+	fresh := saveMedia(t, g.Media, "photo")
+	withdrawn := time.Now()
+	createReport(t, g.Reports, models.Report{Category: "water", VoiceURL: voice, WithdrawnAt: &withdrawn,
+		Revisions:     []models.Revision{{Before: models.ReportSnapshot{PhotoURLs: []string{revised}}}},
+		StatusHistory: []models.StatusChange{{To: models.StatusResolved, PhotoURLs: []string{crew}}}})
+
+	now := time.Now()
+	run, err := g.Collect(ctx, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Scanned != 5 || run.Referenced != 3 || run.Quarantined != 1 || run.Deleted != 0 || run.Failures != 0 {
+		t.Errorf("run %+v", run)
+	}
+	served := fileNames(t, g.Media.List)
+	for _, u := range []string{voice, revised, crew, fresh} {
+		if !listed(served, u) {
+			t.Errorf("%s was taken out of the store", u)
+		}
+	}
+	if listed(served, orphan) {
+		t.Errorf("orphan %s is still served", orphan)
+	}
+	// the quarantine stamp starts the grace period, however old the file was
+	if held := fileNames(t, g.Media.Quarantined); len(held) != 1 || !listed(held, orphan) {
+		t.Errorf("quarantined %v", held)
+	}
+	if runs := store.Runs(); len(runs) != 1 || runs[0].ID != run.ID || run.ID.IsZero() {
+		t.Errorf("stored runs %+v", runs)
+	}
+
+	// the upload fresh belonged to lands before it is old enough to collect
+	createReport(t, g.Reports, models.Report{Category: "water", PhotoURLs: []string{fresh}})
+
+	// within Grace, nothing more happens
+	run, err = g.Collect(ctx, now.Add(g.Grace-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Quarantined != 0 || run.Deleted != 0 || len(fileNames(t, g.Media.Quarantined)) != 1 {
+		t.Errorf("run within grace %+v", run)
+	}
+
+	// after Grace the orphan is purged
+	run, err = g.Collect(ctx, now.Add(g.Grace+time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Deleted != 1 || run.ReclaimedBytes != int64(len("photo")) || run.Quarantined != 0 {
+		t.Errorf("run after grace %+v", run)
+	}
+	if held := fileNames(t, g.Media.Quarantined); len(held) != 0 {
+		t.Errorf("quarantined after grace %v", held)
+	}
+	if served := fileNames(t, g.Media.List); len(served) != 4 {
+		t.Errorf("served after grace %v", served)
+	}
+}
+
+func TestMediaGCRestoresReferencedFiles(t *testing.T) {
+	ctx := context.Background()
+	g, _ := newTestGC(t)
+	photo := oldMedia(t, g.Media, "photo")
+
+	if run, err := g.Collect(ctx, time.Now()); err != nil || run.Quarantined != 1 {
+		t.Fatalf("first pass %+v, %v", run, err)
+	}
+	// e.g. a moderator attached it to a status change meanwhile
+	createReport(t, g.Reports, models.Report{Category: "water",
+		StatusHistory: []models.StatusChange{{To: models.StatusResolved, PhotoURLs: []string{photo}}}})
+
+	run, err := g.Collect(ctx, time.Now().Add(30*24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Restored != 1 || run.Deleted != 0 || run.Quarantined != 0 {
+		t.Errorf("run %+v", run)
+	}
+	if !listed(fileNames(t, g.Media.List), photo) || len(fileNames(t, g.Media.Quarantined)) != 0 {
+		t.Error("photo was not restored")
+	}
+}
+
+func TestMediaGCDiscardsAbandonedStaging(t *testing.T) {
+	ctx := context.Background()
+	g, _ := newTestGC(t)
+	abandoned := g.Media.Stage()
+	old, err := abandoned.Add("photo", ".jpg", strings.NewReader("old"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	age(t, filepath.Join(g.Media.StagingDir, filepath.Base(old)), 48*time.Hour)
+	inFlight := g.Media.Stage()
+	defer inFlight.Rollback()
+	current, err := inFlight.Add("photo", ".jpg", strings.NewReader("new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run, err := g.Collect(ctx, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Deleted != 1 || run.ReclaimedBytes != 3 {
+		t.Errorf("run %+v", run)
+	}
+	staged := fileNames(t, g.Media.Staged)
+	if len(staged) != 1 || !listed(staged, current) {
+		t.Errorf("staged %v, want only %s", staged, current)
+	}
+}
+
+// failingScan is a repository whose scans fail.
+type failingScan struct {
+	repository.ReportRepository
+}
+
+func (failingScan) Scan(context.Context, repository.ReportQuery, func(models.Report) error) error {
+	return errors.New("cursor lost")
+}
+
+func TestMediaGCAbortsWithoutReferences(t *testing.T) {
+	g, store := newTestGC(t)
+	g.Reports = failingScan{repository.NewMemory()}
+	orphan := oldMedia(t, g.Media, "photo")
+	staged, err := g.Media.Stage().Add("photo", ".jpg", strings.NewReader("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	age(t, filepath.Join(g.Media.StagingDir, filepath.Base(staged)), 48*time.Hour)
+
+	if _, err := g.Collect(context.Background(), time.Now()); err == nil {
+		t.Fatal("Collect succeeded without references")
+	}
+	if !listed(fileNames(t, g.Media.List), orphan) || len(fileNames(t, g.Media.Quarantined)) != 0 {
+		t.Error("a file was quarantined")
+	}
+	if len(fileNames(t, g.Media.Staged)) != 1 {
+		t.Error("a staged file was discarded")
+	}
+	if len(store.Runs()) != 0 {
+		t.Errorf("stored runs %+v", store.Runs())
+	}
+}
@@ -0,0 +1,64 @@
+// path: jobs/mediagcstore.go
+package jobs
+
+import (
+	"context"
+	"sync"
+
+	"meniba/database"
+	"meniba/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MediaGCStore keeps the record of MediaGC passes. It runs against
+// MediaGCRunsCol in production and MemoryMediaGC in tests.
+type MediaGCStore interface {
+	// SaveRun stores run, setting its ID.
+	SaveRun(ctx context.Context, run *models.MediaGCRun) error
+}
+
+// MongoMediaGC keeps runs in MediaGCRunsCol.
+type MongoMediaGC struct {
+	DB *database.DB
+}
+
+// NewMongoMediaGC returns a store over db.
+func NewMongoMediaGC(db *database.DB) *MongoMediaGC {
+	return &MongoMediaGC{DB: db}
+}
+
+func (m *MongoMediaGC) SaveRun(ctx context.Context, run *models.MediaGCRun) error {
+	res, err := m.DB.Col(MediaGCRunsCol).InsertOne(ctx, run)
+	if err != nil {
+		return err
+	}
+	run.ID = res.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// MemoryMediaGC is an in-process MediaGCStore for tests and local demos.
+type MemoryMediaGC struct {
+	mu   sync.Mutex
+	runs []models.MediaGCRun
+}
+
+// NewMemoryMediaGC returns an empty in-memory store.
+func NewMemoryMediaGC() *MemoryMediaGC {
+	return &MemoryMediaGC{}
+}
+
+// Runs returns a copy of the stored runs, oldest first.
+func (m *MemoryMediaGC) Runs() []models.MediaGCRun {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]models.MediaGCRun(nil), m.runs...)
+}
+
+func (m *MemoryMediaGC) SaveRun(_ context.Context, run *models.MediaGCRun) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	run.ID = primitive.NewObjectID()
+	m.runs = append(m.runs, *run)
+	return nil
+}
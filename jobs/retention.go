@@ -0,0 +1,367 @@
+// path: jobs/retention.go
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"meniba/audit"
+	"meniba/database"
+	"meniba/media"
+	"meniba/models"
+	"meniba/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Collections used by Retention.
+const (
+	RetentionRulesCol = "retention_rules"
+	RetentionRunsCol  = "retention_runs"
+)
+
+// DefaultCoarsenM is the grid coarsen_location rules use when they don't
+// set RadiusM.
+const DefaultCoarsenM = 1000
+
+// maxRunIDs caps the report ids kept per rule in a RetentionRun.
+const maxRunIDs = 100
+
+// Retention enforces the active retention rules: it strips media and
+// coarsens locations on ageing reports, hard-deletes the ones rules say
+// to drop, removes the files no other report uses from the media store and
+// records each pass in the Store.
+type Retention struct {
+	Store    RetentionStore
+	Reports  repository.ReportRepository
+	Media    *media.Store
+	Audit    *audit.Log // records each change; nil = off
+	Interval time.Duration
+	DryRun   bool // scheduled runs only report what they would do
+}
+
+// NewRetention returns a job that runs daily. RETENTION_DRY_RUN=1 keeps
+// the scheduled runs read-only, e.g. while new rules are being checked.
+func NewRetention(db *database.DB, reports repository.ReportRepository, store *media.Store) *Retention {
+	dry, _ := strconv.ParseBool(os.Getenv("RETENTION_DRY_RUN"))
+	return &Retention{Store: NewMongoRetention(db), Reports: reports, Media: store, Interval: 24 * time.Hour, DryRun: dry}
+}
+
+// Run applies the rules once at start and then every Interval until ctx
+// is cancelled.
+func (j *Retention) Run(ctx context.Context) {
+	t := time.NewTicker(j.Interval)
+	defer t.Stop()
+	for {
+		if run, err := j.Apply(ctx, time.Now().UTC(), j.DryRun); err != nil {
+			log.Printf("retention: %v", err)
+		} else {
+			for _, res := range run.Rules {
+				if res.Reports > 0 || res.Error != "" {
+					log.Printf("retention: rule %q (%s, dry run %v): %d report(s), %d file(s) %s",
+						res.Name, res.Action, run.DryRun, res.Reports, res.Files, res.Error)
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Apply runs every active rule as of now and stores the run. With dryRun
+// nothing is changed and the run lists what would have been.
+func (j *Retention) Apply(ctx context.Context, now time.Time, dryRun bool) (models.RetentionRun, error) {
+	run := models.RetentionRun{DryRun: dryRun, StartedAt: now, Rules: []models.RetentionResult{}}
+
+	rules, err := j.Store.Rules(ctx)
+	if err != nil {
+		return run, err
+	}
+
+	for _, rule := range rules {
+		res, err := j.applyRule(ctx, rule, now, dryRun)
+		if err != nil {
+			// why: one failing rule shouldn't keep the others from running
+			res.Error = err.Error()
+		}
+		run.Rules = append(run.Rules, res)
+		if ctx.Err() != nil {
+			run.Error = ctx.Err().Error()
+			break
+		}
+	}
+
+	run.FinishedAt = time.Now().UTC()
+	err = j.Store.SaveRun(ctx, &run)
+	return run, err
+}
+
+func (j *Retention) applyRule(ctx context.Context, rule models.RetentionRule, now time.Time, dryRun bool) (models.RetentionResult, error) {
+	res := models.RetentionResult{RuleID: rule.ID, Name: rule.Name, Action: rule.Action}
+
+	type dueReport struct {
+		id    primitive.ObjectID
+		after *models.Report // as the rule leaves it; nil when deleted
+		files []string
+	}
+	var due []dueReport
+	err := j.Reports.Scan(ctx, retentionQuery(rule, now), func(r models.Report) error {
+		d := dueReport{id: r.ID}
+		if rule.Action == models.RetentionDeleteReport {
+			d.files = mediaURLs(r)
+		} else {
+			var changed bool
+			if changed, d.files = applyRetention(&r, rule); !changed {
+				return nil
+			}
+			d.after = &r
+		}
+		due = append(due, d)
+		return nil
+	})
+	if err != nil {
+		return res, err
+	}
+
+	for _, d := range due {
+		id := d.id
+		if dryRun {
+			// why: counted once the scan is done; scans don't nest
+			res.Files += len(j.unreferenced(ctx, d.files, id, d.after))
+		} else {
+			var n int
+			var err error
+			if rule.Action == models.RetentionDeleteReport {
+				n, err = j.purge(ctx, id, rule)
+			} else {
+				n, err = j.strip(ctx, id, rule)
+			}
+			if errors.Is(err, repository.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return res, err
+			}
+			res.Files += n
+		}
+		res.Reports++
+		if len(res.ReportIDs) < maxRunIDs {
+			res.ReportIDs = append(res.ReportIDs, id)
+		}
+	}
+	return res, nil
+}
+
+// strip applies a non-deleting rule to one report and removes the files
+// it no longer references. It returns how many files were removed.
+func (j *Retention) strip(ctx context.Context, id primitive.ObjectID, rule models.RetentionRule) (int, error) {
+	var files []string
+	changed := false
+	after, err := j.Reports.Update(ctx, id, func(r *models.Report) error {
+		if changed, files = applyRetention(r, rule); !changed {
+			return repository.ErrNoChange
+		}
+		return nil
+	})
+	if err != nil || !changed {
+		return 0, err
+	}
+	j.record(ctx, rule, id, len(files))
+	return j.deleteFiles(j.unreferenced(ctx, files, id, &after)), nil
+}
+
+// purge hard-deletes a report with its comments, tracking tokens, queued
+// emails and the media no other report uses. It returns how many files
+// were removed.
+func (j *Retention) purge(ctx context.Context, id primitive.ObjectID, rule models.RetentionRule) (int, error) {
+	r, err := j.Reports.Get(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	if err := j.Reports.Delete(ctx, id); err != nil {
+		return 0, err
+	}
+	if err := j.Store.PurgeReport(ctx, id); err != nil {
+		log.Printf("retention: purge %s: %v", id.Hex(), err)
+	}
+	files := mediaURLs(r)
+	j.record(ctx, rule, id, len(files))
+	return j.deleteFiles(j.unreferenced(ctx, files, id, nil)), nil
+}
+
+// unreferenced returns the urls that neither after, the state a rule
+// leaves report id in (nil once deleted), nor any other report points to.
+// Moderators may attach a stored file to several reports, so a file can
+// outlive the report it was uploaded with. If the reports can't be read,
+// nothing is returned and every file is kept.
+func (j *Retention) unreferenced(ctx context.Context, urls []string, id primitive.ObjectID, after *models.Report) []string {
+	if len(urls) == 0 {
+		return nil
+	}
+	used := map[string]bool{}
+	if after != nil {
+		for _, u := range mediaURLs(*after) {
+			used[u] = true
+		}
+	}
+	q := repository.ReportQuery{MediaURLs: urls, IncludeMerged: true, IncludeWithdrawn: true}
+	err := j.Reports.Scan(ctx, q, func(r models.Report) error {
+		if r.ID == id {
+			return nil // the stored copy may predate the change
+		}
+		for _, u := range mediaURLs(r) {
+			used[u] = true
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("retention: look up references to %d file(s) of %s: %v", len(urls), id.Hex(), err)
+		return nil
+	}
+	var out []string
+	for _, u := range urls {
+		if !used[u] {
+			out = append(out, u)
+		}
+	}
+	return out
+}
+
+func (j *Retention) deleteFiles(urls []string) int {
+	n := 0
+	for _, u := range urls {
+		if _, ok := j.Media.Path(u); !ok {
+			continue
+		}
+		if err := j.Media.Delete(u); err != nil {
+			log.Printf("retention: delete %s: %v", u, err)
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+// record notes a rule's effect on a report in the audit log. Only the rule
+// is logged, not the removed values, or the log would keep what the rule
+// is meant to erase.
+func (j *Retention) record(ctx context.Context, rule models.RetentionRule, id primitive.ObjectID, files int) {
+	if j.Audit == nil {
+		return
+	}
+	if err := j.Audit.Record(ctx, audit.System("retention"), audit.Entry{
+		Action: audit.Retention + rule.Action, Target: "report", TargetID: id,
+		After: map[string]any{"rule_id": rule.ID, "rule": rule.Name, "files": files},
+	}); err != nil {
+		log.Printf("retention: audit %s: %v", id.Hex(), err)
+	}
+}
+
+// retentionQuery selects the reports rule may apply to: old enough, in
+// its statuses and categories, and for media rules, still holding media.
+func retentionQuery(rule models.RetentionRule, now time.Time) repository.ReportQuery {
+	cutoff := now.AddDate(0, 0, -rule.AfterDays)
+	q := repository.ReportQuery{
+		CreatedTo:        &cutoff,
+		Status:           repository.Terms{In: rule.Statuses},
+		Category:         repository.Terms{In: rule.Categories},
+		IncludeMerged:    true,
+		IncludeWithdrawn: true,
+	}
+	yes := true
+	switch rule.Action {
+	case models.RetentionDeleteVoice:
+		q.HasVoice = &yes
+	case models.RetentionDeletePhotos:
+		q.HasPhoto = &yes
+	}
+	return q
+}
+
+// applyRetention applies a media or location rule to r and returns
+// whether anything changed and which media URLs r no longer references.
+// Earlier versions kept in Revisions are stripped too.
+func applyRetention(r *models.Report, rule models.RetentionRule) (bool, []string) {
+	var files []string
+	switch rule.Action {
+	case models.RetentionDeleteVoice:
+		if r.VoiceURL != "" {
+			files = append(files, r.VoiceURL)
+			r.VoiceURL = ""
+		}
+		for i := range r.Revisions {
+			if u := r.Revisions[i].Before.VoiceURL; u != "" {
+				files = append(files, u)
+				r.Revisions[i].Before.VoiceURL = ""
+			}
+		}
+		return len(files) > 0, uniqueURLs(files)
+
+	case models.RetentionDeletePhotos:
+		files = append(files, r.PhotoURLs...)
+		r.PhotoURLs = nil
+		for i := range r.Revisions {
+			files = append(files, r.Revisions[i].Before.PhotoURLs...)
+			r.Revisions[i].Before.PhotoURLs = nil
+		}
+		return len(files) > 0, uniqueURLs(files)
+
+	case models.RetentionCoarsenLocation:
+		radius := rule.RadiusM
+		if radius <= 0 {
+			radius = DefaultCoarsenM
+		}
+		if r.CoarsenedM >= radius {
+			return false, nil
+		}
+		if r.PrivacyRadiusM != nil && *r.PrivacyRadiusM > radius {
+			radius = *r.PrivacyRadiusM
+		}
+		r.PrivacyRadiusM = &radius
+		r.Lat, r.Lng = repository.PublicPosition(*r)
+		r.CoarsenedM = radius
+		r.AccuracyM = nil
+		r.Adrehs = "" // why: the address code pins the spot as well as the coordinates
+		return true, nil
+	}
+	return false, nil
+}
+
+// mediaURLs lists every media file a report references.
+func mediaURLs(r models.Report) []string {
+	var out []string
+	if r.VoiceURL != "" {
+		out = append(out, r.VoiceURL)
+	}
+	out = append(out, r.PhotoURLs...)
+	for _, rev := range r.Revisions {
+		if rev.Before.VoiceURL != "" {
+			out = append(out, rev.Before.VoiceURL)
+		}
+		out = append(out, rev.Before.PhotoURLs...)
+	}
+	for _, sc := range r.StatusHistory {
+		out = append(out, sc.PhotoURLs...)
+	}
+	return uniqueURLs(out)
+}
+
+// uniqueURLs drops repeats; revisions usually still list the current media.
+func uniqueURLs(urls []string) []string {
+	seen := map[string]bool{}
+	out := urls[:0]
+	for _, u := range urls {
+		if !seen[u] {
+			seen[u] = true
+			out = append(out, u)
+		}
+	}
+	return out
+}
@@ -0,0 +1,401 @@
+package jobs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"meniba/media"
+	"meniba/models"
+	"meniba/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var retentionNow = time.Date(2026, 6, 1, 3, 0, 0, 0, time.UTC)
+
+func newTestRetention(t *testing.T) (*Retention, *MemoryRetention) {
+	t.Helper()
+	store := NewMemoryRetention()
+	return &Retention{
+		Store:   store,
+		Reports: repository.NewMemory(),
+		Media:   media.NewStore(filepath.Join(t.TempDir(), "uploads"), "/uploads"),
+	}, store
+}
+
+// saveMedia stores a small file and returns its URL.
+func saveMedia(t *testing.T, s *media.Store, prefix string) string {
+	t.Helper()
+	u, err := s.Save(prefix, ".bin", strings.NewReader(prefix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func mediaExists(t *testing.T, s *media.Store, url string) bool {
+	t.Helper()
+	p, ok := s.Path(url)
+	if !ok {
+		t.Fatalf("%s is not a stored file", url)
+	}
+	_, err := os.Stat(p)
+	return err == nil
+}
+
+func createReport(t *testing.T, repo repository.ReportRepository, r models.Report) models.Report {
+	t.Helper()
+	if err := repo.Create(context.Background(), &r); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func scanIDs(t *testing.T, repo repository.ReportRepository, q repository.ReportQuery) []primitive.ObjectID {
+	t.Helper()
+	var ids []primitive.ObjectID
+	if err := repo.Scan(context.Background(), q, func(r models.Report) error {
+		ids = append(ids, r.ID)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+func TestRetentionQuery(t *testing.T) {
+	repo := repository.NewMemory()
+	day := 24 * time.Hour
+	cutoff := retentionNow.Add(-30 * day)
+	withdrawn := cutoff.Add(-day)
+	canonical := primitive.NewObjectID()
+	add := func(status string, created time.Time, mutate func(*models.Report)) primitive.ObjectID {
+		r := models.Report{Category: "water", Status: status, CreatedAt: created, VoiceURL: "/uploads/v.ogg"}
+		if mutate != nil {
+			mutate(&r)
+		}
+		return createReport(t, repo, r).ID
+	}
+	atCutoff := add(models.StatusResolved, cutoff, nil)
+	old := add(models.StatusResolved, cutoff.Add(-day), nil)
+	add(models.StatusResolved, cutoff.Add(time.Second), nil)
+	rejected := add(models.StatusRejected, cutoff.Add(-day), nil)
+	add(models.StatusOpen, cutoff.Add(-day), nil)
+	merged := add(models.StatusResolved, cutoff.Add(-day), func(r *models.Report) { r.DuplicateOf = &canonical })
+	gone := add(models.StatusResolved, cutoff.Add(-day), func(r *models.Report) { r.WithdrawnAt = &withdrawn })
+	silent := add(models.StatusResolved, cutoff.Add(-day), func(r *models.Report) { r.VoiceURL = "" })
+	add(models.StatusResolved, cutoff.Add(-day), func(r *models.Report) { r.Category = "roads" })
+
+	for _, tc := range []struct {
+		name string
+		rule models.RetentionRule
+		want []primitive.ObjectID
+	}{
+		{"age and status", models.RetentionRule{Action: models.RetentionCoarsenLocation, AfterDays: 30,
+			Statuses: []string{models.StatusResolved, models.StatusRejected}, Categories: []string{"water"}},
+			[]primitive.ObjectID{atCutoff, old, rejected, merged, gone, silent}},
+		{"one status", models.RetentionRule{Action: models.RetentionDeleteReport, AfterDays: 30,
+			Statuses: []string{models.StatusRejected}},
+			[]primitive.ObjectID{rejected}},
+		{"voice rules skip reports without voice", models.RetentionRule{Action: models.RetentionDeleteVoice, AfterDays: 30,
+			Statuses: []string{models.StatusResolved}, Categories: []string{"water"}},
+			[]primitive.ObjectID{atCutoff, old, merged, gone}},
+		{"photo rules skip reports without photos", models.RetentionRule{Action: models.RetentionDeletePhotos, AfterDays: 30},
+			nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := scanIDs(t, repo, retentionQuery(tc.rule, retentionNow))
+			slices.SortFunc(got, func(a, b primitive.ObjectID) int { return strings.Compare(a.Hex(), b.Hex()) })
+			want := slices.Clone(tc.want)
+			slices.SortFunc(want, func(a, b primitive.ObjectID) int { return strings.Compare(a.Hex(), b.Hex()) })
+			if !slices.Equal(got, want) {
+				t.Errorf("matched %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestApplyRetention(t *testing.T) {
+	radius := 50
+	accuracy := 10
+	report := func() models.Report {
+		return models.Report{
+			Lat: 9.0301, Lng: 38.7402, AccuracyM: &accuracy, PrivacyRadiusM: &radius, Adrehs: "AD-123",
+			VoiceURL:  "/uploads/v2.ogg",
+			PhotoURLs: []string{"/uploads/p2.jpg"},
+			Revisions: []models.Revision{
+				{Before: models.ReportSnapshot{VoiceURL: "/uploads/v1.ogg", PhotoURLs: []string{"/uploads/p1.jpg", "/uploads/p2.jpg"}}},
+				{Before: models.ReportSnapshot{VoiceURL: "/uploads/v2.ogg"}},
+			},
+			StatusHistory: []models.StatusChange{{To: models.StatusResolved, PhotoURLs: []string{"/uploads/crew.jpg"}}},
+		}
+	}
+
+	t.Run("delete_voice", func(t *testing.T) {
+		r := report()
+		changed, files := applyRetention(&r, models.RetentionRule{Action: models.RetentionDeleteVoice})
+		if !changed || !slices.Equal(files, []string{"/uploads/v2.ogg", "/uploads/v1.ogg"}) {
+			t.Fatalf("got %v %v", changed, files)
+		}
+		if r.VoiceURL != "" || r.Revisions[0].Before.VoiceURL != "" || r.Revisions[1].Before.VoiceURL != "" {
+			t.Errorf("voice left behind: %+v", r)
+		}
+		if len(r.PhotoURLs) != 1 || len(r.Revisions[0].Before.PhotoURLs) != 2 {
+			t.Errorf("photos touched: %+v", r)
+		}
+		if changed, files := applyRetention(&r, models.RetentionRule{Action: models.RetentionDeleteVoice}); changed || files != nil {
+			t.Errorf("second pass changed %v %v", changed, files)
+		}
+	})
+
+	t.Run("delete_photos keeps status photos", func(t *testing.T) {
+		r := report()
+		changed, files := applyRetention(&r, models.RetentionRule{Action: models.RetentionDeletePhotos})
+		if !changed || !slices.Equal(files, []string{"/uploads/p2.jpg", "/uploads/p1.jpg"}) {
+			t.Fatalf("got %v %v", changed, files)
+		}
+		if r.PhotoURLs != nil || r.Revisions[0].Before.PhotoURLs != nil {
+			t.Errorf("photos left behind: %+v", r)
+		}
+		if r.VoiceURL == "" || len(r.StatusHistory[0].PhotoURLs) != 1 {
+			t.Errorf("voice or status photos touched: %+v", r)
+		}
+	})
+
+	t.Run("coarsen_location", func(t *testing.T) {
+		r := report()
+		grid := r
+		coarse := DefaultCoarsenM
+		grid.PrivacyRadiusM = &coarse
+		wantLat, wantLng := repository.PublicPosition(grid)
+		changed, files := applyRetention(&r, models.RetentionRule{Action: models.RetentionCoarsenLocation})
+		if !changed || files != nil {
+			t.Fatalf("got %v %v", changed, files)
+		}
+		if r.CoarsenedM != DefaultCoarsenM || *r.PrivacyRadiusM != DefaultCoarsenM {
+			t.Errorf("coarsened to %d, radius %d", r.CoarsenedM, *r.PrivacyRadiusM)
+		}
+		if r.AccuracyM != nil || r.Adrehs != "" {
+			t.Errorf("precise location left behind: %+v", r)
+		}
+		if r.Lat != wantLat || r.Lng != wantLng {
+			t.Errorf("stored %v,%v, want the %dm cell centre %v,%v", r.Lat, r.Lng, DefaultCoarsenM, wantLat, wantLng)
+		}
+		if changed, _ := applyRetention(&r, models.RetentionRule{Action: models.RetentionCoarsenLocation, RadiusM: 500}); changed {
+			t.Error("a finer grid changed an already coarsened report")
+		}
+		wide := 5000
+		r = report()
+		r.PrivacyRadiusM = &wide
+		applyRetention(&r, models.RetentionRule{Action: models.RetentionCoarsenLocation, RadiusM: 500})
+		if *r.PrivacyRadiusM != wide || r.CoarsenedM != wide {
+			t.Errorf("wider privacy radius shrunk to %d/%d", *r.PrivacyRadiusM, r.CoarsenedM)
+		}
+	})
+}
+
+func TestRetentionApply(t *testing.T) {
+	ctx := context.Background()
+	j, store := newTestRetention(t)
+	old := retentionNow.AddDate(0, 0, -100)
+
+	voice := saveMedia(t, j.Media, "voice")
+	photo := saveMedia(t, j.Media, "photo")
+	stripped := createReport(t, j.Reports, models.Report{Category: "water", Status: models.StatusResolved,
+		CreatedAt: old, VoiceURL: voice, PhotoURLs: []string{photo}})
+
+	doomedVoice := saveMedia(t, j.Media, "voice")
+	doomedPhoto := saveMedia(t, j.Media, "photo")
+	doomed := createReport(t, j.Reports, models.Report{Category: "water", Status: models.StatusRejected,
+		CreatedAt: old, VoiceURL: doomedVoice, PhotoURLs: []string{doomedPhoto}})
+
+	voiceRule := store.AddRule(models.RetentionRule{Name: "voice", Action: models.RetentionDeleteVoice,
+		AfterDays: 90, Statuses: []string{models.StatusResolved}, Active: true})
+	store.AddRule(models.RetentionRule{Name: "inactive", Action: models.RetentionDeletePhotos, AfterDays: 1})
+	store.AddRule(models.RetentionRule{Name: "drop rejected", Action: models.RetentionDeleteReport,
+		AfterDays: 90, Statuses: []string{models.StatusRejected}, Active: true})
+
+	run, err := j.Apply(ctx, retentionNow, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(run.Rules) != 2 || run.Rules[0].RuleID != voiceRule.ID {
+		t.Fatalf("rules run: %+v", run.Rules)
+	}
+	if res := run.Rules[0]; res.Reports != 1 || res.Files != 1 || !slices.Equal(res.ReportIDs, []primitive.ObjectID{stripped.ID}) {
+		t.Errorf("voice rule: %+v", res)
+	}
+	if res := run.Rules[1]; res.Reports != 1 || res.Files != 2 || !slices.Equal(res.ReportIDs, []primitive.ObjectID{doomed.ID}) {
+		t.Errorf("delete rule: %+v", res)
+	}
+
+	r, err := j.Reports.Get(ctx, stripped.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.VoiceURL != "" || !slices.Equal(r.PhotoURLs, []string{photo}) {
+		t.Errorf("stripped report: voice %q photos %v", r.VoiceURL, r.PhotoURLs)
+	}
+	if mediaExists(t, j.Media, voice) || !mediaExists(t, j.Media, photo) {
+		t.Errorf("stripped report's files: voice %v photo %v", mediaExists(t, j.Media, voice), mediaExists(t, j.Media, photo))
+	}
+
+	if _, err := j.Reports.Get(ctx, doomed.ID); err != repository.ErrNotFound {
+		t.Errorf("purged report: %v", err)
+	}
+	if !slices.Equal(store.Purged(), []primitive.ObjectID{doomed.ID}) {
+		t.Errorf("purged %v", store.Purged())
+	}
+	if mediaExists(t, j.Media, doomedVoice) || mediaExists(t, j.Media, doomedPhoto) {
+		t.Error("purged report's files left on disk")
+	}
+
+	if runs := store.Runs(); len(runs) != 1 || runs[0].ID.IsZero() || runs[0].ID != run.ID || runs[0].DryRun {
+		t.Errorf("stored runs %+v", runs)
+	}
+
+	// nothing is left to do on a second pass
+	run, err = j.Apply(ctx, retentionNow, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, res := range run.Rules {
+		if res.Reports != 0 || res.Files != 0 {
+			t.Errorf("second pass: %+v", res)
+		}
+	}
+}
+
+func TestRetentionDryRun(t *testing.T) {
+	ctx := context.Background()
+	j, store := newTestRetention(t)
+	old := retentionNow.AddDate(0, 0, -100)
+	voice := saveMedia(t, j.Media, "voice")
+	photo := saveMedia(t, j.Media, "photo")
+	r := createReport(t, j.Reports, models.Report{Category: "water", Status: models.StatusResolved,
+		CreatedAt: old, VoiceURL: voice, PhotoURLs: []string{photo}})
+	store.AddRule(models.RetentionRule{Name: "voice", Action: models.RetentionDeleteVoice, AfterDays: 90, Active: true})
+	store.AddRule(models.RetentionRule{Name: "all", Action: models.RetentionDeleteReport, AfterDays: 90, Active: true})
+
+	run, err := j.Apply(ctx, retentionNow, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !run.DryRun || len(run.Rules) != 2 {
+		t.Fatalf("run %+v", run)
+	}
+	if res := run.Rules[0]; res.Reports != 1 || res.Files != 1 {
+		t.Errorf("voice rule would do %+v", res)
+	}
+	if res := run.Rules[1]; res.Reports != 1 || res.Files != 2 {
+		t.Errorf("delete rule would do %+v", res)
+	}
+
+	got, err := j.Reports.Get(ctx, r.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.VoiceURL != voice || len(got.PhotoURLs) != 1 {
+		t.Errorf("dry run changed the report: %+v", got)
+	}
+	if !mediaExists(t, j.Media, voice) || !mediaExists(t, j.Media, photo) {
+		t.Error("dry run deleted files")
+	}
+	if len(store.Purged()) != 0 {
+		t.Errorf("dry run purged %v", store.Purged())
+	}
+	if runs := store.Runs(); len(runs) != 1 || !runs[0].DryRun {
+		t.Errorf("stored runs %+v", runs)
+	}
+}
+
+// A moderator can attach a stored file to several reports; retention must
+// only delete it once none of them uses it.
+func TestRetentionKeepsSharedFiles(t *testing.T) {
+	ctx := context.Background()
+	j, store := newTestRetention(t)
+	old := retentionNow.AddDate(0, 0, -100)
+	shared := saveMedia(t, j.Media, "photo")
+	own := saveMedia(t, j.Media, "photo")
+	crew := saveMedia(t, j.Media, "status")
+
+	doomed := createReport(t, j.Reports, models.Report{Category: "water", Status: models.StatusRejected,
+		CreatedAt: old, PhotoURLs: []string{shared, own}})
+	// a recent report the rules don't cover, showing the shared photo as a
+	// status photo
+	createReport(t, j.Reports, models.Report{Category: "water", Status: models.StatusResolved, CreatedAt: retentionNow,
+		StatusHistory: []models.StatusChange{{To: models.StatusResolved, PhotoURLs: []string{shared}}}})
+	// the same file as reporter and status photo of one report
+	stripped := createReport(t, j.Reports, models.Report{Category: "water", Status: models.StatusResolved, CreatedAt: old,
+		PhotoURLs:     []string{crew},
+		StatusHistory: []models.StatusChange{{To: models.StatusResolved, PhotoURLs: []string{crew}}}})
+
+	store.AddRule(models.RetentionRule{Name: "drop rejected", Action: models.RetentionDeleteReport,
+		AfterDays: 90, Statuses: []string{models.StatusRejected}, Active: true})
+	store.AddRule(models.RetentionRule{Name: "photos", Action: models.RetentionDeletePhotos,
+		AfterDays: 90, Statuses: []string{models.StatusResolved}, Active: true})
+
+	dry, err := j.Apply(ctx, retentionNow, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dry.Rules[0].Files != 1 || dry.Rules[1].Files != 0 {
+		t.Errorf("dry run would delete %d and %d file(s), want 1 and 0", dry.Rules[0].Files, dry.Rules[1].Files)
+	}
+
+	run, err := j.Apply(ctx, retentionNow, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Rules[0].Reports != 1 || run.Rules[0].Files != 1 {
+		t.Errorf("delete rule: %+v", run.Rules[0])
+	}
+	if run.Rules[1].Reports != 1 || run.Rules[1].Files != 0 {
+		t.Errorf("photo rule: %+v", run.Rules[1])
+	}
+	if _, err := j.Reports.Get(ctx, doomed.ID); err != repository.ErrNotFound {
+		t.Errorf("purged report: %v", err)
+	}
+	if got, _ := j.Reports.Get(ctx, stripped.ID); got.PhotoURLs != nil {
+		t.Errorf("photos left on %+v", got)
+	}
+	if mediaExists(t, j.Media, own) {
+		t.Error("the purged report's own photo is still on disk")
+	}
+	if !mediaExists(t, j.Media, shared) {
+		t.Error("a photo another report shows was deleted")
+	}
+	if !mediaExists(t, j.Media, crew) {
+		t.Error("a status photo was deleted with the reporter's photos")
+	}
+}
+
+func TestMediaURLs(t *testing.T) {
+	r := models.Report{
+		VoiceURL:  "/uploads/v2.ogg",
+		PhotoURLs: []string{"/uploads/p1.jpg", "/uploads/p2.jpg"},
+		Revisions: []models.Revision{
+			{Before: models.ReportSnapshot{VoiceURL: "/uploads/v1.ogg", PhotoURLs: []string{"/uploads/p1.jpg"}}},
+			{Before: models.ReportSnapshot{PhotoURLs: []string{"/uploads/p0.jpg"}}},
+		},
+		StatusHistory: []models.StatusChange{
+			{To: models.StatusAcknowledged},
+			{To: models.StatusResolved, PhotoURLs: []string{"/uploads/crew.jpg", "/uploads/p2.jpg"}},
+		},
+	}
+	want := []string{"/uploads/v2.ogg", "/uploads/p1.jpg", "/uploads/p2.jpg", "/uploads/v1.ogg", "/uploads/p0.jpg", "/uploads/crew.jpg"}
+	if got := mediaURLs(r); !slices.Equal(got, want) {
+		t.Errorf("mediaURLs = %v, want %v", got, want)
+	}
+	if got := mediaURLs(models.Report{}); len(got) != 0 {
+		t.Errorf("mediaURLs of a bare report = %v", got)
+	}
+	if got := uniqueURLs([]string{"a", "b", "a", "c", "b"}); !slices.Equal(got, []string{"a", "b", "c"}) {
+		t.Errorf("uniqueURLs = %v", got)
+	}
+}
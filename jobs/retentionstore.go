@@ -0,0 +1,144 @@
+// path: jobs/retentionstore.go
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"meniba/database"
+	"meniba/models"
+	"meniba/notify"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RetentionStore holds the rules Retention applies, the record of its
+// runs, and the data kept about a report outside the reports collection.
+// It runs against MongoDB in production and MemoryRetention in tests.
+type RetentionStore interface {
+	// Rules returns the active rules in creation order.
+	Rules(ctx context.Context) ([]models.RetentionRule, error)
+	// SaveRun stores run, setting its ID.
+	SaveRun(ctx context.Context, run *models.RetentionRun) error
+	// PurgeReport deletes the comments, tracking tokens and queued emails
+	// of report id. It tries every collection and returns the failures.
+	PurgeReport(ctx context.Context, id primitive.ObjectID) error
+}
+
+// MongoRetention keeps rules in RetentionRulesCol and runs in
+// RetentionRunsCol.
+type MongoRetention struct {
+	DB *database.DB
+}
+
+// NewMongoRetention returns a store over db.
+func NewMongoRetention(db *database.DB) *MongoRetention {
+	return &MongoRetention{DB: db}
+}
+
+func (m *MongoRetention) Rules(ctx context.Context) ([]models.RetentionRule, error) {
+	cur, err := m.DB.Col(RetentionRulesCol).Find(ctx, bson.M{"active": true},
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var rules []models.RetentionRule
+	err = cur.All(ctx, &rules)
+	return rules, err
+}
+
+func (m *MongoRetention) SaveRun(ctx context.Context, run *models.RetentionRun) error {
+	res, err := m.DB.Col(RetentionRunsCol).InsertOne(ctx, run)
+	if err != nil {
+		return err
+	}
+	run.ID = res.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (m *MongoRetention) PurgeReport(ctx context.Context, id primitive.ObjectID) error {
+	var errs []error
+	for _, del := range []struct {
+		col    string
+		filter bson.M
+	}{
+		{"comments", bson.M{"report_id": id}},
+		{"tracking_tokens", bson.M{"report_id": id}},
+		{notify.OutboxCol, bson.M{"report_id": id.Hex()}},
+	} {
+		if _, err := m.DB.Col(del.col).DeleteMany(ctx, del.filter); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", del.col, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// MemoryRetention is an in-process RetentionStore for tests and local
+// demos.
+type MemoryRetention struct {
+	mu     sync.Mutex
+	rules  []models.RetentionRule
+	runs   []models.RetentionRun
+	purged []primitive.ObjectID
+}
+
+// NewMemoryRetention returns an empty in-memory store.
+func NewMemoryRetention() *MemoryRetention {
+	return &MemoryRetention{}
+}
+
+// AddRule stores rule, assigning an ID if it has none.
+func (m *MemoryRetention) AddRule(rule models.RetentionRule) models.RetentionRule {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if rule.ID.IsZero() {
+		rule.ID = primitive.NewObjectID()
+	}
+	m.rules = append(m.rules, rule)
+	return rule
+}
+
+// Runs returns a copy of the stored runs, oldest first.
+func (m *MemoryRetention) Runs() []models.RetentionRun {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]models.RetentionRun(nil), m.runs...)
+}
+
+// Purged returns the reports PurgeReport was called for, in order.
+func (m *MemoryRetention) Purged() []primitive.ObjectID {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]primitive.ObjectID(nil), m.purged...)
+}
+
+func (m *MemoryRetention) Rules(_ context.Context) ([]models.RetentionRule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []models.RetentionRule
+	for _, r := range m.rules {
+		if r.Active {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+func (m *MemoryRetention) SaveRun(_ context.Context, run *models.RetentionRun) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	run.ID = primitive.NewObjectID()
+	m.runs = append(m.runs, *run)
+	return nil
+}
+
+func (m *MemoryRetention) PurgeReport(_ context.Context, id primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.purged = append(m.purged, id)
+	return nil
+}
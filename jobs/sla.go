@@ -0,0 +1,255 @@
+// path: jobs/sla.go
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"meniba/audit"
+	"meniba/database"
+	"meniba/events"
+	"meniba/models"
+	"meniba/repository"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SLAPoliciesCol holds the SLA definitions applied by SLAMonitor.
+const SLAPoliciesCol = "sla_policies"
+
+// SLAMonitor keeps the SLA clock of every open assigned report, records
+// missed deadlines, escalates to the policy's supervisor agency and
+// announces both on the bus.
+type SLAMonitor struct {
+	DB       *database.DB
+	Reports  repository.ReportRepository
+	Bus      *events.Bus
+	Audit    *audit.Log // records breaches and escalations; nil = off
+	Interval time.Duration
+}
+
+// NewSLAMonitor returns a monitor that checks every minute.
+func NewSLAMonitor(db *database.DB, reports repository.ReportRepository, bus *events.Bus) *SLAMonitor {
+	return &SLAMonitor{DB: db, Reports: reports, Bus: bus, Interval: time.Minute}
+}
+
+// Run checks once at start and then every Interval until ctx is cancelled.
+func (m *SLAMonitor) Run(ctx context.Context) {
+	t := time.NewTicker(m.Interval)
+	defer t.Stop()
+	for {
+		if n, err := m.Check(ctx, time.Now().UTC()); err != nil {
+			log.Printf("sla: %v", err)
+		} else if n > 0 {
+			log.Printf("sla: %d new breach(es)", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Check brings every open assigned report up to date as of now and returns
+// how many deadlines were newly missed.
+func (m *SLAMonitor) Check(ctx context.Context, now time.Time) (int, error) {
+	cur, err := m.DB.Col(SLAPoliciesCol).Find(ctx, bson.M{"active": true},
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return 0, err
+	}
+	var policies []models.SLAPolicy
+	if err := cur.All(ctx, &policies); err != nil {
+		return 0, err
+	}
+
+	assigned := true
+	q := repository.ReportQuery{
+		Assigned: &assigned,
+		Status:   repository.Terms{NotIn: []string{models.StatusResolved, models.StatusRejected}},
+	}
+	var due []primitive.ObjectID
+	err = m.Reports.Scan(ctx, q, func(r models.Report) error {
+		if changed, _ := applySLA(&r, policies, now); changed {
+			due = append(due, r.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, id := range due {
+		var breaches []models.SLABreach
+		var before json.RawMessage
+		after, err := m.Reports.Update(ctx, id, func(r *models.Report) error {
+			before = audit.Snapshot(*r)
+			var changed bool
+			changed, breaches = applySLA(r, policies, now)
+			if !changed {
+				return repository.ErrNoChange
+			}
+			return nil
+		})
+		if errors.Is(err, repository.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return total, err
+		}
+		if len(breaches) > 0 && m.Audit != nil {
+			action := audit.ReportSLABreach
+			for _, b := range breaches {
+				if b.EscalatedTo != nil {
+					action = audit.ReportEscalate
+				}
+			}
+			if err := m.Audit.Record(ctx, audit.System("sla"), audit.Entry{
+				Action: action, Target: "report", TargetID: id, Before: before, After: after,
+			}); err != nil {
+				log.Printf("sla: audit %s: %v", id.Hex(), err)
+			}
+		}
+		for _, b := range breaches {
+			total++
+			m.Bus.Publish(events.Event{Type: events.ReportOverdue, ReportID: id.Hex(), Report: &after, Data: b})
+			if b.EscalatedTo != nil {
+				m.Bus.Publish(events.Event{
+					Type:     events.ReportAssigned,
+					ReportID: id.Hex(),
+					Report:   &after,
+					Data:     after.AssignmentHistory[len(after.AssignmentHistory)-1],
+				})
+			}
+		}
+	}
+	return total, nil
+}
+
+// applySLA updates r's SLA clock and records breaches as of now. It
+// reports whether r changed and returns the newly missed deadlines.
+func applySLA(r *models.Report, policies []models.SLAPolicy, now time.Time) (bool, []models.SLABreach) {
+	agencies, start := baseAssignment(*r)
+	p := pickPolicy(policies, r.Category, agencies)
+	if p == nil {
+		if r.SLA == nil {
+			return false, nil
+		}
+		r.SLA = nil
+		return true, nil
+	}
+
+	changed := false
+	ackDue, resolveDue := dueAt(start, p.AckMinutes), dueAt(start, p.ResolveMinutes)
+	if r.SLA == nil || r.SLA.PolicyID != p.ID || !r.SLA.StartedAt.Equal(start) {
+		// new assignment or policy: a fresh clock
+		r.SLA = &models.SLAState{PolicyID: p.ID, StartedAt: start}
+		changed = true
+	}
+	if !sameTime(r.SLA.AckDueAt, ackDue) || !sameTime(r.SLA.ResolveDueAt, resolveDue) {
+		r.SLA.AckDueAt, r.SLA.ResolveDueAt = ackDue, resolveDue
+		changed = true
+	}
+
+	var breaches []models.SLABreach
+
+	status := r.CurrentStatus()
+	for _, stage := range []struct {
+		name  string
+		due   *time.Time
+		unmet bool
+	}{
+		{models.SLAStageAck, ackDue, status == models.StatusOpen},
+		{models.SLAStageResolve, resolveDue, status != models.StatusResolved && status != models.StatusRejected},
+	} {
+		if stage.due == nil || now.Before(*stage.due) || !stage.unmet || r.SLA.Breached(stage.name) {
+			continue
+		}
+		b := models.SLABreach{Stage: stage.name, DueAt: *stage.due, At: now}
+		if sup := p.SupervisorID; sup != nil && !containsID(r.Agencies, *sup) {
+			to := append(append([]primitive.ObjectID{}, r.Agencies...), *sup)
+			r.AssignmentHistory = append(r.AssignmentHistory, models.Assignment{
+				From:   r.Agencies,
+				To:     to,
+				By:     models.AssignedByEscalation,
+				Reason: stage.name + " overdue",
+				At:     now,
+			})
+			r.Agencies = to
+			b.EscalatedTo = sup
+		}
+		r.SLA.Breaches = append(r.SLA.Breaches, b)
+		breaches = append(breaches, b)
+	}
+	return changed || len(breaches) > 0, breaches
+}
+
+// baseAssignment is the last assignment not made by escalation: the
+// agencies whose policy applies and when their clock started.
+func baseAssignment(r models.Report) ([]primitive.ObjectID, time.Time) {
+	for i := len(r.AssignmentHistory) - 1; i >= 0; i-- {
+		if a := r.AssignmentHistory[i]; a.By != models.AssignedByEscalation {
+			return a.To, a.At
+		}
+	}
+	return r.Agencies, r.CreatedAt
+}
+
+// pickPolicy returns the most specific policy covering the report: agency
+// and category, then agency, then category, then neither. Ties go to the
+// oldest policy.
+func pickPolicy(policies []models.SLAPolicy, category string, agencies []primitive.ObjectID) *models.SLAPolicy {
+	var best *models.SLAPolicy
+	bestScore := -1
+	for i, p := range policies {
+		score := 0
+		if p.Category != "" {
+			if !strings.EqualFold(p.Category, category) {
+				continue
+			}
+			score++
+		}
+		if p.AgencyID != nil {
+			if !containsID(agencies, *p.AgencyID) {
+				continue
+			}
+			score += 2
+		}
+		if score > bestScore {
+			best, bestScore = &policies[i], score
+		}
+	}
+	return best
+}
+
+func dueAt(start time.Time, minutes int) *time.Time {
+	if minutes <= 0 {
+		return nil
+	}
+	t := start.Add(time.Duration(minutes) * time.Minute)
+	return &t
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func containsID(list []primitive.ObjectID, v primitive.ObjectID) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"meniba/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestPickPolicy(t *testing.T) {
+	agencyA, agencyB := primitive.NewObjectID(), primitive.NewObjectID()
+	policies := []models.SLAPolicy{
+		{ID: primitive.NewObjectID(), AckMinutes: 1},                                         // 0: neither
+		{ID: primitive.NewObjectID(), Category: "water", AckMinutes: 2},                      // 1: category
+		{ID: primitive.NewObjectID(), AgencyID: &agencyA, AckMinutes: 3},                     // 2: agency
+		{ID: primitive.NewObjectID(), Category: "water", AgencyID: &agencyA, AckMinutes: 4},  // 3: both
+		{ID: primitive.NewObjectID(), Category: "Water", AckMinutes: 5},                      // 4: same as 1, newer
+		{ID: primitive.NewObjectID(), Category: "roads", AgencyID: &agencyB, AckMinutes: 6},  // 5: both
+		{ID: primitive.NewObjectID(), AgencyID: &agencyB, AckMinutes: 7},                     // 6: agency
+		{ID: primitive.NewObjectID(), Category: "roads", AgencyID: &agencyA, AckMinutes: 8},  // 7: both
+		{ID: primitive.NewObjectID(), Category: "health", AgencyID: &agencyA, AckMinutes: 9}, // 8: both
+	}
+	for _, tc := range []struct {
+		name     string
+		category string
+		agencies []primitive.ObjectID
+		want     int
+	}{
+		{"agency and category", "water", []primitive.ObjectID{agencyA}, 3},
+		{"agency and category among several agencies", "water", []primitive.ObjectID{agencyB, agencyA}, 3},
+		{"agency beats category", "sewage", []primitive.ObjectID{agencyA}, 2},
+		{"category, oldest of a tie", "WATER", []primitive.ObjectID{primitive.NewObjectID()}, 1},
+		{"agency alone", "WATER", []primitive.ObjectID{agencyB}, 6},
+		{"category without agencies", "water", nil, 1},
+		{"neither", "power", nil, 0},
+		{"tie between agency-and-category policies", "roads", []primitive.ObjectID{agencyA, agencyB}, 5},
+	} {
+		p := pickPolicy(policies, tc.category, tc.agencies)
+		if p == nil || p.ID != policies[tc.want].ID {
+			t.Errorf("%s: picked %+v, want policy %d", tc.name, p, tc.want)
+		}
+	}
+
+	if p := pickPolicy(policies[1:2], "roads", nil); p != nil {
+		t.Errorf("no covering policy: picked %+v", p)
+	}
+	if p := pickPolicy(nil, "water", nil); p != nil {
+		t.Errorf("no policies: picked %+v", p)
+	}
+}
+
+func TestApplySLA(t *testing.T) {
+	agency, other, supervisor := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	policy := models.SLAPolicy{ID: primitive.NewObjectID(), AckMinutes: 60, ResolveMinutes: 240, SupervisorID: &supervisor}
+	policies := []models.SLAPolicy{policy}
+	t0 := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	at := func(min int) time.Time { return t0.Add(time.Duration(min) * time.Minute) }
+
+	r := models.Report{
+		ID:        primitive.NewObjectID(),
+		Category:  "water",
+		CreatedAt: t0.Add(-time.Hour),
+		Agencies:  []primitive.ObjectID{agency},
+		AssignmentHistory: []models.Assignment{
+			{To: []primitive.ObjectID{agency}, By: models.AssignedByRouting, At: t0},
+		},
+	}
+
+	// the clock starts at the assignment, not at creation
+	changed, breaches := applySLA(&r, policies, at(1))
+	if !changed || len(breaches) != 0 || r.SLA == nil || r.SLA.PolicyID != policy.ID || !r.SLA.StartedAt.Equal(t0) ||
+		!r.SLA.AckDueAt.Equal(at(60)) || !r.SLA.ResolveDueAt.Equal(at(240)) {
+		t.Fatalf("fresh clock: %v %v %+v", changed, breaches, r.SLA)
+	}
+	if changed, _ := applySLA(&r, policies, at(2)); changed {
+		t.Fatal("changed with nothing due")
+	}
+	if r.Overdue(at(59)) || !r.Overdue(at(60)) {
+		t.Fatal("Overdue disagrees with the ack deadline")
+	}
+
+	// both deadlines pass before the monitor runs again: two breaches, but
+	// the supervisor is added once
+	changed, breaches = applySLA(&r, policies, at(300))
+	if !changed || len(breaches) != 2 || breaches[0].Stage != models.SLAStageAck || breaches[1].Stage != models.SLAStageResolve {
+		t.Fatalf("breaches = %+v", breaches)
+	}
+	if breaches[0].EscalatedTo == nil || *breaches[0].EscalatedTo != supervisor || breaches[1].EscalatedTo != nil {
+		t.Fatalf("escalations = %+v", breaches)
+	}
+	if !breaches[0].DueAt.Equal(at(60)) || !breaches[1].DueAt.Equal(at(240)) || !breaches[1].At.Equal(at(300)) {
+		t.Fatalf("breach times = %+v", breaches)
+	}
+	if len(r.Agencies) != 2 || r.Agencies[1] != supervisor || len(r.AssignmentHistory) != 2 {
+		t.Fatalf("after escalation: agencies %v, history %+v", r.Agencies, r.AssignmentHistory)
+	}
+	if esc := r.AssignmentHistory[1]; esc.By != models.AssignedByEscalation || len(esc.From) != 1 || len(esc.To) != 2 || !esc.At.Equal(at(300)) {
+		t.Fatalf("escalation entry = %+v", esc)
+	}
+
+	// next pass: no repeat, and the escalation did not restart the clock
+	if changed, breaches := applySLA(&r, policies, at(400)); changed || len(breaches) != 0 {
+		t.Fatalf("repeated: %v %+v", changed, breaches)
+	}
+	if !r.SLA.StartedAt.Equal(t0) || len(r.SLA.Breaches) != 2 {
+		t.Fatalf("clock after escalation = %+v", r.SLA)
+	}
+
+	// a moderator reassigns: a fresh clock from the reassignment
+	r.AssignmentHistory = append(r.AssignmentHistory, models.Assignment{
+		From: r.Agencies, To: []primitive.ObjectID{other}, By: models.AssignedByModerator, At: at(500),
+	})
+	r.Agencies = []primitive.ObjectID{other}
+	changed, breaches = applySLA(&r, policies, at(501))
+	if !changed || len(breaches) != 0 || !r.SLA.StartedAt.Equal(at(500)) || len(r.SLA.Breaches) != 0 ||
+		!r.SLA.AckDueAt.Equal(at(560)) || !r.SLA.ResolveDueAt.Equal(at(740)) {
+		t.Fatalf("after reassignment: %v %+v %+v", changed, breaches, r.SLA)
+	}
+
+	// acknowledged in time: only the resolve deadline can be missed
+	r.Status = models.StatusAcknowledged
+	changed, breaches = applySLA(&r, policies, at(800))
+	if !changed || len(breaches) != 1 || breaches[0].Stage != models.SLAStageResolve || breaches[0].EscalatedTo == nil {
+		t.Fatalf("acknowledged: %+v", breaches)
+	}
+
+	// no policy covers the report any more: the clock is dropped
+	if changed, _ := applySLA(&r, nil, at(900)); !changed || r.SLA != nil {
+		t.Fatalf("without a policy: %v %+v", changed, r.SLA)
+	}
+	if changed, _ := applySLA(&r, nil, at(901)); changed {
+		t.Fatal("changed without a policy or a clock")
+	}
+}
+
+func TestApplySLAClosedReports(t *testing.T) {
+	t0 := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	policies := []models.SLAPolicy{{ID: primitive.NewObjectID(), AckMinutes: 0, ResolveMinutes: 30}}
+	for _, st := range []string{models.StatusResolved, models.StatusRejected} {
+		r := models.Report{Status: st, CreatedAt: t0, Agencies: []primitive.ObjectID{primitive.NewObjectID()}}
+		_, breaches := applySLA(&r, policies, t0.Add(time.Hour))
+		if len(breaches) != 0 || r.SLA.AckDueAt != nil || r.Overdue(t0.Add(time.Hour)) {
+			t.Errorf("%s: breaches %+v, sla %+v", st, breaches, r.SLA)
+		}
+	}
+
+	// without a supervisor a breach is recorded but nobody is added
+	r := models.Report{Status: models.StatusInProgress, CreatedAt: t0}
+	_, breaches := applySLA(&r, policies, t0.Add(time.Hour))
+	if len(breaches) != 1 || breaches[0].EscalatedTo != nil || len(r.Agencies) != 0 || len(r.AssignmentHistory) != 0 {
+		t.Fatalf("no supervisor: %+v, %+v", breaches, r)
+	}
+}
@@ -6,8 +6,19 @@ import (
 	"log"
 	"time"
 
+	"meniba/accounts"
+	"meniba/audit"
+	"meniba/controllers"
 	"meniba/database"
+	"meniba/events"
+	"meniba/jobs"
+	"meniba/media"
+	"meniba/messaging"
+	"meniba/notify"
+	"meniba/repository"
 	"meniba/routes"
+	"meniba/routing"
+	"meniba/webhooks"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -16,10 +27,64 @@ import (
 )
 
 func main() {
-	if err := database.Connect(context.Background()); err != nil {
+	db, err := database.Connect(context.Background())
+	if err != nil {
 		log.Fatalf("db connect failed: %v", err)
 	}
 
+	bus := events.NewBus(500)
+	store := media.FromEnv()
+	dispatcher := webhooks.NewDispatcher(db, bus)
+	notifier, err := notify.NewNotifier(db, bus, notify.TransportFromEnv())
+	if err != nil {
+		log.Fatalf("notify: %v", err)
+	}
+
+	reports := repository.NewMongo(db)
+	auditLog, err := audit.New(db)
+	if err != nil {
+		log.Fatalf("audit: %v", err)
+	}
+	retention := jobs.NewRetention(db, reports, store)
+	retention.Audit = auditLog
+	mediaGC := jobs.NewMediaGC(db, reports, store)
+
+	// reporter accounts need a way to deliver codes
+	var accountsSvc *accounts.Service
+	if sms := accounts.SenderFromEnv(); sms != nil {
+		accountsSvc = accounts.New(db, sms)
+	} else {
+		log.Println("accounts: disabled (set SMS_GATEWAY_URL, or SMS_LOG_CODES=1 in development)")
+	}
+
+	h := &controllers.API{
+		Reports:      reports,
+		DB:           db,
+		Bus:          bus,
+		Media:        store,
+		Chat:         messaging.FromEnv(),
+		ChatSessions: repository.NewMongoChatSessions(db),
+		Comments:     repository.NewMongoComments(db),
+		Tracking:     repository.NewMongoTrackingTokens(db),
+		Webhooks:     dispatcher,
+		Router:       routing.NewRouter(db),
+		Notifier:     notifier,
+		Accounts:     accountsSvc,
+		Audit:        auditLog,
+		Retention:    retention,
+		MediaGC:      mediaGC,
+	}
+
+	// Background workers
+	go dispatcher.Run(context.Background())
+	go notifier.Run(context.Background())
+	go jobs.NewAnomalyDetector(db, reports).Run(context.Background())
+	sla := jobs.NewSLAMonitor(db, reports, bus)
+	sla.Audit = auditLog
+	go sla.Run(context.Background())
+	go retention.Run(context.Background())
+	go mediaGC.Run(context.Background())
+
 	app := fiber.New()
 	app.Use(recover.New())
 
@@ -31,7 +96,7 @@ func main() {
 	// CORS (dev-friendly)
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     "http://localhost:3000, http://localhost:3001, http://localhost:3002",
-		AllowMethods:     "GET,POST,OPTIONS",
+		AllowMethods:     "GET,POST,PATCH,DELETE,OPTIONS",
 		AllowHeaders:     "*",
 		AllowCredentials: false,
 		MaxAge:           int((12 * time.Hour).Seconds()),
@@ -44,13 +109,13 @@ func main() {
 	})
 
 	// Static preview for uploaded files
-	app.Static("/uploads", "./uploads")
+	app.Static(store.URLPrefix, store.Dir)
 
 	// Health
 	app.Get("/healthz", func(c *fiber.Ctx) error { return c.SendString("ok") })
 
 	// API
-	routes.Register(app)
+	routes.Register(app, h)
 
 	log.Println("API listening on :3005")
 	log.Fatal(app.Listen(":3005"))
@@ -0,0 +1,325 @@
+// path: media/store.go
+package media
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Store keeps uploaded media on local disk and hands out the public URL
+// path it is served under (app.Static maps URLPrefix to Dir).
+type Store struct {
+	Dir       string
+	URLPrefix string
+	MaxBytes  int64 // 0 = unlimited
+	// QuarantineDir holds files the garbage collector found unreferenced,
+	// outside Dir so they are no longer served.
+	QuarantineDir string
+	// StagingDir holds uploads of a Batch until it commits. It must be on
+	// the same filesystem as Dir so committing is a rename.
+	StagingDir string
+}
+
+// File is a stored (or quarantined) media file.
+type File struct {
+	Name    string
+	URL     string
+	Size    int64
+	ModTime time.Time // for quarantined files, when they were quarantined
+}
+
+// ErrTooLarge is returned when a file exceeds MaxBytes.
+var ErrTooLarge = errors.New("media: file too large")
+
+// FromEnv returns the store behind /uploads, rooted at UPLOAD_DIR.
+func FromEnv() *Store {
+	return NewStore(getenv("UPLOAD_DIR", "uploads"), "/uploads")
+}
+
+// NewStore returns a store writing to dir and serving under urlPrefix.
+// Quarantined and staged files go to sibling directories,
+// "<dir>.quarantine" and "<dir>.staging".
+func NewStore(dir, urlPrefix string) *Store {
+	return &Store{
+		Dir:           dir,
+		URLPrefix:     strings.TrimRight(urlPrefix, "/"),
+		MaxBytes:      32 << 20,
+		QuarantineDir: filepath.Clean(dir) + ".quarantine",
+		StagingDir:    filepath.Clean(dir) + ".staging",
+	}
+}
+
+// Save writes r to a new file named "<prefix>_<nanos>_<rand><ext>" and
+// returns its URL path (e.g. "/uploads/photo_..._a1b2c3.jpg").
+func (s *Store) Save(prefix, ext string, r io.Reader) (string, error) {
+	name := NewName(prefix, ext)
+	if err := s.write(s.Dir, name, r); err != nil {
+		return "", err
+	}
+	return s.URLPrefix + "/" + name, nil
+}
+
+// write copies r to dir/name, enforcing MaxBytes. Nothing is left behind
+// on error.
+func (s *Store) write(dir, name string, r io.Reader) error {
+	dst := filepath.Join(dir, name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	src := r
+	if s.MaxBytes > 0 {
+		src = io.LimitReader(r, s.MaxBytes+1)
+	}
+	n, err := io.Copy(out, src)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && s.MaxBytes > 0 && n > s.MaxBytes {
+		err = ErrTooLarge
+	}
+	if err != nil {
+		_ = os.Remove(dst)
+	}
+	return err
+}
+
+// Batch stages a set of uploads so they appear in the store together, or
+// not at all: files are written to StagingDir by Add and moved into Dir by
+// Commit. Rollback removes whatever wasn't committed, so callers can
+// defer it right after Stage.
+type Batch struct {
+	s     *Store
+	names []string
+	done  bool
+}
+
+// Stage starts a batch of uploads.
+func (s *Store) Stage() *Batch {
+	return &Batch{s: s}
+}
+
+// Resume reopens a batch from the URLs Add returned, for uploads staged
+// by an earlier request (e.g. a chat conversation spanning several
+// messages). URLs this store doesn't own are left out.
+func (s *Store) Resume(urls []string) *Batch {
+	b := &Batch{s: s}
+	for _, u := range urls {
+		name, ok := strings.CutPrefix(u, s.URLPrefix+"/")
+		if ok && validName(name) {
+			b.names = append(b.names, name)
+		}
+	}
+	return b
+}
+
+// Add stages r as "<prefix>_<nanos>_<rand><ext>" and returns the URL path
+// the file will have once the batch commits.
+func (b *Batch) Add(prefix, ext string, r io.Reader) (string, error) {
+	if b.done {
+		return "", errors.New("media: batch already finished")
+	}
+	name := NewName(prefix, ext)
+	if err := b.s.write(b.s.StagingDir, name, r); err != nil {
+		return "", err
+	}
+	b.names = append(b.names, name)
+	return b.s.URLPrefix + "/" + name, nil
+}
+
+// Commit moves every staged file into Dir. If any move fails, the files
+// already moved are removed again and the batch is rolled back. A nil
+// batch commits trivially.
+func (b *Batch) Commit() error {
+	if b == nil || b.done {
+		return nil
+	}
+	if len(b.names) > 0 {
+		if err := os.MkdirAll(b.s.Dir, 0o755); err != nil {
+			b.Rollback()
+			return err
+		}
+	}
+	for i, name := range b.names {
+		if err := os.Rename(filepath.Join(b.s.StagingDir, name), filepath.Join(b.s.Dir, name)); err != nil {
+			for _, moved := range b.names[:i] {
+				_ = os.Remove(filepath.Join(b.s.Dir, moved))
+			}
+			b.Rollback()
+			return err
+		}
+	}
+	b.done = true
+	return nil
+}
+
+// Rollback deletes the staged files. It does nothing once the batch has
+// committed, and is safe to call more than once.
+func (b *Batch) Rollback() {
+	if b == nil || b.done {
+		return
+	}
+	for _, name := range b.names {
+		_ = os.Remove(filepath.Join(b.s.StagingDir, name))
+	}
+	b.done = true
+}
+
+// Path maps a URL path from Save back to the file on disk. ok is false
+// for URLs this store doesn't own (e.g. remote media links).
+func (s *Store) Path(url string) (string, bool) {
+	if !strings.HasPrefix(url, s.URLPrefix+"/") {
+		return "", false
+	}
+	name := strings.TrimPrefix(url, s.URLPrefix+"/")
+	if !validName(name) {
+		return "", false
+	}
+	return filepath.Join(s.Dir, name), true
+}
+
+// Delete removes the file behind url. Missing files are not an error.
+func (s *Store) Delete(url string) error {
+	p, ok := s.Path(url)
+	if !ok {
+		return nil
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// List returns the files currently served from Dir.
+func (s *Store) List() ([]File, error) {
+	return s.list(s.Dir)
+}
+
+// Quarantined returns the files in QuarantineDir.
+func (s *Store) Quarantined() ([]File, error) {
+	return s.list(s.QuarantineDir)
+}
+
+// Staged returns the files in StagingDir: batches in progress, or left
+// behind by a process that died before committing or rolling back.
+func (s *Store) Staged() ([]File, error) {
+	return s.list(s.StagingDir)
+}
+
+// DiscardStaged deletes a staged file. Missing files are not an error.
+func (s *Store) DiscardStaged(name string) error {
+	if !validName(name) {
+		return fmt.Errorf("media: invalid name %q", name)
+	}
+	if err := os.Remove(filepath.Join(s.StagingDir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Quarantine moves the file behind url out of Dir, stamping it with the
+// current time so the grace period can be measured from its ModTime.
+func (s *Store) Quarantine(url string) error {
+	p, ok := s.Path(url)
+	if !ok {
+		return fmt.Errorf("media: not a stored file: %s", url)
+	}
+	if err := os.MkdirAll(s.QuarantineDir, 0o755); err != nil {
+		return err
+	}
+	dst := filepath.Join(s.QuarantineDir, filepath.Base(p))
+	if err := os.Rename(p, dst); err != nil {
+		return err
+	}
+	now := time.Now()
+	return os.Chtimes(dst, now, now)
+}
+
+// Restore moves a quarantined file back into Dir.
+func (s *Store) Restore(name string) error {
+	if !validName(name) {
+		return fmt.Errorf("media: invalid name %q", name)
+	}
+	return os.Rename(filepath.Join(s.QuarantineDir, name), filepath.Join(s.Dir, name))
+}
+
+// Purge deletes a quarantined file. Missing files are not an error.
+func (s *Store) Purge(name string) error {
+	if !validName(name) {
+		return fmt.Errorf("media: invalid name %q", name)
+	}
+	if err := os.Remove(filepath.Join(s.QuarantineDir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *Store) list(dir string) ([]File, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []File
+	for _, e := range entries {
+		if !e.Type().IsRegular() || !validName(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue // removed while listing
+			}
+			return nil, err
+		}
+		out = append(out, File{
+			Name:    e.Name(),
+			URL:     s.URLPrefix + "/" + e.Name(),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+	}
+	return out, nil
+}
+
+func validName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, `/\`) && !strings.Contains(name, "..")
+}
+
+// NewName builds a unique file name. ext is lower-cased and capped so a
+// hostile filename can't produce odd paths.
+func NewName(prefix, ext string) string {
+	ext = strings.ToLower(ext)
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	if len(ext) > 8 {
+		ext = ext[:8]
+	}
+	if strings.ContainsAny(ext, `/\`) {
+		ext = ""
+	}
+	b := make([]byte, 3)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%s_%d_%s%s", prefix, time.Now().UnixNano(), hex.EncodeToString(b), ext)
+}
+
+func getenv(k, def string) string {
+	if v := os.Getenv(k); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,217 @@
+package media
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	return NewStore(filepath.Join(t.TempDir(), "uploads"), "/uploads/")
+}
+
+// names lists the files in dir; a missing dir is empty.
+func names(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, e := range entries {
+		out = append(out, e.Name())
+	}
+	return out
+}
+
+func add(t *testing.T, b *Batch, prefix, body string) string {
+	t.Helper()
+	u, err := b.Add(prefix, ".JPG", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestBatchCommit(t *testing.T) {
+	s := newTestStore(t)
+	b := s.Stage()
+	defer b.Rollback()
+	u1 := add(t, b, "photo", "one")
+	u2 := add(t, b, "photo", "two")
+	if !strings.HasPrefix(u1, "/uploads/photo_") || !strings.HasSuffix(u1, ".jpg") {
+		t.Errorf("url %q", u1)
+	}
+	if got := names(t, s.Dir); len(got) != 0 {
+		t.Errorf("served before commit: %v", got)
+	}
+	if got := names(t, s.StagingDir); len(got) != 2 {
+		t.Errorf("staged %v", got)
+	}
+
+	if err := b.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if got := names(t, s.StagingDir); len(got) != 0 {
+		t.Errorf("left in staging: %v", got)
+	}
+	for u, body := range map[string]string{u1: "one", u2: "two"} {
+		p, ok := s.Path(u)
+		if !ok {
+			t.Fatalf("Path(%q) not ok", u)
+		}
+		data, err := os.ReadFile(p)
+		if err != nil || string(data) != body {
+			t.Errorf("%s: %q, %v", u, data, err)
+		}
+	}
+
+	b.Rollback() // deferred in handlers; must not undo the commit
+	if got := names(t, s.Dir); len(got) != 2 {
+		t.Errorf("rollback after commit left %v", got)
+	}
+	if _, err := b.Add("photo", ".jpg", strings.NewReader("late")); err == nil {
+		t.Error("Add after commit succeeded")
+	}
+	if err := b.Commit(); err != nil {
+		t.Errorf("second commit: %v", err)
+	}
+	var none *Batch
+	if err := none.Commit(); err != nil {
+		t.Errorf("nil batch commit: %v", err)
+	}
+	none.Rollback()
+}
+
+func TestBatchRollback(t *testing.T) {
+	s := newTestStore(t)
+	b := s.Stage()
+	add(t, b, "voice", "hello")
+	add(t, b, "photo", "pic")
+	b.Rollback()
+	b.Rollback()
+	if got := names(t, s.StagingDir); len(got) != 0 {
+		t.Errorf("left in staging: %v", got)
+	}
+	if err := b.Commit(); err != nil {
+		t.Errorf("commit after rollback: %v", err)
+	}
+	if got := names(t, s.Dir); len(got) != 0 {
+		t.Errorf("committed after rollback: %v", got)
+	}
+}
+
+func TestBatchCommitFailureRollsBack(t *testing.T) {
+	t.Run("store dir unusable", func(t *testing.T) {
+		s := newTestStore(t)
+		if err := os.WriteFile(s.Dir, []byte("not a dir"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		b := s.Stage()
+		add(t, b, "photo", "one")
+		if err := b.Commit(); err == nil {
+			t.Fatal("commit succeeded")
+		}
+		if got := names(t, s.StagingDir); len(got) != 0 {
+			t.Errorf("left in staging: %v", got)
+		}
+	})
+
+	t.Run("one file fails to move", func(t *testing.T) {
+		s := newTestStore(t)
+		b := s.Stage()
+		add(t, b, "photo", "one")
+		lost := add(t, b, "photo", "two")
+		add(t, b, "photo", "three")
+		if err := os.Remove(filepath.Join(s.StagingDir, filepath.Base(lost))); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.Commit(); err == nil {
+			t.Fatal("commit succeeded")
+		}
+		// all or nothing: the file already moved is taken back out
+		if got := names(t, s.Dir); len(got) != 0 {
+			t.Errorf("served after a failed commit: %v", got)
+		}
+		if got := names(t, s.StagingDir); len(got) != 0 {
+			t.Errorf("left in staging: %v", got)
+		}
+	})
+}
+
+func TestResume(t *testing.T) {
+	s := newTestStore(t)
+	first := s.Stage()
+	u1 := add(t, first, "photo", "one")
+	u2 := add(t, first, "voice", "two")
+	// the request ends without committing; a later one finishes the batch
+	b := s.Resume([]string{u1, "https://example.org/x.jpg", "/uploads/../secret", u2})
+	if len(b.names) != 2 {
+		t.Fatalf("resumed %v", b.names)
+	}
+	if err := b.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range []string{u1, u2} {
+		p, _ := s.Path(u)
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("%s not committed: %v", u, err)
+		}
+	}
+	if got := names(t, s.StagingDir); len(got) != 0 {
+		t.Errorf("left in staging: %v", got)
+	}
+}
+
+func TestValidName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"photo_1_abc.jpg": true,
+		"":                false,
+		"..":              false,
+		"../secret":       false,
+		"a/b.jpg":         false,
+		`a\b.jpg`:         false,
+		"photo..jpg":      false,
+	} {
+		if got := validName(name); got != want {
+			t.Errorf("validName(%q) = %v, want %v", name, got, want)
+		}
+	}
+
+	s := newTestStore(t)
+	for _, u := range []string{"/uploads/../main.go", "/uploads/a/b.jpg", "/uploadsx/a.jpg", "/other/a.jpg", "/uploads/"} {
+		if p, ok := s.Path(u); ok {
+			t.Errorf("Path(%q) = %q", u, p)
+		}
+	}
+	for name, op := range map[string]func(string) error{
+		"Restore": s.Restore, "Purge": s.Purge, "DiscardStaged": s.DiscardStaged,
+	} {
+		if err := op("../uploads.staging/x"); err == nil {
+			t.Errorf("%s accepted a traversing name", name)
+		}
+	}
+	if err := s.Quarantine("/uploads/../x"); err == nil {
+		t.Error("Quarantine accepted a traversing URL")
+	}
+}
+
+func TestSaveTooLarge(t *testing.T) {
+	s := newTestStore(t)
+	s.MaxBytes = 4
+	if _, err := s.Save("photo", ".jpg", strings.NewReader("12345")); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("err = %v, want ErrTooLarge", err)
+	}
+	if got := names(t, s.Dir); len(got) != 0 {
+		t.Errorf("left behind: %v", got)
+	}
+	if _, err := s.Stage().Add("photo", ".jpg", strings.NewReader("12345")); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("staged err = %v, want ErrTooLarge", err)
+	}
+	if got := names(t, s.StagingDir); len(got) != 0 {
+		t.Errorf("left in staging: %v", got)
+	}
+}
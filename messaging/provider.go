@@ -0,0 +1,152 @@
+// path: messaging/provider.go
+package messaging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Provider is a chat platform (WhatsApp Business API, Telegram, or a
+// gateway in front of them) that can send replies and serve media.
+type Provider interface {
+	Send(ctx context.Context, to, text string) error
+	// Download fetches an attachment referenced by an inbound message. ref
+	// is a provider media ID, or a media URL on the provider's own host.
+	Download(ctx context.Context, ref string) (io.ReadCloser, string, error)
+}
+
+// HTTPProvider talks to a generic messaging gateway:
+//
+//	POST {BaseURL}/messages      {"to": "...", "text": "..."}
+//	GET  {BaseURL}/media/{id}    (or an absolute media URL on BaseURL's host)
+//
+// Both requests carry "Authorization: Bearer {Token}" when set. Media URLs
+// and redirects pointing anywhere else are refused, so an inbound payload
+// can't make the server fetch other hosts or leak the token. A local fake
+// gateway only needs to implement these two endpoints.
+type HTTPProvider struct {
+	BaseURL string
+	Token   string
+	HTTP    *http.Client
+}
+
+// FromEnv returns an HTTPProvider for CHAT_PROVIDER_URL, or a LogProvider
+// when no gateway is configured.
+func FromEnv() Provider {
+	base := strings.TrimRight(strings.TrimSpace(os.Getenv("CHAT_PROVIDER_URL")), "/")
+	if base == "" {
+		return LogProvider{}
+	}
+	return &HTTPProvider{
+		BaseURL: base,
+		Token:   strings.TrimSpace(os.Getenv("CHAT_PROVIDER_TOKEN")),
+		HTTP:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (p *HTTPProvider) Send(ctx context.Context, to, text string) error {
+	body, _ := json.Marshal(map[string]string{"to": to, "text": text})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.BaseURL+"/messages", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	p.auth(req)
+	resp, err := p.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("messaging: send: %s", resp.Status)
+	}
+	return nil
+}
+
+func (p *HTTPProvider) Download(ctx context.Context, ref string) (io.ReadCloser, string, error) {
+	base, err := url.Parse(p.BaseURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("messaging: invalid CHAT_PROVIDER_URL: %w", err)
+	}
+	u := p.BaseURL + "/media/" + url.PathEscape(ref)
+	if strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://") {
+		ru, err := url.Parse(ref)
+		if err != nil || !sameOrigin(ru, base) {
+			return nil, "", fmt.Errorf("messaging: media URL not on the provider host: %q", ref)
+		}
+		u = ru.String()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	p.auth(req)
+
+	c := *p.HTTP
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 5 {
+			return errors.New("messaging: too many redirects")
+		}
+		if !sameOrigin(req.URL, base) {
+			return fmt.Errorf("messaging: media redirect off the provider host: %s", req.URL.Host)
+		}
+		return nil
+	}
+	return fetch(&c, req)
+}
+
+func (p *HTTPProvider) auth(req *http.Request) {
+	if p.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.Token)
+	}
+}
+
+// LogProvider logs replies instead of sending them. With no gateway
+// configured there is no host media could safely be fetched from, so
+// Download always fails. Used in development.
+type LogProvider struct{}
+
+func (p LogProvider) Send(_ context.Context, to, text string) error {
+	log.Printf("messaging: (log only) to=%s text=%q", redactAddr(to), text)
+	return nil
+}
+
+func (p LogProvider) Download(_ context.Context, ref string) (io.ReadCloser, string, error) {
+	return nil, "", fmt.Errorf("messaging: cannot fetch media %q without CHAT_PROVIDER_URL", ref)
+}
+
+func fetch(c *http.Client, req *http.Request) (io.ReadCloser, string, error) {
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, "", fmt.Errorf("messaging: download: %s", resp.Status)
+	}
+	return resp.Body, resp.Header.Get("Content-Type"), nil
+}
+
+// sameOrigin reports whether u has base's scheme and host (port included).
+func sameOrigin(u, base *url.URL) bool {
+	return strings.EqualFold(u.Scheme, base.Scheme) && strings.EqualFold(u.Host, base.Host)
+}
+
+// redactAddr keeps only the last 4 characters of a phone number/handle.
+func redactAddr(s string) string {
+	if len(s) <= 4 {
+		return "****"
+	}
+	return strings.Repeat("*", len(s)-4) + s[len(s)-4:]
+}
@@ -0,0 +1,64 @@
+package messaging
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPProviderDownloadStaysOnProviderHost(t *testing.T) {
+	var leaked bool
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked = leaked || r.Header.Get("Authorization") != ""
+		_, _ = io.WriteString(w, "internal")
+	}))
+	defer other.Close()
+
+	gw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/media/abc":
+			w.Header().Set("Content-Type", "image/jpeg")
+			_, _ = io.WriteString(w, "photo")
+		case "/media/bounce":
+			http.Redirect(w, r, other.URL+"/x", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer gw.Close()
+
+	p := &HTTPProvider{BaseURL: gw.URL, Token: "tok", HTTP: gw.Client()}
+	ctx := context.Background()
+
+	for _, ref := range []string{"abc", gw.URL + "/media/abc"} {
+		body, ctype, err := p.Download(ctx, ref)
+		if err != nil {
+			t.Fatalf("Download(%q): %v", ref, err)
+		}
+		b, _ := io.ReadAll(body)
+		body.Close()
+		if string(b) != "photo" || ctype != "image/jpeg" {
+			t.Fatalf("Download(%q) = %q, %q", ref, b, ctype)
+		}
+	}
+
+	for _, ref := range []string{other.URL + "/x", "bounce", "http://169.254.169.254/latest/meta-data"} {
+		if body, _, err := p.Download(ctx, ref); err == nil {
+			body.Close()
+			t.Errorf("Download(%q) succeeded, want refusal", ref)
+		}
+	}
+	if leaked {
+		t.Fatal("provider token sent to another host")
+	}
+
+	if _, _, err := (LogProvider{}).Download(ctx, other.URL+"/x"); err == nil {
+		t.Fatal("LogProvider fetched an arbitrary URL")
+	}
+}
@@ -0,0 +1,71 @@
+// path: models/agency.go
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Agency is a responsible body (ministry, council, utility) that new reports
+// are routed to. It signs in to its own report list with an API key, of
+// which only the SHA-256 hash is stored.
+type Agency struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name      string             `bson:"name" json:"name"`
+	Code      string             `bson:"code" json:"code"` // short unique handle, e.g. "edsa"
+	Email     string             `bson:"email,omitempty" json:"email,omitempty"`
+	KeyHash   string             `bson:"key_hash" json:"-"`
+	Rules     []RoutingRule      `bson:"rules,omitempty" json:"rules,omitempty"`
+	Active    bool               `bson:"active" json:"active"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// RoutingRule matches a report when every condition it sets holds; within
+// a condition any listed value will do. A report goes to an agency when any
+// of the agency's rules match.
+type RoutingRule struct {
+	Categories []string `bson:"categories,omitempty" json:"categories,omitempty"`
+	Districts  []string `bson:"districts,omitempty" json:"districts,omitempty"`
+	Chiefdoms  []string `bson:"chiefdoms,omitempty" json:"chiefdoms,omitempty"`
+	// Polygon is a ring of [lng, lat] points; the report must lie inside.
+	Polygon [][2]float64 `bson:"polygon,omitempty" json:"polygon,omitempty"`
+	// Keywords are words or phrases, any of which must appear in the note.
+	Keywords []string `bson:"keywords,omitempty" json:"keywords,omitempty"`
+}
+
+// Who made an assignment (Assignment.By).
+const (
+	AssignedByRouting    = "routing"
+	AssignedByModerator  = "moderator"
+	AssignedByEscalation = "escalation"
+)
+
+// Assignment is one entry of a report's assignment history.
+type Assignment struct {
+	From   []primitive.ObjectID `bson:"from,omitempty" json:"from,omitempty"`
+	To     []primitive.ObjectID `bson:"to" json:"to"`
+	By     string               `bson:"by" json:"by"`
+	Reason string               `bson:"reason,omitempty" json:"reason,omitempty"`
+	At     time.Time            `bson:"at" json:"at"`
+}
+
+// AgencyCreatePayload is the body for POST /api/admin/agencies.
+type AgencyCreatePayload struct {
+	Name  string        `json:"name"`
+	Code  string        `json:"code"`
+	Email string        `json:"email"`
+	Rules []RoutingRule `json:"rules"`
+}
+
+// AgencyRulesPayload is the body for POST /api/admin/agencies/:id/rules.
+type AgencyRulesPayload struct {
+	Rules []RoutingRule `json:"rules"`
+}
+
+// AssignPayload is the body for POST /api/admin/reports/:id/assign.
+type AssignPayload struct {
+	AgencyIDs []string `json:"agency_ids"`
+	Reason    string   `json:"reason"`
+}
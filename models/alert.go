@@ -0,0 +1,32 @@
+// path: models/alert.go
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AlertSurge is an unusual rise in reports of one category in one district.
+const AlertSurge = "surge"
+
+// Alert is an anomaly found by the analytics job. While Active it is
+// refreshed on every run that still sees the anomaly; ResolvedAt is set
+// once counts fall back.
+type Alert struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Kind        string             `bson:"kind" json:"kind"`
+	Category    string             `bson:"category" json:"category"`
+	District    string             `bson:"district" json:"district"`
+	WindowStart time.Time          `bson:"window_start" json:"window_start"`
+	WindowEnd   time.Time          `bson:"window_end" json:"window_end"`
+	Count       int                `bson:"count" json:"count"`       // reports in the window
+	Baseline    float64            `bson:"baseline" json:"baseline"` // mean per window over the baseline period
+	StdDev      float64            `bson:"std_dev" json:"std_dev"`
+	Score       float64            `bson:"score" json:"score"` // standard deviations above baseline
+	PeakCount   int                `bson:"peak_count" json:"peak_count"`
+	Active      bool               `bson:"active" json:"active"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+	ResolvedAt  *time.Time         `bson:"resolved_at,omitempty" json:"resolved_at,omitempty"`
+}
@@ -0,0 +1,37 @@
+// path: models/audit.go
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuditEvent is one entry of the append-only audit log. Entries are
+// numbered without gaps (Seq) and each Hash covers the entry and the
+// previous entry's hash, so edits, deletions and reordering are detectable.
+type AuditEvent struct {
+	ID       primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	Seq      int64               `bson:"seq" json:"seq"`
+	Actor    string              `bson:"actor" json:"actor"`
+	Role     string              `bson:"role" json:"role"`
+	IPHash   string              `bson:"ip_hash,omitempty" json:"ip_hash,omitempty"`
+	Action   string              `bson:"action" json:"action"`
+	Target   string              `bson:"target" json:"target"` // "report", "agency", "sla_policy", "webhook"
+	TargetID string              `bson:"target_id,omitempty" json:"target_id,omitempty"`
+	ReportID *primitive.ObjectID `bson:"report_id,omitempty" json:"report_id,omitempty"`
+	Changes  []FieldChange       `bson:"changes,omitempty" json:"changes,omitempty"`
+	At       time.Time           `bson:"at" json:"at"`
+	PrevHash string              `bson:"prev_hash" json:"prev_hash"`
+	Hash     string              `bson:"hash" json:"hash"`
+}
+
+// FieldChange is one top-level field that differs between the before and
+// after versions of the target. Values are JSON-encoded; an empty side
+// means the field was absent. Redacted changes name the field only.
+type FieldChange struct {
+	Field    string `bson:"field" json:"field"`
+	Before   string `bson:"before,omitempty" json:"before,omitempty"`
+	After    string `bson:"after,omitempty" json:"after,omitempty"`
+	Redacted bool   `bson:"redacted,omitempty" json:"redacted,omitempty"`
+}
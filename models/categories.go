@@ -0,0 +1,85 @@
+// path: models/categories.go
+package models
+
+import "strings"
+
+// Category is one entry of the report taxonomy. Code is what is stored in
+// Report.Category; Keywords are the words accepted from SMS/USSD/chat users.
+type Category struct {
+	Code        string   `json:"code"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Group       string   `json:"group"`
+	Keywords    []string `json:"keywords,omitempty"`
+}
+
+// Categories is the category taxonomy, in menu order.
+var Categories = []Category{
+	{Code: "road", Name: "Roads & Bridges", Group: "Infrastructure",
+		Description: "Potholes, damaged roads, broken bridges and culverts",
+		Keywords:    []string{"road", "roads", "pothole", "bridge", "culvert"}},
+	{Code: "water", Name: "Water Supply", Group: "Utilities",
+		Description: "Broken boreholes, taps and wells, no water supply",
+		Keywords:    []string{"water", "borehole", "tap", "well", "pump"}},
+	{Code: "sanitation", Name: "Sanitation & Waste", Group: "Environment",
+		Description: "Uncollected rubbish, blocked drains, broken public toilets",
+		Keywords:    []string{"sanitation", "waste", "rubbish", "garbage", "dirt", "drain", "toilet"}},
+	{Code: "electricity", Name: "Electricity & Streetlights", Group: "Utilities",
+		Description: "Power outages, broken poles and streetlights",
+		Keywords:    []string{"electricity", "power", "light", "lights", "streetlight", "edsa"}},
+	{Code: "health", Name: "Health Facilities", Group: "Services",
+		Description: "Problems at clinics and hospitals, missing drugs or staff",
+		Keywords:    []string{"health", "clinic", "hospital", "phu"}},
+	{Code: "education", Name: "Schools & Education", Group: "Services",
+		Description: "School buildings, teachers, learning materials",
+		Keywords:    []string{"education", "school", "schools", "teacher"}},
+	{Code: "security", Name: "Safety & Security", Group: "Community",
+		Description: "Crime, unsafe areas and public safety concerns",
+		Keywords:    []string{"security", "safety", "police", "crime"}},
+	{Code: "environment", Name: "Environment & Flooding", Group: "Environment",
+		Description: "Flooding, erosion, illegal mining and deforestation",
+		Keywords:    []string{"environment", "flood", "flooding", "erosion", "mining"}},
+	{Code: "other", Name: "Other", Group: "Community",
+		Description: "Anything that doesn't fit another category",
+		Keywords:    []string{"other"}},
+}
+
+// CategoryByCode returns the taxonomy entry for code.
+func CategoryByCode(code string) (Category, bool) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	for _, c := range Categories {
+		if c.Code == code {
+			return c, true
+		}
+	}
+	return Category{}, false
+}
+
+// CategoryByKeyword matches a free-text word (e.g. "ROAD", "borehole") to
+// a category, case-insensitively.
+func CategoryByKeyword(word string) (Category, bool) {
+	word = strings.ToLower(strings.TrimSpace(word))
+	if word == "" {
+		return Category{}, false
+	}
+	for _, c := range Categories {
+		if c.Code == word {
+			return c, true
+		}
+		for _, k := range c.Keywords {
+			if k == word {
+				return c, true
+			}
+		}
+	}
+	return Category{}, false
+}
+
+// CategoryName returns the display name of code, or code itself for
+// categories that predate the taxonomy.
+func CategoryName(code string) string {
+	if c, ok := CategoryByCode(code); ok {
+		return c.Name
+	}
+	return code
+}
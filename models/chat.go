@@ -0,0 +1,31 @@
+// path: models/chat.go
+package models
+
+import "time"
+
+// Chat intake steps, in order.
+const (
+	ChatStepCategory    = "category"
+	ChatStepLocation    = "location"
+	ChatStepDescription = "description"
+	ChatStepConfirm     = "confirm"
+)
+
+// ChatSession is the in-progress report of one chat sender. The _id is a
+// hash of the sender address so phone numbers are never stored.
+type ChatSession struct {
+	ID            string    `bson:"_id" json:"id"`
+	Step          string    `bson:"step" json:"step"`
+	Category      string    `bson:"category,omitempty" json:"category,omitempty"`
+	HasPin        bool      `bson:"has_pin,omitempty" json:"has_pin,omitempty"`
+	Lat           float64   `bson:"lat,omitempty" json:"lat,omitempty"`
+	Lng           float64   `bson:"lng,omitempty" json:"lng,omitempty"`
+	AreaLabel     string    `bson:"area_label,omitempty" json:"area_label,omitempty"`
+	District      string    `bson:"district,omitempty" json:"district,omitempty"`
+	Chiefdom      string    `bson:"chiefdom,omitempty" json:"chiefdom,omitempty"`
+	Note          string    `bson:"note,omitempty" json:"note,omitempty"`
+	VoiceURL      string    `bson:"voice_url,omitempty" json:"voice_url,omitempty"`
+	PhotoURLs     []string  `bson:"photo_urls,omitempty" json:"photo_urls,omitempty"`
+	LastMessageID string    `bson:"last_message_id,omitempty" json:"-"`
+	UpdatedAt     time.Time `bson:"updated_at" json:"updated_at"`
+}
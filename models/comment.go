@@ -0,0 +1,33 @@
+// path: models/comment.go
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Comment is a moderator/agency note attached to a report. Only public
+// comments are shown to reporters and the public.
+type Comment struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ReportID  primitive.ObjectID `bson:"report_id" json:"report_id"`
+	Body      string             `bson:"body" json:"body"`
+	Author    string             `bson:"author,omitempty" json:"author,omitempty"`
+	Public    bool               `bson:"public" json:"public"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// CommentCreatePayload is the body for POST /api/admin/reports/:id/comments.
+type CommentCreatePayload struct {
+	Body   string `json:"body"`
+	Author string `json:"author"`
+	Public bool   `json:"public"`
+}
+
+// StatusUpdatePayload is the body for POST /api/admin/reports/:id/status.
+type StatusUpdatePayload struct {
+	Status    string   `json:"status"`
+	Note      string   `json:"note"`
+	PhotoURLs []string `json:"photo_urls"` // uploaded media paths or http(s) URLs
+}
@@ -0,0 +1,34 @@
+// path: models/media.go
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MediaGCRun is the record of one pass of the media garbage collector.
+type MediaGCRun struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Scanned          int                `bson:"scanned" json:"scanned"` // files in the store
+	ScannedBytes     int64              `bson:"scanned_bytes" json:"scanned_bytes"`
+	Referenced       int                `bson:"referenced" json:"referenced"` // distinct media URLs in use
+	Quarantined      int                `bson:"quarantined" json:"quarantined"`
+	QuarantinedBytes int64              `bson:"quarantined_bytes" json:"quarantined_bytes"`
+	Restored         int                `bson:"restored" json:"restored"` // referenced again while quarantined
+	Deleted          int                `bson:"deleted" json:"deleted"`
+	ReclaimedBytes   int64              `bson:"reclaimed_bytes" json:"reclaimed_bytes"`
+	Failures         int                `bson:"failures,omitempty" json:"failures,omitempty"` // file operations that failed
+	StartedAt        time.Time          `bson:"started_at" json:"started_at"`
+	FinishedAt       time.Time          `bson:"finished_at" json:"finished_at"`
+}
+
+// MediaGCStats summarises the collector for the admin API.
+type MediaGCStats struct {
+	OK                  bool         `json:"ok"`
+	PendingFiles        int          `json:"pending_files"` // in quarantine now
+	PendingBytes        int64        `json:"pending_bytes"`
+	TotalDeleted        int64        `json:"total_deleted"`
+	TotalReclaimedBytes int64        `json:"total_reclaimed_bytes"`
+	Runs                []MediaGCRun `json:"runs"` // newest first
+}
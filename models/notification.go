@@ -0,0 +1,45 @@
+// path: models/notification.go
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Notification states.
+const (
+	NotificationPending    = "pending"
+	NotificationSending    = "sending"
+	NotificationSent       = "sent"
+	NotificationFailed     = "failed"
+	NotificationSuppressed = "suppressed" // recipient opted out after it was queued
+)
+
+// Notification is one rendered email in the outbox. It is rendered when
+// queued, so a retry sends exactly what was first attempted.
+type Notification struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Event         string             `bson:"event" json:"event"`
+	EventID       string             `bson:"event_id,omitempty" json:"event_id,omitempty"`
+	ReportID      string             `bson:"report_id,omitempty" json:"report_id,omitempty"`
+	To            string             `bson:"to" json:"to"`
+	Language      string             `bson:"language" json:"language"`
+	Subject       string             `bson:"subject" json:"subject"`
+	Text          string             `bson:"text" json:"-"`
+	HTML          string             `bson:"html,omitempty" json:"-"`
+	Status        string             `bson:"status" json:"status"`
+	AttemptCount  int                `bson:"attempt_count" json:"attempt_count"`
+	LastError     string             `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	NextAttemptAt time.Time          `bson:"next_attempt_at" json:"next_attempt_at"`
+	LeaseUntil    time.Time          `bson:"lease_until,omitempty" json:"-"`
+	SentAt        *time.Time         `bson:"sent_at,omitempty" json:"sent_at,omitempty"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt     time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// EmailOptOut is an address that asked not to be emailed.
+type EmailOptOut struct {
+	Email     string    `bson:"email" json:"email"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,122 @@
+// path: models/places.go
+package models
+
+import "strings"
+
+// Chiefdom is an administrative area within a district.
+type Chiefdom struct {
+	Name string  `json:"name"`
+	Lat  float64 `json:"lat"`
+	Lng  float64 `json:"lng"`
+}
+
+// District is a Sierra Leone district with a few of its chiefdoms.
+// Centroids are approximate: they place reports that arrive without GPS
+// (SMS, USSD) at district/chiefdom precision, nothing finer.
+type District struct {
+	Name      string     `json:"name"`
+	Region    string     `json:"region"`
+	Lat       float64    `json:"lat"`
+	Lng       float64    `json:"lng"`
+	Chiefdoms []Chiefdom `json:"chiefdoms,omitempty"`
+}
+
+// Districts is the gazetteer used by channels without GPS, in menu order.
+var Districts = []District{
+	{Name: "Bo", Region: "Southern", Lat: 7.96, Lng: -11.74, Chiefdoms: []Chiefdom{
+		{Name: "Kakua", Lat: 7.96, Lng: -11.74},
+		{Name: "Tikonko", Lat: 7.87, Lng: -11.81},
+		{Name: "Bumpe Ngao", Lat: 7.89, Lng: -11.90},
+		{Name: "Baoma", Lat: 7.99, Lng: -11.63},
+	}},
+	{Name: "Bombali", Region: "Northern", Lat: 9.25, Lng: -12.16, Chiefdoms: []Chiefdom{
+		{Name: "Bombali Sebora", Lat: 8.89, Lng: -12.05},
+		{Name: "Makari", Lat: 8.97, Lng: -12.13},
+		{Name: "Biriwa", Lat: 9.30, Lng: -11.95},
+	}},
+	{Name: "Bonthe", Region: "Southern", Lat: 7.53, Lng: -12.50, Chiefdoms: []Chiefdom{
+		{Name: "Jong", Lat: 7.61, Lng: -12.18},
+		{Name: "Sittia", Lat: 7.60, Lng: -12.55},
+	}},
+	{Name: "Falaba", Region: "Northern", Lat: 9.85, Lng: -11.32, Chiefdoms: []Chiefdom{
+		{Name: "Mongo", Lat: 9.82, Lng: -11.25},
+		{Name: "Sulima", Lat: 9.90, Lng: -11.40},
+	}},
+	{Name: "Kailahun", Region: "Eastern", Lat: 8.08, Lng: -10.74, Chiefdoms: []Chiefdom{
+		{Name: "Luawa", Lat: 8.28, Lng: -10.57},
+		{Name: "Jawie", Lat: 8.05, Lng: -10.78},
+		{Name: "Mandu", Lat: 8.10, Lng: -10.92},
+	}},
+	{Name: "Kambia", Region: "North West", Lat: 9.13, Lng: -12.92, Chiefdoms: []Chiefdom{
+		{Name: "Magbema", Lat: 9.12, Lng: -12.92},
+		{Name: "Samu", Lat: 9.05, Lng: -13.10},
+	}},
+	{Name: "Karene", Region: "North West", Lat: 9.30, Lng: -12.35, Chiefdoms: []Chiefdom{
+		{Name: "Sella Limba", Lat: 9.50, Lng: -12.24},
+		{Name: "Buya", Lat: 9.05, Lng: -12.45},
+	}},
+	{Name: "Kenema", Region: "Eastern", Lat: 7.88, Lng: -11.19, Chiefdoms: []Chiefdom{
+		{Name: "Nongowa", Lat: 7.88, Lng: -11.19},
+		{Name: "Lower Bambara", Lat: 8.00, Lng: -11.08},
+		{Name: "Dama", Lat: 7.70, Lng: -11.30},
+		{Name: "Small Bo", Lat: 8.05, Lng: -11.35},
+	}},
+	{Name: "Koinadugu", Region: "Northern", Lat: 9.52, Lng: -11.36, Chiefdoms: []Chiefdom{
+		{Name: "Sengbe", Lat: 9.59, Lng: -11.55},
+		{Name: "Wara Wara Yagala", Lat: 9.55, Lng: -11.60},
+	}},
+	{Name: "Kono", Region: "Eastern", Lat: 8.64, Lng: -10.97, Chiefdoms: []Chiefdom{
+		{Name: "Gbense", Lat: 8.65, Lng: -10.98},
+		{Name: "Tankoro", Lat: 8.67, Lng: -10.94},
+		{Name: "Nimikoro", Lat: 8.55, Lng: -11.05},
+	}},
+	{Name: "Moyamba", Region: "Southern", Lat: 8.16, Lng: -12.43, Chiefdoms: []Chiefdom{
+		{Name: "Kaiyamba", Lat: 8.16, Lng: -12.43},
+		{Name: "Bumpeh", Lat: 7.95, Lng: -12.55},
+	}},
+	{Name: "Port Loko", Region: "North West", Lat: 8.77, Lng: -12.79, Chiefdoms: []Chiefdom{
+		{Name: "Maforki", Lat: 8.77, Lng: -12.79},
+		{Name: "Kaffu Bullom", Lat: 8.62, Lng: -13.15},
+		{Name: "Koya", Lat: 8.55, Lng: -12.95},
+	}},
+	{Name: "Pujehun", Region: "Southern", Lat: 7.35, Lng: -11.72, Chiefdoms: []Chiefdom{
+		{Name: "Kpaka", Lat: 7.40, Lng: -11.75},
+		{Name: "Makpele", Lat: 7.25, Lng: -11.40},
+	}},
+	{Name: "Tonkolili", Region: "Northern", Lat: 8.73, Lng: -11.80, Chiefdoms: []Chiefdom{
+		{Name: "Kholifa Rowalla", Lat: 8.92, Lng: -11.95},
+		{Name: "Yoni", Lat: 8.60, Lng: -11.95},
+	}},
+	{Name: "Western Area Rural", Region: "Western", Lat: 8.35, Lng: -13.10, Chiefdoms: []Chiefdom{
+		{Name: "Waterloo", Lat: 8.34, Lng: -13.07},
+		{Name: "York", Lat: 8.30, Lng: -13.20},
+		{Name: "Mountain", Lat: 8.42, Lng: -13.18},
+	}},
+	{Name: "Western Area Urban", Region: "Western", Lat: 8.47, Lng: -13.23, Chiefdoms: []Chiefdom{
+		{Name: "East End", Lat: 8.48, Lng: -13.20},
+		{Name: "Central", Lat: 8.48, Lng: -13.23},
+		{Name: "West End", Lat: 8.47, Lng: -13.27},
+	}},
+}
+
+// DistrictByName finds a district case-insensitively.
+func DistrictByName(name string) (District, bool) {
+	name = strings.TrimSpace(name)
+	for _, d := range Districts {
+		if strings.EqualFold(d.Name, name) {
+			return d, true
+		}
+	}
+	return District{}, false
+}
+
+// ChiefdomByName finds a chiefdom of d case-insensitively.
+func (d District) ChiefdomByName(name string) (Chiefdom, bool) {
+	name = strings.TrimSpace(name)
+	for _, c := range d.Chiefdoms {
+		if strings.EqualFold(c.Name, name) {
+			return c, true
+		}
+	}
+	return Chiefdom{}, false
+}
@@ -7,6 +7,69 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Report lifecycle states. Reports stored before statuses existed have an
+// empty status, which is treated as StatusOpen.
+const (
+	StatusOpen         = "open"
+	StatusAcknowledged = "acknowledged"
+	StatusInProgress   = "in_progress"
+	StatusResolved     = "resolved"
+	StatusRejected     = "rejected"
+)
+
+// ValidStatus reports whether s is a known report status.
+func ValidStatus(s string) bool {
+	switch s {
+	case StatusOpen, StatusAcknowledged, StatusInProgress, StatusResolved, StatusRejected:
+		return true
+	}
+	return false
+}
+
+// Intake channels a report can arrive through (Report.Source).
+const (
+	SourceApp     = "app"
+	SourceOpen311 = "open311"
+	SourceSMS     = "sms"
+	SourceUSSD    = "ussd"
+	SourceChat    = "chat"
+)
+
+// StatusChange is one entry of a report's status history.
+type StatusChange struct {
+	From string `bson:"from" json:"from"`
+	To   string `bson:"to" json:"to"`
+	Note string `bson:"note,omitempty" json:"note,omitempty"`
+	// PhotoURLs document the change, e.g. the fixed pump on resolution.
+	PhotoURLs []string  `bson:"photo_urls,omitempty" json:"photo_urls,omitempty"`
+	At        time.Time `bson:"at" json:"at"`
+}
+
+// Revision kinds (Revision.Kind).
+const (
+	RevisionEdit     = "edit"
+	RevisionWithdraw = "withdraw"
+)
+
+// Revision is one reporter change to a report. Before holds the edited
+// fields as they were, so every earlier version can be reconstructed.
+type Revision struct {
+	Kind   string         `bson:"kind" json:"kind"`
+	Fields []string       `bson:"fields,omitempty" json:"fields,omitempty"`
+	Before ReportSnapshot `bson:"before" json:"before"`
+	By     string         `bson:"by" json:"by"` // "token" or "account"
+	At     time.Time      `bson:"at" json:"at"`
+}
+
+// ReportSnapshot is the reporter-editable part of a report.
+type ReportSnapshot struct {
+	Category  string   `bson:"category" json:"category"`
+	Note      string   `bson:"note" json:"note"`
+	AreaLabel string   `bson:"area_label" json:"area_label"`
+	VoiceURL  string   `bson:"voice_url,omitempty" json:"voice_url,omitempty"`
+	PhotoURLs []string `bson:"photo_urls,omitempty" json:"photo_urls,omitempty"`
+}
+
 type Report struct {
 	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Category       string             `bson:"category" json:"category"`
@@ -17,6 +80,16 @@ type Report struct {
 	AccuracyM      *int               `bson:"accuracy_m,omitempty" json:"accuracy_m,omitempty"`
 	PrivacyRadiusM *int               `bson:"privacy_radius_m,omitempty" json:"privacy_radius_m,omitempty"`
 	Anonymous      bool               `bson:"anonymous" json:"anonymous"`
+	// CoarsenedM is the grid (metres) Lat/Lng were snapped to by a
+	// retention rule; 0 means they are as reported.
+	CoarsenedM int `bson:"coarsened_m,omitempty" json:"coarsened_m,omitempty"`
+
+	// Optional reporter contact and account, for status updates and
+	// "my reports"; never published. ReporterID is only set when the
+	// report isn't anonymous.
+	ContactEmail string              `bson:"contact_email,omitempty" json:"-"`
+	Language     string              `bson:"language,omitempty" json:"language,omitempty"` // e.g. "en", "kri"
+	ReporterID   *primitive.ObjectID `bson:"reporter_id,omitempty" json:"-"`
 
 	// Media (voice is optional, we store all media in PhotoURLs to keep it simple)
 	VoiceURL  string   `bson:"voice_url,omitempty" json:"voice_url,omitempty"`
@@ -30,5 +103,54 @@ type Report struct {
 	Section   string `bson:"section,omitempty" json:"section,omitempty"`
 	GeoMethod string `bson:"geo_method,omitempty" json:"geo_method,omitempty"`
 
-	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	// Duplicate handling: candidates flagged at submission time, and the
+	// canonical report a moderator merged this one into (if any).
+	PossibleDuplicates []primitive.ObjectID `bson:"possible_duplicates,omitempty" json:"possible_duplicates,omitempty"`
+	DuplicateOf        *primitive.ObjectID  `bson:"duplicate_of,omitempty" json:"duplicate_of,omitempty"`
+	MergedIDs          []primitive.ObjectID `bson:"merged_ids,omitempty" json:"merged_ids,omitempty"`
+	Confirmations      int                  `bson:"confirmations,omitempty" json:"confirmations,omitempty"`
+	MergedAt           *time.Time           `bson:"merged_at,omitempty" json:"merged_at,omitempty"`
+
+	Source        string         `bson:"source,omitempty" json:"source,omitempty"`
+	Status        string         `bson:"status,omitempty" json:"status,omitempty"`
+	StatusHistory []StatusChange `bson:"status_history,omitempty" json:"status_history,omitempty"`
+
+	// Agencies responsible for the report, set by the routing rules at
+	// creation and changed by moderators; AssignmentHistory records each change.
+	Agencies          []primitive.ObjectID `bson:"agencies,omitempty" json:"agencies,omitempty"`
+	AssignmentHistory []Assignment         `bson:"assignment_history,omitempty" json:"assignment_history,omitempty"`
+	SLA               *SLAState            `bson:"sla,omitempty" json:"sla,omitempty"`
+
+	// Reporter edits within the grace window, oldest first, and when the
+	// reporter withdrew the report (withdrawn reports are hidden by default).
+	Revisions   []Revision `bson:"revisions,omitempty" json:"revisions,omitempty"`
+	WithdrawnAt *time.Time `bson:"withdrawn_at,omitempty" json:"withdrawn_at,omitempty"`
+
+	// CapturedAt is when the reporter observed the problem (photo/recording
+	// time), if the client sent it; CreatedAt is when we received it.
+	CapturedAt *time.Time `bson:"captured_at,omitempty" json:"captured_at,omitempty"`
+	CreatedAt  time.Time  `bson:"created_at" json:"created_at"`
+	UpdatedAt  time.Time  `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+
+	// Version guards read-modify-write updates (optimistic concurrency).
+	Version int64 `bson:"version,omitempty" json:"-"`
+}
+
+// Snapshot returns the reporter-editable fields of r.
+func (r Report) Snapshot() ReportSnapshot {
+	return ReportSnapshot{
+		Category:  r.Category,
+		Note:      r.Note,
+		AreaLabel: r.AreaLabel,
+		VoiceURL:  r.VoiceURL,
+		PhotoURLs: append([]string(nil), r.PhotoURLs...),
+	}
+}
+
+// CurrentStatus returns the report status, defaulting legacy reports to open.
+func (r Report) CurrentStatus() string {
+	if r.Status == "" {
+		return StatusOpen
+	}
+	return r.Status
 }
@@ -0,0 +1,49 @@
+// path: models/reporter.go
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Reporter is an optional account identified by a verified phone number.
+// Non-anonymous reports submitted while signed in are linked to it.
+type Reporter struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Phone      string             `bson:"phone" json:"phone"` // E.164
+	VerifiedAt time.Time          `bson:"verified_at" json:"verified_at"`
+	LastSeenAt time.Time          `bson:"last_seen_at" json:"last_seen_at"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// OTPChallenge is a pending phone verification. Only a hash of the code
+// is stored.
+type OTPChallenge struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Phone     string             `bson:"phone" json:"phone"`
+	CodeHash  string             `bson:"code_hash" json:"-"`
+	Attempts  int                `bson:"attempts" json:"attempts"`
+	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// ReporterSession is a signed-in device. Only a hash of the token is stored.
+type ReporterSession struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ReporterID primitive.ObjectID `bson:"reporter_id" json:"reporter_id"`
+	TokenHash  string             `bson:"token_hash" json:"-"`
+	ExpiresAt  time.Time          `bson:"expires_at" json:"expires_at"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// OTPRequestPayload is the body for POST /api/auth/otp.
+type OTPRequestPayload struct {
+	Phone string `json:"phone"`
+}
+
+// OTPVerifyPayload is the body for POST /api/auth/verify.
+type OTPVerifyPayload struct {
+	Phone string `json:"phone"`
+	Code  string `json:"code"`
+}
@@ -1,6 +1,8 @@
 // path: models/responses.go
 package models
 
+import "time"
+
 // LocateRequest is the request body for POST /api/locate.
 type LocateRequest struct {
 	Lat float64 `json:"lat"`
@@ -26,7 +28,36 @@ type ReportCreatePayload struct {
 	Anonymous      bool    `json:"anonymous"`
 }
 type CreateReportResp struct {
-	OK    bool   `json:"ok"`
-	ID    string `json:"id,omitempty"`
-	Error string `json:"error,omitempty"`
+	OK                 bool                 `json:"ok"`
+	ID                 string               `json:"id,omitempty"`
+	PossibleDuplicates []DuplicateCandidate `json:"possible_duplicates,omitempty"`
+	// TrackingToken lets the reporter follow the report at GET /api/track/:token.
+	// It is shown only once.
+	TrackingToken     string     `json:"tracking_token,omitempty"`
+	TrackingExpiresAt *time.Time `json:"tracking_expires_at,omitempty"`
+	Error             string     `json:"error,omitempty"`
+}
+
+// DuplicateCandidate is a recent nearby report that looks like the same issue.
+type DuplicateCandidate struct {
+	ID         string  `json:"id"`
+	Category   string  `json:"category"`
+	Note       string  `json:"note"`
+	AreaLabel  string  `json:"area_label"`
+	DistanceM  int     `json:"distance_m"`
+	Similarity float64 `json:"similarity"`
+	Score      float64 `json:"score"`
+	CreatedAt  string  `json:"created_at"`
+}
+
+// MergeReportsRequest is the body for POST /api/admin/reports/:id/merge.
+type MergeReportsRequest struct {
+	DuplicateIDs []string `json:"duplicate_ids"`
+}
+
+// MergeReportsResp is the response body for a duplicate merge.
+type MergeReportsResp struct {
+	OK     bool   `json:"ok"`
+	ID     string `json:"id"`
+	Merged int64  `json:"merged"`
 }
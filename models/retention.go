@@ -0,0 +1,73 @@
+// path: models/retention.go
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Retention actions (RetentionRule.Action).
+const (
+	RetentionDeleteVoice     = "delete_voice"     // remove the voice note
+	RetentionDeletePhotos    = "delete_photos"    // remove the reporter's photos
+	RetentionCoarsenLocation = "coarsen_location" // snap coordinates to a RadiusM grid
+	RetentionDeleteReport    = "delete_report"    // hard-delete the report and its media
+)
+
+// ValidRetentionAction reports whether a is a known retention action.
+func ValidRetentionAction(a string) bool {
+	switch a {
+	case RetentionDeleteVoice, RetentionDeletePhotos, RetentionCoarsenLocation, RetentionDeleteReport:
+		return true
+	}
+	return false
+}
+
+// RetentionRule applies Action to reports older than AfterDays (counted
+// from CreatedAt). Statuses and Categories narrow the reports it covers;
+// empty means all.
+type RetentionRule struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name       string             `bson:"name" json:"name"`
+	Action     string             `bson:"action" json:"action"`
+	AfterDays  int                `bson:"after_days" json:"after_days"`
+	Statuses   []string           `bson:"statuses,omitempty" json:"statuses,omitempty"`
+	Categories []string           `bson:"categories,omitempty" json:"categories,omitempty"`
+	RadiusM    int                `bson:"radius_m,omitempty" json:"radius_m,omitempty"` // coarsen_location only
+	Active     bool               `bson:"active" json:"active"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// RetentionRulePayload is the body for POST /api/admin/retention/rules.
+type RetentionRulePayload struct {
+	Name       string   `json:"name"`
+	Action     string   `json:"action"`
+	AfterDays  int      `json:"after_days"`
+	Statuses   []string `json:"statuses"`
+	Categories []string `json:"categories"`
+	RadiusM    int      `json:"radius_m"`
+}
+
+// RetentionRun is the record of one pass of the retention job. A dry run
+// lists what would have been done without changing anything.
+type RetentionRun struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	DryRun     bool               `bson:"dry_run" json:"dry_run"`
+	Rules      []RetentionResult  `bson:"rules" json:"rules"`
+	StartedAt  time.Time          `bson:"started_at" json:"started_at"`
+	FinishedAt time.Time          `bson:"finished_at" json:"finished_at"`
+	Error      string             `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+// RetentionResult is what one rule did (or would do) in a run. ReportIDs
+// is capped; Reports is the full count.
+type RetentionResult struct {
+	RuleID    primitive.ObjectID   `bson:"rule_id" json:"rule_id"`
+	Name      string               `bson:"name" json:"name"`
+	Action    string               `bson:"action" json:"action"`
+	Reports   int                  `bson:"reports" json:"reports"`
+	Files     int                  `bson:"files" json:"files"`
+	ReportIDs []primitive.ObjectID `bson:"report_ids,omitempty" json:"report_ids,omitempty"`
+	Error     string               `bson:"error,omitempty" json:"error,omitempty"`
+}
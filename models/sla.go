@@ -0,0 +1,80 @@
+// path: models/sla.go
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SLAPolicy sets how quickly assigned reports must be acknowledged and
+// resolved. Category and AgencyID narrow the reports it covers; the most
+// specific active policy wins. Zero minutes means that stage isn't timed.
+type SLAPolicy struct {
+	ID             primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	Category       string              `bson:"category,omitempty" json:"category,omitempty"`
+	AgencyID       *primitive.ObjectID `bson:"agency_id,omitempty" json:"agency_id,omitempty"`
+	AckMinutes     int                 `bson:"ack_minutes" json:"ack_minutes"`
+	ResolveMinutes int                 `bson:"resolve_minutes" json:"resolve_minutes"`
+	// SupervisorID is the agency added to a report when it breaches.
+	SupervisorID *primitive.ObjectID `bson:"supervisor_id,omitempty" json:"supervisor_id,omitempty"`
+	Active       bool                `bson:"active" json:"active"`
+	CreatedAt    time.Time           `bson:"created_at" json:"created_at"`
+}
+
+// SLA stages (SLAState.Breaches, event data).
+const (
+	SLAStageAck     = "ack"
+	SLAStageResolve = "resolve"
+)
+
+// SLAState is the SLA clock of an assigned report. Timers start at the
+// last routing or moderator assignment; escalations don't restart them.
+type SLAState struct {
+	PolicyID     primitive.ObjectID `bson:"policy_id" json:"policy_id"`
+	StartedAt    time.Time          `bson:"started_at" json:"started_at"`
+	AckDueAt     *time.Time         `bson:"ack_due_at,omitempty" json:"ack_due_at,omitempty"`
+	ResolveDueAt *time.Time         `bson:"resolve_due_at,omitempty" json:"resolve_due_at,omitempty"`
+	Breaches     []SLABreach        `bson:"breaches,omitempty" json:"breaches,omitempty"`
+}
+
+// SLABreach records a missed deadline and who it was escalated to.
+type SLABreach struct {
+	Stage       string              `bson:"stage" json:"stage"`
+	DueAt       time.Time           `bson:"due_at" json:"due_at"`
+	At          time.Time           `bson:"at" json:"at"`
+	EscalatedTo *primitive.ObjectID `bson:"escalated_to,omitempty" json:"escalated_to,omitempty"`
+}
+
+// Breached reports whether stage has already been recorded as missed.
+func (s *SLAState) Breached(stage string) bool {
+	for _, b := range s.Breaches {
+		if b.Stage == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// Overdue reports whether r is past an SLA deadline it hasn't met: still
+// open after AckDueAt, or not closed after ResolveDueAt.
+func (r Report) Overdue(now time.Time) bool {
+	if r.SLA == nil {
+		return false
+	}
+	st := r.CurrentStatus()
+	if r.SLA.AckDueAt != nil && st == StatusOpen && !now.Before(*r.SLA.AckDueAt) {
+		return true
+	}
+	closed := st == StatusResolved || st == StatusRejected
+	return r.SLA.ResolveDueAt != nil && !closed && !now.Before(*r.SLA.ResolveDueAt)
+}
+
+// SLAPolicyPayload is the body for POST /api/admin/sla.
+type SLAPolicyPayload struct {
+	Category       string `json:"category"`
+	AgencyID       string `json:"agency_id"`
+	AckMinutes     int    `json:"ack_minutes"`
+	ResolveMinutes int    `json:"resolve_minutes"`
+	SupervisorID   string `json:"supervisor_id"`
+}
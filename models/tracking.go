@@ -0,0 +1,24 @@
+// path: models/tracking.go
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TrackingToken lets whoever holds the secret follow one report. Only the
+// SHA-256 hash of the secret is stored.
+type TrackingToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ReportID  primitive.ObjectID `bson:"report_id" json:"report_id"`
+	Hash      string             `bson:"hash" json:"-"`
+	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`
+	RevokedAt *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// Live reports whether the token still grants access at now.
+func (t TrackingToken) Live(now time.Time) bool {
+	return t.RevokedAt == nil && now.Before(t.ExpiresAt)
+}
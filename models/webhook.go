@@ -0,0 +1,72 @@
+// path: models/webhook.go
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WebhookSubscription is a partner endpoint that receives report events.
+// Empty Events/Categories/Districts mean "all".
+type WebhookSubscription struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	URL        string             `bson:"url" json:"url"`
+	Secret     string             `bson:"secret" json:"secret,omitempty"`
+	Events     []string           `bson:"events,omitempty" json:"events,omitempty"`
+	Categories []string           `bson:"categories,omitempty" json:"categories,omitempty"`
+	Districts  []string           `bson:"districts,omitempty" json:"districts,omitempty"`
+	Active     bool               `bson:"active" json:"active"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// Webhook delivery states.
+const (
+	DeliveryPending   = "pending"
+	DeliverySending   = "sending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery is one event queued for one subscription, with its log of
+// attempts. Payload holds the exact bytes that are signed and sent.
+type WebhookDelivery struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SubscriptionID primitive.ObjectID `bson:"subscription_id" json:"subscription_id"`
+	EventID        string             `bson:"event_id" json:"event_id"`
+	Event          string             `bson:"event" json:"event"`
+	ReportID       string             `bson:"report_id,omitempty" json:"report_id,omitempty"`
+	Payload        string             `bson:"payload" json:"payload"`
+	Status         string             `bson:"status" json:"status"`
+	AttemptCount   int                `bson:"attempt_count" json:"attempt_count"`
+	Attempts       []DeliveryAttempt  `bson:"attempts,omitempty" json:"attempts,omitempty"`
+	NextAttemptAt  time.Time          `bson:"next_attempt_at" json:"next_attempt_at"`
+	LeaseUntil     time.Time          `bson:"lease_until,omitempty" json:"-"`
+	DeliveredAt    *time.Time         `bson:"delivered_at,omitempty" json:"delivered_at,omitempty"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt      time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// DeliveryAttempt records the outcome of one HTTP attempt.
+type DeliveryAttempt struct {
+	At         time.Time `bson:"at" json:"at"`
+	StatusCode int       `bson:"status_code,omitempty" json:"status_code,omitempty"`
+	Error      string    `bson:"error,omitempty" json:"error,omitempty"`
+	DurationMs int64     `bson:"duration_ms" json:"duration_ms"`
+}
+
+// WebhookCreatePayload is the body for POST /api/admin/webhooks.
+type WebhookCreatePayload struct {
+	URL        string   `json:"url"`
+	Secret     string   `json:"secret"`
+	Events     []string `json:"events"`
+	Categories []string `json:"categories"`
+	Districts  []string `json:"districts"`
+}
+
+// WebhookReplayPayload is the body for POST /api/admin/webhooks/deliveries/replay.
+// With no IDs, every failed delivery (optionally of one subscription) is replayed.
+type WebhookReplayPayload struct {
+	SubscriptionID string   `json:"subscription_id"`
+	DeliveryIDs    []string `json:"delivery_ids"`
+}
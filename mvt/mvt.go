@@ -0,0 +1,162 @@
+// path: mvt/mvt.go
+// Package mvt writes Mapbox Vector Tiles (spec v2.1). It only supports
+// point features, which is all the report map needs, and encodes the
+// protobuf by hand to avoid a code-generation dependency.
+package mvt
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+)
+
+// DefaultExtent is the tile coordinate range used by most renderers.
+const DefaultExtent = 4096
+
+// Layer collects point features sharing one key/value table.
+type Layer struct {
+	Name   string
+	Extent uint32
+
+	features [][]byte
+	keys     []string
+	keyIdx   map[string]uint32
+	values   [][]byte
+	valIdx   map[string]uint32
+}
+
+// NewLayer returns an empty layer.
+func NewLayer(name string, extent uint32) *Layer {
+	return &Layer{Name: name, Extent: extent, keyIdx: map[string]uint32{}, valIdx: map[string]uint32{}}
+}
+
+// Len is the number of features added.
+func (l *Layer) Len() int { return len(l.features) }
+
+// AddPoint adds a point at tile coordinates x, y (0..Extent, y down).
+// Property values may be string, bool, int, int64, float64; others are
+// skipped. id 0 means "no id".
+func (l *Layer) AddPoint(id uint64, x, y int, props map[string]any) {
+	names := make([]string, 0, len(props))
+	for k := range props {
+		names = append(names, k)
+	}
+	// why: sorted tags keep the encoding (and the ETag) deterministic
+	sort.Strings(names)
+
+	var tags []uint32
+	for _, k := range names {
+		v, ok := encodeValue(props[k])
+		if !ok {
+			continue
+		}
+		tags = append(tags, l.key(k), l.value(v))
+	}
+
+	var f []byte
+	if id != 0 {
+		f = appendVarintField(f, 1, id)
+	}
+	if len(tags) > 0 {
+		var packed []byte
+		for _, t := range tags {
+			packed = binary.AppendUvarint(packed, uint64(t))
+		}
+		f = appendBytesField(f, 2, packed)
+	}
+	f = appendVarintField(f, 3, 1) // GeomType POINT
+	var geom []byte
+	geom = binary.AppendUvarint(geom, command(1, 1)) // MoveTo, one point
+	geom = binary.AppendUvarint(geom, zigzag(int64(x)))
+	geom = binary.AppendUvarint(geom, zigzag(int64(y)))
+	f = appendBytesField(f, 4, geom)
+
+	l.features = append(l.features, f)
+}
+
+func (l *Layer) key(k string) uint32 {
+	if i, ok := l.keyIdx[k]; ok {
+		return i
+	}
+	i := uint32(len(l.keys))
+	l.keys = append(l.keys, k)
+	l.keyIdx[k] = i
+	return i
+}
+
+func (l *Layer) value(v []byte) uint32 {
+	if i, ok := l.valIdx[string(v)]; ok {
+		return i
+	}
+	i := uint32(len(l.values))
+	l.values = append(l.values, v)
+	l.valIdx[string(v)] = i
+	return i
+}
+
+func (l *Layer) encode() []byte {
+	var b []byte
+	b = appendVarintField(b, 15, 2) // version
+	b = appendBytesField(b, 1, []byte(l.Name))
+	for _, f := range l.features {
+		b = appendBytesField(b, 2, f)
+	}
+	for _, k := range l.keys {
+		b = appendBytesField(b, 3, []byte(k))
+	}
+	for _, v := range l.values {
+		b = appendBytesField(b, 4, v)
+	}
+	return appendVarintField(b, 5, uint64(l.Extent))
+}
+
+// Encode serialises the layers as one tile. Empty layers are omitted.
+func Encode(layers ...*Layer) []byte {
+	var b []byte
+	for _, l := range layers {
+		if l.Len() == 0 {
+			continue
+		}
+		b = appendBytesField(b, 3, l.encode())
+	}
+	return b
+}
+
+// encodeValue builds a vector_tile.Tile.Value message.
+func encodeValue(v any) ([]byte, bool) {
+	switch x := v.(type) {
+	case string:
+		return appendBytesField(nil, 1, []byte(x)), true
+	case float64:
+		b := appendTag(nil, 3, 1)
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(x)), true
+	case int:
+		return appendVarintField(nil, 6, zigzag(int64(x))), true
+	case int64:
+		return appendVarintField(nil, 6, zigzag(x)), true
+	case bool:
+		n := uint64(0)
+		if x {
+			n = 1
+		}
+		return appendVarintField(nil, 7, n), true
+	}
+	return nil, false
+}
+
+func command(id, count uint32) uint64 { return uint64(id&0x7 | count<<3) }
+
+func zigzag(n int64) uint64 { return uint64((n << 1) ^ (n >> 63)) }
+
+func appendTag(b []byte, field, wire uint64) []byte {
+	return binary.AppendUvarint(b, field<<3|wire)
+}
+
+func appendVarintField(b []byte, field, v uint64) []byte {
+	return binary.AppendUvarint(appendTag(b, field, 0), v)
+}
+
+func appendBytesField(b []byte, field uint64, v []byte) []byte {
+	b = binary.AppendUvarint(appendTag(b, field, 2), uint64(len(v)))
+	return append(b, v...)
+}
@@ -0,0 +1,242 @@
+package mvt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestCommandAndZigzag(t *testing.T) {
+	// examples from the vector tile spec, section 4.3
+	if got := command(1, 1); got != 9 {
+		t.Errorf("MoveTo(1) = %d, want 9", got)
+	}
+	if got := command(2, 3); got != 26 {
+		t.Errorf("LineTo(3) = %d, want 26", got)
+	}
+	if got := command(7, 1); got != 15 {
+		t.Errorf("ClosePath = %d, want 15", got)
+	}
+	for n, want := range map[int64]uint64{0: 0, -1: 1, 1: 2, -2: 3, 2: 4, 25: 50, 17: 34, -2147483648: 4294967295} {
+		if got := zigzag(n); got != want {
+			t.Errorf("zigzag(%d) = %d, want %d", n, got, want)
+		}
+	}
+}
+
+func TestEncodeGolden(t *testing.T) {
+	l := NewLayer("r", DefaultExtent)
+	l.AddPoint(1, 25, 17, map[string]any{"a": "x"})
+	want := []byte{
+		0x1a, 0x1f, // layers, 31 bytes
+		0x78, 0x02, // version 2
+		0x0a, 0x01, 'r', // name
+		0x12, 0x0d, // feature, 13 bytes
+		0x08, 0x01, // id 1
+		0x12, 0x02, 0x00, 0x00, // tags key 0 = value 0
+		0x18, 0x01, // type POINT
+		0x22, 0x03, 0x09, 0x32, 0x22, // MoveTo(25, 17)
+		0x1a, 0x01, 'a', // keys
+		0x22, 0x03, 0x0a, 0x01, 'x', // values: string_value "x"
+		0x28, 0x80, 0x20, // extent 4096
+	}
+	if got := Encode(l); !bytes.Equal(got, want) {
+		t.Fatalf("tile = % x\nwant   % x", got, want)
+	}
+	if got := Encode(NewLayer("empty", DefaultExtent)); len(got) != 0 {
+		t.Fatalf("empty layer encoded as % x", got)
+	}
+}
+
+// field is one decoded protobuf field: a varint, a fixed64 or bytes.
+type field struct {
+	num  uint64
+	v    uint64
+	data []byte
+}
+
+func decode(t *testing.T, b []byte) []field {
+	t.Helper()
+	var out []field
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			t.Fatalf("bad tag in % x", b)
+		}
+		b = b[n:]
+		f := field{num: tag >> 3}
+		switch tag & 7 {
+		case 0:
+			f.v, n = binary.Uvarint(b)
+			if n <= 0 {
+				t.Fatal("bad varint")
+			}
+			b = b[n:]
+		case 1:
+			f.v = binary.LittleEndian.Uint64(b)
+			b = b[8:]
+		case 2:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || int(l) > len(b[n:]) {
+				t.Fatal("bad length")
+			}
+			f.data, b = b[n:n+int(l)], b[n+int(l):]
+		default:
+			t.Fatalf("unexpected wire type %d", tag&7)
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+func packed(t *testing.T, b []byte) []uint64 {
+	var out []uint64
+	for len(b) > 0 {
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			t.Fatal("bad packed varint")
+		}
+		out, b = append(out, v), b[n:]
+	}
+	return out
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	l := NewLayer("reports", 512)
+	l.AddPoint(7, 10, 500, map[string]any{"category": "water", "n": 3, "ok": true, "skip": []int{1}})
+	l.AddPoint(0, -3, 4, map[string]any{"category": "water", "n": int64(-3), "w": 1.5})
+
+	tile := decode(t, Encode(l, NewLayer("none", 512)))
+	if len(tile) != 1 || tile[0].num != 3 {
+		t.Fatalf("tile fields = %+v", tile)
+	}
+	var name string
+	var extent, version uint64
+	var feats [][]field
+	var keys []string
+	var values [][]field
+	for _, f := range decode(t, tile[0].data) {
+		switch f.num {
+		case 1:
+			name = string(f.data)
+		case 2:
+			feats = append(feats, decode(t, f.data))
+		case 3:
+			keys = append(keys, string(f.data))
+		case 4:
+			values = append(values, decode(t, f.data))
+		case 5:
+			extent = f.v
+		case 15:
+			version = f.v
+		}
+	}
+	if name != "reports" || extent != 512 || version != 2 || len(feats) != 2 {
+		t.Fatalf("layer %q extent %d version %d features %d", name, extent, version, len(feats))
+	}
+	// keys and values are shared: "category"/"water" and "n" appear once
+	if fmt.Sprint(keys) != "[category n ok w]" {
+		t.Fatalf("keys = %q", keys)
+	}
+	if len(values) != 5 { // "water", 3, true, -3, 1.5
+		t.Fatalf("values = %d", len(values))
+	}
+
+	props := func(f []field) (id uint64, tags map[string]field, geom []uint64) {
+		tags = map[string]field{}
+		for _, x := range f {
+			switch x.num {
+			case 1:
+				id = x.v
+			case 2:
+				p := packed(t, x.data)
+				for i := 0; i+1 < len(p); i += 2 {
+					tags[keys[p[i]]] = values[p[i+1]][0]
+				}
+			case 3:
+				if x.v != 1 {
+					t.Errorf("geometry type %d, want POINT", x.v)
+				}
+			case 4:
+				geom = packed(t, x.data)
+			}
+		}
+		return
+	}
+
+	id, tags, geom := props(feats[0])
+	if id != 7 || string(tags["category"].data) != "water" || tags["n"].num != 6 || tags["n"].v != zigzag(3) ||
+		tags["ok"].num != 7 || tags["ok"].v != 1 || len(tags) != 3 {
+		t.Fatalf("feature 0: id %d tags %+v", id, tags)
+	}
+	if len(geom) != 3 || geom[0] != 9 || geom[1] != zigzag(10) || geom[2] != zigzag(500) {
+		t.Fatalf("feature 0 geometry = %v", geom)
+	}
+
+	id, tags, geom = props(feats[1])
+	if id != 0 || tags["n"].v != zigzag(-3) || tags["w"].num != 3 || math.Float64frombits(tags["w"].v) != 1.5 {
+		t.Fatalf("feature 1: id %d tags %+v", id, tags)
+	}
+	if geom[1] != zigzag(-3) || geom[2] != zigzag(4) {
+		t.Fatalf("feature 1 geometry = %v", geom)
+	}
+
+	// same input, same bytes (ETags depend on it)
+	again := NewLayer("reports", 512)
+	again.AddPoint(7, 10, 500, map[string]any{"skip": []int{1}, "ok": true, "n": 3, "category": "water"})
+	again.AddPoint(0, -3, 4, map[string]any{"w": 1.5, "n": int64(-3), "category": "water"})
+	if !bytes.Equal(Encode(l), Encode(again)) {
+		t.Fatal("encoding depends on map order")
+	}
+}
+
+func TestTileGeometry(t *testing.T) {
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-6 }
+
+	minLng, minLat, maxLng, maxLat := TileBounds(0, 0, 0)
+	if !near(minLng, -180) || !near(maxLng, 180) || !near(maxLat, 85.0511287798) || !near(minLat, -85.0511287798) {
+		t.Fatalf("z0 bounds = %v %v %v %v", minLng, minLat, maxLng, maxLat)
+	}
+	minLng, minLat, maxLng, maxLat = TileBounds(1, 1, 0)
+	if !near(minLng, 0) || !near(maxLng, 180) || !near(minLat, 0) || !near(maxLat, 85.0511287798) {
+		t.Fatalf("z1 1/0 bounds = %v %v %v %v", minLng, minLat, maxLng, maxLat)
+	}
+
+	for _, tc := range []struct {
+		z, x, y  int
+		lat, lng float64
+		px, py   int
+	}{
+		{0, 0, 0, 0, 0, 2048, 2048},
+		{0, 0, 0, 85.0511287798, -180, 0, 0},
+		{0, 0, 0, 89.9, 180, 4096, 0}, // clamped to the mercator limit
+		{1, 1, 1, 0, 0, 0, 0},         // shared corner of four z1 tiles
+		{1, 0, 0, 0, 0, 4096, 4096},
+		{1, 0, 1, -85.0511287798, 0, 4096, 4096},
+	} {
+		px, py := Project(tc.z, tc.x, tc.y, DefaultExtent, tc.lat, tc.lng)
+		if px != tc.px || py != tc.py {
+			t.Errorf("Project(%d/%d/%d, %v, %v) = %d,%d, want %d,%d", tc.z, tc.x, tc.y, tc.lat, tc.lng, px, py, tc.px, tc.py)
+		}
+	}
+
+	// a point at a tile's corner projects to that tile's origin
+	minLng, _, _, maxLat = TileBounds(14, 7780, 7790)
+	if px, py := Project(14, 7780, 7790, DefaultExtent, maxLat, minLng); px != 0 || py != 0 {
+		t.Fatalf("corner = %d,%d", px, py)
+	}
+
+	for _, tc := range []struct {
+		z, x, y int
+		ok      bool
+	}{
+		{0, 0, 0, true}, {0, 1, 0, false}, {0, 0, -1, false},
+		{3, 7, 7, true}, {3, 8, 0, false}, {24, 1<<24 - 1, 0, true}, {25, 0, 0, false}, {-1, 0, 0, false},
+	} {
+		if got := ValidTile(tc.z, tc.x, tc.y); got != tc.ok {
+			t.Errorf("ValidTile(%d, %d, %d) = %v", tc.z, tc.x, tc.y, got)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+// path: mvt/tile.go
+package mvt
+
+import "math"
+
+// TileBounds returns the lng/lat box of web-mercator tile z/x/y.
+func TileBounds(z, x, y int) (minLng, minLat, maxLng, maxLat float64) {
+	n := math.Exp2(float64(z))
+	minLng = float64(x)/n*360 - 180
+	maxLng = float64(x+1)/n*360 - 180
+	maxLat = tileLat(float64(y), n)
+	minLat = tileLat(float64(y+1), n)
+	return
+}
+
+func tileLat(y, n float64) float64 {
+	return math.Atan(math.Sinh(math.Pi*(1-2*y/n))) * 180 / math.Pi
+}
+
+// Project converts lat/lng to coordinates inside tile z/x/y scaled to
+// extent, y pointing down. Points outside the tile fall outside 0..extent.
+func Project(z, x, y int, extent uint32, lat, lng float64) (int, int) {
+	n := math.Exp2(float64(z))
+	lat = math.Max(math.Min(lat, 85.05112878), -85.05112878)
+	wx := (lng + 180) / 360 * n
+	s := math.Sin(lat * math.Pi / 180)
+	wy := (0.5 - math.Log((1+s)/(1-s))/(4*math.Pi)) * n
+	e := float64(extent)
+	return int(math.Round((wx - float64(x)) * e)), int(math.Round((wy - float64(y)) * e))
+}
+
+// ValidTile reports whether z/x/y addresses an existing tile.
+func ValidTile(z, x, y int) bool {
+	if z < 0 || z > 24 {
+		return false
+	}
+	n := 1 << z
+	return x >= 0 && x < n && y >= 0 && y < n
+}
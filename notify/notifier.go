@@ -0,0 +1,388 @@
+// path: notify/notifier.go
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/mail"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"meniba/database"
+	"meniba/events"
+	"meniba/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Collection names used by the notifier.
+const (
+	OutboxCol  = "notifications"
+	OptOutsCol = "email_optouts"
+)
+
+// Notifier turns bus events into emails: new assignments go to the
+// agencies' addresses, status changes to the reporter's contact address.
+// Emails are rendered into the outbox and sent with retries, like webhook
+// deliveries.
+type Notifier struct {
+	Store       Store
+	Bus         *events.Bus
+	Transport   Transport
+	Templates   *Templates
+	BaseURL     string // public API origin, for unsubscribe links
+	Secret      []byte // signs unsubscribe links
+	Poll        time.Duration
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	Lease       time.Duration
+}
+
+// NewNotifier returns a notifier with production defaults. PUBLIC_BASE_URL
+// and NOTIFY_SECRET configure unsubscribe links. Links signed with a
+// per-process secret would stop working after a restart or on another
+// replica, so real email (SMTPTransport) requires NOTIFY_SECRET; only the
+// LogTransport falls back to a random one.
+func NewNotifier(db *database.DB, bus *events.Bus, transport Transport) (*Notifier, error) {
+	tpl, err := LoadTemplates()
+	if err != nil {
+		return nil, err
+	}
+	secret := []byte(os.Getenv("NOTIFY_SECRET"))
+	if len(secret) == 0 {
+		if _, ok := transport.(*SMTPTransport); ok {
+			return nil, errors.New("NOTIFY_SECRET must be set to send email over SMTP")
+		}
+		log.Printf("notify: NOTIFY_SECRET not set; unsubscribe links are valid until restart")
+		secret = []byte(randomID())
+	}
+	base := strings.TrimRight(strings.TrimSpace(os.Getenv("PUBLIC_BASE_URL")), "/")
+	if base == "" {
+		base = "http://localhost:3005"
+	}
+	return &Notifier{
+		Store:       NewMongoStore(db),
+		Bus:         bus,
+		Transport:   transport,
+		Templates:   tpl,
+		BaseURL:     base,
+		Secret:      secret,
+		Poll:        5 * time.Second,
+		MaxAttempts: 8,
+		BaseBackoff: time.Minute,
+		MaxBackoff:  6 * time.Hour,
+		Lease:       2 * time.Minute,
+	}, nil
+}
+
+// Run consumes events and sends due emails until ctx is cancelled.
+func (n *Notifier) Run(ctx context.Context) {
+	go n.consume(ctx)
+
+	t := time.NewTicker(n.Poll)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			for {
+				sent, err := n.SendNext(ctx)
+				if err != nil {
+					log.Printf("notify: %v", err)
+					break
+				}
+				if !sent {
+					break
+				}
+			}
+		}
+	}
+}
+
+// consume subscribes to the bus, resubscribing from the last seen event if
+// the bus drops us for being slow.
+func (n *Notifier) consume(ctx context.Context) {
+	lastID := ""
+	for ctx.Err() == nil {
+		replay, live, cancel := n.Bus.Subscribe(lastID)
+		for _, ev := range replay {
+			n.handle(ctx, ev)
+			lastID = ev.ID
+		}
+	loop:
+		for {
+			select {
+			case <-ctx.Done():
+				break loop
+			case ev, ok := <-live:
+				if !ok {
+					break loop
+				}
+				n.handle(ctx, ev)
+				lastID = ev.ID
+			}
+		}
+		cancel()
+	}
+}
+
+func (n *Notifier) handle(ctx context.Context, ev events.Event) {
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if k, err := n.Enqueue(cctx, ev); err != nil {
+		log.Printf("notify: enqueue %s %s: %v", ev.Type, ev.ReportID, err)
+	} else if k > 0 {
+		log.Printf("notify: queued %d email(s) for %s", k, ev.Type)
+	}
+}
+
+// recipient is one address to render an event for.
+type recipient struct {
+	email string
+	lang  string
+	data  map[string]any
+}
+
+// Enqueue renders and stores the emails ev calls for, skipping recipients
+// who opted out, and returns how many were queued.
+func (n *Notifier) Enqueue(ctx context.Context, ev events.Event) (int, error) {
+	if ev.Report == nil {
+		return 0, nil
+	}
+	rcpts, err := n.recipients(ctx, ev)
+	if err != nil || len(rcpts) == 0 {
+		return 0, err
+	}
+	name := ev.Type
+	if ev.Type == events.ReportCreated {
+		name = events.ReportAssigned // routed at creation
+	}
+
+	now := time.Now().UTC()
+	var docs []models.Notification
+	for _, rc := range rcpts {
+		out, err := n.OptedOut(ctx, rc.email)
+		if err != nil {
+			return 0, err
+		}
+		if out {
+			continue
+		}
+		rc.data["Report"] = ev.Report
+		rc.data["UnsubscribeURL"] = n.UnsubscribeURL(rc.email)
+		msg, lang, err := n.Templates.Render(name, rc.lang, rc.data)
+		if err != nil {
+			return 0, err
+		}
+		docs = append(docs, models.Notification{
+			Event:         ev.Type,
+			EventID:       ev.ID,
+			ReportID:      ev.ReportID,
+			To:            rc.email,
+			Language:      lang,
+			Subject:       msg.Subject,
+			Text:          msg.Text,
+			HTML:          msg.HTML,
+			Status:        models.NotificationPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		})
+	}
+	if len(docs) == 0 {
+		return 0, nil
+	}
+	if err := n.Store.Insert(ctx, docs); err != nil {
+		return 0, err
+	}
+	return len(docs), nil
+}
+
+func (n *Notifier) recipients(ctx context.Context, ev events.Event) ([]recipient, error) {
+	r := ev.Report
+	switch ev.Type {
+	case events.ReportCreated:
+		return n.agencyRecipients(ctx, r.Agencies, "")
+	case events.ReportAssigned:
+		a, ok := ev.Data.(models.Assignment)
+		if !ok {
+			return nil, nil
+		}
+		var added []primitive.ObjectID
+		for _, id := range a.To {
+			if !containsID(a.From, id) {
+				added = append(added, id)
+			}
+		}
+		return n.agencyRecipients(ctx, added, a.Reason)
+	case events.ReportStatusChanged:
+		ch, ok := ev.Data.(models.StatusChange)
+		if !ok || r.ContactEmail == "" {
+			return nil, nil
+		}
+		return []recipient{{email: r.ContactEmail, lang: r.Language, data: map[string]any{
+			"FromLabel":   statusLabel(ch.From),
+			"StatusLabel": statusLabel(ch.To),
+			"Note":        ch.Note,
+		}}}, nil
+	}
+	return nil, nil
+}
+
+func (n *Notifier) agencyRecipients(ctx context.Context, ids []primitive.ObjectID, reason string) ([]recipient, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	agencies, err := n.Store.ActiveAgencies(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]recipient, 0, len(agencies))
+	for _, ag := range agencies {
+		ag := ag
+		out = append(out, recipient{email: ag.Email, lang: DefaultLanguage, data: map[string]any{
+			"Agency": &ag,
+			"Reason": reason,
+		}})
+	}
+	return out, nil
+}
+
+// SendNext claims one due email and attempts it. It returns false when
+// nothing was due.
+func (n *Notifier) SendNext(ctx context.Context) (bool, error) {
+	msg, ok, err := n.Store.Claim(ctx, time.Now().UTC(), n.Lease)
+	if err != nil {
+		return false, fmt.Errorf("claim notification: %w", err)
+	}
+	if !ok {
+		return false, nil
+	}
+
+	out, err := n.OptedOut(ctx, msg.To)
+	if err != nil {
+		return true, err
+	}
+	if out {
+		msg.Status = models.NotificationSuppressed
+		msg.UpdatedAt = time.Now().UTC()
+		return true, n.Store.Finish(ctx, msg)
+	}
+
+	sctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	err = n.Transport.Send(sctx, Message{To: msg.To, Subject: msg.Subject, Text: msg.Text, HTML: msg.HTML})
+	cancel()
+	now := time.Now().UTC()
+	msg.AttemptCount++
+	msg.UpdatedAt = now
+
+	switch {
+	case err == nil:
+		msg.Status = models.NotificationSent
+		msg.SentAt = &now
+		msg.LastError = ""
+	case msg.AttemptCount >= n.MaxAttempts:
+		msg.Status = models.NotificationFailed
+		msg.LastError = err.Error()
+	default:
+		msg.Status = models.NotificationPending
+		msg.LastError = err.Error()
+		msg.NextAttemptAt = now.Add(n.backoff(msg.AttemptCount))
+	}
+	return true, n.Store.Finish(ctx, msg)
+}
+
+// backoff returns the delay before attempt k+1: base * 2^(k-1), capped,
+// with ±10% jitter.
+func (n *Notifier) backoff(k int) time.Duration {
+	delay := n.BaseBackoff
+	for i := 1; i < k && delay < n.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > n.MaxBackoff {
+		delay = n.MaxBackoff
+	}
+	jitter := time.Duration(rand.Int63n(int64(delay)/5+1)) - delay/10
+	return delay + jitter
+}
+
+// OptedOut reports whether email asked not to be emailed.
+func (n *Notifier) OptedOut(ctx context.Context, email string) (bool, error) {
+	return n.Store.OptedOut(ctx, email)
+}
+
+// OptOut records that email wants no more notifications. Pending emails to
+// it are suppressed when their turn comes.
+func (n *Notifier) OptOut(ctx context.Context, email string) error {
+	return n.Store.OptOut(ctx, email)
+}
+
+// OptIn removes an opt-out.
+func (n *Notifier) OptIn(ctx context.Context, email string) error {
+	return n.Store.OptIn(ctx, email)
+}
+
+// UnsubscribeToken signs email so unsubscribe links can't be forged for
+// other addresses.
+func (n *Notifier) UnsubscribeToken(email string) string {
+	mac := hmac.New(sha256.New, n.Secret)
+	mac.Write([]byte(strings.ToLower(email)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidUnsubscribeToken checks a token from an unsubscribe link.
+func (n *Notifier) ValidUnsubscribeToken(email, token string) bool {
+	want := n.UnsubscribeToken(email)
+	return hmac.Equal([]byte(want), []byte(strings.ToLower(token)))
+}
+
+// UnsubscribeURL is the one-click opt-out link for email.
+func (n *Notifier) UnsubscribeURL(email string) string {
+	v := url.Values{"email": {strings.ToLower(email)}, "token": {n.UnsubscribeToken(email)}}
+	return n.BaseURL + "/api/notifications/unsubscribe?" + v.Encode()
+}
+
+// NormalizeEmail validates a bare address ("a@b.org", no display name)
+// and returns it lower-cased.
+func NormalizeEmail(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	a, err := mailAddr(s)
+	if err != nil || a != s || !strings.Contains(a[strings.LastIndex(a, "@")+1:], ".") {
+		return "", errors.New("invalid email")
+	}
+	return strings.ToLower(a), nil
+}
+
+func mailAddr(s string) (string, error) {
+	a, err := mail.ParseAddress(s)
+	if err != nil {
+		return "", err
+	}
+	return a.Address, nil
+}
+
+func statusLabel(s string) string {
+	if s == "" {
+		s = models.StatusOpen
+	}
+	return strings.ReplaceAll(s, "_", " ")
+}
+
+func containsID(list []primitive.ObjectID, v primitive.ObjectID) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,279 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"meniba/events"
+	"meniba/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fakeTransport fails the first failures sends, then records messages.
+type fakeTransport struct {
+	mu       sync.Mutex
+	failures int
+	sent     []Message
+}
+
+func (f *fakeTransport) Send(_ context.Context, m Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failures > 0 {
+		f.failures--
+		return errors.New("451 try again later")
+	}
+	f.sent = append(f.sent, m)
+	return nil
+}
+
+func newTestNotifier(t *testing.T, tr Transport) (*Notifier, *MemoryStore) {
+	t.Helper()
+	tpl, err := LoadTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewMemoryStore()
+	return &Notifier{
+		Store:       store,
+		Transport:   tr,
+		Templates:   tpl,
+		BaseURL:     "https://api.mineba.test",
+		Secret:      []byte("test-secret"),
+		MaxAttempts: 3,
+		BaseBackoff: time.Millisecond,
+		MaxBackoff:  10 * time.Millisecond,
+		Lease:       time.Minute,
+	}, store
+}
+
+func statusEvent(lang string) events.Event {
+	r := &models.Report{
+		ID:           primitive.NewObjectID(),
+		Category:     "water",
+		AreaLabel:    "Kissy Road",
+		ContactEmail: "Amie@Example.org",
+		Language:     lang,
+	}
+	return events.Event{
+		ID:       "e1",
+		Type:     events.ReportStatusChanged,
+		ReportID: r.ID.Hex(),
+		Report:   r,
+		Data:     models.StatusChange{From: models.StatusOpen, To: models.StatusInProgress, Note: "Crew on the way"},
+	}
+}
+
+func TestTemplatesRenderLanguages(t *testing.T) {
+	n, _ := newTestNotifier(t, &fakeTransport{})
+	ev := statusEvent("")
+	data := map[string]any{
+		"Report": ev.Report, "FromLabel": "open", "StatusLabel": "in progress",
+		"Note": "Crew on the way", "UnsubscribeURL": "https://x/unsub",
+	}
+
+	for _, tc := range []struct{ lang, wantLang, subject, body string }{
+		{"en", "en", "Your report is now in progress", "Its status changed from open to in progress."},
+		{"kri", "kri", "Yu ripɔt de in progress naw", "Di stetɔs dɔn chenj frɔm open to in progress."},
+		{" KRI ", "kri", "Yu ripɔt de in progress naw", "Wetin di tim se: Crew on the way"},
+		{"fr", "en", "Your report is now in progress", "Note from the team: Crew on the way"},
+	} {
+		m, lang, err := n.Templates.Render(events.ReportStatusChanged, tc.lang, data)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.lang, err)
+		}
+		if lang != tc.wantLang || m.Subject != tc.subject || !strings.Contains(m.Text, tc.body) {
+			t.Errorf("%q: lang %q subject %q text %q", tc.lang, lang, m.Subject, m.Text)
+		}
+		if !strings.Contains(m.Text, "https://x/unsub") || !strings.Contains(m.HTML, "https://x/unsub") {
+			t.Errorf("%q: unsubscribe link missing", tc.lang)
+		}
+	}
+
+	// HTML bodies are escaped
+	data["Note"] = "<script>alert(1)</script>"
+	m, _, err := n.Templates.Render(events.ReportStatusChanged, "en", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(m.HTML, "<script>") {
+		t.Errorf("note not escaped in HTML: %s", m.HTML)
+	}
+	if _, _, err := n.Templates.Render("report.unknown", "en", data); err == nil {
+		t.Error("rendered an event without templates")
+	}
+}
+
+func TestOutboxRetriesThenSends(t *testing.T) {
+	tr := &fakeTransport{failures: 2}
+	n, store := newTestNotifier(t, tr)
+	ctx := context.Background()
+
+	if k, err := n.Enqueue(ctx, statusEvent("kri")); err != nil || k != 1 {
+		t.Fatalf("Enqueue = %d, %v", k, err)
+	}
+	msgs := store.Messages()
+	if msgs[0].To != "Amie@Example.org" || msgs[0].Language != "kri" || msgs[0].Status != models.NotificationPending {
+		t.Fatalf("queued = %+v", msgs[0])
+	}
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		if sent, err := n.SendNext(ctx); !sent || err != nil {
+			t.Fatalf("attempt %d: %v, %v", attempt, sent, err)
+		}
+		m := store.Messages()[0]
+		if m.Status != models.NotificationPending || m.AttemptCount != attempt || m.LastError == "" {
+			t.Fatalf("after failure %d: %+v", attempt, m)
+		}
+		if wait := m.NextAttemptAt.Sub(m.UpdatedAt); wait < n.BaseBackoff*9/10 || wait > n.MaxBackoff*11/10 {
+			t.Fatalf("retry %d scheduled %v ahead", attempt, wait)
+		}
+		// not due again until the backoff has passed
+		if _, ok, _ := store.Claim(ctx, m.NextAttemptAt.Add(-time.Microsecond), n.Lease); ok {
+			t.Fatalf("attempt %d due before its backoff", attempt)
+		}
+		time.Sleep(n.MaxBackoff + n.MaxBackoff/10)
+	}
+
+	if sent, err := n.SendNext(ctx); !sent || err != nil {
+		t.Fatalf("final attempt: %v, %v", sent, err)
+	}
+	m := store.Messages()[0]
+	if m.Status != models.NotificationSent || m.SentAt == nil || m.LastError != "" || m.AttemptCount != 3 {
+		t.Fatalf("after success: %+v", m)
+	}
+	if len(tr.sent) != 1 || !strings.HasPrefix(tr.sent[0].Subject, "Yu ripɔt") {
+		t.Fatalf("sent = %+v", tr.sent)
+	}
+	if sent, _ := n.SendNext(ctx); sent {
+		t.Fatal("sent email claimed again")
+	}
+}
+
+func TestOutboxGivesUpAfterMaxAttempts(t *testing.T) {
+	tr := &fakeTransport{failures: 100}
+	n, store := newTestNotifier(t, tr)
+	ctx := context.Background()
+
+	if _, err := n.Enqueue(ctx, statusEvent("en")); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n.MaxAttempts; i++ {
+		time.Sleep(n.MaxBackoff + n.MaxBackoff/10)
+		if sent, err := n.SendNext(ctx); !sent || err != nil {
+			t.Fatalf("attempt %d: %v, %v", i+1, sent, err)
+		}
+	}
+	if m := store.Messages()[0]; m.Status != models.NotificationFailed || m.AttemptCount != n.MaxAttempts {
+		t.Fatalf("after %d failures: %+v", n.MaxAttempts, m)
+	}
+	time.Sleep(n.MaxBackoff)
+	if sent, _ := n.SendNext(ctx); sent {
+		t.Fatal("failed email retried")
+	}
+}
+
+func TestOutboxReclaimsExpiredLease(t *testing.T) {
+	n, store := newTestNotifier(t, &fakeTransport{})
+	ctx := context.Background()
+	if _, err := n.Enqueue(ctx, statusEvent("en")); err != nil {
+		t.Fatal(err)
+	}
+	// a worker claims the email and dies
+	if _, ok, _ := store.Claim(ctx, time.Now(), time.Millisecond); !ok {
+		t.Fatal("nothing to claim")
+	}
+	if _, ok, _ := store.Claim(ctx, time.Now(), time.Minute); ok {
+		t.Fatal("leased email claimed twice")
+	}
+	time.Sleep(2 * time.Millisecond)
+	if sent, err := n.SendNext(ctx); !sent || err != nil {
+		t.Fatalf("expired lease not reclaimed: %v, %v", sent, err)
+	}
+	if m := store.Messages()[0]; m.Status != models.NotificationSent {
+		t.Fatalf("status = %s", m.Status)
+	}
+}
+
+func TestOptOut(t *testing.T) {
+	tr := &fakeTransport{}
+	n, store := newTestNotifier(t, tr)
+	ctx := context.Background()
+
+	// queued before the opt-out: suppressed when its turn comes
+	if _, err := n.Enqueue(ctx, statusEvent("en")); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.OptOut(ctx, "amie@EXAMPLE.org"); err != nil {
+		t.Fatal(err)
+	}
+	if sent, err := n.SendNext(ctx); !sent || err != nil {
+		t.Fatalf("SendNext = %v, %v", sent, err)
+	}
+	if m := store.Messages()[0]; m.Status != models.NotificationSuppressed || len(tr.sent) != 0 {
+		t.Fatalf("opted-out email: %+v, sent %d", m, len(tr.sent))
+	}
+
+	// after the opt-out: not queued at all
+	if k, err := n.Enqueue(ctx, statusEvent("en")); err != nil || k != 0 {
+		t.Fatalf("Enqueue after opt-out = %d, %v", k, err)
+	}
+
+	if err := n.OptIn(ctx, "Amie@example.org"); err != nil {
+		t.Fatal(err)
+	}
+	if k, _ := n.Enqueue(ctx, statusEvent("en")); k != 1 {
+		t.Fatalf("Enqueue after opt-in = %d", k)
+	}
+}
+
+func TestUnsubscribeToken(t *testing.T) {
+	n, _ := newTestNotifier(t, &fakeTransport{})
+	tok := n.UnsubscribeToken("Amie@Example.org")
+	if !n.ValidUnsubscribeToken("amie@example.org", strings.ToUpper(tok)) {
+		t.Fatal("token rejected")
+	}
+	if n.ValidUnsubscribeToken("other@example.org", tok) {
+		t.Fatal("token valid for another address")
+	}
+	other, _ := newTestNotifier(t, &fakeTransport{})
+	other.Secret = []byte("another-secret")
+	if other.ValidUnsubscribeToken("amie@example.org", tok) {
+		t.Fatal("token valid under another secret")
+	}
+	if u := n.UnsubscribeURL("Amie@Example.org"); !strings.HasPrefix(u, "https://api.mineba.test/api/notifications/unsubscribe?email=amie%40example.org&token=") {
+		t.Fatalf("URL = %s", u)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	n := &Notifier{BaseBackoff: time.Minute, MaxBackoff: time.Hour}
+	for k, want := range map[int]time.Duration{1: time.Minute, 2: 2 * time.Minute, 4: 8 * time.Minute, 20: time.Hour} {
+		for i := 0; i < 20; i++ {
+			got := n.backoff(k)
+			if got < want-want/10 || got > want+want/10 {
+				t.Fatalf("backoff(%d) = %v, want %v ±10%%", k, got, want)
+			}
+		}
+	}
+}
+
+func TestNewNotifierRequiresSecretForSMTP(t *testing.T) {
+	t.Setenv("NOTIFY_SECRET", "")
+	if _, err := NewNotifier(nil, nil, &SMTPTransport{Addr: "localhost:25"}); err == nil {
+		t.Fatal("SMTP notifier started without NOTIFY_SECRET")
+	}
+	if _, err := NewNotifier(nil, nil, LogTransport{}); err != nil {
+		t.Fatalf("log transport: %v", err)
+	}
+	t.Setenv("NOTIFY_SECRET", "s3cret")
+	n, err := NewNotifier(nil, nil, &SMTPTransport{Addr: "localhost:25"})
+	if err != nil || string(n.Secret) != "s3cret" {
+		t.Fatalf("with secret: %v", err)
+	}
+}
@@ -0,0 +1,224 @@
+// path: notify/store.go
+package notify
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"meniba/database"
+	"meniba/models"
+	"meniba/routing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Store holds the outbox and opt-outs and looks up agency addresses. The
+// Notifier runs against MongoDB in production and MemoryStore in tests.
+type Store interface {
+	Insert(ctx context.Context, msgs []models.Notification) error
+	// Claim leases the pending email due soonest at now, or one whose
+	// lease has run out, marking it sending until now+lease. ok is false
+	// when nothing is due.
+	Claim(ctx context.Context, now time.Time, lease time.Duration) (msg models.Notification, ok bool, err error)
+	// Finish saves the outcome of an attempt: Status, AttemptCount,
+	// LastError, NextAttemptAt, SentAt and UpdatedAt.
+	Finish(ctx context.Context, msg models.Notification) error
+	OptedOut(ctx context.Context, email string) (bool, error)
+	OptOut(ctx context.Context, email string) error
+	OptIn(ctx context.Context, email string) error
+	// ActiveAgencies returns the active agencies among ids that have an
+	// email address.
+	ActiveAgencies(ctx context.Context, ids []primitive.ObjectID) ([]models.Agency, error)
+}
+
+// MongoStore keeps the outbox in OutboxCol and opt-outs in OptOutsCol.
+type MongoStore struct {
+	DB *database.DB
+}
+
+// NewMongoStore returns a store over db.
+func NewMongoStore(db *database.DB) *MongoStore {
+	return &MongoStore{DB: db}
+}
+
+func (m *MongoStore) Insert(ctx context.Context, msgs []models.Notification) error {
+	docs := make([]any, len(msgs))
+	for i := range msgs {
+		docs[i] = msgs[i]
+	}
+	_, err := m.DB.Col(OutboxCol).InsertMany(ctx, docs)
+	return err
+}
+
+func (m *MongoStore) Claim(ctx context.Context, now time.Time, lease time.Duration) (models.Notification, bool, error) {
+	var msg models.Notification
+	err := m.DB.Col(OutboxCol).FindOneAndUpdate(ctx,
+		bson.M{"$or": []bson.M{
+			{"status": models.NotificationPending, "next_attempt_at": bson.M{"$lte": now}},
+			// why: a worker that crashed mid-send leaves its lease behind
+			{"status": models.NotificationSending, "lease_until": bson.M{"$lt": now}},
+		}},
+		bson.M{"$set": bson.M{"status": models.NotificationSending, "lease_until": now.Add(lease), "updated_at": now}},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(&msg)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return msg, false, nil
+	}
+	return msg, err == nil, err
+}
+
+func (m *MongoStore) Finish(ctx context.Context, msg models.Notification) error {
+	set := bson.M{
+		"status":          msg.Status,
+		"attempt_count":   msg.AttemptCount,
+		"last_error":      msg.LastError,
+		"next_attempt_at": msg.NextAttemptAt,
+		"updated_at":      msg.UpdatedAt,
+	}
+	if msg.SentAt != nil {
+		set["sent_at"] = msg.SentAt
+	}
+	_, err := m.DB.Col(OutboxCol).UpdateOne(ctx, bson.M{"_id": msg.ID}, bson.M{"$set": set})
+	return err
+}
+
+func (m *MongoStore) OptedOut(ctx context.Context, email string) (bool, error) {
+	c, err := m.DB.Col(OptOutsCol).CountDocuments(ctx, bson.M{"email": strings.ToLower(email)}, options.Count().SetLimit(1))
+	return c > 0, err
+}
+
+func (m *MongoStore) OptOut(ctx context.Context, email string) error {
+	email = strings.ToLower(email)
+	_, err := m.DB.Col(OptOutsCol).UpdateOne(ctx, bson.M{"email": email},
+		bson.M{"$setOnInsert": models.EmailOptOut{Email: email, CreatedAt: time.Now().UTC()}},
+		options.Update().SetUpsert(true))
+	return err
+}
+
+func (m *MongoStore) OptIn(ctx context.Context, email string) error {
+	_, err := m.DB.Col(OptOutsCol).DeleteOne(ctx, bson.M{"email": strings.ToLower(email)})
+	return err
+}
+
+func (m *MongoStore) ActiveAgencies(ctx context.Context, ids []primitive.ObjectID) ([]models.Agency, error) {
+	cur, err := m.DB.Col(routing.AgenciesCol).Find(ctx, bson.M{
+		"_id": bson.M{"$in": ids}, "active": true, "email": bson.M{"$nin": bson.A{"", nil}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var agencies []models.Agency
+	err = cur.All(ctx, &agencies)
+	return agencies, err
+}
+
+// MemoryStore is an in-process Store for tests and local demos.
+type MemoryStore struct {
+	mu       sync.Mutex
+	outbox   []models.Notification
+	optOuts  map[string]bool
+	Agencies []models.Agency // looked up by ActiveAgencies
+}
+
+// NewMemoryStore returns an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{optOuts: map[string]bool{}}
+}
+
+// Messages returns a copy of the outbox.
+func (m *MemoryStore) Messages() []models.Notification {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]models.Notification(nil), m.outbox...)
+}
+
+func (m *MemoryStore) Insert(_ context.Context, msgs []models.Notification) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, msg := range msgs {
+		if msg.ID.IsZero() {
+			msg.ID = primitive.NewObjectID()
+		}
+		m.outbox = append(m.outbox, msg)
+	}
+	return nil
+}
+
+func (m *MemoryStore) Claim(_ context.Context, now time.Time, lease time.Duration) (models.Notification, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due []int
+	for i, msg := range m.outbox {
+		if (msg.Status == models.NotificationPending && !msg.NextAttemptAt.After(now)) ||
+			(msg.Status == models.NotificationSending && msg.LeaseUntil.Before(now)) {
+			due = append(due, i)
+		}
+	}
+	if len(due) == 0 {
+		return models.Notification{}, false, nil
+	}
+	sort.SliceStable(due, func(a, b int) bool {
+		return m.outbox[due[a]].NextAttemptAt.Before(m.outbox[due[b]].NextAttemptAt)
+	})
+	msg := &m.outbox[due[0]]
+	msg.Status, msg.LeaseUntil, msg.UpdatedAt = models.NotificationSending, now.Add(lease), now
+	return *msg, true, nil
+}
+
+func (m *MemoryStore) Finish(_ context.Context, msg models.Notification) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.outbox {
+		if m.outbox[i].ID == msg.ID {
+			cur := &m.outbox[i]
+			cur.Status, cur.AttemptCount, cur.LastError = msg.Status, msg.AttemptCount, msg.LastError
+			cur.NextAttemptAt, cur.UpdatedAt = msg.NextAttemptAt, msg.UpdatedAt
+			if msg.SentAt != nil {
+				cur.SentAt = msg.SentAt
+			}
+			return nil
+		}
+	}
+	return errors.New("notify: no such notification")
+}
+
+func (m *MemoryStore) OptedOut(_ context.Context, email string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.optOuts[strings.ToLower(email)], nil
+}
+
+func (m *MemoryStore) OptOut(_ context.Context, email string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.optOuts[strings.ToLower(email)] = true
+	return nil
+}
+
+func (m *MemoryStore) OptIn(_ context.Context, email string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.optOuts, strings.ToLower(email))
+	return nil
+}
+
+func (m *MemoryStore) ActiveAgencies(_ context.Context, ids []primitive.ObjectID) ([]models.Agency, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []models.Agency
+	for _, ag := range m.Agencies {
+		if ag.Active && ag.Email != "" && containsID(ids, ag.ID) {
+			out = append(out, ag)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,102 @@
+// path: notify/templates.go
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+)
+
+// DefaultLanguage is used when a recipient's language has no template.
+const DefaultLanguage = "en"
+
+//go:embed templates
+var templateFS embed.FS
+
+// Templates holds the email templates, one pair per language and event:
+// templates/<lang>/<event>.txt defines "subject" and "body" (plain text),
+// templates/<lang>/<event>.html defines "body" (HTML, optional).
+type Templates struct {
+	text map[string]*texttemplate.Template
+	html map[string]*htmltemplate.Template
+}
+
+// LoadTemplates parses the embedded templates.
+func LoadTemplates() (*Templates, error) {
+	t := &Templates{text: map[string]*texttemplate.Template{}, html: map[string]*htmltemplate.Template{}}
+	err := fs.WalkDir(templateFS, "templates", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		raw, err := templateFS.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		lang := path.Base(path.Dir(p))
+		name := path.Base(p)
+		ext := path.Ext(name)
+		key := lang + "/" + strings.TrimSuffix(name, ext)
+		switch ext {
+		case ".txt":
+			tt, err := texttemplate.New(name).Option("missingkey=error").Parse(string(raw))
+			if err != nil {
+				return err
+			}
+			t.text[key] = tt
+		case ".html":
+			ht, err := htmltemplate.New(name).Option("missingkey=error").Parse(string(raw))
+			if err != nil {
+				return err
+			}
+			t.html[key] = ht
+		}
+		return nil
+	})
+	return t, err
+}
+
+// Has reports whether event has a template in any language.
+func (t *Templates) Has(event string) bool {
+	_, ok := t.text[DefaultLanguage+"/"+event]
+	return ok
+}
+
+// Render executes the templates for event in lang, falling back to
+// DefaultLanguage. It returns the language actually used.
+func (t *Templates) Render(event, lang string, data any) (Message, string, error) {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	tt, ok := t.text[lang+"/"+event]
+	if !ok {
+		lang = DefaultLanguage
+		tt, ok = t.text[lang+"/"+event]
+	}
+	if !ok {
+		return Message{}, "", fmt.Errorf("notify: no template for %s", event)
+	}
+
+	var m Message
+	var buf bytes.Buffer
+	if err := tt.ExecuteTemplate(&buf, "subject", data); err != nil {
+		return m, lang, err
+	}
+	m.Subject = strings.Join(strings.Fields(buf.String()), " ")
+	buf.Reset()
+	if err := tt.ExecuteTemplate(&buf, "body", data); err != nil {
+		return m, lang, err
+	}
+	m.Text = strings.TrimSpace(buf.String()) + "\n"
+
+	if ht, ok := t.html[lang+"/"+event]; ok {
+		buf.Reset()
+		if err := ht.ExecuteTemplate(&buf, "body", data); err != nil {
+			return m, lang, err
+		}
+		m.HTML = buf.String()
+	}
+	return m, lang, nil
+}
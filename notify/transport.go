@@ -0,0 +1,165 @@
+// path: notify/transport.go
+// Package notify sends templated email notifications through an outbox.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// Message is one email ready to send. HTML is optional.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Transport delivers messages.
+type Transport interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// TransportFromEnv returns an SMTPTransport for SMTP_ADDR, or a
+// LogTransport when no server is configured. A local sink such as MailHog
+// (SMTP_ADDR=localhost:1025) needs no credentials.
+func TransportFromEnv() Transport {
+	addr := strings.TrimSpace(os.Getenv("SMTP_ADDR"))
+	if addr == "" {
+		return LogTransport{}
+	}
+	from := strings.TrimSpace(os.Getenv("SMTP_FROM"))
+	if from == "" {
+		from = "Mineba <no-reply@mineba.local>"
+	}
+	return &SMTPTransport{
+		Addr:     addr,
+		From:     from,
+		Username: strings.TrimSpace(os.Getenv("SMTP_USERNAME")),
+		Password: os.Getenv("SMTP_PASSWORD"),
+	}
+}
+
+// SMTPTransport sends through an SMTP server, with PLAIN auth when a
+// username is set (net/smtp only allows that over TLS or to localhost).
+type SMTPTransport struct {
+	Addr     string // host:port
+	From     string // RFC 5322 address, may include a display name
+	Username string
+	Password string
+}
+
+func (t *SMTPTransport) Send(ctx context.Context, m Message) error {
+	from, err := mailAddr(t.From)
+	if err != nil {
+		return fmt.Errorf("notify: invalid from address: %w", err)
+	}
+	var auth smtp.Auth
+	if t.Username != "" {
+		host, _, _ := net.SplitHostPort(t.Addr)
+		auth = smtp.PlainAuth("", t.Username, t.Password, host)
+	}
+	msg, err := t.build(m)
+	if err != nil {
+		return err
+	}
+
+	// why: smtp.SendMail has no context; run it aside so shutdown and
+	// per-send timeouts are honoured
+	done := make(chan error, 1)
+	go func() { done <- smtp.SendMail(t.Addr, auth, from, []string{m.To}, msg) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// build renders m as MIME: text/plain alone, or multipart/alternative
+// with an HTML part.
+func (t *SMTPTransport) build(m Message) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", t.From)
+	fmt.Fprintf(&b, "To: %s\r\n", m.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <%s@%s>\r\n", randomID(), domainOf(t.From))
+	b.WriteString("MIME-Version: 1.0\r\n")
+
+	if m.HTML == "" {
+		b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		if err := writeQP(&b, m.Text); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	}
+
+	boundary := "mineba-" + randomID()
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+	for _, part := range []struct{ ctype, body string }{
+		{"text/plain", m.Text},
+		{"text/html", m.HTML},
+	} {
+		fmt.Fprintf(&b, "--%s\r\n", boundary)
+		fmt.Fprintf(&b, "Content-Type: %s; charset=utf-8\r\n", part.ctype)
+		b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		if err := writeQP(&b, part.body); err != nil {
+			return nil, err
+		}
+		b.WriteString("\r\n")
+	}
+	fmt.Fprintf(&b, "--%s--\r\n", boundary)
+	return b.Bytes(), nil
+}
+
+func writeQP(b *bytes.Buffer, s string) error {
+	w := quotedprintable.NewWriter(b)
+	if _, err := w.Write([]byte(strings.ReplaceAll(s, "\n", "\r\n"))); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// LogTransport logs messages instead of sending them. Used in development.
+type LogTransport struct{}
+
+func (LogTransport) Send(_ context.Context, m Message) error {
+	log.Printf("notify: (log only) to=%s subject=%q\n%s", redactEmail(m.To), m.Subject, m.Text)
+	return nil
+}
+
+func randomID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func domainOf(addr string) string {
+	a, err := mailAddr(addr)
+	if err != nil {
+		return "localhost"
+	}
+	return a[strings.LastIndex(a, "@")+1:]
+}
+
+// redactEmail keeps the first character of the local part and the domain.
+func redactEmail(s string) string {
+	at := strings.LastIndex(s, "@")
+	if at < 1 {
+		return "****"
+	}
+	return s[:1] + "***" + s[at:]
+}
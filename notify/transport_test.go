@@ -0,0 +1,184 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpSink is a minimal in-process SMTP server that accepts every message
+// and hands it to the test.
+type smtpSink struct {
+	ln   net.Listener
+	msgs chan sinkMsg
+}
+
+type sinkMsg struct {
+	from string
+	rcpt []string
+	data string
+}
+
+func newSMTPSink(t *testing.T) *smtpSink {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpSink{ln: ln, msgs: make(chan sinkMsg, 10)}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpSink) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+	reply("220 sink ESMTP")
+	var m sinkMsg
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 sink")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			m = sinkMsg{from: strings.Trim(strings.TrimSpace(line)[10:], "<>")}
+			reply("250 ok")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			m.rcpt = append(m.rcpt, strings.Trim(strings.TrimSpace(line)[8:], "<>"))
+			reply("250 ok")
+		case cmd == "DATA":
+			reply("354 go ahead")
+			var b strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				b.WriteString(strings.TrimPrefix(l, "."))
+			}
+			m.data = b.String()
+			s.msgs <- m
+			reply("250 queued")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func TestSMTPTransportSendsMultipart(t *testing.T) {
+	sink := newSMTPSink(t)
+	tr := &SMTPTransport{Addr: sink.ln.Addr().String(), From: "Mineba <no-reply@mineba.test>"}
+
+	err := tr.Send(context.Background(), Message{
+		To:      "amie@example.org",
+		Subject: "Yu ripɔt de resolved naw",
+		Text:    "Tɛnki.\nReference: 123",
+		HTML:    "<p>Tɛnki.</p>",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got sinkMsg
+	select {
+	case got = <-sink.msgs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+	if got.from != "no-reply@mineba.test" || len(got.rcpt) != 1 || got.rcpt[0] != "amie@example.org" {
+		t.Fatalf("envelope = %q -> %q", got.from, got.rcpt)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(got.data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "Yu ripɔt de resolved naw" {
+		t.Fatalf("subject = %q, %v", subject, err)
+	}
+	if !strings.HasSuffix(msg.Header.Get("Message-ID"), "@mineba.test>") {
+		t.Errorf("Message-ID = %q", msg.Header.Get("Message-ID"))
+	}
+	mt, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mt != "multipart/alternative" {
+		t.Fatalf("content type = %q, %v", mt, err)
+	}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	want := map[string]string{"text/plain": "Tɛnki.\r\nReference: 123", "text/html": "<p>Tɛnki.</p>"}
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		ct, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		// multipart.Reader decodes quoted-printable itself
+		body, _ := io.ReadAll(p)
+		if string(body) != want[ct] {
+			t.Errorf("%s part = %q, want %q", ct, body, want[ct])
+		}
+		delete(want, ct)
+	}
+	if len(want) > 0 {
+		t.Errorf("missing parts: %v", want)
+	}
+}
+
+func TestSMTPTransportBuildPlainText(t *testing.T) {
+	tr := &SMTPTransport{From: "no-reply@mineba.test"}
+	raw, err := tr.build(Message{To: "a@example.org", Subject: "Hi", Text: "line one\nline two"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := msg.Header.Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	body, _ := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	if string(body) != "line one\r\nline two" {
+		t.Fatalf("body = %q", body)
+	}
+}
+
+func TestSMTPTransportUnreachable(t *testing.T) {
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := ln.Addr().String()
+	ln.Close()
+	tr := &SMTPTransport{Addr: addr, From: "no-reply@mineba.test"}
+	if err := tr.Send(context.Background(), Message{To: "a@example.org", Subject: "x", Text: "x"}); err == nil {
+		t.Fatal("send to a closed port succeeded")
+	}
+}
@@ -0,0 +1,93 @@
+// path: repository/chat.go
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"meniba/database"
+	"meniba/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ChatSessionRepository stores in-progress chat intake conversations.
+type ChatSessionRepository interface {
+	// Get returns the session with id, or ErrNotFound.
+	Get(ctx context.Context, id string) (models.ChatSession, error)
+	// Save creates or replaces s.
+	Save(ctx context.Context, s models.ChatSession) error
+	// Delete removes a session; missing sessions are not an error.
+	Delete(ctx context.Context, id string) error
+}
+
+// MongoChatSessions keeps chat sessions in the chat_sessions collection,
+// where a TTL index expires abandoned ones.
+type MongoChatSessions struct {
+	col *mongo.Collection
+}
+
+// NewMongoChatSessions returns a session store over db.
+func NewMongoChatSessions(db *database.DB) *MongoChatSessions {
+	return &MongoChatSessions{col: db.Col("chat_sessions")}
+}
+
+func (m *MongoChatSessions) Get(ctx context.Context, id string) (models.ChatSession, error) {
+	var s models.ChatSession
+	err := m.col.FindOne(ctx, bson.M{"_id": id}).Decode(&s)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return s, ErrNotFound
+	}
+	return s, err
+}
+
+func (m *MongoChatSessions) Save(ctx context.Context, s models.ChatSession) error {
+	_, err := m.col.ReplaceOne(ctx, bson.M{"_id": s.ID}, s, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (m *MongoChatSessions) Delete(ctx context.Context, id string) error {
+	_, err := m.col.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+// MemoryChatSessions is an in-process ChatSessionRepository for tests and
+// local demos. Sessions don't expire.
+type MemoryChatSessions struct {
+	mu   sync.Mutex
+	docs map[string]models.ChatSession
+}
+
+// NewMemoryChatSessions returns an empty in-memory session store.
+func NewMemoryChatSessions() *MemoryChatSessions {
+	return &MemoryChatSessions{docs: map[string]models.ChatSession{}}
+}
+
+func (m *MemoryChatSessions) Get(_ context.Context, id string) (models.ChatSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.docs[id]
+	if !ok {
+		return models.ChatSession{}, ErrNotFound
+	}
+	s.PhotoURLs = append([]string(nil), s.PhotoURLs...)
+	return s, nil
+}
+
+func (m *MemoryChatSessions) Save(_ context.Context, s models.ChatSession) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s.PhotoURLs = append([]string(nil), s.PhotoURLs...)
+	m.docs[s.ID] = s
+	return nil
+}
+
+func (m *MemoryChatSessions) Delete(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.docs, id)
+	return nil
+}
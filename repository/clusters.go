@@ -0,0 +1,119 @@
+// path: repository/clusters.go
+package repository
+
+import (
+	"math"
+
+	"meniba/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DefaultPrivacyRadiusM applies to reports stored without a radius.
+const DefaultPrivacyRadiusM = 300
+
+// Cluster is a grid cell's worth of reports for zoomed-out maps.
+type Cluster struct {
+	Lat        float64          `json:"lat"` // mean of the members' public positions
+	Lng        float64          `json:"lng"`
+	Count      int64            `json:"count"`
+	ByCategory map[string]int64 `json:"by_category"`
+	RadiusM    int              `json:"radius_m"`            // largest privacy radius among members
+	ReportID   string           `json:"report_id,omitempty"` // set when Count is 1
+}
+
+// PublicPosition is where a report may be shown: its coordinates snapped
+// to a grid as coarse as its privacy radius, so a cluster of one doesn't
+// reveal the exact spot.
+func PublicPosition(r models.Report) (lat, lng float64) {
+	dLat, dLng := privacyCell(r.Lat, float64(PrivacyRadius(r)))
+	return (math.Floor(r.Lat/dLat) + 0.5) * dLat, (math.Floor(r.Lng/dLng) + 0.5) * dLng
+}
+
+// PrivacyRadius is the radius r is snapped to: its own if positive,
+// DefaultPrivacyRadiusM otherwise.
+func PrivacyRadius(r models.Report) int {
+	if r.PrivacyRadiusM != nil && *r.PrivacyRadiusM > 0 {
+		return *r.PrivacyRadiusM
+	}
+	return DefaultPrivacyRadiusM
+}
+
+func privacyCell(lat, radiusM float64) (dLat, dLng float64) {
+	cos := math.Max(math.Cos(lat*math.Pi/180), 0.01)
+	return radiusM / metresPerDegree, radiusM / (metresPerDegree * cos)
+}
+
+// GridCount is the number of reports in grid cell X (column from -180°
+// eastwards), Y (row from -90° northwards).
+type GridCount struct {
+	X, Y int64
+	N    int64
+}
+
+// gridCell is the cell of lat/lng in a 2^lngBits × 2^latBits grid. Points
+// on the east or north edge of the world fall in the last cell.
+func gridCell(lat, lng float64, lngBits, latBits int) (x, y int64) {
+	nx, ny := int64(1)<<lngBits, int64(1)<<latBits
+	x = min(int64(math.Floor((lng+180)/360*float64(nx))), nx-1)
+	y = min(int64(math.Floor((lat+90)/180*float64(ny))), ny-1)
+	return max(x, 0), max(y, 0)
+}
+
+// gridReports is the in-memory counting Mongo.Grid mirrors.
+func gridReports(docs []models.Report, lngBits, latBits int) []GridCount {
+	idx := map[[2]int64]int{}
+	var out []GridCount
+	for _, r := range docs {
+		lat, lng := PublicPosition(r)
+		x, y := gridCell(lat, lng, lngBits, latBits)
+		i, ok := idx[[2]int64{x, y}]
+		if !ok {
+			i = len(out)
+			idx[[2]int64{x, y}] = i
+			out = append(out, GridCount{X: x, Y: y})
+		}
+		out[i].N++
+	}
+	return out
+}
+
+type clusterKey struct{ x, y int64 }
+
+type clusterAcc struct {
+	Cluster
+	sumLat, sumLng float64
+	firstID        primitive.ObjectID
+}
+
+// clusterReports is the in-memory grouping Mongo.Clusters mirrors.
+func clusterReports(docs []models.Report, cellDeg float64) []Cluster {
+	acc := map[clusterKey]*clusterAcc{}
+	var order []clusterKey
+	for _, r := range docs {
+		lat, lng := PublicPosition(r)
+		k := clusterKey{int64(math.Floor(lng / cellDeg)), int64(math.Floor(lat / cellDeg))}
+		a, ok := acc[k]
+		if !ok {
+			a = &clusterAcc{Cluster: Cluster{ByCategory: map[string]int64{}}, firstID: r.ID}
+			acc[k] = a
+			order = append(order, k)
+		}
+		a.Count++
+		a.ByCategory[r.Category]++
+		a.sumLat += lat
+		a.sumLng += lng
+		a.RadiusM = max(a.RadiusM, PrivacyRadius(r))
+	}
+	out := make([]Cluster, 0, len(order))
+	for _, k := range order {
+		a := acc[k]
+		a.Lat = a.sumLat / float64(a.Count)
+		a.Lng = a.sumLng / float64(a.Count)
+		if a.Count == 1 {
+			a.ReportID = a.firstID.Hex()
+		}
+		out = append(out, a.Cluster)
+	}
+	return out
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"math"
+	"testing"
+
+	"meniba/models"
+)
+
+func TestPrivacyRadiusFallback(t *testing.T) {
+	zero, neg, own := 0, -5, 1000
+	for _, tc := range []struct {
+		radius *int
+		want   int
+	}{{nil, DefaultPrivacyRadiusM}, {&zero, DefaultPrivacyRadiusM}, {&neg, DefaultPrivacyRadiusM}, {&own, 1000}} {
+		r := models.Report{Lat: 8.4844, Lng: -13.2344, PrivacyRadiusM: tc.radius}
+		if got := PrivacyRadius(r); got != tc.want {
+			t.Errorf("PrivacyRadius(%v) = %d, want %d", tc.radius, got, tc.want)
+		}
+		// the position is snapped to a cell of that radius, so it is
+		// never further than one cell diagonal from the real spot
+		lat, lng := PublicPosition(r)
+		dLat, dLng := privacyCell(r.Lat, float64(tc.want))
+		if math.Abs(lat-r.Lat) > dLat/2 || math.Abs(lng-r.Lng) > dLng/2 {
+			t.Errorf("PublicPosition(%v) = %v,%v; outside its %dm cell", tc.radius, lat, lng, tc.want)
+		}
+	}
+
+	cl := clusterReports([]models.Report{{PrivacyRadiusM: &zero}}, 1)
+	if len(cl) != 1 || cl[0].RadiusM != DefaultPrivacyRadiusM {
+		t.Fatalf("cluster radius = %+v", cl)
+	}
+}
+
+func TestGridCell(t *testing.T) {
+	for _, tc := range []struct {
+		lat, lng float64
+		x, y     int64
+	}{
+		{-90, -180, 0, 0},
+		{90, 180, 7, 3}, // the east and north edges fall in the last cell
+		{0, 0, 4, 2},
+		{-0.001, -0.001, 3, 1},
+		{44.9, 89.9, 5, 2},
+	} {
+		if x, y := gridCell(tc.lat, tc.lng, 3, 2); x != tc.x || y != tc.y {
+			t.Errorf("gridCell(%v, %v) = %d,%d, want %d,%d", tc.lat, tc.lng, x, y, tc.x, tc.y)
+		}
+	}
+
+	docs := []models.Report{{Lat: 10, Lng: 10}, {Lat: 10.0001, Lng: 10.0001}, {Lat: -10, Lng: -10}}
+	got := gridReports(docs, 3, 2)
+	if len(got) != 2 || got[0] != (GridCount{X: 4, Y: 2, N: 2}) || got[1] != (GridCount{X: 3, Y: 1, N: 1}) {
+		t.Fatalf("gridReports = %+v", got)
+	}
+}
@@ -0,0 +1,91 @@
+// path: repository/comments.go
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"meniba/database"
+	"meniba/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CommentRepository stores moderator comments on reports.
+type CommentRepository interface {
+	// Insert stores cm, assigning its ID.
+	Insert(ctx context.Context, cm *models.Comment) error
+	// Public returns up to limit public comments on reportID, oldest first.
+	Public(ctx context.Context, reportID primitive.ObjectID, limit int) ([]models.Comment, error)
+}
+
+// MongoComments keeps comments in the comments collection.
+type MongoComments struct {
+	col *mongo.Collection
+}
+
+// NewMongoComments returns a comment store over db.
+func NewMongoComments(db *database.DB) *MongoComments {
+	return &MongoComments{col: db.Col("comments")}
+}
+
+func (m *MongoComments) Insert(ctx context.Context, cm *models.Comment) error {
+	cm.ID = primitive.NewObjectID()
+	_, err := m.col.InsertOne(ctx, cm)
+	return err
+}
+
+func (m *MongoComments) Public(ctx context.Context, reportID primitive.ObjectID, limit int) ([]models.Comment, error) {
+	cur, err := m.col.Find(ctx,
+		bson.M{"report_id": reportID, "public": true},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}).SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]models.Comment, 0)
+	if err := cur.All(ctx, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// MemoryComments is an in-process CommentRepository for tests and local
+// demos.
+type MemoryComments struct {
+	mu   sync.Mutex
+	docs []models.Comment
+}
+
+// NewMemoryComments returns an empty in-memory comment store.
+func NewMemoryComments() *MemoryComments {
+	return &MemoryComments{}
+}
+
+func (m *MemoryComments) Insert(_ context.Context, cm *models.Comment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cm.ID = primitive.NewObjectID()
+	m.docs = append(m.docs, *cm)
+	return nil
+}
+
+func (m *MemoryComments) Public(_ context.Context, reportID primitive.ObjectID, limit int) ([]models.Comment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	items := make([]models.Comment, 0)
+	for _, cm := range m.docs {
+		if cm.ReportID == reportID && cm.Public {
+			items = append(items, cm)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].CreatedAt.Before(items[j].CreatedAt) })
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items, nil
+}
@@ -0,0 +1,260 @@
+// path: repository/memory.go
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"meniba/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Memory is a fully functional in-process ReportRepository. It applies the
+// same filter semantics as Mongo and stores copies, so callers can't alias
+// stored documents.
+type Memory struct {
+	mu   sync.RWMutex
+	docs map[primitive.ObjectID]models.Report
+}
+
+// NewMemory returns an empty in-memory repository.
+func NewMemory() *Memory {
+	return &Memory{docs: map[primitive.ObjectID]models.Report{}}
+}
+
+func (m *Memory) Create(_ context.Context, r *models.Report) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if r.ID.IsZero() {
+		r.ID = primitive.NewObjectID()
+	}
+	if _, ok := m.docs[r.ID]; ok {
+		return errors.New("duplicate key")
+	}
+	c, err := clone(*r)
+	if err != nil {
+		return err
+	}
+	m.docs[r.ID] = c
+	return nil
+}
+
+func (m *Memory) Get(_ context.Context, id primitive.ObjectID) (models.Report, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	r, ok := m.docs[id]
+	if !ok {
+		return models.Report{}, ErrNotFound
+	}
+	return clone(r)
+}
+
+func (m *Memory) List(_ context.Context, f ReportQuery) ([]models.Report, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	out := make([]models.Report, 0)
+	for _, r := range m.sorted(f) {
+		if !f.Match(r) || !f.afterPosition(r) {
+			continue
+		}
+		c, err := clone(r)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	if f.Text != "" && f.Sort.Field == "" {
+		t := parseText(f.Text)
+		sort.SliceStable(out, func(i, j int) bool { return t.score(out[i]) > t.score(out[j]) })
+	}
+	if f.Skip > 0 {
+		if f.Skip >= len(out) {
+			return out[:0], nil
+		}
+		out = out[f.Skip:]
+	}
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[:f.Limit]
+	}
+	return out, nil
+}
+
+func (m *Memory) Scan(ctx context.Context, f ReportQuery, fn func(models.Report) error) error {
+	m.mu.RLock()
+	var docs []models.Report
+	for _, r := range m.docs {
+		if f.Match(r) {
+			docs = append(docs, r)
+		}
+	}
+	m.mu.RUnlock()
+
+	// why: fn runs unlocked so it may call back into the repository
+	for _, r := range docs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		c, err := clone(r)
+		if err != nil {
+			return err
+		}
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Memory) Count(_ context.Context, f ReportQuery) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var n int64
+	for _, r := range m.docs {
+		if f.Match(r) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Update is optimistic like Mongo's: mutate runs unlocked on a copy, and
+// the write only lands if the version is still the one that was read.
+func (m *Memory) Update(ctx context.Context, id primitive.ObjectID, mutate func(r *models.Report) error) (models.Report, error) {
+	for i := 0; i < maxUpdateRetries; i++ {
+		cur, err := m.Get(ctx, id)
+		if err != nil {
+			return cur, err
+		}
+		next, err := clone(cur)
+		if err != nil {
+			return cur, err
+		}
+		if err := mutate(&next); err != nil {
+			if errors.Is(err, ErrNoChange) {
+				return cur, nil
+			}
+			return cur, err
+		}
+		next.ID = id
+		next.Version = cur.Version + 1
+		next.UpdatedAt = time.Now().UTC()
+
+		saved, err := clone(next)
+		if err != nil {
+			return cur, err
+		}
+		m.mu.Lock()
+		stored, ok := m.docs[id]
+		if ok && stored.Version == cur.Version {
+			m.docs[id] = saved
+		}
+		m.mu.Unlock()
+		if !ok {
+			return models.Report{}, ErrNotFound
+		}
+		if stored.Version == cur.Version {
+			return next, nil
+		}
+	}
+	return models.Report{}, ErrConflict
+}
+
+func (m *Memory) Delete(_ context.Context, id primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.docs[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.docs, id)
+	return nil
+}
+
+func (m *Memory) Stats(_ context.Context, f ReportQuery) (ReportStats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	st := newStats()
+	for _, r := range m.docs {
+		if !f.Match(r) {
+			continue
+		}
+		st.Total++
+		st.ByCategory[r.Category]++
+		st.ByStatus[r.CurrentStatus()]++
+		st.ByDistrict[r.District]++
+	}
+	return st, nil
+}
+
+func (m *Memory) Clusters(_ context.Context, f ReportQuery, cellDeg float64) ([]Cluster, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var docs []models.Report
+	for _, r := range m.docs {
+		if f.Match(r) {
+			docs = append(docs, r)
+		}
+	}
+	return clusterReports(docs, cellDeg), nil
+}
+
+func (m *Memory) Grid(_ context.Context, f ReportQuery, lngBits, latBits int) ([]GridCount, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var docs []models.Report
+	for _, r := range m.docs {
+		if f.Match(r) {
+			docs = append(docs, r)
+		}
+	}
+	return gridReports(docs, lngBits, latBits), nil
+}
+
+// sorted returns documents in f.Sort order, like the Mongo sort.
+func (m *Memory) sorted(f ReportQuery) []models.Report {
+	out := make([]models.Report, 0, len(m.docs))
+	for _, r := range m.docs {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return f.less(out[i], out[j]) })
+	return out
+}
+
+// clone deep-copies a report through BSON, which also mimics Mongo's
+// millisecond time precision.
+func clone(r models.Report) (models.Report, error) {
+	raw, err := bson.Marshal(r)
+	if err != nil {
+		return models.Report{}, err
+	}
+	var out models.Report
+	err = bson.Unmarshal(raw, &out)
+	return out, err
+}
+
+func containsStr(list []string, v string) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func containsID(list []primitive.ObjectID, v primitive.ObjectID) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
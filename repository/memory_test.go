@@ -0,0 +1,276 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"meniba/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMemoryCreateGetCopies(t *testing.T) {
+	m := NewMemory()
+	ctx := context.Background()
+
+	r := &models.Report{Category: "water", PhotoURLs: []string{"/uploads/a.jpg"}}
+	if err := m.Create(ctx, r); err != nil {
+		t.Fatal(err)
+	}
+	if r.ID.IsZero() {
+		t.Fatal("Create did not set an id")
+	}
+	if err := m.Create(ctx, r); err == nil {
+		t.Fatal("created the same id twice")
+	}
+
+	r.PhotoURLs[0] = "/uploads/changed.jpg"
+	got, err := m.Get(ctx, r.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.PhotoURLs[0] != "/uploads/a.jpg" {
+		t.Fatal("stored report aliases the caller's slice")
+	}
+	got.Category = "roads"
+	if again, _ := m.Get(ctx, r.ID); again.Category != "water" {
+		t.Fatal("Get returned the stored document itself")
+	}
+
+	if _, err := m.Get(ctx, primitive.NewObjectID()); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get(missing) = %v", err)
+	}
+	if err := m.Delete(ctx, r.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Delete(ctx, r.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Delete(deleted) = %v", err)
+	}
+}
+
+func TestMemoryUpdate(t *testing.T) {
+	m := NewMemory()
+	ctx := context.Background()
+	r := &models.Report{Category: "water"}
+	if err := m.Create(ctx, r); err != nil {
+		t.Fatal(err)
+	}
+
+	next, err := m.Update(ctx, r.ID, func(r *models.Report) error {
+		r.Status = models.StatusAcknowledged
+		r.ID = primitive.NewObjectID() // ignored
+		return nil
+	})
+	if err != nil || next.Version != 1 || next.ID != r.ID || next.UpdatedAt.IsZero() {
+		t.Fatalf("Update = %+v, %v", next, err)
+	}
+
+	cur, err := m.Update(ctx, r.ID, func(r *models.Report) error {
+		r.Status = models.StatusRejected
+		return ErrNoChange
+	})
+	if err != nil || cur.Status != models.StatusAcknowledged || cur.Version != 1 {
+		t.Fatalf("ErrNoChange: %+v, %v", cur, err)
+	}
+
+	boom := errors.New("boom")
+	if _, err := m.Update(ctx, r.ID, func(r *models.Report) error {
+		r.Status = models.StatusRejected
+		return boom
+	}); !errors.Is(err, boom) {
+		t.Fatalf("mutator error = %v", err)
+	}
+	if got, _ := m.Get(ctx, r.ID); got.Status != models.StatusAcknowledged {
+		t.Fatalf("aborted update was written: %s", got.Status)
+	}
+
+	if _, err := m.Update(ctx, primitive.NewObjectID(), func(*models.Report) error { return nil }); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Update(missing) = %v", err)
+	}
+}
+
+func TestMemoryUpdateVersionConflict(t *testing.T) {
+	m := NewMemory()
+	ctx := context.Background()
+	r := &models.Report{Category: "water"}
+	if err := m.Create(ctx, r); err != nil {
+		t.Fatal(err)
+	}
+
+	// another writer lands between our read and our write: the mutation
+	// is re-applied to its result, so neither change is lost
+	calls := 0
+	next, err := m.Update(ctx, r.ID, func(r *models.Report) error {
+		calls++
+		if calls == 1 {
+			if _, err := m.Update(ctx, r.ID, func(o *models.Report) error {
+				o.Confirmations++
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		r.Status = models.StatusInProgress
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Fatalf("Update = %v after %d call(s)", err, calls)
+	}
+	if next.Version != 2 || next.Confirmations != 1 || next.Status != models.StatusInProgress {
+		t.Fatalf("merged result = %+v", next)
+	}
+
+	// a writer that always gets there first exhausts the retries
+	calls = 0
+	_, err = m.Update(ctx, r.ID, func(r *models.Report) error {
+		calls++
+		if _, err := m.Update(ctx, r.ID, func(o *models.Report) error {
+			o.Confirmations++
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		r.Note = "lost"
+		return nil
+	})
+	if !errors.Is(err, ErrConflict) || calls != maxUpdateRetries {
+		t.Fatalf("Update = %v after %d call(s), want ErrConflict after %d", err, calls, maxUpdateRetries)
+	}
+	if got, _ := m.Get(ctx, r.ID); got.Note == "lost" || got.Confirmations != 1+maxUpdateRetries {
+		t.Fatalf("stored = %+v", got)
+	}
+}
+
+func TestMemoryListFilters(t *testing.T) {
+	m := NewMemory()
+	ctx := context.Background()
+	now := time.Now().UTC()
+	canon := primitive.NewObjectID()
+	add := func(r models.Report) primitive.ObjectID {
+		t.Helper()
+		if r.CreatedAt.IsZero() {
+			r.CreatedAt = now
+		}
+		if err := m.Create(ctx, &r); err != nil {
+			t.Fatal(err)
+		}
+		return r.ID
+	}
+	water := add(models.Report{Category: "water", District: "Bo", Note: "burst pipe"})
+	roads := add(models.Report{Category: "roads", District: "Bo", Status: models.StatusResolved})
+	add(models.Report{Category: "water", District: "Kenema", WithdrawnAt: &now})
+	dup := add(models.Report{Category: "water", District: "Bo", DuplicateOf: &canon})
+
+	ids := func(q ReportQuery) []primitive.ObjectID {
+		t.Helper()
+		rs, err := m.List(ctx, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := m.Count(ctx, q)
+		if err != nil || (q.Skip == 0 && q.Limit == 0 && n != int64(len(rs))) {
+			t.Fatalf("Count = %d, %v; List returned %d", n, err, len(rs))
+		}
+		out := make([]primitive.ObjectID, len(rs))
+		for i, r := range rs {
+			out[i] = r.ID
+		}
+		return out
+	}
+	same := func(got []primitive.ObjectID, want ...primitive.ObjectID) bool {
+		if len(got) != len(want) {
+			return false
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	// newest id first; merged and withdrawn reports hidden by default
+	if got := ids(ReportQuery{}); !same(got, roads, water) {
+		t.Fatalf("default = %v", got)
+	}
+	if got := ids(ReportQuery{Category: Terms{In: []string{"water"}}}); !same(got, water) {
+		t.Fatalf("category = %v", got)
+	}
+	if got := ids(ReportQuery{Status: Terms{NotIn: []string{models.StatusResolved}}}); !same(got, water) {
+		t.Fatalf("status exclusion = %v", got)
+	}
+	if got := ids(ReportQuery{Status: Terms{In: []string{models.StatusOpen}}}); !same(got, water) {
+		t.Fatalf("legacy empty status as open = %v", got)
+	}
+	if got := ids(ReportQuery{DuplicateOf: []primitive.ObjectID{canon}}); !same(got, dup) {
+		t.Fatalf("duplicate_of = %v", got)
+	}
+	if got := ids(ReportQuery{IncludeMerged: true, IncludeWithdrawn: true}); len(got) != 4 {
+		t.Fatalf("include all = %v", got)
+	}
+	if got := ids(ReportQuery{Text: "pipes"}); !same(got, water) {
+		t.Fatalf("text = %v", got)
+	}
+	if got := ids(ReportQuery{Skip: 1, Limit: 1}); !same(got, water) {
+		t.Fatalf("skip/limit = %v", got)
+	}
+}
+
+func TestMemoryListCursors(t *testing.T) {
+	m := NewMemory()
+	ctx := context.Background()
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cats := []string{"roads", "water", "health", "roads", "power", "water", "roads"}
+	for i, c := range cats {
+		r := &models.Report{Category: c, CreatedAt: base.Add(time.Duration(i) * time.Hour), Confirmations: i % 3}
+		if err := m.Create(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, s := range []Sort{
+		{},
+		{Field: SortCreatedAt},
+		{Field: SortCategory},
+		{Field: SortCategory, Desc: true},
+		{Field: SortConfirmations, Desc: true},
+	} {
+		all, err := m.List(ctx, ReportQuery{Sort: s})
+		if err != nil || len(all) != len(cats) {
+			t.Fatalf("%+v: %d, %v", s, len(all), err)
+		}
+		for i := 1; i < len(all); i++ {
+			q := ReportQuery{Sort: s}
+			if q.less(all[i], all[i-1]) {
+				t.Fatalf("%+v: out of order at %d", s, i)
+			}
+		}
+
+		// walk the pages and expect the unpaged order back, without
+		// repeats, even though sort values tie
+		var paged []models.Report
+		q := ReportQuery{Sort: s, Limit: 2}
+		for {
+			page, err := m.List(ctx, q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			paged = append(paged, page...)
+			if len(page) < q.Limit {
+				break
+			}
+			last := page[len(page)-1]
+			q.After = &Position{Value: q.SortValue(last), ID: last.ID}
+		}
+		if len(paged) != len(all) {
+			t.Fatalf("%+v: paged %d of %d", s, len(paged), len(all))
+		}
+		for i := range all {
+			if paged[i].ID != all[i].ID {
+				t.Fatalf("%+v: page order differs at %d", s, i)
+			}
+		}
+	}
+}
@@ -0,0 +1,606 @@
+// path: repository/mongo.go
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"log"
+	"math"
+	"time"
+
+	"meniba/database"
+	"meniba/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Mongo is the ReportRepository backed by the "reports" collection.
+type Mongo struct {
+	db  *database.DB
+	col *mongo.Collection
+}
+
+// NewMongo returns a repository over db's reports collection.
+func NewMongo(db *database.DB) *Mongo {
+	return &Mongo{db: db, col: db.Col("reports")}
+}
+
+func (m *Mongo) Create(ctx context.Context, r *models.Report) error {
+	res, err := m.col.InsertOne(ctx, r)
+	if err != nil {
+		return err
+	}
+	r.ID = res.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (m *Mongo) Get(ctx context.Context, id primitive.ObjectID) (models.Report, error) {
+	var r models.Report
+	err := m.col.FindOne(ctx, bson.M{"_id": id}).Decode(&r)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return r, ErrNotFound
+	}
+	return r, err
+}
+
+func (m *Mongo) List(ctx context.Context, f ReportQuery) ([]models.Report, error) {
+	if f.Sort.Field != "" {
+		return m.listSorted(ctx, f)
+	}
+	filter := mongoFilter(f)
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}})
+	if f.Text != "" {
+		opts.SetSort(bson.D{{Key: "score", Value: bson.M{"$meta": "textScore"}}, {Key: "_id", Value: -1}})
+	} else if f.After != nil {
+		filter = bson.M{"$and": bson.A{filter, bson.M{"_id": bson.M{"$lt": f.After.ID}}}}
+	}
+	if f.Skip > 0 {
+		opts.SetSkip(int64(f.Skip))
+	}
+	if f.Limit > 0 {
+		opts.SetLimit(int64(f.Limit))
+	}
+	cur, err := m.col.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]models.Report, 0)
+	if err := cur.All(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// listSorted handles the non-default sorts: the key is computed into a
+// temporary _sort field so derived keys (captured_at falling back to
+// created_at, distance) sort and page like plain ones.
+func (m *Mongo) listSorted(ctx context.Context, f ReportQuery) ([]models.Report, error) {
+	dir := 1
+	cmp := "$gt"
+	if f.Sort.Desc {
+		dir, cmp = -1, "$lt"
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: mongoFilter(f)}},
+		{{Key: "$addFields", Value: bson.M{"_sort": sortExpr(f)}}},
+	}
+	if f.After != nil {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.M{"$or": bson.A{
+			bson.M{"_sort": bson.M{cmp: f.After.Value}},
+			bson.M{"_sort": f.After.Value, "_id": bson.M{cmp: f.After.ID}},
+		}}}})
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$sort", Value: bson.D{{Key: "_sort", Value: dir}, {Key: "_id", Value: dir}}}})
+	if f.Skip > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$skip", Value: f.Skip}})
+	}
+	if f.Limit > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: f.Limit}})
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$project", Value: bson.M{"_sort": 0}}})
+
+	cur, err := m.col.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]models.Report, 0)
+	if err := cur.All(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// sortExpr is the aggregation twin of ReportQuery.SortValue.
+func sortExpr(f ReportQuery) any {
+	switch f.Sort.Field {
+	case SortCreatedAt:
+		return "$created_at"
+	case SortCapturedAt:
+		return bson.M{"$ifNull": bson.A{"$captured_at", "$created_at"}}
+	case SortCategory:
+		return bson.M{"$ifNull": bson.A{"$category", ""}}
+	case SortConfirmations:
+		return bson.M{"$toDouble": bson.M{"$ifNull": bson.A{"$confirmations", 0}}}
+	case SortDistance:
+		if f.Near == nil {
+			return 0.0
+		}
+		k := math.Cos(f.Near.Lat * math.Pi / 180)
+		dx := bson.M{"$multiply": bson.A{bson.M{"$subtract": bson.A{"$lng", f.Near.Lng}}, k}}
+		dy := bson.M{"$subtract": bson.A{"$lat", f.Near.Lat}}
+		sq := bson.M{"$add": bson.A{bson.M{"$multiply": bson.A{dx, dx}}, bson.M{"$multiply": bson.A{dy, dy}}}}
+		return bson.M{"$round": bson.A{bson.M{"$multiply": bson.A{bson.M{"$sqrt": sq}, metresPerDegree}}, 0}}
+	}
+	return "$_id"
+}
+
+func (m *Mongo) Scan(ctx context.Context, f ReportQuery, fn func(models.Report) error) error {
+	cur, err := m.col.Find(ctx, mongoFilter(f))
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var r models.Report
+		if err := cur.Decode(&r); err != nil {
+			return err
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return cur.Err()
+}
+
+func (m *Mongo) Count(ctx context.Context, f ReportQuery) (int64, error) {
+	return m.col.CountDocuments(ctx, mongoFilter(f))
+}
+
+// Update does a read-modify-write guarded by Report.Version, so two
+// writers can't silently overwrite each other's changes.
+func (m *Mongo) Update(ctx context.Context, id primitive.ObjectID, mutate func(r *models.Report) error) (models.Report, error) {
+	for i := 0; i < maxUpdateRetries; i++ {
+		cur, err := m.Get(ctx, id)
+		if err != nil {
+			return cur, err
+		}
+		next := cur
+		if err := mutate(&next); err != nil {
+			if errors.Is(err, ErrNoChange) {
+				return cur, nil
+			}
+			return cur, err
+		}
+		next.ID = cur.ID
+		next.Version = cur.Version + 1
+		next.UpdatedAt = time.Now().UTC()
+
+		match := bson.M{"_id": id, "version": cur.Version}
+		if cur.Version == 0 {
+			// documents written before versioning have no field at all
+			match["version"] = bson.M{"$in": bson.A{nil, 0}}
+		}
+		res, err := m.col.ReplaceOne(ctx, match, next)
+		if err != nil {
+			return cur, err
+		}
+		if res.MatchedCount == 1 {
+			return next, nil
+		}
+	}
+	return models.Report{}, ErrConflict
+}
+
+func (m *Mongo) Delete(ctx context.Context, id primitive.ObjectID) error {
+	res, err := m.col.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m *Mongo) Stats(ctx context.Context, f ReportQuery) (ReportStats, error) {
+	group := func(field string, def any) bson.A {
+		return bson.A{
+			bson.M{"$group": bson.M{"_id": bson.M{"$ifNull": bson.A{"$" + field, def}}, "n": bson.M{"$sum": 1}}},
+		}
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: mongoFilter(f)}},
+		{{Key: "$facet", Value: bson.M{
+			"total":       bson.A{bson.M{"$count": "n"}},
+			"by_category": group("category", ""),
+			"by_status":   group("status", models.StatusOpen),
+			"by_district": group("district", ""),
+		}}},
+	}
+	cur, err := m.col.Aggregate(ctx, pipeline)
+	if err != nil {
+		return ReportStats{}, err
+	}
+	type bucket struct {
+		ID string `bson:"_id"`
+		N  int64  `bson:"n"`
+	}
+	var rows []struct {
+		Total      []struct{ N int64 } `bson:"total"`
+		ByCategory []bucket            `bson:"by_category"`
+		ByStatus   []bucket            `bson:"by_status"`
+		ByDistrict []bucket            `bson:"by_district"`
+	}
+	if err := cur.All(ctx, &rows); err != nil {
+		return ReportStats{}, err
+	}
+	st := newStats()
+	if len(rows) == 0 {
+		return st, nil
+	}
+	if len(rows[0].Total) > 0 {
+		st.Total = rows[0].Total[0].N
+	}
+	for _, b := range rows[0].ByCategory {
+		st.ByCategory[b.ID] += b.N
+	}
+	for _, b := range rows[0].ByStatus {
+		st.ByStatus[b.ID] += b.N
+	}
+	for _, b := range rows[0].ByDistrict {
+		st.ByDistrict[b.ID] += b.N
+	}
+	return st, nil
+}
+
+// publicPositionStages computes PublicPosition into _plat/_plng, and the
+// radius used into _r, for the documents matching f.
+func publicPositionStages(f ReportQuery) mongo.Pipeline {
+	radius := bson.M{"$cond": bson.A{
+		bson.M{"$gt": bson.A{"$privacy_radius_m", 0}}, "$privacy_radius_m", DefaultPrivacyRadiusM,
+	}}
+	cos := bson.M{"$max": bson.A{bson.M{"$cos": bson.M{"$degreesToRadians": "$lat"}}, 0.01}}
+	snap := func(v any, d any) bson.M {
+		return bson.M{"$multiply": bson.A{bson.M{"$add": bson.A{bson.M{"$floor": bson.M{"$divide": bson.A{v, d}}}, 0.5}}, d}}
+	}
+	return mongo.Pipeline{
+		{{Key: "$match", Value: mongoFilter(f)}},
+		{{Key: "$addFields", Value: bson.M{
+			"_r":    radius,
+			"_dlat": bson.M{"$divide": bson.A{radius, metresPerDegree}},
+			"_dlng": bson.M{"$divide": bson.A{radius, bson.M{"$multiply": bson.A{metresPerDegree, cos}}}},
+		}}},
+		{{Key: "$addFields", Value: bson.M{
+			"_plat": snap("$lat", "$_dlat"),
+			"_plng": snap("$lng", "$_dlng"),
+		}}},
+	}
+}
+
+// Clusters runs the PublicPosition snapping and grid grouping in the
+// database, so only one row per cell comes back.
+func (m *Mongo) Clusters(ctx context.Context, f ReportQuery, cellDeg float64) ([]Cluster, error) {
+	cell := func(v string) bson.M {
+		return bson.M{"$floor": bson.M{"$divide": bson.A{v, cellDeg}}}
+	}
+	pipeline := append(publicPositionStages(f),
+		bson.D{{Key: "$group", Value: bson.M{
+			"_id":    bson.M{"x": cell("$_plng"), "y": cell("$_plat"), "c": bson.M{"$ifNull": bson.A{"$category", ""}}},
+			"n":      bson.M{"$sum": 1},
+			"sumLat": bson.M{"$sum": "$_plat"},
+			"sumLng": bson.M{"$sum": "$_plng"},
+			"r":      bson.M{"$max": "$_r"},
+			"first":  bson.M{"$first": "$_id"},
+		}}},
+		bson.D{{Key: "$group", Value: bson.M{
+			"_id":    bson.M{"x": "$_id.x", "y": "$_id.y"},
+			"n":      bson.M{"$sum": "$n"},
+			"sumLat": bson.M{"$sum": "$sumLat"},
+			"sumLng": bson.M{"$sum": "$sumLng"},
+			"r":      bson.M{"$max": "$r"},
+			"first":  bson.M{"$first": "$first"},
+			"cats":   bson.M{"$push": bson.M{"k": "$_id.c", "n": "$n"}},
+		}}},
+	)
+	cur, err := m.col.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		N      int64              `bson:"n"`
+		SumLat float64            `bson:"sumLat"`
+		SumLng float64            `bson:"sumLng"`
+		R      int                `bson:"r"`
+		First  primitive.ObjectID `bson:"first"`
+		Cats   []struct {
+			K string `bson:"k"`
+			N int64  `bson:"n"`
+		} `bson:"cats"`
+	}
+	if err := cur.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	out := make([]Cluster, 0, len(rows))
+	for _, row := range rows {
+		c := Cluster{
+			Lat:        row.SumLat / float64(row.N),
+			Lng:        row.SumLng / float64(row.N),
+			Count:      row.N,
+			ByCategory: map[string]int64{},
+			RadiusM:    row.R,
+		}
+		for _, cat := range row.Cats {
+			c.ByCategory[cat.K] += cat.N
+		}
+		if row.N == 1 {
+			c.ReportID = row.First.Hex()
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// Grid groups by gridCell of the snapped position in the database.
+func (m *Mongo) Grid(ctx context.Context, f ReportQuery, lngBits, latBits int) ([]GridCount, error) {
+	cell := func(v string, offset, span float64, bits int) bson.M {
+		n := float64(int64(1) << bits)
+		return bson.M{"$max": bson.A{0, bson.M{"$min": bson.A{n - 1, bson.M{"$floor": bson.M{"$multiply": bson.A{
+			bson.M{"$divide": bson.A{bson.M{"$add": bson.A{v, offset}}, span}}, n,
+		}}}}}}}
+	}
+	pipeline := append(publicPositionStages(f),
+		bson.D{{Key: "$group", Value: bson.M{
+			"_id": bson.M{"x": cell("$_plng", 180, 360, lngBits), "y": cell("$_plat", 90, 180, latBits)},
+			"n":   bson.M{"$sum": 1},
+		}}},
+	)
+	cur, err := m.col.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		ID struct {
+			X float64 `bson:"x"`
+			Y float64 `bson:"y"`
+		} `bson:"_id"`
+		N int64 `bson:"n"`
+	}
+	if err := cur.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	out := make([]GridCount, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, GridCount{X: int64(row.ID.X), Y: int64(row.ID.Y), N: row.N})
+	}
+	return out, nil
+}
+
+// Watch opens a change stream on reports. Tokens are base64url-encoded
+// resume tokens, so they survive API restarts.
+func (m *Mongo) Watch(ctx context.Context, resumeAfter string) (<-chan ReportChange, error) {
+	if !m.db.ChangeStreamsSupported() {
+		return nil, ErrWatchUnsupported
+	}
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{
+		"operationType": bson.M{"$in": []string{"insert", "update", "replace"}},
+	}}}}
+	newOpts := func() *options.ChangeStreamOptions {
+		return options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	}
+
+	var cs *mongo.ChangeStream
+	var err error
+	if tok, ok := decodeResumeToken(resumeAfter); ok {
+		cs, err = m.col.Watch(ctx, pipeline, newOpts().SetResumeAfter(tok))
+		if err != nil {
+			// why: token may have rolled off the oplog; start live instead
+			log.Printf("repository: resume failed, starting live: %v", err)
+			cs, err = m.col.Watch(ctx, pipeline, newOpts())
+		}
+	} else {
+		cs, err = m.col.Watch(ctx, pipeline, newOpts())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan ReportChange, 16)
+	go func() {
+		defer close(out)
+		defer cs.Close(context.Background())
+		for cs.Next(ctx) {
+			var ce struct {
+				OperationType string         `bson:"operationType"`
+				FullDocument  *models.Report `bson:"fullDocument"`
+			}
+			if err := cs.Decode(&ce); err != nil || ce.FullDocument == nil {
+				continue
+			}
+			ch := ReportChange{
+				Token:   base64.RawURLEncoding.EncodeToString(cs.ResumeToken()),
+				Created: ce.OperationType == "insert",
+				Report:  *ce.FullDocument,
+			}
+			select {
+			case out <- ch:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err := cs.Err(); err != nil && ctx.Err() == nil {
+			log.Printf("repository: change stream ended: %v", err)
+		}
+	}()
+	return out, nil
+}
+
+func decodeResumeToken(tok string) (bson.Raw, bool) {
+	if tok == "" {
+		return nil, false
+	}
+	b, err := base64.RawURLEncoding.DecodeString(tok)
+	if err != nil {
+		return nil, false
+	}
+	raw := bson.Raw(b)
+	if raw.Validate() != nil {
+		return nil, false
+	}
+	return raw, true
+}
+
+// mongoFilter compiles f into a query; keep it in step with
+// ReportQuery.Match. Every condition is its own $and clause so two
+// conditions on the same field (or two $or) never collide.
+func mongoFilter(f ReportQuery) bson.M {
+	var and []bson.M
+
+	if f.Text != "" {
+		and = append(and, bson.M{"$text": bson.M{"$search": f.Text, "$language": "english"}})
+	}
+	if len(f.IDs) > 0 {
+		and = append(and, bson.M{"_id": bson.M{"$in": f.IDs}})
+	}
+	and = appendTerms(and, "category", f.Category, nil)
+	and = appendTerms(and, "district", f.District, nil)
+	and = appendTerms(and, "chiefdom", f.Chiefdom, nil)
+	and = appendTerms(and, "region", f.Region, nil)
+	and = appendTerms(and, "status", f.Status, statusValues)
+	and = appendTerms(and, "geo_method", f.GeoMethod, nil)
+	if f.Anonymous != nil {
+		if *f.Anonymous {
+			and = append(and, bson.M{"anonymous": true})
+		} else {
+			and = append(and, bson.M{"anonymous": bson.M{"$ne": true}})
+		}
+	}
+	if f.CreatedFrom != nil {
+		and = append(and, bson.M{"created_at": bson.M{"$gte": *f.CreatedFrom}})
+	}
+	if f.CreatedTo != nil {
+		and = append(and, bson.M{"created_at": bson.M{"$lte": *f.CreatedTo}})
+	}
+	if f.HasMedia != nil {
+		if *f.HasMedia {
+			and = append(and, bson.M{"$or": []bson.M{
+				{"voice_url": bson.M{"$exists": true, "$ne": ""}},
+				{"photo_urls.0": bson.M{"$exists": true}},
+			}})
+		} else {
+			and = append(and,
+				bson.M{"$or": []bson.M{
+					{"voice_url": bson.M{"$exists": false}},
+					{"voice_url": ""},
+				}},
+				bson.M{"photo_urls.0": bson.M{"$exists": false}},
+			)
+		}
+	}
+	if f.HasVoice != nil {
+		if *f.HasVoice {
+			and = append(and, bson.M{"voice_url": bson.M{"$exists": true, "$ne": ""}})
+		} else {
+			and = append(and, bson.M{"$or": []bson.M{
+				{"voice_url": bson.M{"$exists": false}},
+				{"voice_url": ""},
+			}})
+		}
+	}
+	if f.HasPhoto != nil {
+		and = append(and, bson.M{"photo_urls.0": bson.M{"$exists": *f.HasPhoto}})
+	}
+	if f.Bbox != nil {
+		and = append(and,
+			bson.M{"lat": bson.M{"$gte": f.Bbox.MinLat, "$lte": f.Bbox.MaxLat}},
+			bson.M{"lng": bson.M{"$gte": f.Bbox.MinLng, "$lte": f.Bbox.MaxLng}},
+		)
+	}
+	if len(f.DuplicateOf) > 0 {
+		and = append(and, bson.M{"duplicate_of": bson.M{"$in": f.DuplicateOf}})
+	} else if !f.IncludeMerged {
+		and = append(and, bson.M{"duplicate_of": bson.M{"$exists": false}})
+	}
+	if len(f.Agencies) > 0 {
+		and = append(and, bson.M{"agencies": bson.M{"$in": f.Agencies}})
+	}
+	if !f.IncludeWithdrawn {
+		and = append(and, bson.M{"withdrawn_at": bson.M{"$exists": false}})
+	}
+	if f.ReporterID != nil {
+		and = append(and, bson.M{"reporter_id": *f.ReporterID})
+	}
+	if len(f.MediaURLs) > 0 {
+		in := bson.M{"$in": f.MediaURLs}
+		and = append(and, bson.M{"$or": []bson.M{
+			{"voice_url": in},
+			{"photo_urls": in},
+			{"revisions.before.voice_url": in},
+			{"revisions.before.photo_urls": in},
+			{"status_history.photo_urls": in},
+		}})
+	}
+	if f.Assigned != nil {
+		and = append(and, bson.M{"agencies.0": bson.M{"$exists": *f.Assigned}})
+	}
+	if f.Overdue != nil {
+		if *f.Overdue {
+			and = append(and, overdueFilter(time.Now()))
+		} else {
+			and = append(and, bson.M{"$nor": bson.A{overdueFilter(time.Now())}})
+		}
+	}
+
+	if len(and) == 0 {
+		return bson.M{}
+	}
+	return bson.M{"$and": and}
+}
+
+// appendTerms adds the $in/$nin clauses for t on field. values maps the
+// filter values to what is stored (nil = as is, with "" also matching a
+// missing field, since the string fields are omitempty).
+func appendTerms(and []bson.M, field string, t Terms, values func([]string) bson.A) []bson.M {
+	if values == nil {
+		values = func(v []string) bson.A {
+			out := bson.A{}
+			for _, s := range v {
+				out = append(out, s)
+				if s == "" {
+					out = append(out, nil)
+				}
+			}
+			return out
+		}
+	}
+	if len(t.In) > 0 {
+		and = append(and, bson.M{field: bson.M{"$in": values(t.In)}})
+	}
+	if len(t.NotIn) > 0 {
+		and = append(and, bson.M{field: bson.M{"$nin": values(t.NotIn)}})
+	}
+	return and
+}
+
+// overdueFilter mirrors models.Report.Overdue.
+func overdueFilter(now time.Time) bson.M {
+	return bson.M{"$or": bson.A{
+		bson.M{"sla.ack_due_at": bson.M{"$lte": now}, "status": bson.M{"$in": statusValues([]string{models.StatusOpen})}},
+		bson.M{"sla.resolve_due_at": bson.M{"$lte": now}, "status": bson.M{"$nin": bson.A{models.StatusResolved, models.StatusRejected}}},
+	}}
+}
+
+// statusValues adds nil next to "open" so legacy documents match.
+func statusValues(st []string) bson.A {
+	out := bson.A{}
+	for _, s := range st {
+		out = append(out, s)
+		if s == models.StatusOpen {
+			out = append(out, nil)
+		}
+	}
+	return out
+}
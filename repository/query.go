@@ -0,0 +1,412 @@
+// path: repository/query.go
+package repository
+
+import (
+	"bytes"
+	"math"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"meniba/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const maxTextQuery = 200
+
+// QueryError reports a filter parameter that couldn't be parsed.
+type QueryError struct {
+	Param  string
+	Reason string
+}
+
+func (e *QueryError) Error() string {
+	return "invalid " + e.Param + ": " + e.Reason
+}
+
+// ParseReportQuery reads the filter grammar shared by the list, export,
+// GeoJSON, stats and stream endpoints:
+//
+//	q                               full-text search over note, area_label and address
+//	category, district, chiefdom,   comma-separated values; prefix a value
+//	region, status, geo_method      with ! to exclude it (status=!resolved,!rejected)
+//	anonymous                       true|false (or !true)
+//	start_date, end_date            RFC3339, inclusive
+//	has_media, has_voice, has_photo true|false
+//	assigned, overdue               true|false
+//	bbox                            minLng,minLat,maxLng,maxLat
+//	include_merged                  true to include merged duplicates
+//	agency                          comma-separated agency ids (assigned to any)
+//	sort                            created_at, captured_at, category, distance
+//	                                or confirmations; prefix - for descending
+//	near                            lat,lng; required for sort=distance
+//
+// Paging (limit, cursor) is left to the caller.
+func ParseReportQuery(v url.Values) (ReportQuery, error) {
+	var q ReportQuery
+
+	q.Text = strings.TrimSpace(v.Get("q"))
+	if len(q.Text) > maxTextQuery {
+		return q, &QueryError{"q", "too long"}
+	}
+	q.Category = parseTerms(v["category"])
+	q.District = parseTerms(v["district"])
+	q.Chiefdom = parseTerms(v["chiefdom"])
+	q.Region = parseTerms(v["region"])
+	q.GeoMethod = parseTerms(v["geo_method"])
+	q.Status = parseTerms(v["status"])
+	for _, s := range append(q.Status.In, q.Status.NotIn...) {
+		if !models.ValidStatus(s) {
+			return q, &QueryError{"status", "unknown status " + s}
+		}
+	}
+	if s := v.Get("anonymous"); s != "" {
+		neg := strings.HasPrefix(strings.TrimSpace(s), "!")
+		b, ok := parseBool(strings.TrimPrefix(strings.TrimSpace(s), "!"))
+		if !ok {
+			return q, &QueryError{"anonymous", "want true or false"}
+		}
+		b = b != neg
+		q.Anonymous = &b
+	}
+	if s := v.Get("start_date"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return q, &QueryError{"start_date", "must be RFC3339"}
+		}
+		q.CreatedFrom = &t
+	}
+	if s := v.Get("end_date"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return q, &QueryError{"end_date", "must be RFC3339"}
+		}
+		q.CreatedTo = &t
+	}
+	if q.CreatedFrom != nil && q.CreatedTo != nil && q.CreatedTo.Before(*q.CreatedFrom) {
+		return q, &QueryError{"end_date", "must not be before start_date"}
+	}
+	for _, f := range []struct {
+		param string
+		dst   **bool
+	}{
+		{"has_media", &q.HasMedia},
+		{"has_voice", &q.HasVoice},
+		{"has_photo", &q.HasPhoto},
+		{"assigned", &q.Assigned},
+		{"overdue", &q.Overdue},
+	} {
+		if s := v.Get(f.param); s != "" {
+			b, ok := parseBool(s)
+			if !ok {
+				return q, &QueryError{f.param, "want true or false"}
+			}
+			*f.dst = &b
+		}
+	}
+	if s := v.Get("bbox"); s != "" {
+		bb, err := ParseBbox(s)
+		if err != nil {
+			return q, err
+		}
+		q.Bbox = &bb
+	}
+	if s := v.Get("include_merged"); s != "" {
+		b, ok := parseBool(s)
+		if !ok {
+			return q, &QueryError{"include_merged", "want true or false"}
+		}
+		// merged duplicates are hidden unless explicitly requested
+		q.IncludeMerged = b
+	}
+	agency := parseTerms(v["agency"])
+	if len(agency.NotIn) > 0 {
+		return q, &QueryError{"agency", "exclusion is not supported"}
+	}
+	for _, s := range agency.In {
+		id, err := primitive.ObjectIDFromHex(s)
+		if err != nil {
+			return q, &QueryError{"agency", "invalid id " + s}
+		}
+		q.Agencies = append(q.Agencies, id)
+	}
+	if s := strings.TrimSpace(v.Get("near")); s != "" {
+		p, err := parsePoint(s)
+		if err != nil {
+			return q, err
+		}
+		q.Near = &p
+	}
+	if s := strings.TrimSpace(v.Get("sort")); s != "" {
+		q.Sort.Desc = strings.HasPrefix(s, "-")
+		q.Sort.Field = strings.TrimPrefix(s, "-")
+		switch q.Sort.Field {
+		case SortCreatedAt, SortCapturedAt, SortCategory, SortConfirmations:
+		case SortDistance:
+			if q.Near == nil {
+				return q, &QueryError{"sort", "distance needs near=lat,lng"}
+			}
+		default:
+			return q, &QueryError{"sort", "unknown field " + q.Sort.Field}
+		}
+	}
+	return q, nil
+}
+
+func parsePoint(s string) (Point, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return Point{}, &QueryError{"near", "need lat,lng"}
+	}
+	lat, err1 := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	lng, err2 := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err1 != nil || err2 != nil || lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		return Point{}, &QueryError{"near", "need lat,lng"}
+	}
+	return Point{Lat: lat, Lng: lng}, nil
+}
+
+// parseTerms splits comma-separated (and repeated) values into included
+// and excluded ("!value") sets.
+func parseTerms(params []string) Terms {
+	var t Terms
+	for _, p := range params {
+		for _, s := range strings.Split(p, ",") {
+			s = strings.TrimSpace(s)
+			if neg := strings.HasPrefix(s, "!"); neg {
+				if s = strings.TrimSpace(s[1:]); s != "" {
+					t.NotIn = append(t.NotIn, s)
+				}
+			} else if s != "" {
+				t.In = append(t.In, s)
+			}
+		}
+	}
+	return t
+}
+
+// ParseBbox reads "minLng,minLat,maxLng,maxLat".
+func ParseBbox(s string) (Bbox, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return Bbox{}, &QueryError{"bbox", "need minLng,minLat,maxLng,maxLat"}
+	}
+	var n [4]float64
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return Bbox{}, &QueryError{"bbox", "not a number: " + p}
+		}
+		n[i] = f
+	}
+	bb := Bbox{MinLng: n[0], MinLat: n[1], MaxLng: n[2], MaxLat: n[3]}
+	if bb.MaxLng < bb.MinLng || bb.MaxLat < bb.MinLat {
+		return Bbox{}, &QueryError{"bbox", "max must be >= min"}
+	}
+	return bb, nil
+}
+
+// parseBool is strict, so a typo in a filter is an error rather than
+// silently meaning false.
+func parseBool(s string) (bool, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "1", "true", "yes", "y", "on":
+		return true, true
+	case "0", "false", "no", "n", "off":
+		return false, true
+	}
+	return false, false
+}
+
+// Match reports whether r satisfies q. It is the in-memory twin of
+// mongoFilter, used by Memory and to filter live event streams.
+func (q ReportQuery) Match(r models.Report) bool {
+	if len(q.IDs) > 0 && !containsID(q.IDs, r.ID) {
+		return false
+	}
+	if q.Text != "" && parseText(q.Text).score(r) == 0 {
+		return false
+	}
+	if !q.Category.match(r.Category) || !q.District.match(r.District) ||
+		!q.Chiefdom.match(r.Chiefdom) || !q.Region.match(r.Region) ||
+		!q.Status.match(r.CurrentStatus()) || !q.GeoMethod.match(r.GeoMethod) {
+		return false
+	}
+	if q.Anonymous != nil && r.Anonymous != *q.Anonymous {
+		return false
+	}
+	if q.CreatedFrom != nil && r.CreatedAt.Before(*q.CreatedFrom) {
+		return false
+	}
+	if q.CreatedTo != nil && r.CreatedAt.After(*q.CreatedTo) {
+		return false
+	}
+	if q.HasMedia != nil {
+		has := r.VoiceURL != "" || len(r.PhotoURLs) > 0
+		if has != *q.HasMedia {
+			return false
+		}
+	}
+	if q.HasVoice != nil && (r.VoiceURL != "") != *q.HasVoice {
+		return false
+	}
+	if q.HasPhoto != nil && (len(r.PhotoURLs) > 0) != *q.HasPhoto {
+		return false
+	}
+	if b := q.Bbox; b != nil {
+		if r.Lat < b.MinLat || r.Lat > b.MaxLat || r.Lng < b.MinLng || r.Lng > b.MaxLng {
+			return false
+		}
+	}
+	if len(q.DuplicateOf) > 0 {
+		if r.DuplicateOf == nil || !containsID(q.DuplicateOf, *r.DuplicateOf) {
+			return false
+		}
+	} else if !q.IncludeMerged && r.DuplicateOf != nil {
+		return false
+	}
+	if !q.IncludeWithdrawn && r.WithdrawnAt != nil {
+		return false
+	}
+	if q.ReporterID != nil && (r.ReporterID == nil || *r.ReporterID != *q.ReporterID) {
+		return false
+	}
+	if len(q.MediaURLs) > 0 && !referencesAny(r, q.MediaURLs) {
+		return false
+	}
+	if q.Assigned != nil && (len(r.Agencies) > 0) != *q.Assigned {
+		return false
+	}
+	if q.Overdue != nil && r.Overdue(time.Now()) != *q.Overdue {
+		return false
+	}
+	if len(q.Agencies) > 0 {
+		assigned := false
+		for _, id := range r.Agencies {
+			if containsID(q.Agencies, id) {
+				assigned = true
+				break
+			}
+		}
+		if !assigned {
+			return false
+		}
+	}
+	return true
+}
+
+// referencesAny reports whether r points at any of urls: its current
+// media, an earlier revision's or a status change's photos.
+func referencesAny(r models.Report, urls []string) bool {
+	any := func(list ...string) bool {
+		for _, u := range list {
+			if u != "" && slices.Contains(urls, u) {
+				return true
+			}
+		}
+		return false
+	}
+	if any(r.VoiceURL) || any(r.PhotoURLs...) {
+		return true
+	}
+	for _, rev := range r.Revisions {
+		if any(rev.Before.VoiceURL) || any(rev.Before.PhotoURLs...) {
+			return true
+		}
+	}
+	for _, sc := range r.StatusHistory {
+		if any(sc.PhotoURLs...) {
+			return true
+		}
+	}
+	return false
+}
+
+// metresPerDegree is the length of one degree of latitude.
+const metresPerDegree = 111195.08
+
+// SortValue is r's key under q.Sort, as stored in a Position: time.Time
+// for the date fields, string for category and float64 for the numbers.
+// It returns nil for the default sort, which only needs the id.
+func (q ReportQuery) SortValue(r models.Report) any {
+	switch q.Sort.Field {
+	case SortCreatedAt:
+		return r.CreatedAt
+	case SortCapturedAt:
+		if r.CapturedAt != nil {
+			return *r.CapturedAt
+		}
+		return r.CreatedAt
+	case SortCategory:
+		return r.Category
+	case SortConfirmations:
+		return float64(r.Confirmations)
+	case SortDistance:
+		if q.Near == nil {
+			return 0.0
+		}
+		// why: an equirectangular approximation, rounded to whole metres,
+		// computed step for step like the Mongo expression so cursor
+		// values compare equal on both sides.
+		k := math.Cos(q.Near.Lat * math.Pi / 180)
+		dx := (r.Lng - q.Near.Lng) * k
+		dy := r.Lat - q.Near.Lat
+		return math.RoundToEven(math.Sqrt(dx*dx+dy*dy) * metresPerDegree)
+	}
+	return nil
+}
+
+// compareSortValues orders two SortValue results of the same field.
+func compareSortValues(a, b any) int {
+	switch x := a.(type) {
+	case time.Time:
+		if y, ok := b.(time.Time); ok {
+			return x.Compare(y)
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y)
+		}
+	case float64:
+		if y, ok := b.(float64); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+// less reports whether a sorts before b under q.Sort (ties by id).
+func (q ReportQuery) less(a, b models.Report) bool {
+	c := compareSortValues(q.SortValue(a), q.SortValue(b))
+	if c == 0 {
+		c = bytes.Compare(a.ID[:], b.ID[:])
+	}
+	if q.Sort.Desc || q.Sort.Field == "" {
+		return c > 0
+	}
+	return c < 0
+}
+
+// afterPosition reports whether r comes after q.After in sort order.
+func (q ReportQuery) afterPosition(r models.Report) bool {
+	if q.After == nil {
+		return true
+	}
+	c := compareSortValues(q.SortValue(r), q.After.Value)
+	if c == 0 {
+		c = bytes.Compare(r.ID[:], q.After.ID[:])
+	}
+	if q.Sort.Desc || q.Sort.Field == "" {
+		return c < 0
+	}
+	return c > 0
+}
@@ -0,0 +1,380 @@
+package repository
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"meniba/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// mongoMatch evaluates the subset of the MongoDB query language that
+// mongoFilter emits against a report as stored, so the filter can be
+// checked against ReportQuery.Match without a server. Both sides go
+// through BSON, so times, ids and missing fields compare as in Mongo.
+func mongoMatch(t *testing.T, r models.Report, filter bson.M) bool {
+	t.Helper()
+	var doc, f bson.M
+	for _, c := range []struct {
+		in  any
+		out *bson.M
+	}{{r, &doc}, {filter, &f}} {
+		raw, err := bson.Marshal(c.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := bson.Unmarshal(raw, c.out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return evalDoc(t, doc, f)
+}
+
+func evalDoc(t *testing.T, doc bson.M, f bson.M) bool {
+	for k, v := range f {
+		switch k {
+		case "$and", "$or", "$nor":
+			any := false
+			for _, sub := range v.(bson.A) {
+				ok := evalDoc(t, doc, asM(t, sub))
+				if k == "$and" && !ok {
+					return false
+				}
+				any = any || ok
+			}
+			if (k == "$or" && !any) || (k == "$nor" && any) {
+				return false
+			}
+		default:
+			if !evalField(t, doc, k, v) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func evalField(t *testing.T, doc bson.M, path string, cond any) bool {
+	vals, exists := lookup(doc, path)
+	ops, isOps := cond.(bson.M)
+	if !isOps || !hasOps(ops) {
+		return eqAny(vals, exists, cond)
+	}
+	for op, arg := range ops {
+		var ok bool
+		switch op {
+		case "$exists":
+			ok = exists == arg.(bool)
+		case "$ne":
+			ok = !eqAny(vals, exists, arg)
+		case "$in", "$nin":
+			for _, x := range arg.(bson.A) {
+				if eqAny(vals, exists, x) {
+					ok = true
+					break
+				}
+			}
+			if op == "$nin" {
+				ok = !ok
+			}
+		case "$gt", "$gte", "$lt", "$lte":
+			for _, v := range vals {
+				c, comparable := compareBSON(v, arg)
+				if comparable && ((op == "$gt" && c > 0) || (op == "$gte" && c >= 0) ||
+					(op == "$lt" && c < 0) || (op == "$lte" && c <= 0)) {
+					ok = true
+					break
+				}
+			}
+		default:
+			t.Fatalf("mongoMatch: unsupported operator %s", op)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func hasOps(m bson.M) bool {
+	for k := range m {
+		if strings.HasPrefix(k, "$") {
+			return true
+		}
+	}
+	return false
+}
+
+func asM(t *testing.T, v any) bson.M {
+	switch x := v.(type) {
+	case bson.M:
+		return x
+	case bson.D:
+		return x.Map()
+	}
+	t.Fatalf("mongoMatch: not a document: %T", v)
+	return nil
+}
+
+// lookup resolves a dotted path. Array fields yield their elements (and
+// the array itself); a numeric segment indexes into an array, any other
+// applies the rest of the path to each element, as Mongo does.
+func lookup(doc bson.M, path string) (vals []any, exists bool) {
+	return lookupIn(doc, strings.Split(path, "."))
+}
+
+func lookupIn(cur any, path []string) (vals []any, exists bool) {
+	if len(path) == 0 {
+		if a, ok := cur.(bson.A); ok {
+			return append(append([]any{}, a...), a), true
+		}
+		return []any{cur}, true
+	}
+	switch x := cur.(type) {
+	case bson.M:
+		v, ok := x[path[0]]
+		if !ok {
+			return nil, false
+		}
+		return lookupIn(v, path[1:])
+	case bson.A:
+		if i, err := strconv.Atoi(path[0]); err == nil {
+			if i >= len(x) {
+				return nil, false
+			}
+			return lookupIn(x[i], path[1:])
+		}
+		for _, el := range x {
+			if v, ok := lookupIn(el, path); ok {
+				vals, exists = append(vals, v...), true
+			}
+		}
+		return vals, exists
+	}
+	return nil, false
+}
+
+func eqAny(vals []any, exists bool, x any) bool {
+	if x == nil && !exists {
+		return true
+	}
+	for _, v := range vals {
+		if c, ok := compareBSON(v, x); ok && c == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func compareBSON(a, b any) (int, bool) {
+	if a == nil || b == nil {
+		if a == nil && b == nil {
+			return 0, true
+		}
+		return 0, false
+	}
+	if fa, ok := num(a); ok {
+		if fb, ok := num(b); ok {
+			switch {
+			case fa < fb:
+				return -1, true
+			case fa > fb:
+				return 1, true
+			}
+			return 0, true
+		}
+		return 0, false
+	}
+	switch x := a.(type) {
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), true
+		}
+	case bool:
+		if y, ok := b.(bool); ok && x == y {
+			return 0, true
+		}
+		return 1, false
+	case primitive.DateTime:
+		if y, ok := b.(primitive.DateTime); ok {
+			return compareBSON(int64(x), int64(y))
+		}
+	case primitive.ObjectID:
+		if y, ok := b.(primitive.ObjectID); ok {
+			return bytes.Compare(x[:], y[:]), true
+		}
+	}
+	return 0, false
+}
+
+func num(v any) (float64, bool) {
+	switch x := v.(type) {
+	case int32:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case float64:
+		return x, true
+	}
+	return 0, false
+}
+
+// twinFixtures covers every field ReportQuery filters on, including the
+// legacy and zero-value shapes (no status, no version, empty slices).
+func twinFixtures() []models.Report {
+	now := time.Now().UTC()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	canon, agency, other, reporter := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 12, 0, 0, 0, time.UTC) }
+	return []models.Report{
+		{ID: primitive.NewObjectID(), Category: "water", District: "Bo", Chiefdom: "Kakua", Region: "Southern",
+			Lat: 7.96, Lng: -11.74, CreatedAt: day(1), GeoMethod: "gps"},
+		{ID: primitive.NewObjectID(), Category: "roads", District: "Western Area Urban", Status: models.StatusResolved,
+			Lat: 8.48, Lng: -13.23, CreatedAt: day(2), Anonymous: true, VoiceURL: "/uploads/v.ogg",
+			Agencies: []primitive.ObjectID{agency}},
+		{ID: primitive.NewObjectID(), Category: "water", District: "Kenema", Status: models.StatusOpen,
+			CreatedAt: day(3), PhotoURLs: []string{"/uploads/p.jpg"}, ReporterID: &reporter,
+			Agencies: []primitive.ObjectID{other, agency},
+			SLA:      &models.SLAState{AckDueAt: &past, ResolveDueAt: &future}},
+		{ID: primitive.NewObjectID(), Category: "health", District: "Bo", Status: models.StatusInProgress,
+			CreatedAt: day(4), SLA: &models.SLAState{AckDueAt: &past, ResolveDueAt: &past}, GeoMethod: "gazetteer"},
+		{ID: primitive.NewObjectID(), Category: "water", District: "Bo", Status: models.StatusAcknowledged,
+			CreatedAt: day(5), DuplicateOf: &canon, Lat: 7.95, Lng: -11.75},
+		{ID: primitive.NewObjectID(), Category: "power", District: "Kenema", Status: models.StatusRejected,
+			CreatedAt: day(6), WithdrawnAt: &now, SLA: &models.SLAState{ResolveDueAt: &past},
+			VoiceURL: "/uploads/w.ogg", PhotoURLs: []string{"/uploads/q.jpg"}},
+		{ID: primitive.NewObjectID(), Category: "water", CreatedAt: day(7), SLA: &models.SLAState{AckDueAt: &future},
+			Revisions: []models.Revision{
+				{Kind: models.RevisionEdit, Before: models.ReportSnapshot{VoiceURL: "/uploads/old.ogg"}},
+				{Kind: models.RevisionEdit, Before: models.ReportSnapshot{PhotoURLs: []string{"/uploads/old.jpg"}}},
+			},
+			StatusHistory: []models.StatusChange{{To: models.StatusAcknowledged, PhotoURLs: []string{"/uploads/crew.jpg"}}}},
+	}
+}
+
+func TestMatchAgreesWithMongoFilter(t *testing.T) {
+	docs := twinFixtures()
+	yes, no := true, false
+	from, to := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 5, 12, 0, 0, 0, time.UTC)
+	canon := *docs[4].DuplicateOf
+	agency := docs[1].Agencies[0]
+
+	queries := map[string]ReportQuery{
+		"none":              {},
+		"ids":               {IDs: []primitive.ObjectID{docs[0].ID, docs[4].ID}},
+		"category":          {Category: Terms{In: []string{"water", "health"}}},
+		"category excluded": {Category: Terms{NotIn: []string{"water"}}},
+		"district in+out":   {District: Terms{In: []string{"Bo", "Kenema"}, NotIn: []string{"Kenema"}}},
+		"no district":       {District: Terms{In: []string{""}}},
+		"some district":     {District: Terms{NotIn: []string{""}}},
+		"chiefdom":          {Chiefdom: Terms{In: []string{"Kakua"}}},
+		"region excluded":   {Region: Terms{NotIn: []string{"Southern"}}},
+		"status open":       {Status: Terms{In: []string{models.StatusOpen}}},
+		"status not open":   {Status: Terms{NotIn: []string{models.StatusOpen}}},
+		"status closed":     {Status: Terms{In: []string{models.StatusResolved, models.StatusRejected}}, IncludeWithdrawn: true},
+		"geo method":        {GeoMethod: Terms{NotIn: []string{"gps"}}},
+		"anonymous":         {Anonymous: &yes},
+		"not anonymous":     {Anonymous: &no},
+		"created range":     {CreatedFrom: &from, CreatedTo: &to},
+		"has media":         {HasMedia: &yes, IncludeWithdrawn: true},
+		"no media":          {HasMedia: &no},
+		"has voice":         {HasVoice: &yes},
+		"no voice":          {HasVoice: &no, IncludeWithdrawn: true},
+		"has photo":         {HasPhoto: &yes},
+		"no photo":          {HasPhoto: &no},
+		"bbox":              {Bbox: &Bbox{MinLng: -12, MinLat: 7.9, MaxLng: -11.7, MaxLat: 8}, IncludeMerged: true},
+		"duplicate of":      {DuplicateOf: []primitive.ObjectID{canon}},
+		"include merged":    {IncludeMerged: true},
+		"include withdrawn": {IncludeWithdrawn: true},
+		"include both":      {IncludeMerged: true, IncludeWithdrawn: true},
+		"agency":            {Agencies: []primitive.ObjectID{agency}},
+		"assigned":          {Assigned: &yes},
+		"unassigned":        {Assigned: &no},
+		"overdue":           {Overdue: &yes, IncludeWithdrawn: true},
+		"not overdue":       {Overdue: &no, IncludeWithdrawn: true},
+		"reporter":          {ReporterID: docs[2].ReporterID},
+		"media url":         {MediaURLs: []string{"/uploads/p.jpg", "/uploads/v.ogg"}},
+		"revision media":    {MediaURLs: []string{"/uploads/old.ogg"}},
+		"revision photo":    {MediaURLs: []string{"/uploads/old.jpg", "/uploads/none.jpg"}},
+		"status photo":      {MediaURLs: []string{"/uploads/crew.jpg"}, IncludeWithdrawn: true},
+		"media empty url":   {MediaURLs: []string{""}, IncludeWithdrawn: true},
+		"combined":          {Category: Terms{In: []string{"water"}}, District: Terms{NotIn: []string{"Kenema"}}, HasMedia: &no, IncludeMerged: true},
+	}
+
+	for name, q := range queries {
+		filter := mongoFilter(q)
+		var matched []string
+		for i, r := range docs {
+			want := mongoMatch(t, r, filter)
+			if got := q.Match(r); got != want {
+				t.Errorf("%s: report %d: Match = %v, mongoFilter = %v (filter %v)", name, i, got, want, filter)
+			}
+			if want {
+				matched = append(matched, fmt.Sprint(i))
+			}
+		}
+		if name != "none" && name != "include both" && len(matched) == len(docs) {
+			t.Errorf("%s: matches every fixture; the case tests nothing", name)
+		}
+	}
+}
+
+// TestOverdueAgreesWithOverdueFilter runs every status against deadlines
+// before, at and after a fixed now, which the time.Now() fixtures above
+// can't pin down.
+func TestOverdueAgreesWithOverdueFilter(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+	dues := []*time.Time{nil, &past, &now, &future}
+	statuses := []string{"", models.StatusOpen, models.StatusAcknowledged, models.StatusInProgress, models.StatusResolved, models.StatusRejected}
+	filter := overdueFilter(now)
+	for _, st := range statuses {
+		for _, ack := range dues {
+			for _, resolve := range dues {
+				r := models.Report{ID: primitive.NewObjectID(), Status: st, SLA: &models.SLAState{AckDueAt: ack, ResolveDueAt: resolve}}
+				if got, want := r.Overdue(now), mongoMatch(t, r, filter); got != want {
+					t.Errorf("status %q, ack %v, resolve %v: Overdue = %v, overdueFilter = %v", st, ack, resolve, got, want)
+				}
+			}
+		}
+		if r := (models.Report{Status: st}); r.Overdue(now) || mongoMatch(t, r, filter) {
+			t.Errorf("status %q without an SLA is overdue", st)
+		}
+	}
+}
+
+func TestParseReportQuery(t *testing.T) {
+	q, err := ParseReportQuery(map[string][]string{
+		"category": {"water,!roads", "health"},
+		"status":   {"!resolved"},
+		"bbox":     {"-13.3,8.4,-13.1,8.5"},
+		"sort":     {"-category"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(q.Category.In, q.Category.NotIn, q.Status.NotIn) != "[water health] [roads] [resolved]" ||
+		q.Bbox == nil || q.Bbox.MinLat != 8.4 || q.Sort != (Sort{Field: SortCategory, Desc: true}) {
+		t.Fatalf("parsed = %+v", q)
+	}
+
+	for _, bad := range []map[string][]string{
+		{"status": {"closed"}},
+		{"anonymous": {"maybe"}},
+		{"start_date": {"2026-03-05T00:00:00Z"}, "end_date": {"2026-03-01T00:00:00Z"}},
+		{"bbox": {"1,1,0,0"}},
+		{"agency": {"!" + primitive.NewObjectID().Hex()}},
+		{"sort": {"distance"}},
+		{"sort": {"votes"}},
+	} {
+		if _, err := ParseReportQuery(bad); err == nil {
+			t.Errorf("ParseReportQuery(%v) accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// path: repository/repository.go
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"meniba/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrNotFound is returned when no report has the requested id.
+	ErrNotFound = errors.New("report not found")
+	// ErrConflict is returned when a report kept changing underneath Update.
+	ErrConflict = errors.New("report changed concurrently; retry")
+	// ErrNoChange may be returned by an Update mutator to skip the write.
+	ErrNoChange = errors.New("no change")
+	// ErrWatchUnsupported is returned by Watch on servers without change streams.
+	ErrWatchUnsupported = errors.New("change streams not supported")
+)
+
+// ReportRepository is the storage for reports. Handlers depend on this
+// interface only, so they run against MongoDB in production and against
+// the in-memory implementation in tests and local demos.
+type ReportRepository interface {
+	// Create stores r and sets its ID.
+	Create(ctx context.Context, r *models.Report) error
+	Get(ctx context.Context, id primitive.ObjectID) (models.Report, error)
+	// List returns matching reports in f.Sort order: newest first by
+	// default, or most relevant first when the query has Text.
+	List(ctx context.Context, f ReportQuery) ([]models.Report, error)
+	// Scan calls fn for every matching report in no particular order,
+	// ignoring sort and paging; it stops at the first error fn returns.
+	Scan(ctx context.Context, f ReportQuery, fn func(models.Report) error) error
+	// Count returns how many reports match, ignoring paging.
+	Count(ctx context.Context, f ReportQuery) (int64, error)
+	// Update applies mutate to the current document and saves it, retrying
+	// if another writer got there first. UpdatedAt is set automatically.
+	// If mutate returns ErrNoChange nothing is written and the current
+	// document is returned; any other error aborts the update.
+	Update(ctx context.Context, id primitive.ObjectID, mutate func(r *models.Report) error) (models.Report, error)
+	// Delete removes a report for good (retention purges); ErrNotFound if
+	// it doesn't exist.
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	Stats(ctx context.Context, f ReportQuery) (ReportStats, error)
+	// Clusters groups matching reports into square grid cells of cellDeg
+	// degrees, by their PublicPosition.
+	Clusters(ctx context.Context, f ReportQuery, cellDeg float64) ([]Cluster, error)
+	// Grid counts matching reports per cell of a lng/lat grid of
+	// 2^lngBits columns and 2^latBits rows over the world, by their
+	// PublicPosition. Geohash cells are such a grid.
+	Grid(ctx context.Context, f ReportQuery, lngBits, latBits int) ([]GridCount, error)
+}
+
+// Watcher is implemented by repositories that can stream changes (MongoDB
+// replica sets). Tokens are opaque and can be passed back as resumeAfter.
+type Watcher interface {
+	Watch(ctx context.Context, resumeAfter string) (<-chan ReportChange, error)
+}
+
+// ReportChange is one insert or update seen by a Watcher.
+type ReportChange struct {
+	Token   string
+	Created bool
+	Report  models.Report
+}
+
+// Bbox is a lng/lat bounding box.
+type Bbox struct {
+	MinLng, MinLat, MaxLng, MaxLat float64
+}
+
+// ReportQuery selects reports. Zero values mean "no constraint"; all set
+// fields must match. Build it from request params with ParseReportQuery so
+// every endpoint accepts the same filter grammar.
+type ReportQuery struct {
+	IDs         []primitive.ObjectID
+	Text        string // full-text search; results are sorted by relevance
+	Category    Terms
+	District    Terms
+	Chiefdom    Terms
+	Region      Terms
+	Status      Terms // legacy reports without a status count as open
+	GeoMethod   Terms
+	Anonymous   *bool
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	HasMedia    *bool // voice note or at least one photo
+	HasVoice    *bool
+	HasPhoto    *bool
+	Bbox        *Bbox
+	DuplicateOf []primitive.ObjectID // only reports merged into one of these
+	Agencies    []primitive.ObjectID // only reports assigned to one of these
+	Assigned    *bool                // has at least one agency
+	Overdue     *bool                // past an SLA deadline right now (see Report.Overdue)
+	ReporterID  *primitive.ObjectID  // submitted by this reporter account
+	MediaURLs   []string             // points at any of these files, now or in a revision or status change
+
+	IncludeMerged    bool // include reports merged into another
+	IncludeWithdrawn bool // include reports their reporter withdrew
+
+	Sort  Sort
+	Near  *Point    // reference point for SortDistance
+	After *Position // keyset paging: only reports after this one in Sort order
+
+	Skip  int
+	Limit int // 0 = no limit
+}
+
+// Sort fields. The zero Sort is newest first by id.
+const (
+	SortCreatedAt     = "created_at"
+	SortCapturedAt    = "captured_at" // falls back to created_at
+	SortCategory      = "category"
+	SortDistance      = "distance" // metres from ReportQuery.Near
+	SortConfirmations = "confirmations"
+)
+
+// Sort orders List results; ties are broken by id in the same direction.
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+// Point is a lat/lng position.
+type Point struct {
+	Lat, Lng float64
+}
+
+// Position is where a page ended: the sort value (see ReportQuery.SortValue)
+// and id of its last report.
+type Position struct {
+	Value any
+	ID    primitive.ObjectID
+}
+
+// Terms filters a string field: the value must be one of In (if any) and
+// none of NotIn.
+type Terms struct {
+	In    []string
+	NotIn []string
+}
+
+// Any reports whether t constrains anything.
+func (t Terms) Any() bool { return len(t.In) > 0 || len(t.NotIn) > 0 }
+
+func (t Terms) match(v string) bool {
+	if len(t.In) > 0 && !containsStr(t.In, v) {
+		return false
+	}
+	return !containsStr(t.NotIn, v)
+}
+
+// ReportStats are counts over the reports matching a filter.
+type ReportStats struct {
+	Total      int64            `json:"total"`
+	ByCategory map[string]int64 `json:"by_category"`
+	ByStatus   map[string]int64 `json:"by_status"`
+	ByDistrict map[string]int64 `json:"by_district"`
+}
+
+func newStats() ReportStats {
+	return ReportStats{
+		ByCategory: map[string]int64{},
+		ByStatus:   map[string]int64{},
+		ByDistrict: map[string]int64{},
+	}
+}
+
+// maxUpdateRetries bounds optimistic-concurrency retries in Update.
+const maxUpdateRetries = 5
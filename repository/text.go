@@ -0,0 +1,193 @@
+// path: repository/text.go
+package repository
+
+import (
+	"html"
+	"strings"
+	"unicode"
+
+	"meniba/models"
+)
+
+// In-memory approximation of MongoDB $text search: the same query syntax
+// (words, "phrases", -excluded), a light English stemmer and the field
+// weights of the reports_text index.
+
+var textWeights = []struct {
+	field  func(models.Report) string
+	weight float64
+}{
+	{func(r models.Report) string { return r.Note }, 5},
+	{func(r models.Report) string { return r.AreaLabel }, 3},
+	{func(r models.Report) string { return r.Adrehs }, 2},
+}
+
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "for": true, "from": true, "in": true, "is": true,
+	"it": true, "near": true, "of": true, "on": true, "or": true, "the": true,
+	"to": true, "with": true,
+}
+
+type textQuery struct {
+	terms    []string // stemmed
+	phrases  []string // lower-cased
+	excluded []string // stemmed
+}
+
+func parseText(s string) textQuery {
+	var t textQuery
+	for {
+		i := strings.IndexByte(s, '"')
+		if i < 0 {
+			break
+		}
+		j := strings.IndexByte(s[i+1:], '"')
+		if j < 0 {
+			break
+		}
+		if p := strings.ToLower(strings.TrimSpace(s[i+1 : i+1+j])); p != "" {
+			t.phrases = append(t.phrases, p)
+			// phrase words also count as terms for scoring
+			s = s[:i] + " " + s[i+1:i+1+j] + " " + s[i+2+j:]
+		} else {
+			s = s[:i] + " " + s[i+2+j:]
+		}
+	}
+	for _, f := range strings.Fields(s) {
+		neg := strings.HasPrefix(f, "-")
+		for _, w := range textWords(f) {
+			if stopWords[w] {
+				continue
+			}
+			if neg {
+				t.excluded = append(t.excluded, stem(w))
+			} else {
+				t.terms = append(t.terms, stem(w))
+			}
+		}
+	}
+	return t
+}
+
+// score is 0 when r doesn't match, like a document $text leaves out.
+func (t textQuery) score(r models.Report) float64 {
+	var all strings.Builder
+	total := 0.0
+	for _, f := range textWeights {
+		text := f.field(r)
+		all.WriteString(strings.ToLower(text))
+		all.WriteByte('\n')
+		for _, w := range textWords(text) {
+			s := stem(w)
+			if containsStr(t.excluded, s) {
+				return 0
+			}
+			if containsStr(t.terms, s) {
+				total += f.weight
+			}
+		}
+	}
+	for _, p := range t.phrases {
+		if !strings.Contains(all.String(), p) {
+			return 0
+		}
+	}
+	return total
+}
+
+func textWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// stem strips common English inflections so "boreholes" finds "borehole"
+// and "flooding" finds "flooded". It is deliberately crude.
+func stem(w string) string {
+	for _, suf := range []struct{ from, to string }{
+		{"ies", "y"}, {"sses", "ss"}, {"ing", ""}, {"edly", ""}, {"ed", ""}, {"s", ""},
+	} {
+		if strings.HasSuffix(w, suf.from) && len(w)-len(suf.from) >= 3 {
+			if suf.from == "s" && strings.HasSuffix(w, "ss") {
+				break
+			}
+			w = w[:len(w)-len(suf.from)] + suf.to
+			break
+		}
+	}
+	// "pave"/"paved", "borehole"/"boreholes" and "box"/"boxes" meet here
+	if len(w) > 3 && strings.HasSuffix(w, "e") {
+		w = w[:len(w)-1]
+	}
+	return w
+}
+
+// Snippet returns up to width runes of text around the first word that
+// matches query, HTML-escaped with matching words wrapped in <mark>. It
+// returns "" when nothing in text matches.
+func Snippet(text, query string, width int) string {
+	t := parseText(query)
+	if len(t.terms) == 0 {
+		return ""
+	}
+	runes := []rune(text)
+
+	// word spans in rune offsets
+	type span struct{ start, end int }
+	var words []span
+	hit := -1
+	for i := 0; i < len(runes); {
+		if !unicode.IsLetter(runes[i]) && !unicode.IsDigit(runes[i]) {
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j])) {
+			j++
+		}
+		words = append(words, span{i, j})
+		if hit < 0 && containsStr(t.terms, stem(strings.ToLower(string(runes[i:j])))) {
+			hit = len(words) - 1
+		}
+		i = j
+	}
+	if hit < 0 {
+		return ""
+	}
+
+	from := max(0, words[hit].start-width/3)
+	to := min(len(runes), from+width)
+	from = max(0, min(from, to-width))
+	// don't cut words in half
+	for from > 0 && !unicode.IsSpace(runes[from-1]) {
+		from--
+	}
+	for to < len(runes) && !unicode.IsSpace(runes[to]) {
+		to++
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	pos := from
+	for _, w := range words {
+		if w.start < from || w.end > to {
+			continue
+		}
+		if !containsStr(t.terms, stem(strings.ToLower(string(runes[w.start:w.end])))) {
+			continue
+		}
+		b.WriteString(html.EscapeString(string(runes[pos:w.start])))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(string(runes[w.start:w.end])))
+		b.WriteString("</mark>")
+		pos = w.end
+	}
+	b.WriteString(html.EscapeString(string(runes[pos:to])))
+	if to < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
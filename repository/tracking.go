@@ -0,0 +1,132 @@
+// path: repository/tracking.go
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"meniba/database"
+	"meniba/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// TrackingTokenRepository stores report tracking tokens by the hash of
+// their secret.
+type TrackingTokenRepository interface {
+	// Insert stores tok, assigning its ID.
+	Insert(ctx context.Context, tok *models.TrackingToken) error
+	// FindByHash returns the token with hash, live or not, or ErrNotFound.
+	FindByHash(ctx context.Context, hash string) (models.TrackingToken, error)
+	// Revoke switches token id off as of now.
+	Revoke(ctx context.Context, id primitive.ObjectID, now time.Time) error
+	// RevokeReport switches off every unrevoked token of reportID and
+	// returns how many it revoked.
+	RevokeReport(ctx context.Context, reportID primitive.ObjectID, now time.Time) (int64, error)
+}
+
+// MongoTrackingTokens keeps tokens in the tracking_tokens collection.
+type MongoTrackingTokens struct {
+	col *mongo.Collection
+}
+
+// NewMongoTrackingTokens returns a token store over db.
+func NewMongoTrackingTokens(db *database.DB) *MongoTrackingTokens {
+	return &MongoTrackingTokens{col: db.Col("tracking_tokens")}
+}
+
+func (m *MongoTrackingTokens) Insert(ctx context.Context, tok *models.TrackingToken) error {
+	tok.ID = primitive.NewObjectID()
+	_, err := m.col.InsertOne(ctx, tok)
+	return err
+}
+
+func (m *MongoTrackingTokens) FindByHash(ctx context.Context, hash string) (models.TrackingToken, error) {
+	var tok models.TrackingToken
+	err := m.col.FindOne(ctx, bson.M{"hash": hash}).Decode(&tok)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return tok, ErrNotFound
+	}
+	return tok, err
+}
+
+func (m *MongoTrackingTokens) Revoke(ctx context.Context, id primitive.ObjectID, now time.Time) error {
+	_, err := m.col.UpdateByID(ctx, id, bson.M{"$set": bson.M{"revoked_at": now}})
+	return err
+}
+
+func (m *MongoTrackingTokens) RevokeReport(ctx context.Context, reportID primitive.ObjectID, now time.Time) (int64, error) {
+	res, err := m.col.UpdateMany(ctx,
+		bson.M{"report_id": reportID, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": now}})
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
+
+// MemoryTrackingTokens is an in-process TrackingTokenRepository for tests
+// and local demos.
+type MemoryTrackingTokens struct {
+	mu   sync.Mutex
+	docs []models.TrackingToken
+}
+
+// NewMemoryTrackingTokens returns an empty in-memory token store.
+func NewMemoryTrackingTokens() *MemoryTrackingTokens {
+	return &MemoryTrackingTokens{}
+}
+
+// All returns a copy of every stored token, oldest first.
+func (m *MemoryTrackingTokens) All() []models.TrackingToken {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]models.TrackingToken(nil), m.docs...)
+}
+
+func (m *MemoryTrackingTokens) Insert(_ context.Context, tok *models.TrackingToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tok.ID = primitive.NewObjectID()
+	m.docs = append(m.docs, *tok)
+	return nil
+}
+
+func (m *MemoryTrackingTokens) FindByHash(_ context.Context, hash string) (models.TrackingToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, tok := range m.docs {
+		if tok.Hash == hash {
+			return tok, nil
+		}
+	}
+	return models.TrackingToken{}, ErrNotFound
+}
+
+func (m *MemoryTrackingTokens) Revoke(_ context.Context, id primitive.ObjectID, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.docs {
+		if m.docs[i].ID == id {
+			m.docs[i].RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (m *MemoryTrackingTokens) RevokeReport(_ context.Context, reportID primitive.ObjectID, now time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for i := range m.docs {
+		if m.docs[i].ReportID == reportID && m.docs[i].RevokedAt == nil {
+			m.docs[i].RevokedAt = &now
+			n++
+		}
+	}
+	return n, nil
+}
@@ -7,14 +7,103 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-// Register attaches all API endpoints to the app.
-func Register(app *fiber.App) {
+// Register attaches all API endpoints to the app, served by h.
+func Register(app *fiber.App, h *controllers.API) {
 	api := app.Group("/api")
 
 	api.Post("/locate", controllers.HandleLocate)
 
-	api.Post("/reports", controllers.HandlePostReport)
-	api.Get("/reports", controllers.HandleListReports)
+	api.Post("/reports", h.HandlePostReport)
+	api.Get("/reports", h.HandleListReports)
+	api.Get("/reports/stats", h.HandleReportStats)
+	api.Get("/reports/export", h.HandleExportReports)
+	api.Get("/reports/geojson", h.HandleReportsGeoJSON)
+	api.Get("/reports/clusters", h.HandleReportClusters)
+	api.Get("/reports/density", h.HandleReportDensity)
+	api.Get("/reports/stream", h.HandleReportStream)
+	api.Get("/reports/:id/comments", h.HandleListComments)
+
+	// Reporter edits and withdrawal within the grace window
+	api.Patch("/reports/:id", h.HandlePatchReport)
+	api.Delete("/reports/:id", h.HandleWithdrawReport)
+
+	api.Get("/tiles/reports/:z/:x/:y.mvt", h.HandleReportTile)
+
+	api.Get("/alerts", h.HandleListAlerts)
+
+	// Reporter accounts (phone + SMS code)
+	api.Post("/auth/otp", h.HandleRequestOTP)
+	api.Post("/auth/verify", h.HandleVerifyOTP)
+	me := api.Group("/me", h.RequireReporter)
+	me.Get("/", h.HandleMe)
+	me.Get("/reports", h.HandleMyReports)
+	api.Post("/auth/logout", h.RequireReporter, h.HandleLogout)
+
+	// Reporter follow-up (token from POST /api/reports)
+	api.Get("/track/:token", h.HandleTrackReport)
+	api.Delete("/track/:token", h.HandleRevokeTrackingToken)
+
+	api.Get("/notifications/unsubscribe", h.HandleUnsubscribe)
+	api.Post("/notifications/unsubscribe", h.HandleUnsubscribe)
+
+	// SMS/USSD/chat intake (gateway callbacks)
+	api.Post("/inbound/sms", h.HandleInboundSMS)
+	api.Post("/inbound/ussd", h.HandleInboundUSSD)
+	api.Post("/inbound/messages", h.HandleInboundMessage)
+
+	// Moderation (requires ADMIN_TOKEN)
+	admin := api.Group("/admin", controllers.RequireAdmin)
+	admin.Post("/reports/:id/merge", h.HandleMergeReports)
+	admin.Post("/reports/:id/status", h.HandleSetStatus)
+	admin.Post("/reports/:id/comments", h.HandleAddComment)
+	admin.Post("/reports/:id/assign", h.HandleAssignReport)
+	admin.Post("/reports/:id/tracking/revoke", h.HandleRevokeReportTracking)
+
+	// Agencies and routing rules
+	admin.Post("/agencies", h.HandleCreateAgency)
+	admin.Get("/agencies", h.HandleListAgencies)
+	admin.Post("/agencies/:id/rules", h.HandleSetAgencyRules)
+	admin.Delete("/agencies/:id", h.HandleDeleteAgency)
+
+	// SLA definitions
+	admin.Post("/sla", h.HandleCreateSLAPolicy)
+	admin.Get("/sla", h.HandleListSLAPolicies)
+	admin.Delete("/sla/:id", h.HandleDeleteSLAPolicy)
+
+	// Retention rules and runs
+	admin.Post("/retention/rules", h.HandleCreateRetentionRule)
+	admin.Get("/retention/rules", h.HandleListRetentionRules)
+	admin.Delete("/retention/rules/:id", h.HandleDeleteRetentionRule)
+	admin.Post("/retention/run", h.HandleRunRetention)
+	admin.Get("/retention/runs", h.HandleListRetentionRuns)
+
+	// Orphaned media collection
+	admin.Get("/media/gc", h.HandleMediaGCStats)
+	admin.Post("/media/gc/run", h.HandleRunMediaGC)
+
+	// Audit log
+	admin.Get("/audit", h.HandleListAudit)
+
+	// Email outbox
+	admin.Get("/notifications", h.HandleListNotifications)
+
+	// Agency workspace (requires the agency's key)
+	agency := api.Group("/agency", h.RequireAgency)
+	agency.Get("/reports", h.HandleAgencyReports)
+
+	// Outbound webhooks
+	admin.Post("/webhooks", h.HandleCreateWebhook)
+	admin.Get("/webhooks", h.HandleListWebhooks)
+	admin.Get("/webhooks/deliveries", h.HandleListDeliveries)
+	admin.Post("/webhooks/deliveries/replay", h.HandleReplayDeliveries)
+	admin.Delete("/webhooks/:id", h.HandleDeleteWebhook)
+
+	// Open311 GeoReport v2 (format suffix: .json or .xml)
+	o311 := app.Group("/open311/v2")
+	o311.Get("/services.:format", h.HandleOpen311Services)
+	o311.Get("/requests.:format", h.HandleOpen311ListRequests)
+	o311.Post("/requests.:format", h.HandleOpen311CreateRequest)
+	o311.Get("/requests/:id.:format", h.HandleOpen311GetRequest)
 
 	// Optional: quick echo to verify requests reach the API
 	api.Get("/debug/echo", func(c *fiber.Ctx) error {
@@ -0,0 +1,160 @@
+// path: routing/routing.go
+// Package routing decides which agencies are responsible for a report.
+package routing
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"meniba/database"
+	"meniba/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AgenciesCol holds the agencies and their routing rules.
+const AgenciesCol = "agencies"
+
+// Router assigns new reports to every active agency with a matching rule.
+type Router struct {
+	DB *database.DB
+}
+
+// NewRouter returns a router reading agencies from db.
+func NewRouter(db *database.DB) *Router {
+	return &Router{DB: db}
+}
+
+// Route returns the ids of the active agencies responsible for r, oldest
+// agency first.
+func (rt *Router) Route(ctx context.Context, r models.Report) ([]primitive.ObjectID, error) {
+	cur, err := rt.DB.Col(AgenciesCol).Find(ctx, bson.M{"active": true, "rules.0": bson.M{"$exists": true}},
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetProjection(bson.M{"rules": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var agencies []models.Agency
+	if err := cur.All(ctx, &agencies); err != nil {
+		return nil, err
+	}
+	var ids []primitive.ObjectID
+	for _, ag := range agencies {
+		if Matches(ag, r) {
+			ids = append(ids, ag.ID)
+		}
+	}
+	return ids, nil
+}
+
+// Matches reports whether any of ag's rules selects r.
+func Matches(ag models.Agency, r models.Report) bool {
+	for _, rule := range ag.Rules {
+		if RuleMatches(rule, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// RuleMatches reports whether every condition of rule holds for r. A rule
+// without conditions matches nothing.
+func RuleMatches(rule models.RoutingRule, r models.Report) bool {
+	if isEmpty(rule) {
+		return false
+	}
+	if len(rule.Categories) > 0 && !containsFold(rule.Categories, r.Category) {
+		return false
+	}
+	if len(rule.Districts) > 0 && !containsFold(rule.Districts, r.District) {
+		return false
+	}
+	if len(rule.Chiefdoms) > 0 && !containsFold(rule.Chiefdoms, r.Chiefdom) {
+		return false
+	}
+	if len(rule.Polygon) > 0 && !inPolygon(rule.Polygon, r.Lng, r.Lat) {
+		return false
+	}
+	if len(rule.Keywords) > 0 {
+		note := " " + strings.Join(words(r.Note), " ") + " "
+		found := false
+		for _, k := range rule.Keywords {
+			if w := words(k); len(w) > 0 && strings.Contains(note, " "+strings.Join(w, " ")+" ") {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateRules checks rules before they are stored.
+func ValidateRules(rules []models.RoutingRule) error {
+	for i, rule := range rules {
+		if isEmpty(rule) {
+			return fmt.Errorf("rule %d: needs at least one condition", i+1)
+		}
+		if len(rule.Polygon) == 0 {
+			continue
+		}
+		if len(rule.Polygon) < 3 {
+			return fmt.Errorf("rule %d: polygon needs at least 3 points", i+1)
+		}
+		for _, p := range rule.Polygon {
+			if p[0] < -180 || p[0] > 180 || p[1] < -90 || p[1] > 90 {
+				return fmt.Errorf("rule %d: polygon points are [lng, lat]", i+1)
+			}
+		}
+	}
+	return nil
+}
+
+func isEmpty(rule models.RoutingRule) bool {
+	return len(rule.Categories) == 0 && len(rule.Districts) == 0 && len(rule.Chiefdoms) == 0 &&
+		len(rule.Polygon) == 0 && len(rule.Keywords) == 0
+}
+
+func containsFold(list []string, v string) bool {
+	v = strings.TrimSpace(v)
+	for _, x := range list {
+		if strings.EqualFold(strings.TrimSpace(x), v) {
+			return true
+		}
+	}
+	return false
+}
+
+// words lower-cases s and splits it on anything but letters and digits, so
+// keyword "oil spill" matches "Oil-spill!" but "oil" doesn't match "toilet".
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// inPolygon is the even-odd ray casting test; the ring may be open or closed.
+func inPolygon(ring [][2]float64, x, y float64) bool {
+	in := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (yi > y) != (yj > y) && x < (xj-xi)*(y-yi)/(yj-yi)+xi {
+			in = !in
+		}
+	}
+	return in
+}
+
+// HashKey is how agency API keys are stored and looked up.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
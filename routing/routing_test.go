@@ -0,0 +1,128 @@
+package routing
+
+import (
+	"strings"
+	"testing"
+
+	"meniba/models"
+)
+
+// square is the ring [0,0]-[10,10] in [lng, lat].
+var square = [][2]float64{{0, 0}, {10, 0}, {10, 10}, {0, 10}}
+
+func TestRuleMatches(t *testing.T) {
+	report := models.Report{
+		Category: "Sanitation",
+		District: "Western Area Urban",
+		Chiefdom: "Freetown",
+		Lat:      5, Lng: 5,
+		Note: "Oil-spill behind the toilet block",
+	}
+	for _, tc := range []struct {
+		name string
+		rule models.RoutingRule
+		want bool
+	}{
+		{"no conditions", models.RoutingRule{}, false},
+		{"empty lists are no conditions", models.RoutingRule{Categories: []string{}, Keywords: []string{}}, false},
+		{"category, any case and padding", models.RoutingRule{Categories: []string{"water", " sanitation "}}, true},
+		{"wrong category", models.RoutingRule{Categories: []string{"water"}}, false},
+		{"district", models.RoutingRule{Districts: []string{"western area urban"}}, true},
+		{"chiefdom", models.RoutingRule{Chiefdoms: []string{"Bo"}}, false},
+		{"inside polygon", models.RoutingRule{Polygon: square}, true},
+		{"outside polygon", models.RoutingRule{Polygon: [][2]float64{{20, 20}, {30, 20}, {30, 30}}}, false},
+		{"polygon is [lng, lat]", models.RoutingRule{Polygon: [][2]float64{{4, 0}, {6, 0}, {6, 20}, {4, 20}}}, true},
+
+		// conditions within a rule are ANDed
+		{"all conditions hold", models.RoutingRule{Categories: []string{"sanitation"}, Districts: []string{"Western Area Urban"}, Polygon: square}, true},
+		{"one condition fails", models.RoutingRule{Categories: []string{"sanitation"}, Districts: []string{"Bo"}}, false},
+		{"keyword fails the rule", models.RoutingRule{Categories: []string{"sanitation"}, Keywords: []string{"flood"}}, false},
+
+		// keywords match whole words and phrases
+		{"keyword", models.RoutingRule{Keywords: []string{"OIL"}}, true},
+		{"phrase across punctuation", models.RoutingRule{Keywords: []string{"oil spill"}}, true},
+		{"any keyword", models.RoutingRule{Keywords: []string{"flood", "block"}}, true},
+		{"no match inside a word", models.RoutingRule{Keywords: []string{"toil"}}, false},
+		{"phrase out of order", models.RoutingRule{Keywords: []string{"spill oil"}}, false},
+		{"punctuation-only keyword", models.RoutingRule{Keywords: []string{"--"}}, false},
+	} {
+		if got := RuleMatches(tc.rule, report); got != tc.want {
+			t.Errorf("%s: RuleMatches = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	// "oil" must not match "toilet"
+	toilet := models.Report{Note: "blocked toilet"}
+	if RuleMatches(models.RoutingRule{Keywords: []string{"oil"}}, toilet) {
+		t.Error(`"oil" matched "toilet"`)
+	}
+}
+
+func TestMatchesORsRules(t *testing.T) {
+	r := models.Report{Category: "water", District: "Bo"}
+	water := models.RoutingRule{Categories: []string{"water"}, Districts: []string{"Kenema"}}
+	bo := models.RoutingRule{Districts: []string{"Bo"}}
+	for _, tc := range []struct {
+		rules []models.RoutingRule
+		want  bool
+	}{
+		{nil, false},
+		{[]models.RoutingRule{water}, false},
+		{[]models.RoutingRule{water, bo}, true},
+		{[]models.RoutingRule{{}, bo}, true},
+		{[]models.RoutingRule{{}}, false},
+	} {
+		if got := Matches(models.Agency{Rules: tc.rules}, r); got != tc.want {
+			t.Errorf("Matches(%+v) = %v, want %v", tc.rules, got, tc.want)
+		}
+	}
+}
+
+func TestInPolygon(t *testing.T) {
+	closed := append(append([][2]float64{}, square...), square[0])
+	// an L shape: the notch at [5..10, 5..10] is outside
+	ell := [][2]float64{{0, 0}, {10, 0}, {10, 5}, {5, 5}, {5, 10}, {0, 10}}
+	for _, tc := range []struct {
+		name string
+		ring [][2]float64
+		x, y float64
+		want bool
+	}{
+		{"open ring, inside", square, 5, 5, true},
+		{"closed ring, inside", closed, 5, 5, true},
+		{"open ring, outside", square, 15, 5, false},
+		{"closed ring, outside", closed, -1, 5, false},
+		{"above", square, 5, 11, false},
+		{"concave, inside", ell, 2, 8, true},
+		{"concave, in the notch", ell, 8, 8, false},
+		{"too few points", [][2]float64{{0, 0}, {10, 10}}, 5, 5, false},
+		{"empty", nil, 0, 0, false},
+	} {
+		if got := inPolygon(tc.ring, tc.x, tc.y); got != tc.want {
+			t.Errorf("%s: inPolygon(%v, %v) = %v, want %v", tc.name, tc.x, tc.y, got, tc.want)
+		}
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		rules []models.RoutingRule
+		err   string
+	}{
+		{"none", nil, ""},
+		{"valid", []models.RoutingRule{{Categories: []string{"water"}}, {Polygon: square}}, ""},
+		{"empty rule", []models.RoutingRule{{Categories: []string{"water"}}, {}}, "rule 2: needs at least one condition"},
+		{"two points", []models.RoutingRule{{Polygon: [][2]float64{{0, 0}, {1, 1}}}}, "rule 1: polygon needs at least 3 points"},
+		{"lat, lng swapped", []models.RoutingRule{{Polygon: [][2]float64{{8.4, -13.2}, {8.5, -13.2}, {8.5, -100}}}}, "rule 1: polygon points are [lng, lat]"},
+		{"longitude out of range", []models.RoutingRule{{Polygon: [][2]float64{{0, 0}, {181, 0}, {0, 1}}}}, "rule 1: polygon points are [lng, lat]"},
+	} {
+		err := ValidateRules(tc.rules)
+		switch {
+		case tc.err == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		case tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)):
+			t.Errorf("%s: error %v, want %q", tc.name, err, tc.err)
+		}
+	}
+}
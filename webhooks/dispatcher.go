@@ -0,0 +1,309 @@
+// path: webhooks/dispatcher.go
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"meniba/database"
+	"meniba/events"
+	"meniba/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Collection names used by the dispatcher.
+const (
+	SubscriptionsCol = "webhook_subscriptions"
+	DeliveriesCol    = "webhook_deliveries"
+)
+
+// Events lists the event types partners can subscribe to.
+var Events = []string{events.ReportCreated, events.ReportStatusChanged, events.ReportAssigned, events.ReportOverdue, events.CommentAdded}
+
+// SupportedEvent reports whether t can be subscribed to.
+func SupportedEvent(t string) bool {
+	for _, e := range Events {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Dispatcher turns bus events into persisted deliveries and sends them with
+// exponential backoff. Deliveries are persisted (in Mongo in production), so
+// retries survive restarts.
+type Dispatcher struct {
+	Store       Store
+	HTTP        *http.Client
+	Bus         *events.Bus
+	Poll        time.Duration // how often due deliveries are picked up
+	MaxAttempts int           // after this many failures a delivery is "failed"
+	BaseBackoff time.Duration // delay after the first failure
+	MaxBackoff  time.Duration
+	Lease       time.Duration // how long a claimed delivery is hidden from other workers
+}
+
+// NewDispatcher returns a dispatcher over db and bus with production defaults.
+func NewDispatcher(db *database.DB, bus *events.Bus) *Dispatcher {
+	return &Dispatcher{
+		Store:       NewMongoStore(db),
+		HTTP:        &http.Client{Timeout: 10 * time.Second},
+		Bus:         bus,
+		Poll:        2 * time.Second,
+		MaxAttempts: 10,
+		BaseBackoff: 30 * time.Second,
+		MaxBackoff:  6 * time.Hour,
+		Lease:       time.Minute,
+	}
+}
+
+// Run consumes events and sends due deliveries until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	go d.consume(ctx)
+
+	t := time.NewTicker(d.Poll)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			for {
+				sent, err := d.SendNext(ctx)
+				if err != nil {
+					log.Printf("webhooks: %v", err)
+					break
+				}
+				if !sent {
+					break
+				}
+			}
+		}
+	}
+}
+
+// consume subscribes to the bus, resubscribing from the last seen event if
+// the bus drops us for being slow.
+func (d *Dispatcher) consume(ctx context.Context) {
+	lastID := ""
+	for ctx.Err() == nil {
+		replay, live, cancel := d.Bus.Subscribe(lastID)
+		for _, ev := range replay {
+			d.handle(ctx, ev)
+			lastID = ev.ID
+		}
+	loop:
+		for {
+			select {
+			case <-ctx.Done():
+				break loop
+			case ev, ok := <-live:
+				if !ok {
+					break loop
+				}
+				d.handle(ctx, ev)
+				lastID = ev.ID
+			}
+		}
+		cancel()
+	}
+}
+
+func (d *Dispatcher) handle(ctx context.Context, ev events.Event) {
+	if !SupportedEvent(ev.Type) {
+		return
+	}
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if n, err := d.Enqueue(cctx, ev); err != nil {
+		log.Printf("webhooks: enqueue %s %s: %v", ev.Type, ev.ReportID, err)
+	} else if n > 0 {
+		log.Printf("webhooks: queued %s for %d subscription(s)", ev.Type, n)
+	}
+}
+
+// payload is the JSON body partners receive.
+type payload struct {
+	ID        string         `json:"id"`
+	Event     string         `json:"event"`
+	CreatedAt time.Time      `json:"created_at"`
+	Report    *models.Report `json:"report,omitempty"`
+	Data      any            `json:"data,omitempty"`
+}
+
+// Enqueue stores one pending delivery per active subscription matching ev.
+func (d *Dispatcher) Enqueue(ctx context.Context, ev events.Event) (int, error) {
+	subs, err := d.Store.Subscriptions(ctx, ev.Type)
+	if err != nil {
+		return 0, err
+	}
+
+	body, err := json.Marshal(payload{ID: ev.ID, Event: ev.Type, CreatedAt: ev.At, Report: ev.Report, Data: ev.Data})
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	var docs []models.WebhookDelivery
+	for _, s := range subs {
+		if !matches(s, ev.Report) {
+			continue
+		}
+		docs = append(docs, models.WebhookDelivery{
+			SubscriptionID: s.ID,
+			EventID:        ev.ID,
+			Event:          ev.Type,
+			ReportID:       ev.ReportID,
+			Payload:        string(body),
+			Status:         models.DeliveryPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		})
+	}
+	if len(docs) == 0 {
+		return 0, nil
+	}
+	if err := d.Store.InsertDeliveries(ctx, docs); err != nil {
+		return 0, err
+	}
+	return len(docs), nil
+}
+
+func matches(s models.WebhookSubscription, r *models.Report) bool {
+	if r == nil {
+		return len(s.Categories) == 0 && len(s.Districts) == 0
+	}
+	return inList(s.Categories, r.Category) && inList(s.Districts, r.District)
+}
+
+func inList(list []string, v string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// SendNext claims one due delivery and attempts it. It returns false when
+// nothing was due.
+func (d *Dispatcher) SendNext(ctx context.Context) (bool, error) {
+	del, ok, err := d.Store.Claim(ctx, time.Now().UTC(), d.Lease)
+	if err != nil {
+		return false, fmt.Errorf("claim delivery: %w", err)
+	}
+	if !ok {
+		return false, nil
+	}
+
+	sub, err := d.Store.GetSubscription(ctx, del.SubscriptionID)
+	if errors.Is(err, errNotFound) {
+		now := time.Now().UTC()
+		del.Status, del.UpdatedAt = models.DeliveryFailed, now
+		return true, d.Store.Finish(ctx, del, models.DeliveryAttempt{At: now, Error: "subscription deleted"})
+	}
+	if err != nil {
+		return true, err
+	}
+
+	attempt := d.attempt(ctx, sub, del)
+	now := time.Now().UTC()
+	del.AttemptCount++
+	del.UpdatedAt = now
+
+	switch {
+	case attempt.Error == "":
+		del.Status = models.DeliverySucceeded
+		del.DeliveredAt = &attempt.At
+	case del.AttemptCount >= d.MaxAttempts:
+		del.Status = models.DeliveryFailed
+	default:
+		del.Status = models.DeliveryPending
+		del.NextAttemptAt = now.Add(d.backoff(del.AttemptCount))
+	}
+	return true, d.Store.Finish(ctx, del, attempt)
+}
+
+// attempt POSTs the delivery once and reports the outcome.
+func (d *Dispatcher) attempt(ctx context.Context, sub models.WebhookSubscription, del models.WebhookDelivery) models.DeliveryAttempt {
+	start := time.Now().UTC()
+	out := models.DeliveryAttempt{At: start}
+
+	ts := strconv.FormatInt(start.Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader([]byte(del.Payload)))
+	if err != nil {
+		out.Error = err.Error()
+		out.DurationMs = time.Since(start).Milliseconds()
+		return out
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "mineba-webhooks/1")
+	req.Header.Set("X-Mineba-Event", del.Event)
+	req.Header.Set("X-Mineba-Delivery", del.ID.Hex())
+	req.Header.Set("X-Mineba-Timestamp", ts)
+	req.Header.Set("X-Mineba-Signature", "sha256="+Sign(sub.Secret, ts, []byte(del.Payload)))
+
+	resp, err := d.HTTP.Do(req)
+	if err != nil {
+		out.Error = err.Error()
+		out.DurationMs = time.Since(start).Milliseconds()
+		return out
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	out.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		out.Error = "non-2xx response: " + resp.Status
+	}
+	out.DurationMs = time.Since(start).Milliseconds()
+	return out
+}
+
+// backoff returns the delay before attempt n+1: base * 2^(n-1), capped,
+// with ±10% jitter so a recovering receiver isn't hit all at once.
+func (d *Dispatcher) backoff(n int) time.Duration {
+	delay := d.BaseBackoff
+	for i := 1; i < n && delay < d.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > d.MaxBackoff {
+		delay = d.MaxBackoff
+	}
+	jitter := time.Duration(rand.Int63n(int64(delay)/5+1)) - delay/10
+	return delay + jitter
+}
+
+// Sign returns hex(HMAC-SHA256(secret, timestamp + "." + body)). Receivers
+// recompute it from the X-Mineba-Timestamp header and the raw body.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Replay puts failed deliveries back in the queue. If ids is empty, every
+// failed delivery (optionally limited to one subscription) is replayed.
+func (d *Dispatcher) Replay(ctx context.Context, subID *primitive.ObjectID, ids []primitive.ObjectID) (int64, error) {
+	return d.Store.Requeue(ctx, subID, ids, time.Now().UTC())
+}
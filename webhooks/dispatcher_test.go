@@ -0,0 +1,248 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"meniba/events"
+	"meniba/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// receiver is a partner endpoint that verifies signatures and answers with
+// the queued status codes, then 204.
+type receiver struct {
+	t      *testing.T
+	secret string
+	mu     sync.Mutex
+	codes  []int
+	got    []payload
+}
+
+func (rv *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	ts := r.Header.Get("X-Mineba-Timestamp")
+	if r.Header.Get("X-Mineba-Signature") != "sha256="+Sign(rv.secret, ts, body) {
+		rv.t.Errorf("bad signature %q", r.Header.Get("X-Mineba-Signature"))
+	}
+	if _, err := strconv.ParseInt(ts, 10, 64); err != nil {
+		rv.t.Errorf("bad timestamp %q", ts)
+	}
+	if r.Header.Get("X-Mineba-Delivery") == "" || r.Header.Get("Content-Type") != "application/json" {
+		rv.t.Errorf("headers = %v", r.Header)
+	}
+	var p payload
+	if err := json.Unmarshal(body, &p); err != nil {
+		rv.t.Errorf("body %s: %v", body, err)
+	}
+	if r.Header.Get("X-Mineba-Event") != p.Event {
+		rv.t.Errorf("event header %q, body %q", r.Header.Get("X-Mineba-Event"), p.Event)
+	}
+
+	rv.mu.Lock()
+	defer rv.mu.Unlock()
+	rv.got = append(rv.got, p)
+	code := http.StatusNoContent
+	if len(rv.codes) > 0 {
+		code, rv.codes = rv.codes[0], rv.codes[1:]
+	}
+	w.WriteHeader(code)
+}
+
+func newTestDispatcher(t *testing.T, codes ...int) (*Dispatcher, *MemoryStore, *receiver, models.WebhookSubscription) {
+	t.Helper()
+	rv := &receiver{t: t, secret: "whsec", codes: codes}
+	srv := httptest.NewServer(rv)
+	t.Cleanup(srv.Close)
+
+	store := NewMemoryStore()
+	sub := store.AddSubscription(models.WebhookSubscription{URL: srv.URL + "/hook", Secret: "whsec", Active: true})
+	return &Dispatcher{
+		Store:       store,
+		HTTP:        srv.Client(),
+		MaxAttempts: 3,
+		BaseBackoff: time.Millisecond,
+		MaxBackoff:  10 * time.Millisecond,
+		Lease:       time.Minute,
+	}, store, rv, sub
+}
+
+func createdEvent(category, district string) events.Event {
+	r := &models.Report{ID: primitive.NewObjectID(), Category: category, District: district}
+	return events.Event{ID: "e1", Type: events.ReportCreated, ReportID: r.ID.Hex(), Report: r, At: time.Now().UTC()}
+}
+
+func TestSignKnownValue(t *testing.T) {
+	// echo -n '1700000000.{}' | openssl dgst -sha256 -hmac secret
+	if got := Sign("secret", "1700000000", []byte("{}")); got != "b8569b78799ff9e3cbff0fc2d63a33a2b57f3282abd07c37ae5e8e7d79a5f163" {
+		t.Fatalf("Sign = %q", got)
+	}
+	a := Sign("secret", "1700000000", []byte("{}"))
+	if a == Sign("other", "1700000000", []byte("{}")) || a == Sign("secret", "1700000001", []byte("{}")) {
+		t.Fatal("signature ignores the secret or timestamp")
+	}
+}
+
+func TestEnqueueFiltersSubscriptions(t *testing.T) {
+	d, store, _, _ := newTestDispatcher(t)
+	store.AddSubscription(models.WebhookSubscription{URL: "http://x", Active: true, Events: []string{events.ReportStatusChanged}})
+	store.AddSubscription(models.WebhookSubscription{URL: "http://x", Active: true, Categories: []string{"roads"}})
+	store.AddSubscription(models.WebhookSubscription{URL: "http://x", Active: true, Districts: []string{"Bo"}})
+	store.AddSubscription(models.WebhookSubscription{URL: "http://x", Active: false})
+	water := store.AddSubscription(models.WebhookSubscription{URL: "http://x", Active: true,
+		Events: []string{events.ReportCreated}, Categories: []string{"water"}, Districts: []string{"Western Area Urban"}})
+
+	n, err := d.Enqueue(context.Background(), createdEvent("water", "Western Area Urban"))
+	if err != nil || n != 2 {
+		t.Fatalf("Enqueue = %d, %v; want the catch-all and the water subscription", n, err)
+	}
+	dels := store.Deliveries()
+	if dels[1].SubscriptionID != water.ID || dels[0].Status != models.DeliveryPending || dels[0].Payload != dels[1].Payload {
+		t.Fatalf("deliveries = %+v", dels)
+	}
+}
+
+func TestDeliveryRetriesWithBackoff(t *testing.T) {
+	d, store, rv, _ := newTestDispatcher(t, http.StatusInternalServerError, http.StatusBadGateway)
+	ctx := context.Background()
+	ev := createdEvent("water", "Bo")
+	if _, err := d.Enqueue(ctx, ev); err != nil {
+		t.Fatal(err)
+	}
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		if sent, err := d.SendNext(ctx); !sent || err != nil {
+			t.Fatalf("attempt %d: %v, %v", attempt, sent, err)
+		}
+		del := store.Deliveries()[0]
+		if del.Status != models.DeliveryPending || del.AttemptCount != attempt || len(del.Attempts) != attempt {
+			t.Fatalf("after failure %d: %+v", attempt, del)
+		}
+		last := del.Attempts[attempt-1]
+		if last.StatusCode < 500 || last.Error == "" {
+			t.Fatalf("attempt log = %+v", last)
+		}
+		if wait := del.NextAttemptAt.Sub(del.UpdatedAt); wait < d.BaseBackoff*9/10 || wait > d.MaxBackoff*11/10 {
+			t.Fatalf("retry %d scheduled %v ahead", attempt, wait)
+		}
+		if _, ok, _ := store.Claim(ctx, del.NextAttemptAt.Add(-time.Microsecond), d.Lease); ok {
+			t.Fatalf("attempt %d due before its backoff", attempt)
+		}
+		time.Sleep(d.MaxBackoff + d.MaxBackoff/10)
+	}
+
+	if sent, err := d.SendNext(ctx); !sent || err != nil {
+		t.Fatalf("final attempt: %v, %v", sent, err)
+	}
+	del := store.Deliveries()[0]
+	if del.Status != models.DeliverySucceeded || del.DeliveredAt == nil || del.AttemptCount != 3 ||
+		del.Attempts[2].StatusCode != http.StatusNoContent || del.Attempts[2].Error != "" {
+		t.Fatalf("after success: %+v", del)
+	}
+	if len(rv.got) != 3 || rv.got[2].ID != ev.ID || rv.got[2].Report == nil || rv.got[2].Report.ID != ev.Report.ID {
+		t.Fatalf("received = %+v", rv.got)
+	}
+	if sent, _ := d.SendNext(ctx); sent {
+		t.Fatal("delivered event claimed again")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := &Dispatcher{BaseBackoff: 30 * time.Second, MaxBackoff: time.Hour}
+	for n, want := range map[int]time.Duration{1: 30 * time.Second, 2: time.Minute, 5: 8 * time.Minute, 30: time.Hour} {
+		for i := 0; i < 20; i++ {
+			if got := d.backoff(n); got < want-want/10 || got > want+want/10 {
+				t.Fatalf("backoff(%d) = %v, want %v ±10%%", n, got, want)
+			}
+		}
+	}
+}
+
+func TestFailedDeliveryReplay(t *testing.T) {
+	d, store, rv, sub := newTestDispatcher(t, 500, 500, 500)
+	ctx := context.Background()
+	if _, err := d.Enqueue(ctx, createdEvent("water", "Bo")); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < d.MaxAttempts; i++ {
+		time.Sleep(d.MaxBackoff + d.MaxBackoff/10)
+		if _, err := d.SendNext(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	del := store.Deliveries()[0]
+	if del.Status != models.DeliveryFailed || del.AttemptCount != d.MaxAttempts {
+		t.Fatalf("after %d failures: %+v", d.MaxAttempts, del)
+	}
+	time.Sleep(d.MaxBackoff)
+	if sent, _ := d.SendNext(ctx); sent {
+		t.Fatal("failed delivery retried without a replay")
+	}
+
+	other := primitive.NewObjectID()
+	if n, err := d.Replay(ctx, &other, nil); err != nil || n != 0 {
+		t.Fatalf("Replay(other subscription) = %d, %v", n, err)
+	}
+	if n, err := d.Replay(ctx, &sub.ID, []primitive.ObjectID{del.ID}); err != nil || n != 1 {
+		t.Fatalf("Replay = %d, %v", n, err)
+	}
+	if del := store.Deliveries()[0]; del.Status != models.DeliveryPending || del.AttemptCount != 0 {
+		t.Fatalf("replayed = %+v", del)
+	}
+	if sent, err := d.SendNext(ctx); !sent || err != nil {
+		t.Fatalf("replayed send: %v, %v", sent, err)
+	}
+	del = store.Deliveries()[0]
+	if del.Status != models.DeliverySucceeded || del.AttemptCount != 1 || len(del.Attempts) != 4 {
+		t.Fatalf("after replay: %+v", del)
+	}
+	if len(rv.got) != 4 {
+		t.Fatalf("receiver saw %d requests", len(rv.got))
+	}
+}
+
+func TestDeliveryLease(t *testing.T) {
+	d, store, rv, _ := newTestDispatcher(t)
+	ctx := context.Background()
+	if _, err := d.Enqueue(ctx, createdEvent("water", "Bo")); err != nil {
+		t.Fatal(err)
+	}
+	// a worker claims the delivery and dies
+	if _, ok, _ := store.Claim(ctx, time.Now(), time.Millisecond); !ok {
+		t.Fatal("nothing to claim")
+	}
+	if _, ok, _ := store.Claim(ctx, time.Now(), time.Minute); ok {
+		t.Fatal("leased delivery claimed twice")
+	}
+	time.Sleep(2 * time.Millisecond)
+	if sent, err := d.SendNext(ctx); !sent || err != nil {
+		t.Fatalf("expired lease not reclaimed: %v, %v", sent, err)
+	}
+	if del := store.Deliveries()[0]; del.Status != models.DeliverySucceeded || len(rv.got) != 1 {
+		t.Fatalf("delivery = %+v, received %d", del, len(rv.got))
+	}
+}
+
+func TestDeliveryToDeletedSubscriptionFails(t *testing.T) {
+	d, store, rv, sub := newTestDispatcher(t)
+	ctx := context.Background()
+	if _, err := d.Enqueue(ctx, createdEvent("water", "Bo")); err != nil {
+		t.Fatal(err)
+	}
+	store.DeleteSubscription(sub.ID)
+	if sent, err := d.SendNext(ctx); !sent || err != nil {
+		t.Fatalf("SendNext = %v, %v", sent, err)
+	}
+	del := store.Deliveries()[0]
+	if del.Status != models.DeliveryFailed || len(del.Attempts) != 1 || del.Attempts[0].Error != "subscription deleted" || len(rv.got) != 0 {
+		t.Fatalf("delivery = %+v", del)
+	}
+}
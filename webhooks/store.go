@@ -0,0 +1,292 @@
+// path: webhooks/store.go
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"meniba/database"
+	"meniba/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// errNotFound is returned by Store lookups that match nothing.
+var errNotFound = errors.New("webhooks: not found")
+
+// Store holds subscriptions and the delivery queue. The Dispatcher runs
+// against MongoDB in production and MemoryStore in tests.
+type Store interface {
+	// Subscriptions returns the active subscriptions to event; those with
+	// no Events list take every event.
+	Subscriptions(ctx context.Context, event string) ([]models.WebhookSubscription, error)
+	// GetSubscription returns errNotFound if id was deleted.
+	GetSubscription(ctx context.Context, id primitive.ObjectID) (models.WebhookSubscription, error)
+	InsertDeliveries(ctx context.Context, dels []models.WebhookDelivery) error
+	// Claim leases the pending delivery due soonest at now, or one whose
+	// lease has run out, marking it sending until now+lease. ok is false
+	// when nothing is due.
+	Claim(ctx context.Context, now time.Time, lease time.Duration) (del models.WebhookDelivery, ok bool, err error)
+	// Finish saves the outcome of an attempt: Status, AttemptCount,
+	// NextAttemptAt, DeliveredAt and UpdatedAt, and appends attempt to the
+	// log.
+	Finish(ctx context.Context, del models.WebhookDelivery, attempt models.DeliveryAttempt) error
+	// Requeue makes failed deliveries due at now with a fresh attempt
+	// budget; see Dispatcher.Replay.
+	Requeue(ctx context.Context, subID *primitive.ObjectID, ids []primitive.ObjectID, now time.Time) (int64, error)
+}
+
+// MongoStore keeps subscriptions in SubscriptionsCol and deliveries in
+// DeliveriesCol.
+type MongoStore struct {
+	DB *database.DB
+}
+
+// NewMongoStore returns a store over db.
+func NewMongoStore(db *database.DB) *MongoStore {
+	return &MongoStore{DB: db}
+}
+
+func (m *MongoStore) Subscriptions(ctx context.Context, event string) ([]models.WebhookSubscription, error) {
+	cur, err := m.DB.Col(SubscriptionsCol).Find(ctx, bson.M{
+		"active": true,
+		"$or": []bson.M{
+			{"events": bson.M{"$exists": false}},
+			{"events": bson.M{"$size": 0}},
+			{"events": event},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var subs []models.WebhookSubscription
+	err = cur.All(ctx, &subs)
+	return subs, err
+}
+
+func (m *MongoStore) GetSubscription(ctx context.Context, id primitive.ObjectID) (models.WebhookSubscription, error) {
+	var sub models.WebhookSubscription
+	err := m.DB.Col(SubscriptionsCol).FindOne(ctx, bson.M{"_id": id}).Decode(&sub)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return sub, errNotFound
+	}
+	return sub, err
+}
+
+func (m *MongoStore) InsertDeliveries(ctx context.Context, dels []models.WebhookDelivery) error {
+	docs := make([]any, len(dels))
+	for i := range dels {
+		docs[i] = dels[i]
+	}
+	_, err := m.DB.Col(DeliveriesCol).InsertMany(ctx, docs)
+	return err
+}
+
+func (m *MongoStore) Claim(ctx context.Context, now time.Time, lease time.Duration) (models.WebhookDelivery, bool, error) {
+	var del models.WebhookDelivery
+	err := m.DB.Col(DeliveriesCol).FindOneAndUpdate(ctx,
+		bson.M{"$or": []bson.M{
+			{"status": models.DeliveryPending, "next_attempt_at": bson.M{"$lte": now}},
+			// why: a worker that crashed mid-send leaves its lease behind
+			{"status": models.DeliverySending, "lease_until": bson.M{"$lt": now}},
+		}},
+		bson.M{"$set": bson.M{"status": models.DeliverySending, "lease_until": now.Add(lease), "updated_at": now}},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(&del)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return del, false, nil
+	}
+	return del, err == nil, err
+}
+
+func (m *MongoStore) Finish(ctx context.Context, del models.WebhookDelivery, attempt models.DeliveryAttempt) error {
+	set := bson.M{
+		"status":          del.Status,
+		"attempt_count":   del.AttemptCount,
+		"next_attempt_at": del.NextAttemptAt,
+		"updated_at":      del.UpdatedAt,
+	}
+	if del.DeliveredAt != nil {
+		set["delivered_at"] = del.DeliveredAt
+	}
+	_, err := m.DB.Col(DeliveriesCol).UpdateOne(ctx, bson.M{"_id": del.ID}, bson.M{
+		"$set":  set,
+		"$push": bson.M{"attempts": attempt},
+	})
+	return err
+}
+
+func (m *MongoStore) Requeue(ctx context.Context, subID *primitive.ObjectID, ids []primitive.ObjectID, now time.Time) (int64, error) {
+	filter := bson.M{"status": models.DeliveryFailed}
+	if subID != nil {
+		filter["subscription_id"] = *subID
+	}
+	if len(ids) > 0 {
+		filter["_id"] = bson.M{"$in": ids}
+	}
+	res, err := m.DB.Col(DeliveriesCol).UpdateMany(ctx, filter, bson.M{"$set": bson.M{
+		"status":          models.DeliveryPending,
+		"attempt_count":   0,
+		"next_attempt_at": now,
+		"updated_at":      now,
+	}})
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
+
+// MemoryStore is an in-process Store for tests and local demos.
+type MemoryStore struct {
+	mu         sync.Mutex
+	subs       []models.WebhookSubscription
+	deliveries []models.WebhookDelivery
+}
+
+// NewMemoryStore returns an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// AddSubscription stores sub, assigning an ID if it has none.
+func (m *MemoryStore) AddSubscription(sub models.WebhookSubscription) models.WebhookSubscription {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if sub.ID.IsZero() {
+		sub.ID = primitive.NewObjectID()
+	}
+	m.subs = append(m.subs, sub)
+	return sub
+}
+
+// DeleteSubscription removes id, leaving its deliveries queued.
+func (m *MemoryStore) DeleteSubscription(id primitive.ObjectID) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, s := range m.subs {
+		if s.ID == id {
+			m.subs = append(m.subs[:i], m.subs[i+1:]...)
+			return
+		}
+	}
+}
+
+// Deliveries returns a copy of the delivery queue in insertion order.
+func (m *MemoryStore) Deliveries() []models.WebhookDelivery {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]models.WebhookDelivery, len(m.deliveries))
+	for i, del := range m.deliveries {
+		del.Attempts = append([]models.DeliveryAttempt(nil), del.Attempts...)
+		out[i] = del
+	}
+	return out
+}
+
+func (m *MemoryStore) Subscriptions(_ context.Context, event string) ([]models.WebhookSubscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []models.WebhookSubscription
+	for _, s := range m.subs {
+		if s.Active && (len(s.Events) == 0 || inList(s.Events, event)) {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+func (m *MemoryStore) GetSubscription(_ context.Context, id primitive.ObjectID) (models.WebhookSubscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, s := range m.subs {
+		if s.ID == id {
+			return s, nil
+		}
+	}
+	return models.WebhookSubscription{}, errNotFound
+}
+
+func (m *MemoryStore) InsertDeliveries(_ context.Context, dels []models.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, del := range dels {
+		if del.ID.IsZero() {
+			del.ID = primitive.NewObjectID()
+		}
+		m.deliveries = append(m.deliveries, del)
+	}
+	return nil
+}
+
+func (m *MemoryStore) Claim(_ context.Context, now time.Time, lease time.Duration) (models.WebhookDelivery, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due []int
+	for i, del := range m.deliveries {
+		if (del.Status == models.DeliveryPending && !del.NextAttemptAt.After(now)) ||
+			(del.Status == models.DeliverySending && del.LeaseUntil.Before(now)) {
+			due = append(due, i)
+		}
+	}
+	if len(due) == 0 {
+		return models.WebhookDelivery{}, false, nil
+	}
+	sort.SliceStable(due, func(a, b int) bool {
+		return m.deliveries[due[a]].NextAttemptAt.Before(m.deliveries[due[b]].NextAttemptAt)
+	})
+	del := &m.deliveries[due[0]]
+	del.Status, del.LeaseUntil, del.UpdatedAt = models.DeliverySending, now.Add(lease), now
+	return *del, true, nil
+}
+
+func (m *MemoryStore) Finish(_ context.Context, del models.WebhookDelivery, attempt models.DeliveryAttempt) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.deliveries {
+		if m.deliveries[i].ID == del.ID {
+			cur := &m.deliveries[i]
+			cur.Status, cur.AttemptCount = del.Status, del.AttemptCount
+			cur.NextAttemptAt, cur.UpdatedAt = del.NextAttemptAt, del.UpdatedAt
+			if del.DeliveredAt != nil {
+				cur.DeliveredAt = del.DeliveredAt
+			}
+			cur.Attempts = append(cur.Attempts, attempt)
+			return nil
+		}
+	}
+	return errors.New("webhooks: no such delivery")
+}
+
+func (m *MemoryStore) Requeue(_ context.Context, subID *primitive.ObjectID, ids []primitive.ObjectID, now time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int64
+	for i := range m.deliveries {
+		del := &m.deliveries[i]
+		if del.Status != models.DeliveryFailed ||
+			(subID != nil && del.SubscriptionID != *subID) ||
+			(len(ids) > 0 && !containsID(ids, del.ID)) {
+			continue
+		}
+		del.Status, del.AttemptCount, del.NextAttemptAt, del.UpdatedAt = models.DeliveryPending, 0, now, now
+		n++
+	}
+	return n, nil
+}
+
+func containsID(list []primitive.ObjectID, v primitive.ObjectID) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}